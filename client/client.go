@@ -0,0 +1,312 @@
+// Package client is a Go client for the Ember API. Every endpoint has a typed method named after its
+// operationId in the OpenAPI document. The client keeps the access-refresh token pair, trades the
+// refresh token for a new access token when a call is rejected with 401, and retries idempotent calls
+// that fail with a network error or a 429, 502, 503 or 504 response
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAttempts = 3
+	defaultBackoff  = 200 * time.Millisecond
+	// Upper bound for a single wait between attempts, including one asked for with Retry-After
+	maxBackoff = 10 * time.Second
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	attempts   int
+	backoff    time.Duration
+	onTokens   func(Tokens)
+
+	mu     sync.Mutex
+	tokens Tokens
+
+	// Held while trading the refresh token, so concurrent 401s only refresh once
+	refreshMu sync.Mutex
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTokens starts the client with a token pair saved from an earlier login
+func WithTokens(tokens Tokens) Option {
+	return func(c *Client) {
+		c.tokens = tokens
+	}
+}
+
+// WithTokenHook calls fn every time the token pair changes: on login, on refresh and on logout (with
+// empty tokens). Use it to persist the tokens
+func WithTokenHook(fn func(Tokens)) Option {
+	return func(c *Client) {
+		c.onTokens = fn
+	}
+}
+
+// WithRetry sets how many times an idempotent call is attempted in total, and the wait before the first
+// retry, which doubles on every further retry. An attempts of 1 disables retries
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.attempts = max(attempts, 1)
+		c.backoff = backoff
+	}
+}
+
+// New creates a client for the API served at baseURL, e.g. https://ember.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		attempts:   defaultAttempts,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tokens returns the current token pair
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// SetTokens replaces the token pair
+func (c *Client) SetTokens(tokens Tokens) {
+	c.mu.Lock()
+	c.tokens = tokens
+	c.mu.Unlock()
+
+	if c.onTokens != nil {
+		c.onTokens(tokens)
+	}
+}
+
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+}
+
+func newRequest(method string, path string, in any) (request, error) {
+	req := request{method: method, path: path}
+	if in == nil {
+		return req, nil
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return request{}, fmt.Errorf("ember: cannot encode request: %w", err)
+	}
+	req.body = body
+	req.contentType = "application/json"
+	return req, nil
+}
+
+// Everything under /v1 needs an access token, except for the auth endpoints that hand them out
+func (r request) authenticated() bool {
+	return strings.HasPrefix(r.path, "/v1/") && !strings.HasPrefix(r.path, "/v1/auth/")
+}
+
+// Calls that can safely be sent twice
+func (r request) idempotent() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Send a JSON request and decode the JSON response into out, which may be nil
+func (c *Client) call(ctx context.Context, method string, path string, in any, out any) error {
+	req, err := newRequest(method, path, in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, req, out)
+}
+
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ember: cannot decode response: %w", err)
+	}
+	return nil
+}
+
+// Send a request, refreshing the access token once if it is rejected. Error responses are returned
+// as *Error, otherwise the caller must close the response body
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	access := c.Tokens().Access
+	resp, err := c.sendWithRetry(ctx, req, access)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && req.authenticated() && c.Tokens().Refresh != "" {
+		discard(resp)
+		if err := c.refresh(ctx, access); err != nil {
+			return nil, err
+		}
+
+		resp, err = c.sendWithRetry(ctx, req, c.Tokens().Access)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+func (c *Client) sendWithRetry(ctx context.Context, req request, access string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, bytes.NewReader(req.body))
+		if err != nil {
+			return nil, fmt.Errorf("ember: %w", err)
+		}
+		if len(req.query) > 0 {
+			httpReq.URL.RawQuery = req.query.Encode()
+		}
+		if req.contentType != "" {
+			httpReq.Header.Set("Content-Type", req.contentType)
+		}
+		httpReq.Header.Set("Accept", "application/json")
+		if req.authenticated() && access != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+access)
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if attempt >= c.attempts || !req.idempotent() || ctx.Err() != nil || !retryable(resp, err) {
+			if err != nil {
+				return nil, fmt.Errorf("ember: %w", err)
+			}
+			return resp, nil
+		}
+
+		wait := c.wait(attempt, resp)
+		if resp != nil {
+			discard(resp)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("ember: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Exponential backoff with jitter, unless the server said how long to wait
+func (c *Client) wait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+	}
+
+	wait := min(c.backoff<<(attempt-1), maxBackoff)
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// Trade the refresh token for a new access token, unless another call already replaced the stale one
+func (c *Client) refresh(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	tokens := c.Tokens()
+	if tokens.Access != stale {
+		return nil
+	}
+
+	var resp struct {
+		Access string `json:"access"`
+	}
+	req := map[string]string{"refresh_token": tokens.Refresh}
+	if err := c.call(ctx, http.MethodPost, "/v1/auth/refresh", req, &resp); err != nil {
+		return err
+	}
+
+	c.SetTokens(Tokens{Access: resp.Access, Refresh: tokens.Refresh})
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Code == "" {
+		// Not a problem document, e.g. from a proxy in front of the API
+		apiErr = &Error{Title: http.StatusText(resp.StatusCode), Detail: strings.TrimSpace(string(body))}
+	}
+	apiErr.Status = resp.StatusCode
+	return apiErr
+}
+
+// Read what is left of a response so the connection can be reused
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+}
+
+// IsUnavailable reports whether err means the API could not be reached or is overloaded, as opposed to
+// a rejected request
+func IsUnavailable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= http.StatusInternalServerError
+	}
+	return err != nil
+}
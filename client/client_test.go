@@ -0,0 +1,257 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/client"
+	"github.com/PlatosRepublic7/ember/client/clienttest"
+	"github.com/PlatosRepublic7/ember/internal/openapi"
+)
+
+// Every operation in the OpenAPI document has a client method named after its operationId
+func TestEveryOperationHasAMethod(t *testing.T) {
+	clientType := reflect.TypeOf(&client.Client{})
+
+	for path, item := range openapi.Build().Paths {
+		for method, op := range item {
+			name := strings.ToUpper(op.OperationID[:1]) + op.OperationID[1:]
+			if _, ok := clientType.MethodByName(name); !ok {
+				t.Errorf("%s %s (%s) has no client method %s", strings.ToUpper(method), path, op.OperationID, name)
+			}
+		}
+	}
+}
+
+// The client types carry the same JSON fields as the schemas the server describes
+func TestTypesMatchSchemas(t *testing.T) {
+	schemas := openapi.Build().Components.Schemas
+
+	types := map[string]any{
+		"User":                          client.User{},
+		"TokenPair":                     client.Tokens{},
+		"Message":                       client.Message{},
+		"MessageSearchResult":           client.MessageSearchResult{},
+		"Conversation":                  client.Conversation{},
+		"Contact":                       client.Contact{},
+		"ContactRequests":               client.ContactRequests{},
+		"BlockedUser":                   client.BlockedUser{},
+		"PrivacySettings":               client.PrivacySettings{},
+		"PublicProfile":                 client.PublicProfile{},
+		"Profile":                       client.Profile{},
+		"Presence":                      client.Presence{},
+		"Webhook":                       client.Webhook{},
+		"WebhookDelivery":               client.WebhookDelivery{},
+		"Report":                        client.HealthReport{},
+		"Result":                        client.HealthResult{},
+		"Problem":                       client.Error{},
+		"FieldError":                    client.FieldError{},
+		"RegisterRequest":               client.RegisterRequest{},
+		"SendMessageRequest":            client.SendMessageRequest{},
+		"UpdateScheduledMessageRequest": client.UpdateScheduledMessageRequest{},
+		"PrivacySettingsRequest":        client.PrivacySettingsRequest{},
+		"UpdateProfileRequest":          client.UpdateProfileRequest{},
+		"CreateWebhookRequest":          client.CreateWebhookRequest{},
+		"UpdateWebhookRequest":          client.UpdateWebhookRequest{},
+	}
+
+	for name, v := range types {
+		schema, ok := schemas[name]
+		if !ok {
+			t.Errorf("no %s schema in the OpenAPI document", name)
+			continue
+		}
+
+		var want []string
+		for property := range schema.Properties {
+			want = append(want, property)
+		}
+
+		var got []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			jsonName, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			got = append(got, jsonName)
+		}
+
+		slices.Sort(want)
+		slices.Sort(got)
+		// The problem's instance is always empty for the API, the client does not keep it
+		want = slices.DeleteFunc(want, func(s string) bool { return name == "Problem" && s == "instance" })
+		if !slices.Equal(got, want) {
+			t.Errorf("%T has fields %v, the %s schema has %v", v, got, name, want)
+		}
+	}
+}
+
+func TestRefreshesRejectedAccessToken(t *testing.T) {
+	srv := clienttest.NewServer(t)
+	srv.JSON("GET /v1/me", http.StatusOK, client.Profile{Username: "alice"})
+
+	var saved []client.Tokens
+	c := srv.Client(client.WithTokenHook(func(tokens client.Tokens) { saved = append(saved, tokens) }))
+
+	srv.ExpireAccessToken()
+	profile, err := c.GetProfile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Username != "alice" {
+		t.Errorf("unexpected profile %+v", profile)
+	}
+
+	if len(saved) != 1 || saved[0] != srv.Tokens() {
+		t.Errorf("expected the refreshed tokens to be saved, got %v", saved)
+	}
+
+	var paths []string
+	for _, req := range srv.Requests() {
+		paths = append(paths, req.Method+" "+req.Path)
+	}
+	if want := []string{"GET /v1/me", "POST /v1/auth/refresh", "GET /v1/me"}; !slices.Equal(paths, want) {
+		t.Errorf("expected requests %v, got %v", want, paths)
+	}
+}
+
+func TestRejectedRefreshTokenIsReturned(t *testing.T) {
+	srv := clienttest.NewServer(t)
+	c := srv.Client(client.WithTokens(client.Tokens{Access: "stale", Refresh: "revoked"}))
+
+	_, err := c.GetConversations(context.Background())
+	if !client.IsCode(err, client.CodeInvalidToken) {
+		t.Fatalf("expected an invalid token error, got %v", err)
+	}
+}
+
+func TestRetriesIdempotentCalls(t *testing.T) {
+	srv := clienttest.NewServer(t)
+
+	var calls atomic.Int32
+	srv.Handle("GET /v1/conversations", func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			clienttest.WriteProblem(w, http.StatusServiceUnavailable, client.CodeUnavailable, "try again")
+			return
+		}
+		clienttest.WriteJSON(w, http.StatusOK, []client.Conversation{{CounterpartUsername: "bob"}})
+	})
+	srv.Problem("POST /v1/messages", http.StatusServiceUnavailable, client.CodeUnavailable, "try again")
+
+	conversations, err := srv.Client().GetConversations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 || len(conversations) != 1 {
+		t.Errorf("expected success on the third attempt, got %d attempts", calls.Load())
+	}
+
+	// Sending a message twice would deliver it twice
+	_, err = srv.Client().SendMessage(context.Background(), client.SendMessageRequest{Username: "bob", Content: "hi"})
+	if !client.IsCode(err, client.CodeUnavailable) {
+		t.Fatalf("expected the 503 to be returned, got %v", err)
+	}
+	posts := 0
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodPost {
+			posts++
+		}
+	}
+	if posts != 1 {
+		t.Errorf("expected a single POST, got %d", posts)
+	}
+}
+
+func TestContextStopsRetries(t *testing.T) {
+	srv := clienttest.NewServer(t)
+	srv.Problem("GET /v1/contacts", http.StatusServiceUnavailable, client.CodeUnavailable, "down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := srv.Client(client.WithRetry(5, time.Minute)).GetContacts(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to end the call, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the call kept waiting after the deadline")
+	}
+}
+
+func TestErrorsAreDecoded(t *testing.T) {
+	srv := clienttest.NewServer(t)
+	srv.Handle("POST /v1/auth/register", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(client.Error{
+			Status: http.StatusUnprocessableEntity,
+			Code:   client.CodeValidationFailed,
+			Detail: "One or more fields are invalid",
+			Errors: []client.FieldError{{Field: "email", Message: "must be a valid email address"}},
+		})
+	})
+
+	_, err := srv.Client().Register(context.Background(), client.RegisterRequest{Username: "alice"})
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected a *client.Error, got %v", err)
+	}
+	if apiErr.Status != http.StatusUnprocessableEntity || len(apiErr.Errors) != 1 || apiErr.Errors[0].Field != "email" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	if !strings.Contains(err.Error(), "email must be a valid email address") {
+		t.Errorf("field errors missing from %q", err.Error())
+	}
+}
+
+func TestLoginKeepsTokens(t *testing.T) {
+	srv := clienttest.NewServer(t)
+	c := client.New(srv.URL)
+
+	tokens, err := c.Login(context.Background(), "alice@example.com", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens != srv.Tokens() || c.Tokens() != tokens {
+		t.Errorf("expected the client to keep %v, got %v", srv.Tokens(), c.Tokens())
+	}
+
+	if err := c.Logout(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.Tokens() != (client.Tokens{}) {
+		t.Errorf("expected logout to forget the tokens")
+	}
+
+	var logout struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	requests := srv.Requests()
+	if err := requests[len(requests)-1].Decode(&logout); err != nil || logout.RefreshToken != tokens.Refresh {
+		t.Errorf("expected logout to send the refresh token, got %+v", logout)
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	srv := clienttest.NewServer(t)
+	srv.JSON("GET /v1/messages/search", http.StatusOK, []client.MessageSearchResult{})
+
+	from := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err := srv.Client().SearchMessages(context.Background(), client.SearchMessagesParams{Query: "hello world", From: from, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := srv.Requests()[0].Query
+	if query.Get("q") != "hello world" || query.Get("from") != "2026-01-02T03:04:05Z" || query.Get("limit") != "5" || query.Has("offset") {
+		t.Errorf("unexpected query %v", query)
+	}
+}
@@ -0,0 +1,215 @@
+// Package clienttest provides a mock Ember API for testing code that uses the client package.
+//
+// The server handles the auth endpoints itself and checks the access token on everything under /v1.
+// Every other endpoint answers 404 until a handler is registered for it:
+//
+//	srv := clienttest.NewServer(t)
+//	srv.JSON("GET /v1/conversations", http.StatusOK, []client.Conversation{...})
+//	c := srv.Client()
+package clienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/client"
+)
+
+// Request is a request the server received
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Decode unmarshals the JSON body of the request into v
+func (r Request) Decode(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]http.HandlerFunc
+	mux      *http.ServeMux
+	requests []Request
+	tokens   client.Tokens
+	issued   int
+}
+
+// NewServer starts a mock server that is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	s := &Server{handlers: make(map[string]http.HandlerFunc)}
+	s.issueTokens()
+
+	s.handlers["POST /v1/auth/login"] = s.handleLogin
+	s.handlers["POST /v1/auth/refresh"] = s.handleRefresh
+	s.handlers["POST /v1/auth/logout"] = s.handleLogout
+	s.rebuild()
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Client returns a client for the server, already holding a valid token pair
+func (s *Server) Client(opts ...client.Option) *client.Client {
+	opts = append([]client.Option{client.WithTokens(s.Tokens()), client.WithRetry(3, 0)}, opts...)
+	return client.New(s.URL, opts...)
+}
+
+// Handle registers h for pattern, in the form used by http.ServeMux such as "GET /v1/users/{username}".
+// Registering a pattern again replaces its handler, including the built in auth handlers
+func (s *Server) Handle(pattern string, h http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[pattern] = h
+	s.rebuild()
+}
+
+// JSON registers a handler that answers pattern with status and body encoded as JSON
+func (s *Server) JSON(pattern string, status int, body any) {
+	s.Handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, status, body)
+	})
+}
+
+// Problem registers a handler that answers pattern with an error response
+func (s *Server) Problem(pattern string, status int, code string, detail string) {
+	s.Handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+		WriteProblem(w, status, code, detail)
+	})
+}
+
+// Requests returns every request received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Tokens returns the token pair the server currently accepts
+func (s *Server) Tokens() client.Tokens {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens
+}
+
+// ExpireAccessToken makes the server reject the current access token, so the next call has to refresh
+func (s *Server) ExpireAccessToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.issued++
+	s.tokens.Access = fmt.Sprintf("access-%d", s.issued)
+}
+
+// WriteJSON writes body as a JSON response
+func WriteJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// WriteProblem writes a problem details response like the API's
+func WriteProblem(w http.ResponseWriter, status int, code string, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(client.Error{
+		Status: status,
+		Type:   "urn:ember:error:" + code,
+		Title:  http.StatusText(status),
+		Detail: detail,
+		Code:   code,
+	})
+}
+
+func (s *Server) issueTokens() {
+	s.issued++
+	s.tokens = client.Tokens{
+		Access:  fmt.Sprintf("access-%d", s.issued),
+		Refresh: fmt.Sprintf("refresh-%d", s.issued),
+	}
+}
+
+// Must be called with mu held
+func (s *Server) rebuild() {
+	s.mux = http.NewServeMux()
+	for pattern, h := range s.handlers {
+		s.mux.HandleFunc(pattern, h)
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	mux, access := s.mux, s.tokens.Access
+	s.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/v1/") && !strings.HasPrefix(r.URL.Path, "/v1/auth/") {
+		if r.Header.Get("Authorization") != "Bearer "+access {
+			WriteProblem(w, http.StatusUnauthorized, client.CodeInvalidToken, "Invalid or expired token")
+			return
+		}
+	}
+
+	if _, pattern := mux.Handler(r); pattern == "" {
+		WriteProblem(w, http.StatusNotFound, client.CodeNotFound, "No mock registered for "+r.Method+" "+r.URL.Path)
+		return
+	}
+	mux.ServeHTTP(w, r)
+}
+
+// Any email and password log in
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.issueTokens()
+	tokens := s.tokens
+	s.mu.Unlock()
+
+	WriteJSON(w, http.StatusCreated, tokens)
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.RefreshToken != s.tokens.Refresh {
+		WriteProblem(w, http.StatusUnauthorized, client.CodeInvalidToken, "Refresh token is invalid")
+		return
+	}
+
+	s.issued++
+	s.tokens.Access = fmt.Sprintf("access-%d", s.issued)
+	WriteJSON(w, http.StatusCreated, map[string]string{"access": s.tokens.Access})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]string{"Success": "Logout complete"})
+}
@@ -0,0 +1,430 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Livez calls the liveness probe
+func (c *Client) Livez(ctx context.Context) error {
+	return c.call(ctx, http.MethodGet, "/livez", nil, nil)
+}
+
+// Readyz calls the readiness probe. A failing check is returned as an *Error with status 503
+func (c *Client) Readyz(ctx context.Context) (*HealthReport, error) {
+	var report HealthReport
+	if err := c.call(ctx, http.MethodGet, "/readyz", nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// HealthCheck calls the legacy health check
+func (c *Client) HealthCheck(ctx context.Context) error {
+	return c.call(ctx, http.MethodGet, "/healthc", nil, nil)
+}
+
+// GetOpenAPI fetches the OpenAPI document
+func (c *Client) GetOpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	if err := c.call(ctx, http.MethodGet, "/openapi.json", nil, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Register creates a new user. It does not log in
+func (c *Client) Register(ctx context.Context, req RegisterRequest) (*User, error) {
+	var user User
+	if err := c.call(ctx, http.MethodPost, "/v1/auth/register", req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login logs in and keeps the token pair for later calls. Every refresh token issued to the user
+// before is invalidated
+func (c *Client) Login(ctx context.Context, email string, password string) (Tokens, error) {
+	req := map[string]string{"email": email, "password": password}
+
+	var tokens Tokens
+	if err := c.call(ctx, http.MethodPost, "/v1/auth/login", req, &tokens); err != nil {
+		return Tokens{}, err
+	}
+
+	c.SetTokens(tokens)
+	return tokens, nil
+}
+
+// Logout invalidates the refresh token and forgets the token pair
+func (c *Client) Logout(ctx context.Context) error {
+	req := map[string]string{"refresh_token": c.Tokens().Refresh}
+	if err := c.call(ctx, http.MethodPost, "/v1/auth/logout", req, nil); err != nil {
+		return err
+	}
+
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+// Refresh trades the refresh token for a new access token. Calls do this on their own when the access
+// token is rejected, so this is only needed to refresh ahead of time
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, c.Tokens().Access)
+}
+
+// AuthTest returns the claims of the access token
+func (c *Client) AuthTest(ctx context.Context) (map[string]any, error) {
+	var resp struct {
+		User map[string]any `json:"user"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/v1/test", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, username string) (*PublicProfile, error) {
+	var profile PublicProfile
+	if err := c.call(ctx, http.MethodGet, "/v1/users/"+url.PathEscape(username), nil, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (c *Client) SendMessage(ctx context.Context, req SendMessageRequest) (*Message, error) {
+	var message Message
+	if err := c.call(ctx, http.MethodPost, "/v1/messages", req, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetMessages lists the messages sent and received by the user. Listing does not mark them as read
+func (c *Client) GetMessages(ctx context.Context, params GetMessagesParams) ([]Message, error) {
+	req := request{method: http.MethodGet, path: "/v1/messages", query: url.Values{}}
+	setQuery(req.query, "type", params.Type)
+	setQuery(req.query, "username", params.Username)
+
+	var messages []Message
+	if err := c.do(ctx, req, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (c *Client) SearchMessages(ctx context.Context, params SearchMessagesParams) ([]MessageSearchResult, error) {
+	req := request{method: http.MethodGet, path: "/v1/messages/search", query: url.Values{}}
+	req.query.Set("q", params.Query)
+	setQuery(req.query, "username", params.Username)
+	if !params.From.IsZero() {
+		req.query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		req.query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Limit > 0 {
+		req.query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset > 0 {
+		req.query.Set("offset", strconv.Itoa(params.Offset))
+	}
+
+	var results []MessageSearchResult
+	if err := c.do(ctx, req, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (c *Client) GetScheduledMessages(ctx context.Context) ([]Message, error) {
+	var messages []Message
+	if err := c.call(ctx, http.MethodGet, "/v1/messages/scheduled", nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (c *Client) UpdateScheduledMessage(ctx context.Context, id uuid.UUID, req UpdateScheduledMessageRequest) (*Message, error) {
+	var message Message
+	if err := c.call(ctx, http.MethodPatch, "/v1/messages/scheduled/"+id.String(), req, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (c *Client) CancelScheduledMessage(ctx context.Context, id uuid.UUID) error {
+	return c.call(ctx, http.MethodDelete, "/v1/messages/scheduled/"+id.String(), nil, nil)
+}
+
+func (c *Client) GetConversations(ctx context.Context) ([]Conversation, error) {
+	var conversations []Conversation
+	if err := c.call(ctx, http.MethodGet, "/v1/conversations", nil, &conversations); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// MarkConversationRead marks the messages received from username as read, only those received up to
+// upTo unless it is zero. It returns how many messages were marked
+func (c *Client) MarkConversationRead(ctx context.Context, username string, upTo time.Time) (int, error) {
+	var in any
+	if !upTo.IsZero() {
+		in = map[string]time.Time{"up_to": upTo}
+	}
+
+	var resp struct {
+		MarkedRead int `json:"marked_read"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/v1/conversations/"+url.PathEscape(username)+"/read", in, &resp); err != nil {
+		return 0, err
+	}
+	return resp.MarkedRead, nil
+}
+
+func (c *Client) GetContacts(ctx context.Context) ([]Contact, error) {
+	var contacts []Contact
+	if err := c.call(ctx, http.MethodGet, "/v1/contacts", nil, &contacts); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+func (c *Client) DeleteContact(ctx context.Context, username string) error {
+	return c.call(ctx, http.MethodDelete, "/v1/contacts/"+url.PathEscape(username), nil, nil)
+}
+
+func (c *Client) GetContactRequests(ctx context.Context) (*ContactRequests, error) {
+	var requests ContactRequests
+	if err := c.call(ctx, http.MethodGet, "/v1/contacts/requests", nil, &requests); err != nil {
+		return nil, err
+	}
+	return &requests, nil
+}
+
+// CreateContactRequest asks username to become a contact. A pending request from them is accepted instead
+func (c *Client) CreateContactRequest(ctx context.Context, username string) error {
+	return c.call(ctx, http.MethodPost, "/v1/contacts/requests", map[string]string{"username": username}, nil)
+}
+
+func (c *Client) AcceptContactRequest(ctx context.Context, username string) error {
+	return c.call(ctx, http.MethodPost, "/v1/contacts/requests/"+url.PathEscape(username)+"/accept", nil, nil)
+}
+
+func (c *Client) DeclineContactRequest(ctx context.Context, username string) error {
+	return c.call(ctx, http.MethodPost, "/v1/contacts/requests/"+url.PathEscape(username)+"/decline", nil, nil)
+}
+
+func (c *Client) GetBlockedUsers(ctx context.Context) ([]BlockedUser, error) {
+	var blocked []BlockedUser
+	if err := c.call(ctx, http.MethodGet, "/v1/blocks", nil, &blocked); err != nil {
+		return nil, err
+	}
+	return blocked, nil
+}
+
+func (c *Client) BlockUser(ctx context.Context, username string) error {
+	return c.call(ctx, http.MethodPost, "/v1/blocks", map[string]string{"username": username}, nil)
+}
+
+func (c *Client) UnblockUser(ctx context.Context, username string) error {
+	return c.call(ctx, http.MethodDelete, "/v1/blocks/"+url.PathEscape(username), nil, nil)
+}
+
+func (c *Client) UpdatePrivacySettings(ctx context.Context, req PrivacySettingsRequest) (*PrivacySettings, error) {
+	var settings PrivacySettings
+	if err := c.call(ctx, http.MethodPut, "/v1/settings/privacy", req, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (c *Client) GetProfile(ctx context.Context) (*Profile, error) {
+	var profile Profile
+	if err := c.call(ctx, http.MethodGet, "/v1/me", nil, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (c *Client) UpdateProfile(ctx context.Context, req UpdateProfileRequest) (*Profile, error) {
+	var profile Profile
+	if err := c.call(ctx, http.MethodPatch, "/v1/me", req, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// UploadAvatar replaces the avatar with the image read from avatar. The image is buffered, so the
+// upload can be retried
+func (c *Client) UploadAvatar(ctx context.Context, filename string, avatar io.Reader) (*Profile, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("avatar", filename)
+	if err != nil {
+		return nil, fmt.Errorf("ember: %w", err)
+	}
+	if _, err := io.Copy(part, avatar); err != nil {
+		return nil, fmt.Errorf("ember: cannot read avatar: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("ember: %w", err)
+	}
+
+	req := request{
+		method:      http.MethodPut,
+		path:        "/v1/me/avatar",
+		body:        body.Bytes(),
+		contentType: form.FormDataContentType(),
+	}
+
+	var profile Profile
+	if err := c.do(ctx, req, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (c *Client) DeleteAvatar(ctx context.Context) (*Profile, error) {
+	var profile Profile
+	if err := c.call(ctx, http.MethodDelete, "/v1/me/avatar", nil, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetAvatar downloads the avatar of username, along with its content type
+func (c *Client) GetAvatar(ctx context.Context, username string) ([]byte, string, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/v1/users/" + url.PathEscape(username) + "/avatar"})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	avatar, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("ember: cannot read avatar: %w", err)
+	}
+	return avatar, resp.Header.Get("Content-Type"), nil
+}
+
+func (c *Client) GetContactsPresence(ctx context.Context) ([]Presence, error) {
+	var presences []Presence
+	if err := c.call(ctx, http.MethodGet, "/v1/contacts/presence", nil, &presences); err != nil {
+		return nil, err
+	}
+	return presences, nil
+}
+
+// SetTyping signals that the user is typing to username. Signals expire after a few seconds, so resend
+// it while the user keeps typing
+func (c *Client) SetTyping(ctx context.Context, username string) error {
+	return c.call(ctx, http.MethodPost, "/v1/conversations/"+url.PathEscape(username)+"/typing", nil, nil)
+}
+
+// GetTyping reports whether username is typing to the user
+func (c *Client) GetTyping(ctx context.Context, username string) (bool, error) {
+	var resp struct {
+		Typing bool `json:"typing"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/v1/conversations/"+url.PathEscape(username)+"/typing", nil, &resp); err != nil {
+		return false, err
+	}
+	return resp.Typing, nil
+}
+
+func (c *Client) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	return c.getWebhooks(ctx, "/v1/webhooks")
+}
+
+// CreateWebhook registers a webhook. The signing secret is only ever returned here
+func (c *Client) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	return c.createWebhook(ctx, "/v1/webhooks", req)
+}
+
+func (c *Client) UpdateWebhook(ctx context.Context, id uuid.UUID, req UpdateWebhookRequest) (*Webhook, error) {
+	return c.updateWebhook(ctx, "/v1/webhooks/"+id.String(), req)
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return c.call(ctx, http.MethodDelete, "/v1/webhooks/"+id.String(), nil, nil)
+}
+
+// GetWebhookDeliveries lists the most recent deliveries, limit defaults to 50 when zero
+func (c *Client) GetWebhookDeliveries(ctx context.Context, id uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	return c.getWebhookDeliveries(ctx, "/v1/webhooks/"+id.String()+"/deliveries", limit)
+}
+
+// AdminGetWebhooks lists the global webhooks. The Admin methods need an admin user
+func (c *Client) AdminGetWebhooks(ctx context.Context) ([]Webhook, error) {
+	return c.getWebhooks(ctx, "/v1/admin/webhooks")
+}
+
+func (c *Client) AdminCreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	return c.createWebhook(ctx, "/v1/admin/webhooks", req)
+}
+
+func (c *Client) AdminUpdateWebhook(ctx context.Context, id uuid.UUID, req UpdateWebhookRequest) (*Webhook, error) {
+	return c.updateWebhook(ctx, "/v1/admin/webhooks/"+id.String(), req)
+}
+
+func (c *Client) AdminDeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return c.call(ctx, http.MethodDelete, "/v1/admin/webhooks/"+id.String(), nil, nil)
+}
+
+func (c *Client) AdminGetWebhookDeliveries(ctx context.Context, id uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	return c.getWebhookDeliveries(ctx, "/v1/admin/webhooks/"+id.String()+"/deliveries", limit)
+}
+
+func (c *Client) getWebhooks(ctx context.Context, path string) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := c.call(ctx, http.MethodGet, path, nil, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (c *Client) createWebhook(ctx context.Context, path string, req CreateWebhookRequest) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.call(ctx, http.MethodPost, path, req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) updateWebhook(ctx context.Context, path string, req UpdateWebhookRequest) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.call(ctx, http.MethodPatch, path, req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) getWebhookDeliveries(ctx context.Context, path string, limit int) ([]WebhookDelivery, error) {
+	req := request{method: http.MethodGet, path: path, query: url.Values{}}
+	if limit > 0 {
+		req.query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var deliveries []WebhookDelivery
+	if err := c.do(ctx, req, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func setQuery(query url.Values, key string, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error is an error response from the API, decoded from its problem details
+type Error struct {
+	Status    int          `json:"status"`
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Detail    string       `json:"detail"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id"`
+	Errors    []FieldError `json:"errors"`
+}
+
+// FieldError names a payload field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	message := e.Detail
+	if message == "" {
+		message = e.Title
+	}
+
+	var fields []string
+	for _, field := range e.Errors {
+		fields = append(fields, field.Field+" "+field.Message)
+	}
+	if len(fields) > 0 {
+		message += " (" + strings.Join(fields, "; ") + ")"
+	}
+
+	return fmt.Sprintf("ember: %d %s: %s", e.Status, e.Code, message)
+}
+
+// Error codes the API answers with, see the Problem schema of the OpenAPI document
+const (
+	CodeMalformedRequest     = "malformed_request"
+	CodeInvalidInput         = "invalid_input"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeInvalidToken         = "invalid_token"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInternal             = "internal"
+	CodeUnavailable          = "unavailable"
+)
+
+// IsCode reports whether err is an API error with the given code
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The types below are the client side of the API's JSON payloads. Their field names match the schemas
+// in the OpenAPI document, which the tests check
+
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+}
+
+// Tokens is an access-refresh token pair. The access token is short lived, the refresh token is used
+// to get a new one
+type Tokens struct {
+	Access  string `json:"access"`
+	Refresh string `json:"refresh"`
+}
+
+type Message struct {
+	ID          uuid.UUID     `json:"id"`
+	SenderID    uuid.UUID     `json:"sender_id"`
+	RecipientID uuid.UUID     `json:"recipient_id"`
+	Content     string        `json:"content"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ReadAt      sql.NullTime  `json:"read_at"`
+	TtlSeconds  sql.NullInt32 `json:"ttl_seconds"`
+	ExpiresAt   sql.NullTime  `json:"expires_at"`
+	Deleted     bool          `json:"deleted"`
+	DeliverAt   sql.NullTime  `json:"deliver_at"`
+	DeliveredAt sql.NullTime  `json:"delivered_at"`
+}
+
+type MessageSearchResult struct {
+	Message Message `json:"message"`
+	Snippet string  `json:"snippet"`
+}
+
+type Conversation struct {
+	CounterpartID       uuid.UUID `json:"counterpart_id"`
+	CounterpartUsername string    `json:"counterpart_username"`
+	LastMessageID       uuid.UUID `json:"last_message_id"`
+	LastMessageSenderID uuid.UUID `json:"last_message_sender_id"`
+	LastMessagePreview  string    `json:"last_message_preview"`
+	LastActivityAt      time.Time `json:"last_activity_at"`
+	UnreadCount         int64     `json:"unread_count"`
+}
+
+type Contact struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ContactRequests struct {
+	Incoming []Contact `json:"incoming"`
+	Outgoing []Contact `json:"outgoing"`
+}
+
+type BlockedUser struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PrivacySettings struct {
+	ContactsOnly bool `json:"contacts_only"`
+	HideLastSeen bool `json:"hide_last_seen"`
+}
+
+type PublicProfile struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	StatusText  string    `json:"status_text"`
+	Timezone    string    `json:"timezone"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Profile struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	DisplayName  string    `json:"display_name"`
+	Bio          string    `json:"bio"`
+	AvatarURL    string    `json:"avatar_url"`
+	StatusText   string    `json:"status_text"`
+	Timezone     string    `json:"timezone"`
+	ContactsOnly bool      `json:"contacts_only"`
+	HideLastSeen bool      `json:"hide_last_seen"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type Presence struct {
+	UserID   uuid.UUID    `json:"user_id"`
+	Username string       `json:"username"`
+	Status   string       `json:"status"`
+	LastSeen sql.NullTime `json:"last_seen"`
+}
+
+// Secret is only set on the webhook returned by CreateWebhook
+type Webhook struct {
+	ID                  uuid.UUID    `json:"id"`
+	URL                 string       `json:"url"`
+	Events              []string     `json:"events"`
+	Enabled             bool         `json:"enabled"`
+	ConsecutiveFailures int32        `json:"consecutive_failures"`
+	DisabledAt          sql.NullTime `json:"disabled_at"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+	Secret              string       `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             int64          `json:"id"`
+	EventID        int64          `json:"event_id"`
+	EventType      string         `json:"event_type"`
+	Status         string         `json:"status"`
+	Attempts       int32          `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt32  `json:"last_status_code"`
+	LastError      sql.NullString `json:"last_error"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]HealthResult `json:"checks"`
+}
+
+type HealthResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// DeliverAt schedules the message, TtlSeconds makes it expire after it has been read
+type SendMessageRequest struct {
+	Username   string     `json:"username"`
+	Content    string     `json:"content"`
+	TtlSeconds *int32     `json:"ttl_seconds,omitempty"`
+	DeliverAt  *time.Time `json:"deliver_at,omitempty"`
+}
+
+// Nil fields are left unchanged
+type UpdateScheduledMessageRequest struct {
+	Content    *string    `json:"content,omitempty"`
+	TtlSeconds *int32     `json:"ttl_seconds,omitempty"`
+	DeliverAt  *time.Time `json:"deliver_at,omitempty"`
+}
+
+type PrivacySettingsRequest struct {
+	ContactsOnly *bool `json:"contacts_only,omitempty"`
+	HideLastSeen *bool `json:"hide_last_seen,omitempty"`
+}
+
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	StatusText  *string `json:"status_text,omitempty"`
+	Timezone    *string `json:"timezone,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type UpdateWebhookRequest struct {
+	URL     *string   `json:"url,omitempty"`
+	Events  *[]string `json:"events,omitempty"`
+	Enabled *bool     `json:"enabled,omitempty"`
+}
+
+// Empty fields are left out of the query
+type GetMessagesParams struct {
+	// "sent" or "received"
+	Type     string
+	Username string
+}
+
+type SearchMessagesParams struct {
+	Query    string
+	Username string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/client"
+	"github.com/PlatosRepublic7/ember/internal/tui"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+func runRegister(ctx context.Context, c *cli, args []string) error {
+	flags := c.flagSet("register")
+	username := flags.String("username", "", "username to register")
+	email := flags.String("email", "", "email address")
+	if rest, err := parseFlags(flags, args); err != nil || len(rest) != 0 {
+		return errUsage
+	}
+
+	lines := bufio.NewReader(c.stdin)
+	req := client.RegisterRequest{Username: *username, Email: *email}
+
+	var err error
+	if req.Username == "" {
+		if req.Username, err = c.prompt(lines, "Username: "); err != nil {
+			return err
+		}
+	}
+	if req.Email == "" {
+		if req.Email, err = c.prompt(lines, "Email: "); err != nil {
+			return err
+		}
+	}
+	if req.Password, err = c.promptSecret(lines, "Password: "); err != nil {
+		return err
+	}
+
+	user, err := c.client().Register(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(user)
+	}
+	fmt.Fprintf(c.stdout, "Registered %s, log in with `ember-cli login --email %s`\n", user.Username, user.Email)
+	return nil
+}
+
+func runLogin(ctx context.Context, c *cli, args []string) error {
+	flags := c.flagSet("login")
+	email := flags.String("email", "", "email address")
+	if rest, err := parseFlags(flags, args); err != nil || len(rest) != 0 {
+		return errUsage
+	}
+
+	lines := bufio.NewReader(c.stdin)
+
+	var err error
+	if *email == "" {
+		if *email, err = c.prompt(lines, "Email: "); err != nil {
+			return err
+		}
+	}
+	password, err := c.promptSecret(lines, "Password: ")
+	if err != nil {
+		return err
+	}
+
+	// Whoever was logged in before is forgotten, the token hook saves the new tokens
+	c.creds = credentials{Server: c.server}
+	api := c.client()
+	if _, err := api.Login(ctx, *email, password); err != nil {
+		return err
+	}
+
+	profile, err := api.GetProfile(ctx)
+	if err != nil {
+		return err
+	}
+	c.creds.Username = profile.Username
+	if err := c.store.Save(c.creds); err != nil {
+		return fmt.Errorf("cannot save tokens: %w", err)
+	}
+
+	if c.json {
+		return c.printJSON(map[string]string{"server": c.server, "username": profile.Username})
+	}
+	fmt.Fprintf(c.stdout, "Logged in to %s as %s\n", c.server, profile.Username)
+	return nil
+}
+
+func runLogout(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	api, err := c.authenticatedClient()
+	if err != nil {
+		return err
+	}
+
+	// A token the server no longer knows is as good as logged out
+	if err := api.Logout(ctx); err != nil && !client.IsCode(err, client.CodeNotFound) {
+		return err
+	}
+	if err := c.store.Delete(); err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(map[string]string{"server": c.server})
+	}
+	fmt.Fprintln(c.stdout, "Logged out")
+	return nil
+}
+
+func runSend(ctx context.Context, c *cli, args []string) error {
+	flags := c.flagSet("send")
+	ttl := flags.Duration("ttl", 0, "delete the message this long after it is read, e.g. 10m")
+	at := flags.String("at", "", "deliver the message at this RFC 3339 time instead of now")
+	rest, err := parseFlags(flags, args)
+	if err != nil || len(rest) < 2 {
+		return errUsage
+	}
+
+	req := client.SendMessageRequest{
+		Username: rest[0],
+		Content:  strings.Join(rest[1:], " "),
+	}
+
+	// `send bob -` takes the message from stdin, so it can be piped in
+	if req.Content == "-" {
+		content, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		req.Content = strings.TrimRight(string(content), "\n")
+	}
+
+	if *ttl != 0 {
+		if *ttl < time.Second {
+			return fmt.Errorf("--ttl must be at least 1s")
+		}
+		seconds := int32(ttl.Seconds())
+		req.TtlSeconds = &seconds
+	}
+
+	if *at != "" {
+		deliverAt, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("--at must be an RFC 3339 time such as 2025-01-02T15:04:05Z")
+		}
+		req.DeliverAt = &deliverAt
+	}
+
+	api, err := c.authenticatedClient()
+	if err != nil {
+		return err
+	}
+
+	message, err := api.SendMessage(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(message)
+	}
+	if message.DeliverAt.Valid && !message.DeliveredAt.Valid {
+		fmt.Fprintf(c.stdout, "Scheduled message %s to %s for %s\n", message.ID, req.Username, message.DeliverAt.Time.Local().Format(timeFormat))
+	} else {
+		fmt.Fprintf(c.stdout, "Sent message %s to %s\n", message.ID, req.Username)
+	}
+	return nil
+}
+
+func runInbox(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	api, err := c.authenticatedClient()
+	if err != nil {
+		return err
+	}
+
+	conversations, err := api.GetConversations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(conversations)
+	}
+	if len(conversations) == 0 {
+		fmt.Fprintln(c.stdout, "No conversations")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tUNREAD\tLAST ACTIVITY\tLAST MESSAGE")
+	for _, conversation := range conversations {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n",
+			conversation.CounterpartUsername,
+			conversation.UnreadCount,
+			conversation.LastActivityAt.Local().Format(timeFormat),
+			strings.ReplaceAll(conversation.LastMessagePreview, "\n", " "),
+		)
+	}
+	return w.Flush()
+}
+
+func runHistory(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	api, err := c.authenticatedClient()
+	if err != nil {
+		return err
+	}
+
+	counterpart, err := api.GetUser(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	messages, err := api.GetMessages(ctx, client.GetMessagesParams{Username: counterpart.Username})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(messages, func(a client.Message, b client.Message) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	if c.json {
+		return c.printJSON(messages)
+	}
+
+	me := firstNonEmpty(c.creds.Username, "you")
+	for _, message := range messages {
+		sender := me
+		if message.SenderID == counterpart.ID {
+			sender = counterpart.Username
+		}
+		c.printMessage(message, sender)
+	}
+	return nil
+}
+
+// There is no streaming endpoint yet, so watch polls for received messages it has not printed before.
+// Messages that were already there when it started are not printed
+func runWatch(ctx context.Context, c *cli, args []string) error {
+	flags := c.flagSet("watch")
+	username := flags.String("user", "", "only messages from this user")
+	interval := flags.Duration("interval", 2*time.Second, "how often to check for new messages")
+	if rest, err := parseFlags(flags, args); err != nil || len(rest) != 0 || *interval <= 0 {
+		return errUsage
+	}
+
+	api, err := c.authenticatedClient()
+	if err != nil {
+		return err
+	}
+
+	params := client.GetMessagesParams{Type: "received", Username: *username}
+	seen := make(map[uuid.UUID]bool)
+	names := make(map[uuid.UUID]string)
+
+	messages, err := api.GetMessages(ctx, params)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		seen[message.ID] = true
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		messages, err := api.GetMessages(ctx, params)
+		if ctx.Err() != nil {
+			return nil
+		} else if client.IsUnavailable(err) {
+			// Keep watching through a server restart, the client already retried
+			fmt.Fprintln(c.stderr, "ember-cli:", err)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		messages = slices.DeleteFunc(messages, func(message client.Message) bool { return seen[message.ID] })
+		slices.SortFunc(messages, func(a client.Message, b client.Message) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+
+		for _, message := range messages {
+			seen[message.ID] = true
+			if c.json {
+				if err := c.printJSON(message); err != nil {
+					return err
+				}
+				continue
+			}
+			c.printMessage(message, c.senderName(ctx, api, names, message.SenderID))
+		}
+	}
+}
+
+// The full-screen client takes over the terminal until ctrl+c or /quit
+func runChat(ctx context.Context, c *cli, args []string) error {
+	flags := c.flagSet("chat")
+	interval := flags.Duration("interval", 2*time.Second, "how often to check for new messages")
+	if rest, err := parseFlags(flags, args); err != nil || len(rest) != 0 || *interval <= 0 {
+		return errUsage
+	}
+	if c.json {
+		return fmt.Errorf("chat is interactive and has no JSON output")
+	}
+
+	api, err := c.authenticatedClient()
+	if err != nil {
+		return err
+	}
+
+	model := tui.New(api, tui.Options{Me: c.creds.Username, PollInterval: *interval})
+	program := tea.NewProgram(model,
+		tea.WithAltScreen(),
+		tea.WithContext(ctx),
+		tea.WithInput(c.stdin),
+		tea.WithOutput(c.stdout),
+	)
+	if _, err := program.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		return err
+	}
+	return nil
+}
+
+// Usernames of senders, looked up through the conversation list the first time a sender is seen
+func (c *cli) senderName(ctx context.Context, api *client.Client, names map[uuid.UUID]string, senderID uuid.UUID) string {
+	if name, ok := names[senderID]; ok {
+		return name
+	}
+
+	conversations, err := api.GetConversations(ctx)
+	if err == nil {
+		for _, conversation := range conversations {
+			names[conversation.CounterpartID] = conversation.CounterpartUsername
+		}
+	}
+
+	if name, ok := names[senderID]; ok {
+		return name
+	}
+	return senderID.String()
+}
+
+func (c *cli) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("ember-cli "+name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	return flags
+}
+
+// Parse flags wherever they appear among the arguments, so `send bob hi --ttl 1m` works like
+// `send --ttl 1m bob hi`. Everything after -- is taken as it is
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// Ask for a value on stderr and read it from stdin
+func (c *cli) prompt(lines *bufio.Reader, label string) (string, error) {
+	fmt.Fprint(c.stderr, label)
+	line, err := lines.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", fmt.Errorf("cannot read %s%w", strings.ToLower(label), err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Like prompt, but without echoing what is typed when stdin is a terminal
+func (c *cli) promptSecret(lines *bufio.Reader, label string) (string, error) {
+	file, ok := c.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return c.prompt(lines, label)
+	}
+
+	fmt.Fprint(c.stderr, label)
+	secret, err := term.ReadPassword(int(file.Fd()))
+	fmt.Fprintln(c.stderr)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s%w", strings.ToLower(label), err)
+	}
+	return string(secret), nil
+}
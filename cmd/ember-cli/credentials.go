@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/PlatosRepublic7/ember/client"
+)
+
+// What `ember-cli login` leaves behind for later commands
+type credentials struct {
+	Server   string        `json:"server"`
+	Username string        `json:"username,omitempty"`
+	Tokens   client.Tokens `json:"tokens"`
+}
+
+// Credentials live in a file only the user can read, inside a directory only the user can open
+type credentialStore struct {
+	path string
+}
+
+// The store is in <user config dir>/ember, e.g. ~/.config/ember on Linux, unless EMBER_CONFIG_DIR is set
+func newCredentialStore() (*credentialStore, error) {
+	dir := os.Getenv("EMBER_CONFIG_DIR")
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot find the user config directory: %w", err)
+		}
+		dir = filepath.Join(configDir, "ember")
+	}
+	return &credentialStore{path: filepath.Join(dir, "credentials.json")}, nil
+}
+
+// Load the saved credentials, which are empty before the first login
+func (s *credentialStore) Load() (credentials, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return credentials{}, nil
+	} else if err != nil {
+		return credentials{}, err
+	}
+
+	var creds credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return credentials{}, fmt.Errorf("%s is corrupt: %w", s.path, err)
+	}
+	return creds, nil
+}
+
+// Save replaces the stored credentials. The new file is written next to the old one and renamed over
+// it, so a crash never leaves half a file behind
+func (s *credentialStore) Save(creds credentials) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Delete forgets the stored credentials
+func (s *credentialStore) Delete() error {
+	err := os.Remove(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
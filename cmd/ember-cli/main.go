@@ -0,0 +1,197 @@
+// ember-cli is a command-line client for the Ember API.
+//
+//	ember-cli [--server URL] [--json] <command> [arguments]
+//
+// The server defaults to $EMBER_URL, then to the server of the last login. Tokens from `login` are
+// kept in the user config directory and refreshed as needed
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/PlatosRepublic7/ember/client"
+)
+
+// Exit codes, following sysexits.h where one fits
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 64
+	exitUnavailable = 69
+	exitNoPerm      = 77
+	exitConfig      = 78
+)
+
+const defaultServer = "http://localhost:8080"
+
+var (
+	errUsage       = errors.New("usage")
+	errNotLoggedIn = errors.New("not logged in, run `ember-cli login` first")
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = []command{
+	{"register", "[--username NAME] [--email EMAIL]", "Create an account, prompting for anything not given", runRegister},
+	{"login", "[--email EMAIL]", "Log in and save the tokens", runLogin},
+	{"logout", "", "Invalidate and forget the saved tokens", runLogout},
+	{"send", "<user> <text>... [--ttl DURATION] [--at TIME]", "Send a message, text - reads it from stdin", runSend},
+	{"inbox", "", "List conversations with their unread counts", runInbox},
+	{"history", "<user>", "Show the conversation with a user", runHistory},
+	{"watch", "[--user NAME] [--interval DURATION]", "Print messages as they arrive", runWatch},
+	{"chat", "[--interval DURATION]", "Open the full-screen chat client", runChat},
+}
+
+// Everything a command needs, so tests can run commands against their own streams and servers
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	json   bool
+	server string
+	store  *credentialStore
+	creds  credentials
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Run the command named in args and return the process exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ember-cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { printUsage(stderr) }
+	server := flags.String("server", "", "Ember API base URL")
+	jsonOutput := flags.Bool("json", false, "print JSON instead of text, one document per line")
+
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	if flags.Arg(0) == "help" {
+		printUsage(stdout)
+		return exitOK
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flags.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "ember-cli: unknown command %q\n", flags.Arg(0))
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	store, err := newCredentialStore()
+	if err != nil {
+		fmt.Fprintln(stderr, "ember-cli:", err)
+		return exitConfig
+	}
+	creds, err := store.Load()
+	if err != nil {
+		fmt.Fprintln(stderr, "ember-cli:", err)
+		return exitConfig
+	}
+
+	c := &cli{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		json:   *jsonOutput,
+		server: firstNonEmpty(*server, os.Getenv("EMBER_URL"), creds.Server, defaultServer),
+		store:  store,
+		creds:  creds,
+	}
+
+	err = cmd.run(ctx, c, flags.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "usage: ember-cli %s %s\n", cmd.name, cmd.args)
+		return exitUsage
+	} else if err != nil {
+		c.printError(err)
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+func exitCode(err error) int {
+	var apiErr *client.Error
+	switch {
+	case errors.Is(err, errNotLoggedIn):
+		return exitNoPerm
+	case errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized:
+		return exitNoPerm
+	case client.IsUnavailable(err):
+		return exitUnavailable
+	}
+	return exitError
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: ember-cli [--server URL] [--json] <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+		if cmd.args != "" {
+			fmt.Fprintf(w, "  %-9s   %s %s\n", "", cmd.name, cmd.args)
+		}
+	}
+}
+
+// A client for the selected server. Refreshed tokens are saved straight away, since the old refresh
+// token keeps working but the old access token does not
+func (c *cli) client() *client.Client {
+	return client.New(c.server,
+		client.WithTokens(c.creds.Tokens),
+		client.WithTokenHook(func(tokens client.Tokens) {
+			c.creds.Tokens = tokens
+			if err := c.store.Save(c.creds); err != nil {
+				fmt.Fprintln(c.stderr, "ember-cli: cannot save tokens:", err)
+			}
+		}),
+	)
+}
+
+// A client for commands that need a logged in user
+func (c *cli) authenticatedClient() (*client.Client, error) {
+	if c.creds.Tokens.Refresh == "" {
+		return nil, errNotLoggedIn
+	}
+	return c.client(), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
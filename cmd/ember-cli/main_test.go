@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/client"
+	"github.com/PlatosRepublic7/ember/client/clienttest"
+	"github.com/google/uuid"
+)
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func runCLI(ctx context.Context, stdin string, args ...string) result {
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, args, strings.NewReader(stdin), &stdout, &stderr)
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// A mock server, with the CLI's config directory set up as if alice had logged in to it
+func newLoggedInServer(t *testing.T) *clienttest.Server {
+	t.Helper()
+	t.Setenv("EMBER_CONFIG_DIR", t.TempDir())
+
+	srv := clienttest.NewServer(t)
+	store, err := newCredentialStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(credentials{Server: srv.URL, Username: "alice", Tokens: srv.Tokens()}); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func savedCredentials(t *testing.T) credentials {
+	t.Helper()
+	store, err := newCredentialStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creds
+}
+
+func TestLoginSavesTokensPrivately(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ember")
+	t.Setenv("EMBER_CONFIG_DIR", dir)
+
+	srv := clienttest.NewServer(t)
+	srv.JSON("GET /v1/me", http.StatusOK, client.Profile{Username: "alice"})
+
+	res := runCLI(context.Background(), "hunter22\n", "--server", srv.URL, "login", "--email", "alice@example.com")
+	if res.code != exitOK {
+		t.Fatalf("exit %d: %s", res.code, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "as alice") {
+		t.Errorf("unexpected output %q", res.stdout)
+	}
+
+	var login struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := srv.Requests()[0].Decode(&login); err != nil || login.Email != "alice@example.com" || login.Password != "hunter22" {
+		t.Errorf("unexpected login request %+v", login)
+	}
+
+	creds := savedCredentials(t)
+	if creds.Tokens != srv.Tokens() || creds.Server != srv.URL || creds.Username != "alice" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+
+	for path, want := range map[string]os.FileMode{dir: 0o700, filepath.Join(dir, "credentials.json"): 0o600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, expected %v", path, info.Mode().Perm(), want)
+		}
+	}
+}
+
+func TestRefreshedTokensAreSaved(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.JSON("GET /v1/conversations", http.StatusOK, []client.Conversation{{CounterpartUsername: "bob", UnreadCount: 2, LastMessagePreview: "hi"}})
+
+	srv.ExpireAccessToken()
+	res := runCLI(context.Background(), "", "inbox")
+	if res.code != exitOK {
+		t.Fatalf("exit %d: %s", res.code, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "bob") || !strings.Contains(res.stdout, "hi") {
+		t.Errorf("unexpected output %q", res.stdout)
+	}
+
+	if creds := savedCredentials(t); creds.Tokens != srv.Tokens() {
+		t.Errorf("expected the refreshed tokens to be saved, got %+v", creds.Tokens)
+	}
+}
+
+func TestSendWithJSONOutput(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	var sent client.SendMessageRequest
+	srv.Handle("POST /v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		clienttest.WriteJSON(w, http.StatusCreated, client.Message{ID: uuid.New(), Content: sent.Content})
+	})
+
+	res := runCLI(context.Background(), "", "--json", "send", "bob", "hello", "there", "--ttl", "90s")
+	if res.code != exitOK {
+		t.Fatalf("exit %d: %s", res.code, res.stderr)
+	}
+
+	if sent.Username != "bob" || sent.Content != "hello there" || sent.TtlSeconds == nil || *sent.TtlSeconds != 90 {
+		t.Errorf("unexpected request %+v", sent)
+	}
+
+	var message client.Message
+	if err := json.Unmarshal([]byte(res.stdout), &message); err != nil || message.Content != "hello there" {
+		t.Errorf("expected the message as JSON, got %q", res.stdout)
+	}
+}
+
+func TestHistoryNamesSenders(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	bob := uuid.New()
+	now := time.Now()
+	srv.JSON("GET /v1/users/{username}", http.StatusOK, client.PublicProfile{ID: bob, Username: "bob"})
+	srv.JSON("GET /v1/messages", http.StatusOK, []client.Message{
+		{ID: uuid.New(), SenderID: uuid.New(), RecipientID: bob, Content: "hey bob", CreatedAt: now},
+		{ID: uuid.New(), SenderID: bob, Content: "hi", CreatedAt: now.Add(-time.Minute)},
+	})
+
+	res := runCLI(context.Background(), "", "history", "bob")
+	if res.code != exitOK {
+		t.Fatalf("exit %d: %s", res.code, res.stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(res.stdout), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "bob: hi") || !strings.HasSuffix(lines[1], "alice: hey bob") {
+		t.Errorf("expected the conversation oldest first, got %q", res.stdout)
+	}
+	if query := srv.Requests()[1].Query; query.Get("username") != "bob" {
+		t.Errorf("expected the history to be filtered to bob, got %v", query)
+	}
+}
+
+func TestWatchPrintsNewMessages(t *testing.T) {
+	srv := newLoggedInServer(t)
+
+	bob := uuid.New()
+	old := client.Message{ID: uuid.New(), SenderID: bob, Content: "already here"}
+	fresh := client.Message{ID: uuid.New(), SenderID: bob, Content: "just arrived"}
+
+	var polls atomic.Int32
+	srv.Handle("GET /v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		if polls.Add(1) == 1 {
+			clienttest.WriteJSON(w, http.StatusOK, []client.Message{old})
+			return
+		}
+		clienttest.WriteJSON(w, http.StatusOK, []client.Message{old, fresh})
+	})
+	srv.JSON("GET /v1/conversations", http.StatusOK, []client.Conversation{{CounterpartID: bob, CounterpartUsername: "bob"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	res := runCLI(ctx, "", "watch", "--interval", "10ms")
+	if res.code != exitOK {
+		t.Fatalf("exit %d: %s", res.code, res.stderr)
+	}
+	if strings.Count(res.stdout, "bob: just arrived") != 1 || strings.Contains(res.stdout, "already here") {
+		t.Errorf("expected only the new message, once, got %q", res.stdout)
+	}
+	if query := srv.Requests()[0].Query; query.Get("type") != "received" {
+		t.Errorf("expected only received messages to be polled, got %v", query)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	srv := newLoggedInServer(t)
+	srv.Problem("POST /v1/messages", http.StatusNotFound, client.CodeNotFound, "Requested user 'nobody' does not exist")
+
+	res := runCLI(context.Background(), "", "--json", "send", "nobody", "hi")
+	if res.code != exitError {
+		t.Errorf("expected exit %d for an API error, got %d", exitError, res.code)
+	}
+	var problem client.Error
+	if err := json.Unmarshal([]byte(res.stderr), &problem); err != nil || problem.Code != client.CodeNotFound {
+		t.Errorf("expected the problem as JSON on stderr, got %q", res.stderr)
+	}
+
+	if res := runCLI(context.Background(), "", "send", "bob"); res.code != exitUsage {
+		t.Errorf("expected exit %d for missing arguments, got %d", exitUsage, res.code)
+	}
+	if res := runCLI(context.Background(), "", "frobnicate"); res.code != exitUsage {
+		t.Errorf("expected exit %d for an unknown command, got %d", exitUsage, res.code)
+	}
+
+	t.Setenv("EMBER_CONFIG_DIR", t.TempDir())
+	if res := runCLI(context.Background(), "", "inbox"); res.code != exitNoPerm {
+		t.Errorf("expected exit %d when not logged in, got %d", exitNoPerm, res.code)
+	}
+}
+
+func TestParseFlagsAnywhere(t *testing.T) {
+	c := &cli{stderr: &bytes.Buffer{}}
+	flags := c.flagSet("send")
+	ttl := flags.Duration("ttl", 0, "")
+
+	rest, err := parseFlags(flags, []string{"bob", "--ttl", "1m", "hi", "--", "--ttl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *ttl != time.Minute || strings.Join(rest, " ") != "bob hi --ttl" {
+		t.Errorf("unexpected parse: ttl %v, rest %q", *ttl, rest)
+	}
+}
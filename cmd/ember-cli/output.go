@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/client"
+)
+
+// Timestamps are shown in the local time zone, to the minute
+const timeFormat = "2006-01-02 15:04"
+
+// Print v as a single line of JSON
+func (c *cli) printJSON(v any) error {
+	return json.NewEncoder(c.stdout).Encode(v)
+}
+
+// Errors go to stderr. In JSON mode API errors keep their problem details, so scripts can branch on the
+// code rather than the message
+func (c *cli) printError(err error) {
+	if !c.json {
+		fmt.Fprintln(c.stderr, "ember-cli:", err)
+		return
+	}
+
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		json.NewEncoder(c.stderr).Encode(apiErr)
+		return
+	}
+	json.NewEncoder(c.stderr).Encode(map[string]string{"detail": err.Error()})
+}
+
+// Print one message of a conversation as a line of text
+func (c *cli) printMessage(message client.Message, sender string) {
+	line := fmt.Sprintf("[%s] %s: %s", message.CreatedAt.Local().Format(timeFormat), sender, message.Content)
+	if remaining := time.Until(message.ExpiresAt.Time); message.ExpiresAt.Valid && remaining > 0 {
+		line += fmt.Sprintf(" (expires in %s)", remaining.Round(time.Second))
+	}
+	fmt.Fprintln(c.stdout, line)
+}
@@ -0,0 +1,855 @@
+// Package admin implements ember admin, the commands operators use to manage users, tokens, expired
+// messages and signing keys instead of writing raw SQL
+package admin
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/blob"
+	"github.com/PlatosRepublic7/ember/internal/config"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/migrate"
+	"github.com/PlatosRepublic7/ember/internal/reaper"
+	"github.com/PlatosRepublic7/ember/internal/validate"
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+const adminUsage = `usage: ember admin [--dry-run] [--yes] <command> [arguments]
+
+Commands:
+  create-user <username> --email <email> [--admin]   create a user, prompting for the password
+  suspend <username>                                 block logins and API access, revoke every refresh token
+  unsuspend <username>                               lift a suspension
+  delete-user <username>                             delete a user with everything they own
+  reset-password <username>                          set a new password and revoke every refresh token
+  tokens <username> [--all]                          list refresh tokens, only valid ones without --all
+  revoke-tokens <username> | --id <id> | --everyone  revoke refresh tokens
+  purge-expired                                      expire every message whose TTL has run out now
+  stats                                              show row counts and storage use
+  rotate-keys [--purpose access|refresh|all] [--grace <duration>] [--activate-in <duration>]
+                                                     sign new tokens with fresh keys and retire the old ones
+
+--dry-run runs a command in a transaction that is rolled back, so nothing changes. Destructive commands
+ask for confirmation first, unless --yes is given`
+
+// ErrUsage is returned for a missing or unknown admin command, or arguments it does not take
+var ErrUsage = errors.New("usage: ember admin [--dry-run] [--yes] <command> [arguments], see ember admin help")
+
+// Returned when the operator does not confirm a destructive command
+var errAborted = errors.New("aborted")
+
+// Rolls back the transaction of a dry run, see adminCommand.change
+var errDryRun = errors.New("dry run")
+
+// adminCommand runs the `ember admin` commands operators use instead of raw SQL
+type adminCommand struct {
+	Tx    *events.TxManager
+	DB    database.Querier
+	Auth  *auth.Authenticator
+	Blobs *blob.FileStore
+	In    *bufio.Reader
+	Out   io.Writer
+	// Reads a password without echoing it when set, otherwise passwords are read from In like any line
+	ReadPassword func() (string, error)
+	DryRun       bool
+	Yes          bool
+}
+
+// Run runs `ember admin <command>` against the database at conn, which must have the schema of this build
+func Run(ctx context.Context, conn *sql.DB, cfg *config.Config, args []string) error {
+	migrations, err := migrate.NewProvider(conn)
+	if err != nil {
+		return err
+	}
+	if err := migrate.Check(ctx, migrations); err != nil {
+		return err
+	}
+
+	blobStore, err := blob.NewFileStore(cfg.Server.BlobDir)
+	if err != nil {
+		return err
+	}
+
+	store := database.NewStore(conn)
+
+	admin := &adminCommand{
+		Tx:    events.NewTxManager(store, events.NewBus()),
+		DB:    store,
+		Auth:  auth.NewAuthenticator(cfg.Auth),
+		Blobs: blobStore,
+		In:    bufio.NewReader(os.Stdin),
+		Out:   os.Stdout,
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		admin.ReadPassword = func() (string, error) {
+			password, err := term.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Fprintln(admin.Out)
+			return string(password), err
+		}
+	}
+
+	return admin.run(ctx, args)
+}
+
+func (a *adminCommand) run(ctx context.Context, args []string) error {
+	global := a.flagSet("admin")
+	if err := global.Parse(args); err != nil {
+		return fmt.Errorf("%v, %w", err, ErrUsage)
+	}
+	if global.NArg() == 0 {
+		return ErrUsage
+	}
+
+	command, args := global.Arg(0), global.Args()[1:]
+	switch command {
+	case "help":
+		fmt.Fprintln(a.Out, adminUsage)
+		return nil
+	case "create-user":
+		return a.createUser(ctx, args)
+	case "suspend":
+		return a.suspend(ctx, args)
+	case "unsuspend":
+		return a.unsuspend(ctx, args)
+	case "delete-user":
+		return a.deleteUser(ctx, args)
+	case "reset-password":
+		return a.resetPassword(ctx, args)
+	case "tokens":
+		return a.listTokens(ctx, args)
+	case "revoke-tokens":
+		return a.revokeTokens(ctx, args)
+	case "purge-expired":
+		return a.purgeExpired(ctx, args)
+	case "stats":
+		return a.stats(ctx, args)
+	case "rotate-keys":
+		return a.rotateKeys(ctx, args)
+	default:
+		return fmt.Errorf("unknown admin command %q, %w", command, ErrUsage)
+	}
+}
+
+// A flag set taking --dry-run and --yes, which every command accepts before or after its name
+func (a *adminCommand) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(&a.DryRun, "dry-run", a.DryRun, "roll back instead of committing")
+	flags.BoolVar(&a.Yes, "yes", a.Yes, "do not ask for confirmation")
+	return flags
+}
+
+// Parse the flags of a command, which may come before, between or after its positional arguments,
+// and check it was given between least and most of those
+func parseAdminFlags(flags *flag.FlagSet, args []string, least int, most int) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("%v, %w", err, ErrUsage)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if len(positional) < least || len(positional) > most {
+		return nil, fmt.Errorf("wrong number of arguments for %s, %w", flags.Name(), ErrUsage)
+	}
+	return positional, nil
+}
+
+// Read one line from In, without its line ending
+func (a *adminCommand) readLine() (string, error) {
+	line, err := a.In.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Prompt for a password and check it is one the API would accept
+func (a *adminCommand) readPassword() (string, error) {
+	fmt.Fprint(a.Out, "Password: ")
+
+	var password string
+	var err error
+	if a.ReadPassword != nil {
+		password, err = a.ReadPassword()
+	} else {
+		password, err = a.readLine()
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read password: %w", err)
+	}
+
+	check := struct {
+		Password string `json:"password" validate:"password"`
+	}{password}
+	return password, validationError(validate.Struct(check))
+}
+
+// Ask the operator to confirm a destructive command. Dry runs and --yes skip the question
+func (a *adminCommand) confirm(question string) error {
+	if a.Yes || a.DryRun {
+		return nil
+	}
+
+	fmt.Fprintf(a.Out, "%s [y/N] ", question)
+	answer, err := a.readLine()
+	if err != nil {
+		return errAborted
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errAborted
+	}
+}
+
+// Run fn in one transaction, rolled back again in dry-run mode so the output shows what would change
+// without changing anything
+func (a *adminCommand) change(ctx context.Context, fn func(tx *events.Tx) error) error {
+	err := a.Tx.WithTx(ctx, func(tx *events.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if a.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		fmt.Fprintln(a.Out, "Dry run, nothing was changed")
+		return nil
+	}
+	return err
+}
+
+// Look up a user by username
+func (a *adminCommand) user(ctx context.Context, db database.Querier, username string) (database.User, error) {
+	user, err := db.GetUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("no user is named %q", username)
+	}
+	return user, err
+}
+
+// Turn the field errors of a failed validate.Struct into one line
+func validationError(err error) error {
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) || len(apiErr.Fields) == 0 {
+		return err
+	}
+
+	messages := make([]string, len(apiErr.Fields))
+	for i, field := range apiErr.Fields {
+		messages[i] = field.Field + " " + field.Message
+	}
+	return errors.New(strings.Join(messages, ", "))
+}
+
+func (a *adminCommand) createUser(ctx context.Context, args []string) error {
+	flags := a.flagSet("create-user")
+	email := flags.String("email", "", "email address of the user")
+	isAdmin := flags.Bool("admin", false, "make the user an administrator")
+	positional, err := parseAdminFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	// The same rules as registering through the API, apart from the MX lookup
+	check := struct {
+		Username string `json:"username" validate:"required,username"`
+		Email    string `json:"email" validate:"required,email"`
+	}{positional[0], *email}
+	if err := validationError(validate.Struct(check)); err != nil {
+		return err
+	}
+
+	password, err := a.readPassword()
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := a.Auth.HashPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	err = a.change(ctx, func(tx *events.Tx) error {
+		params := database.CreateUserParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			Username:  check.Username,
+			Email:     check.Email,
+			Password:  hashedPassword,
+		}
+		user, err := tx.CreateUser(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		if *isAdmin {
+			adminParams := database.SetUserAdminParams{
+				IsAdmin:   true,
+				UpdatedAt: time.Now().UTC(),
+				ID:        user.ID,
+			}
+			if user, err = tx.SetUserAdmin(ctx, adminParams); err != nil {
+				return err
+			}
+		}
+
+		registered := events.UserRegistered{
+			UserID:    user.ID,
+			Username:  user.Username,
+			CreatedAt: user.CreatedAt,
+		}
+		if err := tx.Emit(ctx, registered); err != nil {
+			return err
+		}
+
+		role := "user"
+		if user.IsAdmin {
+			role = "administrator"
+		}
+		fmt.Fprintf(a.Out, "Created %s %s with id %s\n", role, user.Username, user.ID)
+		return nil
+	})
+	if apierr.IsUniqueViolation(err) {
+		return errors.New("username or email is already taken")
+	}
+	return err
+}
+
+func (a *adminCommand) suspend(ctx context.Context, args []string) error {
+	positional, err := parseAdminFlags(a.flagSet("suspend"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.user(ctx, a.DB, positional[0])
+	if err != nil {
+		return err
+	}
+	if user.SuspendedAt.Valid {
+		fmt.Fprintf(a.Out, "%s has been suspended since %s\n", user.Username, user.SuspendedAt.Time.Format(time.RFC3339))
+		return nil
+	}
+
+	if err := a.confirm(fmt.Sprintf("Suspend %s and log them out everywhere?", user.Username)); err != nil {
+		return err
+	}
+
+	return a.change(ctx, func(tx *events.Tx) error {
+		suspendParams := database.UpdateUserSuspensionParams{
+			SuspendedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+			UpdatedAt:   time.Now().UTC(),
+			ID:          user.ID,
+		}
+		if _, err := tx.UpdateUserSuspension(ctx, suspendParams); err != nil {
+			return err
+		}
+
+		revokeParams := database.RevokeUserRefreshTokensParams{
+			UpdatedAt: time.Now().UTC(),
+			UserID:    user.ID,
+		}
+		revoked, err := tx.RevokeUserRefreshTokens(ctx, revokeParams)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.Out, "Suspended %s and revoked %d refresh token(s)\n", user.Username, revoked)
+		return nil
+	})
+}
+
+func (a *adminCommand) unsuspend(ctx context.Context, args []string) error {
+	positional, err := parseAdminFlags(a.flagSet("unsuspend"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.user(ctx, a.DB, positional[0])
+	if err != nil {
+		return err
+	}
+	if !user.SuspendedAt.Valid {
+		fmt.Fprintf(a.Out, "%s is not suspended\n", user.Username)
+		return nil
+	}
+
+	return a.change(ctx, func(tx *events.Tx) error {
+		params := database.UpdateUserSuspensionParams{
+			SuspendedAt: sql.NullTime{Valid: false},
+			UpdatedAt:   time.Now().UTC(),
+			ID:          user.ID,
+		}
+		if _, err := tx.UpdateUserSuspension(ctx, params); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.Out, "Lifted the suspension of %s\n", user.Username)
+		return nil
+	})
+}
+
+func (a *adminCommand) deleteUser(ctx context.Context, args []string) error {
+	positional, err := parseAdminFlags(a.flagSet("delete-user"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.user(ctx, a.DB, positional[0])
+	if err != nil {
+		return err
+	}
+
+	question := fmt.Sprintf("Delete %s with all of their messages, contacts, blocks and webhooks? This cannot be undone", user.Username)
+	if err := a.confirm(question); err != nil {
+		return err
+	}
+
+	err = a.change(ctx, func(tx *events.Tx) error {
+		if _, err := tx.DeleteUser(ctx, user.ID); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.Out, "Deleted %s\n", user.Username)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The avatar goes once the row is gone, the same order the profile handlers replace it in
+	if !a.DryRun && user.AvatarKey != "" {
+		if err := a.Blobs.Delete(ctx, user.AvatarKey); err != nil {
+			return fmt.Errorf("deleted %s, but not their avatar: %w", user.Username, err)
+		}
+	}
+	return nil
+}
+
+func (a *adminCommand) resetPassword(ctx context.Context, args []string) error {
+	positional, err := parseAdminFlags(a.flagSet("reset-password"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.user(ctx, a.DB, positional[0])
+	if err != nil {
+		return err
+	}
+
+	if err := a.confirm(fmt.Sprintf("Reset the password of %s and log them out everywhere?", user.Username)); err != nil {
+		return err
+	}
+
+	password, err := a.readPassword()
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := a.Auth.HashPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	return a.change(ctx, func(tx *events.Tx) error {
+		passwordParams := database.UpdateUserPasswordParams{
+			Password:  hashedPassword,
+			UpdatedAt: time.Now().UTC(),
+			ID:        user.ID,
+		}
+		if _, err := tx.UpdateUserPassword(ctx, passwordParams); err != nil {
+			return err
+		}
+
+		revokeParams := database.RevokeUserRefreshTokensParams{
+			UpdatedAt: time.Now().UTC(),
+			UserID:    user.ID,
+		}
+		revoked, err := tx.RevokeUserRefreshTokens(ctx, revokeParams)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.Out, "Reset the password of %s and revoked %d refresh token(s)\n", user.Username, revoked)
+		return nil
+	})
+}
+
+func (a *adminCommand) listTokens(ctx context.Context, args []string) error {
+	flags := a.flagSet("tokens")
+	all := flags.Bool("all", false, "include revoked tokens")
+	positional, err := parseAdminFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.user(ctx, a.DB, positional[0])
+	if err != nil {
+		return err
+	}
+
+	tokens, err := a.DB.GetAllUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(tokens, func(a, b database.RefreshToken) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	// The tokens themselves are credentials, so only their ids are shown
+	w := tabwriter.NewWriter(a.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVALID\tCREATED AT\tUPDATED AT")
+	for _, token := range tokens {
+		if !token.IsValid && !*all {
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%t\t%s\t%s\n", token.ID, token.IsValid, token.CreatedAt.Format(time.RFC3339), token.UpdatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func (a *adminCommand) revokeTokens(ctx context.Context, args []string) error {
+	flags := a.flagSet("revoke-tokens")
+	id := flags.Int("id", 0, "revoke only the refresh token with this id")
+	everyone := flags.Bool("everyone", false, "revoke the refresh tokens of every user")
+	positional, err := parseAdminFlags(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	// Exactly one of a username, --id and --everyone says which tokens go
+	var selected int
+	for _, set := range []bool{len(positional) == 1, *id != 0, *everyone} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		return fmt.Errorf("revoke-tokens takes one of a username, --id or --everyone, %w", ErrUsage)
+	}
+
+	var question string
+	var revoke func(tx *events.Tx) (int64, error)
+	switch {
+	case *everyone:
+		question = "Revoke the refresh tokens of every user, logging everybody out?"
+		revoke = func(tx *events.Tx) (int64, error) {
+			return tx.RevokeAllRefreshTokens(ctx, time.Now().UTC())
+		}
+	case *id != 0:
+		question = fmt.Sprintf("Revoke refresh token %d?", *id)
+		revoke = func(tx *events.Tx) (int64, error) {
+			params := database.RevokeRefreshTokenByIDParams{
+				UpdatedAt: time.Now().UTC(),
+				ID:        int32(*id),
+			}
+			return tx.RevokeRefreshTokenByID(ctx, params)
+		}
+	default:
+		user, err := a.user(ctx, a.DB, positional[0])
+		if err != nil {
+			return err
+		}
+
+		question = fmt.Sprintf("Revoke the refresh tokens of %s, logging them out everywhere?", user.Username)
+		revoke = func(tx *events.Tx) (int64, error) {
+			params := database.RevokeUserRefreshTokensParams{
+				UpdatedAt: time.Now().UTC(),
+				UserID:    user.ID,
+			}
+			return tx.RevokeUserRefreshTokens(ctx, params)
+		}
+	}
+
+	if err := a.confirm(question); err != nil {
+		return err
+	}
+
+	return a.change(ctx, func(tx *events.Tx) error {
+		revoked, err := revoke(tx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.Out, "Revoked %d refresh token(s)\n", revoked)
+		return nil
+	})
+}
+
+func (a *adminCommand) purgeExpired(ctx context.Context, args []string) error {
+	if _, err := parseAdminFlags(a.flagSet("purge-expired"), args, 0, 0); err != nil {
+		return err
+	}
+
+	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	stats, err := a.DB.GetStorageStats(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if stats.ExpiredMessages == 0 {
+		fmt.Fprintln(a.Out, "No messages have expired")
+		return nil
+	}
+
+	if a.DryRun {
+		fmt.Fprintf(a.Out, "%d expired message(s) would be purged\n", stats.ExpiredMessages)
+		fmt.Fprintln(a.Out, "Dry run, nothing was changed")
+		return nil
+	}
+
+	if err := a.confirm(fmt.Sprintf("Purge %d expired message(s)?", stats.ExpiredMessages)); err != nil {
+		return err
+	}
+
+	// The same passes the reaper makes, so MessageExpired events go out for every message
+	messageReaper := reaper.NewReaper(a.Tx, 0)
+	var purged int
+	for {
+		expired, err := messageReaper.ExpireDue(ctx)
+		purged += expired
+		if err != nil {
+			return fmt.Errorf("purged %d message(s) before failing: %w", purged, err)
+		}
+		if expired < int(messageReaper.BatchSize) {
+			break
+		}
+	}
+
+	fmt.Fprintf(a.Out, "Purged %d expired message(s)\n", purged)
+	return nil
+}
+
+func (a *adminCommand) stats(ctx context.Context, args []string) error {
+	if _, err := parseAdminFlags(a.flagSet("stats"), args, 0, 0); err != nil {
+		return err
+	}
+
+	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	stats, err := a.DB.GetStorageStats(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	databaseSize, err := a.DB.GetDatabaseSize(ctx)
+	if err != nil {
+		return err
+	}
+
+	blobCount, blobSize, err := directorySize(a.Blobs.Root)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(a.Out, 0, 0, 2, ' ', 0)
+	for _, row := range []struct {
+		name  string
+		value any
+	}{
+		{"users", stats.Users},
+		{"suspended users", stats.SuspendedUsers},
+		{"administrators", stats.Admins},
+		{"delivered messages", stats.DeliveredMessages},
+		{"scheduled messages", stats.ScheduledMessages},
+		{"expired messages", stats.ExpiredMessages},
+		{"deleted messages", stats.DeletedMessages},
+		{"valid refresh tokens", stats.ValidRefreshTokens},
+		{"contacts", stats.Contacts},
+		{"blocks", stats.Blocks},
+		{"webhooks", stats.Webhooks},
+		{"pending events", stats.PendingEvents},
+		{"database size", formatBytes(databaseSize)},
+		{"blobs", blobCount},
+		{"blob size", formatBytes(blobSize)},
+	} {
+		fmt.Fprintf(w, "%s\t%v\n", row.name, row.value)
+	}
+	return w.Flush()
+}
+
+// Count the files under dir and add up their sizes
+func directorySize(dir string) (int64, int64, error) {
+	var count, size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		count++
+		size += info.Size()
+		return nil
+	})
+	return count, size, err
+}
+
+// Format a byte count with a binary unit, such as 1.5 MiB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func (a *adminCommand) rotateKeys(ctx context.Context, args []string) error {
+	flags := a.flagSet("rotate-keys")
+	purpose := flags.String("purpose", "all", "which keys to rotate: access, refresh or all")
+	grace := flags.Duration("grace", -1, "how long tokens signed with the old keys keep working, the token lifetime by default")
+	activateIn := flags.Duration("activate-in", 2*auth.KeyReloadInterval, "how long until the new keys start signing tokens")
+	if _, err := parseAdminFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	var purposes []string
+	switch *purpose {
+	case "all":
+		purposes = []string{auth.PurposeAccess, auth.PurposeRefresh}
+	case auth.PurposeAccess, auth.PurposeRefresh:
+		purposes = []string{*purpose}
+	default:
+		return fmt.Errorf("unknown key purpose %q, %w", *purpose, ErrUsage)
+	}
+
+	// Old keys keep working for a token lifetime after the new ones take over, so no token that was
+	// valid when they did is cut short
+	graceFor := func(purpose string) time.Duration {
+		if *grace >= 0 {
+			return *grace
+		}
+		if purpose == auth.PurposeRefresh {
+			return a.Auth.Config.RefreshTokenTTL
+		}
+		return a.Auth.Config.AccessTokenTTL
+	}
+
+	question := fmt.Sprintf("Rotate the %s signing keys?", strings.Join(purposes, " and "))
+	if *grace == 0 {
+		question = fmt.Sprintf("Rotate the %s signing keys, invalidating every token signed with the old ones?", strings.Join(purposes, " and "))
+	}
+	if err := a.confirm(question); err != nil {
+		return err
+	}
+
+	// New secrets are encrypted at rest, so there is no rotating without the key to do it with
+	if a.Auth.Config.KeyEncryptionKey == "" {
+		return fmt.Errorf("rotating keys: %w", auth.ErrNoKeyEncryptionKey)
+	}
+
+	return a.change(ctx, func(tx *events.Tx) error {
+		existing, err := tx.GetSigningKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Keys written before secrets were encrypted are encrypted now
+		var encrypted int
+		for _, key := range existing {
+			if auth.IsKeySecretEncrypted(key.Secret) {
+				continue
+			}
+			secret, err := a.Auth.EncryptKeySecret(key.ID, key.Secret)
+			if err != nil {
+				return err
+			}
+			if err := tx.UpdateSigningKeySecret(ctx, database.UpdateSigningKeySecretParams{Secret: secret, ID: key.ID}); err != nil {
+				return err
+			}
+			encrypted++
+		}
+		if encrypted > 0 {
+			fmt.Fprintf(a.Out, "Encrypted %d key(s) stored in plaintext\n", encrypted)
+		}
+
+		now := time.Now().UTC()
+		activatesAt := now.Add(*activateIn)
+		for _, purpose := range purposes {
+			// The configured secret gets a row of its own the first time round, so it can be retired
+			configKeyID := auth.ConfigKeyID(purpose)
+			if !slices.ContainsFunc(existing, func(key database.SigningKey) bool { return key.ID == configKeyID }) {
+				configKeyParams := database.CreateSigningKeyParams{
+					ID:          configKeyID,
+					Purpose:     purpose,
+					Secret:      "",
+					CreatedAt:   now,
+					ActivatesAt: now,
+				}
+				if _, err := tx.CreateSigningKey(ctx, configKeyParams); err != nil {
+					return err
+				}
+			}
+
+			retiresAt := activatesAt.Add(graceFor(purpose))
+			retireParams := database.RetireSigningKeysParams{
+				RetiresAt: sql.NullTime{Time: retiresAt, Valid: true},
+				Purpose:   purpose,
+			}
+			retired, err := tx.RetireSigningKeys(ctx, retireParams)
+			if err != nil {
+				return err
+			}
+
+			keyID := uuid.NewString()
+			secret, err := auth.GenerateKeySecret()
+			if err != nil {
+				return err
+			}
+			encryptedSecret, err := a.Auth.EncryptKeySecret(keyID, secret)
+			if err != nil {
+				return err
+			}
+
+			keyParams := database.CreateSigningKeyParams{
+				ID:          keyID,
+				Purpose:     purpose,
+				Secret:      encryptedSecret,
+				CreatedAt:   now,
+				ActivatesAt: activatesAt,
+			}
+			key, err := tx.CreateSigningKey(ctx, keyParams)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(a.Out, "New %s key %s signs from %s, %d previous key(s) retire at %s\n", purpose, key.ID, activatesAt.Format(time.RFC3339), retired, retiresAt.Format(time.RFC3339))
+		}
+		return nil
+	})
+}
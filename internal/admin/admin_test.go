@@ -0,0 +1,435 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/blob"
+	"github.com/PlatosRepublic7/ember/internal/config"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/memstore"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse battery staple"
+
+var ctx = context.Background()
+
+type adminTest struct {
+	t     *testing.T
+	store *memstore.Store
+	auth  *auth.Authenticator
+	blobs *blob.FileStore
+}
+
+func newAdminTest(t *testing.T) *adminTest {
+	t.Helper()
+
+	blobs, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cheap hashes keep the suite fast
+	return &adminTest{
+		t:     t,
+		store: memstore.New(),
+		auth: auth.NewAuthenticator(config.Auth{
+			AccessSecret:     "test-access-secret",
+			RefreshSecret:    "test-refresh-secret",
+			AccessTokenTTL:   15 * time.Minute,
+			RefreshTokenTTL:  7 * 24 * time.Hour,
+			BcryptCost:       bcrypt.MinCost,
+			KeyEncryptionKey: "test-key-encryption-key-of-32-chars",
+		}),
+		blobs: blobs,
+	}
+}
+
+// Run `ember admin args...` with input on stdin, returning what it printed
+func (at *adminTest) run(input string, args ...string) (string, error) {
+	at.t.Helper()
+
+	var out bytes.Buffer
+	admin := &adminCommand{
+		Tx:    events.NewTxManager(at.store, events.NewBus()),
+		DB:    at.store,
+		Auth:  at.auth,
+		Blobs: at.blobs,
+		In:    bufio.NewReader(strings.NewReader(input)),
+		Out:   &out,
+	}
+	err := admin.run(ctx, args)
+	return out.String(), err
+}
+
+// Like run, failing the test on an error
+func (at *adminTest) mustRun(input string, args ...string) string {
+	at.t.Helper()
+
+	out, err := at.run(input, args...)
+	if err != nil {
+		at.t.Fatalf("ember admin %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+func (at *adminTest) user(username string) database.User {
+	at.t.Helper()
+
+	user, err := at.store.GetUserByUsername(ctx, username)
+	if err != nil {
+		at.t.Fatalf("looking up %s: %v", username, err)
+	}
+	return user
+}
+
+// Create username through the admin command, with a valid refresh token as if they had logged in
+func (at *adminTest) createUser(username string) database.User {
+	at.t.Helper()
+
+	at.mustRun(testPassword+"\n", "create-user", username, "--email", username+"@example.com")
+	user := at.user(username)
+
+	_, err := at.store.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		RefreshToken: uuid.NewString(),
+		IsValid:      true,
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+		UserID:       user.ID,
+	})
+	if err != nil {
+		at.t.Fatal(err)
+	}
+	return user
+}
+
+func (at *adminTest) validTokens(user database.User) int {
+	at.t.Helper()
+
+	tokens, err := at.store.GetAllUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		at.t.Fatal(err)
+	}
+
+	var valid int
+	for _, token := range tokens {
+		if token.IsValid {
+			valid++
+		}
+	}
+	return valid
+}
+
+func TestAdminCreateUser(t *testing.T) {
+	at := newAdminTest(t)
+
+	out := at.mustRun(testPassword+"\n", "create-user", "alice", "--email", "alice@example.com", "--admin")
+	if !strings.Contains(out, "Created administrator alice") {
+		t.Errorf("got output %q", out)
+	}
+
+	alice := at.user("alice")
+	if !alice.IsAdmin || !auth.CheckPasswordHash(ctx, testPassword, alice.Password) {
+		t.Errorf("got user %+v, want an administrator with the given password", alice)
+	}
+
+	if _, err := at.run(testPassword+"\n", "create-user", "alice", "--email", "other@example.com"); err == nil {
+		t.Error("creating a user with a taken username succeeded")
+	}
+
+	if _, err := at.run("short\n", "create-user", "bob", "--email", "bob@example.com"); err == nil {
+		t.Error("creating a user with a too short password succeeded")
+	}
+
+	if _, err := at.run(testPassword+"\n", "create-user", "bob"); err == nil {
+		t.Error("creating a user without an email succeeded")
+	}
+}
+
+func TestAdminSuspend(t *testing.T) {
+	at := newAdminTest(t)
+	alice := at.createUser("alice")
+
+	// Anything but yes leaves the user alone
+	if _, err := at.run("n\n", "suspend", "alice"); !errors.Is(err, errAborted) {
+		t.Fatalf("got error %v, want %v", err, errAborted)
+	}
+	if at.user("alice").SuspendedAt.Valid {
+		t.Fatal("user was suspended without confirmation")
+	}
+
+	out := at.mustRun("y\n", "suspend", "alice")
+	if !strings.Contains(out, "revoked 1 refresh token(s)") {
+		t.Errorf("got output %q", out)
+	}
+	if !at.user("alice").SuspendedAt.Valid || at.validTokens(alice) != 0 {
+		t.Error("suspended user kept a valid refresh token or is not suspended")
+	}
+
+	at.mustRun("", "unsuspend", "alice")
+	if at.user("alice").SuspendedAt.Valid {
+		t.Error("suspension was not lifted")
+	}
+
+	if _, err := at.run("y\n", "suspend", "nobody"); err == nil {
+		t.Error("suspending a user that does not exist succeeded")
+	}
+}
+
+func TestAdminDeleteUser(t *testing.T) {
+	at := newAdminTest(t)
+	alice := at.createUser("alice")
+
+	avatarKey := "avatars/" + alice.ID.String() + "/avatar.png"
+	if err := at.blobs.Put(ctx, avatarKey, strings.NewReader("png")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := at.store.UpdateUserAvatar(ctx, database.UpdateUserAvatarParams{AvatarKey: avatarKey, UpdatedAt: time.Now().UTC(), ID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run neither asks nor changes anything
+	out := at.mustRun("", "--dry-run", "delete-user", "alice")
+	if !strings.Contains(out, "Dry run, nothing was changed") {
+		t.Errorf("got output %q", out)
+	}
+	at.user("alice")
+
+	at.mustRun("", "delete-user", "alice", "--yes")
+	if _, err := at.store.GetUserByUsername(ctx, "alice"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted user is still there (err %v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(at.blobs.Root, avatarKey)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("avatar of the deleted user is still there (err %v)", err)
+	}
+}
+
+func TestAdminResetPassword(t *testing.T) {
+	at := newAdminTest(t)
+	alice := at.createUser("alice")
+
+	at.mustRun("yes\na new password\n", "reset-password", "alice")
+
+	if !auth.CheckPasswordHash(ctx, "a new password", at.user("alice").Password) {
+		t.Error("password was not changed")
+	}
+	if at.validTokens(alice) != 0 {
+		t.Error("refresh tokens were not revoked")
+	}
+}
+
+func TestAdminTokens(t *testing.T) {
+	at := newAdminTest(t)
+	alice := at.createUser("alice")
+	bob := at.createUser("bob")
+
+	out := at.mustRun("", "tokens", "alice")
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || !strings.Contains(lines[1], "true") {
+		t.Errorf("got token list %q, want one valid token", out)
+	}
+
+	tokens, err := at.store.GetAllUserRefreshTokens(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at.mustRun("", "revoke-tokens", "--id", strconv.Itoa(int(tokens[0].ID)), "--yes")
+	if at.validTokens(alice) != 0 || at.validTokens(bob) != 1 {
+		t.Errorf("revoking token %d revoked the wrong tokens", tokens[0].ID)
+	}
+
+	// Revoked tokens only show up with --all
+	if out := at.mustRun("", "tokens", "alice"); strings.Contains(out, "false") {
+		t.Errorf("revoked token listed without --all: %q", out)
+	}
+	if out := at.mustRun("", "tokens", "alice", "--all"); !strings.Contains(out, "false") {
+		t.Errorf("revoked token missing with --all: %q", out)
+	}
+
+	at.mustRun("", "revoke-tokens", "--everyone", "--yes")
+	if at.validTokens(bob) != 0 {
+		t.Error("revoking every token left one valid")
+	}
+
+	for _, args := range [][]string{
+		{"revoke-tokens"},
+		{"revoke-tokens", "alice", "--everyone"},
+		{"revoke-tokens", "alice", "bob"},
+	} {
+		if _, err := at.run("", args...); !errors.Is(err, ErrUsage) {
+			t.Errorf("ember admin %s: got error %v, want a usage error", strings.Join(args, " "), err)
+		}
+	}
+}
+
+func TestAdminPurgeExpired(t *testing.T) {
+	at := newAdminTest(t)
+	alice := at.createUser("alice")
+	bob := at.createUser("bob")
+
+	for i, expiresAt := range []time.Time{time.Now().Add(-time.Minute), time.Now().Add(-time.Second), time.Now().Add(time.Hour)} {
+		_, err := at.store.CreateMessage(ctx, database.CreateMessageParams{
+			ID:          uuid.New(),
+			SenderID:    alice.ID,
+			RecipientID: bob.ID,
+			Content:     "message " + string(rune('a'+i)),
+			CreatedAt:   time.Now().UTC(),
+			ExpiresAt:   sql.NullTime{Time: expiresAt.UTC(), Valid: true},
+			DeliveredAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if out := at.mustRun("", "purge-expired", "--dry-run"); !strings.Contains(out, "2 expired message(s) would be purged") {
+		t.Errorf("got dry run output %q", out)
+	}
+
+	if out := at.mustRun("y\n", "purge-expired"); !strings.Contains(out, "Purged 2 expired message(s)") {
+		t.Errorf("got output %q", out)
+	}
+
+	stats, err := at.store.GetStorageStats(ctx, sql.NullTime{Time: time.Now().UTC(), Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ExpiredMessages != 0 || stats.DeletedMessages != 2 || stats.DeliveredMessages != 1 {
+		t.Errorf("got stats %+v after purging", stats)
+	}
+
+	if out := at.mustRun("", "purge-expired"); !strings.Contains(out, "No messages have expired") {
+		t.Errorf("got output %q with nothing to purge", out)
+	}
+}
+
+func TestAdminStats(t *testing.T) {
+	at := newAdminTest(t)
+	at.createUser("alice")
+	at.createUser("bob")
+	at.mustRun("", "suspend", "bob", "--yes")
+
+	if err := at.blobs.Put(ctx, "avatars/a.png", strings.NewReader(strings.Repeat("x", 2048))); err != nil {
+		t.Fatal(err)
+	}
+
+	out := at.mustRun("", "stats")
+	for _, want := range []string{"users                 2", "suspended users       1", "valid refresh tokens  1", "blob size             2.0 KiB"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stats output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestAdminRotateKeys(t *testing.T) {
+	at := newAdminTest(t)
+
+	out := at.mustRun("", "rotate-keys", "--purpose", "access", "--activate-in", "0s", "--yes")
+	if !strings.Contains(out, "New access key") {
+		t.Errorf("got output %q", out)
+	}
+
+	keys, err := at.store.GetSigningKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want the new key and the configured secret", len(keys))
+	}
+
+	// The configured secret keeps working for an access token lifetime
+	for _, key := range keys {
+		if key.ID == auth.ConfigKeyID(auth.PurposeAccess) {
+			if want := key.CreatedAt.Add(at.auth.Config.AccessTokenTTL); !key.RetiresAt.Valid || !key.RetiresAt.Time.Equal(want) {
+				t.Errorf("configured secret retires at %v, want %v", key.RetiresAt, want)
+			}
+		} else if key.Secret == "" || key.RetiresAt.Valid {
+			t.Errorf("new key %+v has no secret or is retired", key)
+		}
+	}
+
+	// Secrets are only stored encrypted, and the server decrypts them to sign with the new key
+	for _, key := range keys {
+		if !auth.IsKeySecretEncrypted(key.Secret) {
+			t.Errorf("key %s is stored in plaintext", key.ID)
+		}
+	}
+	if err := at.auth.LoadKeys(ctx, at.store); err != nil {
+		t.Fatal(err)
+	}
+	accessToken, _, err := at.auth.GenerateTokenPair(database.GetUserLoginInfoRow{ID: uuid.New(), Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := at.auth.ParseAccessToken(accessToken); err != nil {
+		t.Errorf("token signed with the rotated key: %v", err)
+	}
+
+	// A key stored before secrets were encrypted is encrypted by the next rotation
+	_, err = at.store.CreateSigningKey(ctx, database.CreateSigningKeyParams{
+		ID:          "plaintext",
+		Purpose:     auth.PurposeAccess,
+		Secret:      "plaintext-secret",
+		CreatedAt:   time.Now().UTC(),
+		ActivatesAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating again retires the key from the first rotation, but not the configured secret twice
+	out = at.mustRun("", "rotate-keys", "--purpose", "access", "--yes")
+	if !strings.Contains(out, "Encrypted 1 key(s)") {
+		t.Errorf("got output %q, want the plaintext key encrypted", out)
+	}
+	if keys, _ = at.store.GetSigningKeys(ctx); len(keys) != 4 {
+		t.Errorf("got %d keys after the second rotation, want 4", len(keys))
+	}
+	for _, key := range keys {
+		if !auth.IsKeySecretEncrypted(key.Secret) {
+			t.Errorf("key %s is stored in plaintext after the second rotation", key.ID)
+		}
+	}
+
+	if _, err := at.run("", "rotate-keys", "--purpose", "signing", "--yes"); !errors.Is(err, ErrUsage) {
+		t.Errorf("unknown purpose: got error %v, want a usage error", err)
+	}
+
+	at.auth.Config.KeyEncryptionKey = ""
+	if _, err := at.run("", "rotate-keys", "--yes"); !errors.Is(err, auth.ErrNoKeyEncryptionKey) {
+		t.Errorf("without KEY_ENCRYPTION_KEY: got error %v, want %v", err, auth.ErrNoKeyEncryptionKey)
+	}
+}
+
+func TestAdminUsage(t *testing.T) {
+	at := newAdminTest(t)
+
+	for _, args := range [][]string{
+		{},
+		{"frobnicate"},
+		{"suspend"},
+		{"suspend", "alice", "bob"},
+		{"stats", "--verbose"},
+	} {
+		if _, err := at.run("", args...); !errors.Is(err, ErrUsage) {
+			t.Errorf("ember admin %s: got error %v, want a usage error", strings.Join(args, " "), err)
+		}
+	}
+
+	if out := at.mustRun("", "help"); !strings.Contains(out, "rotate-keys") {
+		t.Errorf("help does not list the commands: %q", out)
+	}
+}
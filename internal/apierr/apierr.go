@@ -0,0 +1,195 @@
+// Package apierr is the error model of the HTTP API. Handlers return an *Error, or any other error,
+// and ErrorHandler turns it into an RFC 7807 problem+json response carrying a stable Code that
+// clients can branch on. Nothing but the Code and the Detail written by the handler reaches the
+// client, the underlying cause is only logged
+package apierr
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+)
+
+// Code identifies a kind of error. Codes are part of the API, so existing ones must never change
+type Code string
+
+const (
+	// The request could not be read: a body that does not parse, or a malformed path parameter
+	MalformedRequest Code = "malformed_request"
+	// The request was read but its values are not acceptable
+	InvalidInput Code = "invalid_input"
+	// One or more fields of the request failed validation, the response lists each of them
+	ValidationFailed Code = "validation_failed"
+	// No usable credentials were sent
+	Unauthenticated Code = "unauthenticated"
+	// The access or refresh token is invalid, expired or revoked
+	InvalidToken Code = "invalid_token"
+	// The credentials are fine but do not allow this
+	Forbidden Code = "forbidden"
+	// The resource does not exist, or is hidden from the requesting user
+	NotFound Code = "not_found"
+	// The route exists but not for this method
+	MethodNotAllowed Code = "method_not_allowed"
+	// The request conflicts with the current state, such as a username that is already taken
+	Conflict Code = "conflict"
+	// The body is larger than the server accepts
+	PayloadTooLarge Code = "payload_too_large"
+	// The body is not in a format the endpoint accepts
+	UnsupportedMediaType Code = "unsupported_media_type"
+	// Something went wrong on the server. Retrying may help
+	Internal Code = "internal"
+	// The server or one of its dependencies is not able to answer right now
+	Unavailable Code = "unavailable"
+)
+
+type entry struct {
+	status int
+	title  string
+}
+
+// The status and title of each code
+var catalog = map[Code]entry{
+	MalformedRequest:     {http.StatusBadRequest, "Malformed request"},
+	InvalidInput:         {http.StatusBadRequest, "Invalid input"},
+	ValidationFailed:     {http.StatusUnprocessableEntity, "Validation failed"},
+	Unauthenticated:      {http.StatusUnauthorized, "Authentication required"},
+	InvalidToken:         {http.StatusUnauthorized, "Invalid token"},
+	Forbidden:            {http.StatusForbidden, "Forbidden"},
+	NotFound:             {http.StatusNotFound, "Not found"},
+	MethodNotAllowed:     {http.StatusMethodNotAllowed, "Method not allowed"},
+	Conflict:             {http.StatusConflict, "Conflict"},
+	PayloadTooLarge:      {http.StatusRequestEntityTooLarge, "Payload too large"},
+	UnsupportedMediaType: {http.StatusUnsupportedMediaType, "Unsupported media type"},
+	Internal:             {http.StatusInternalServerError, "Internal server error"},
+	Unavailable:          {http.StatusServiceUnavailable, "Service unavailable"},
+}
+
+// Status returns the HTTP status a code is answered with
+func (c Code) Status() int {
+	if e, ok := catalog[c]; ok {
+		return e.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Title returns the short, fixed summary of a code
+func (c Code) Title() string {
+	if e, ok := catalog[c]; ok {
+		return e.title
+	}
+	return http.StatusText(c.Status())
+}
+
+// Codes lists every code in the catalog
+func Codes() []Code {
+	codes := make([]Code, 0, len(catalog))
+	for code := range catalog {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// Error is an API error. Detail is shown to the client, Err is the cause and is only logged
+type Error struct {
+	Code   Code
+	Detail string
+	Err    error
+	// The failing fields of a ValidationFailed error
+	Fields []FieldError
+}
+
+// FieldError describes why one field of a request was rejected. Field is named as the client sent
+// it, e.g. "ttl_seconds"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New returns an error with the given code and a detail message for the client
+func New(code Code, detail string) *Error {
+	return &Error{Code: code, Detail: detail}
+}
+
+// Invalid returns a ValidationFailed error listing the given fields
+func Invalid(fields ...FieldError) *Error {
+	return &Error{Code: ValidationFailed, Detail: "One or more fields are invalid", Fields: fields}
+}
+
+// Wrap returns an internal error caused by err. The client only learns that something went wrong
+func Wrap(err error) *Error {
+	return &Error{Code: Internal, Detail: "An internal error occurred", Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Detail + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status the error is answered with
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// From turns any error a handler returns into an *Error. Errors that are not already one are
+// classified by what they are: Fiber's own errors keep their status, a missing row is not found, a
+// unique violation is a conflict and anything unrecognised is internal
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return &Error{Code: codeForStatus(fiberErr.Code), Detail: fiberErr.Message}
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Code: NotFound, Detail: "The requested resource does not exist", Err: err}
+	}
+
+	if IsUniqueViolation(err) {
+		return &Error{Code: Conflict, Detail: "The resource already exists", Err: err}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Code: Unavailable, Detail: "The request timed out", Err: err}
+	}
+
+	return Wrap(err)
+}
+
+// Status returns the HTTP status err will be answered with, for middleware that runs before
+// ErrorHandler has written the response
+func Status(err error) int {
+	return From(err).Status()
+}
+
+// IsUniqueViolation reports whether err is Postgres refusing a duplicate key
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func codeForStatus(status int) Code {
+	for code, e := range catalog {
+		// InvalidInput and InvalidToken share their statuses with more general codes
+		if e.status == status && code != InvalidInput && code != InvalidToken {
+			return code
+		}
+	}
+	if status >= http.StatusInternalServerError {
+		return Internal
+	}
+	return MalformedRequest
+}
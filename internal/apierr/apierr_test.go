@@ -0,0 +1,76 @@
+package apierr
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code Code
+	}{
+		{"api error", New(Forbidden, "no"), Forbidden},
+		{"wrapped api error", fmt.Errorf("handler: %w", New(Conflict, "taken")), Conflict},
+		{"fiber not found", fiber.ErrNotFound, NotFound},
+		{"fiber body limit", fiber.ErrRequestEntityTooLarge, PayloadTooLarge},
+		{"missing row", fmt.Errorf("lookup: %w", sql.ErrNoRows), NotFound},
+		{"unique violation", &pq.Error{Code: "23505"}, Conflict},
+		{"timeout", context.DeadlineExceeded, Unavailable},
+		{"anything else", errors.New("connection reset"), Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := From(tt.err).Code; got != tt.code {
+				t.Errorf("got %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	for _, code := range Codes() {
+		if code.Status() < 400 || code.Title() == "" {
+			t.Errorf("%s: status %d, title %q", code, code.Status(), code.Title())
+		}
+	}
+}
+
+func TestErrorHandlerHidesCause(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/boom", func(c *fiber.Ctx) error {
+		return Wrap(errors.New("pq: password authentication failed for user \"ember\""))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/boom", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusInternalServerError || resp.Header.Get("Content-Type") != ProblemContentType {
+		t.Errorf("got status %d and content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var problem Problem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Code != Internal || problem.Type != TypeURI(Internal) || problem.Instance != "/boom" {
+		t.Errorf("got %+v", problem)
+	}
+	if strings.Contains(problem.Detail, "pq") {
+		t.Errorf("response leaks the cause: %q", problem.Detail)
+	}
+}
@@ -0,0 +1,46 @@
+package apierr
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// The media type of every error response
+const ProblemContentType = "application/problem+json"
+
+// Problem is the RFC 7807 body of an error response, extended with the error code and the ID of the
+// request so a report can be matched with the server's logs
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	// Every failing field, for validation errors
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// TypeURI returns the problem type of a code
+func TypeURI(code Code) string {
+	return "urn:ember:error:" + string(code)
+}
+
+// ErrorHandler is the app's fiber.Config.ErrorHandler. It answers every error returned by a handler
+// or middleware with a problem+json body. The cause is left to the request log, see logging.Middleware
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	apiErr := From(err)
+	status := apiErr.Status()
+
+	problem := Problem{
+		Type:      TypeURI(apiErr.Code),
+		Title:     apiErr.Code.Title(),
+		Status:    status,
+		Detail:    apiErr.Detail,
+		Instance:  c.Path(),
+		Code:      apiErr.Code,
+		RequestID: c.GetRespHeader(fiber.HeaderXRequestID),
+		Errors:    apiErr.Fields,
+	}
+	return c.Status(status).JSON(problem, ProblemContentType)
+}
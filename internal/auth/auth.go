@@ -1,29 +1,56 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/mail"
-	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/config"
 	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/tracing"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtAccessSecret = []byte(os.Getenv("ACCESS_SECRET_KEY"))
-var jwtRefreshSecret = []byte(os.Getenv("REFRESH_SECRET_KEY"))
+// Authenticator hashes passwords and signs and verifies tokens with the configured secrets, lifetimes
+// and bcrypt cost. Once signing keys have been rotated, tokens are signed with the keys in the database
+// instead, see SetKeys
+type Authenticator struct {
+	Config config.Auth
+	keys   atomic.Pointer[[]database.SigningKey]
+}
+
+func NewAuthenticator(cfg config.Auth) *Authenticator {
+	return &Authenticator{Config: cfg}
+}
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// LookupMX resolves the mail servers of a domain when validating emails. Tests replace it so they
+// do not depend on the network
+var LookupMX = net.LookupMX
+
+// Hashing is deliberately slow, so it gets its own span to show how much of a request it takes
+func (a *Authenticator) HashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.GenerateFromPassword", trace.WithAttributes(attribute.Int("bcrypt.cost", a.Config.BcryptCost)))
+	defer span.End()
+
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), a.Config.BcryptCost)
 	return string(bytes), err
 }
 
-func CheckPasswordHash(password string, hash string) bool {
+func CheckPasswordHash(ctx context.Context, password string, hash string) bool {
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.CompareHashAndPassword")
+	defer span.End()
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
@@ -53,7 +80,7 @@ func IsEmailValid(email string) bool {
 	domain := parts[1]
 
 	// Try to find MX records
-	mxRecords, err := net.LookupMX(domain)
+	mxRecords, err := LookupMX(domain)
 	if err != nil || len(mxRecords) == 0 {
 		return false
 	}
@@ -62,30 +89,24 @@ func IsEmailValid(email string) bool {
 }
 
 // Generate an access and refresh token pair for login functionality, return an error if either cannot be generated
-func GenerateTokenPair(user database.GetUserLoginInfoRow) (string, string, error) {
+func (a *Authenticator) GenerateTokenPair(user database.GetUserLoginInfoRow) (string, string, error) {
 
 	// Generate the access token with a short expiration time
-	accessClaims := jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"exp":      time.Now().Add(15 * time.Minute).Unix(),
-	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(jwtAccessSecret)
+	accessTokenString, err := a.signAccessToken(user.ID, user.Username, user.Email)
 	if err != nil {
-		return "", "", fmt.Errorf("could not generate access token")
+		return "", "", err
 	}
 
-	// Generate the refresh token with a longer expiration time
+	// Generate the refresh token with a longer expiration time. The jti keeps two logins within the
+	// same second from producing the same token, which would make one of them look revoked
 	refreshClaims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"username": user.Username,
 		"email":    user.Email,
-		"exp":      time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"exp":      time.Now().Add(a.Config.RefreshTokenTTL).Unix(),
+		"jti":      uuid.NewString(),
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(jwtRefreshSecret)
+	refreshTokenString, err := a.sign(PurposeRefresh, refreshClaims)
 	if err != nil {
 		return "", "", fmt.Errorf("could not generate refresh token")
 	}
@@ -93,60 +114,110 @@ func GenerateTokenPair(user database.GetUserLoginInfoRow) (string, string, error
 	return accessTokenString, refreshTokenString, nil
 }
 
-// Check refreshToken for expiration, if it is valid, generate and return an accessTokenString.
-// If it has expired, invalidate it, otherwise return an error
-func AnalyzeRefreshToken(DB *database.Queries, c *fiber.Ctx, refreshToken string) (string, error) {
+// Check refreshToken for expiration, if it is valid, generate and return an access token. An expired
+// token is invalidated. The errors are *apierr.Error values the handler can return as they are
+func (a *Authenticator) AnalyzeRefreshToken(DB database.Querier, c *fiber.Ctx, refreshToken string) (string, error) {
 	// Query the database to check that the given refreshToken exists within our system
 	dbRefreshToken, err := DB.GetRefreshToken(c.UserContext(), refreshToken)
 	if err != nil {
-		return "", fmt.Errorf("refresh token does not exist")
+		return "", apierr.New(apierr.InvalidInput, "Refresh token does not exist")
 	}
 
 	if !dbRefreshToken.IsValid {
-		return "refresh token is blacklisted, login required", nil
+		return "", apierr.New(apierr.InvalidToken, "Refresh token has been revoked, login required")
 	}
 
-	token, err := jwt.Parse(dbRefreshToken.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtAccessSecret, nil
-	})
-	if err != nil {
-		return "", fmt.Errorf("refresh token cannot be parsed")
-	}
+	// Refresh tokens are signed with their own keys, see GenerateTokenPair
+	token, err := a.parseToken(dbRefreshToken.RefreshToken, PurposeRefresh)
 
-	// Need to check if this condition is the only one where jwt.Parse will return non-valid
-	if !token.Valid {
+	var validationErr *jwt.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
 		// We need to update our database entry for this refresh token to be invalid
 		params := database.UpdateRefreshTokenParams{
 			IsValid:      false,
 			UpdatedAt:    time.Now().UTC(),
 			RefreshToken: dbRefreshToken.RefreshToken,
 		}
-		err := DB.UpdateRefreshToken(c.UserContext(), params)
-		if err != nil {
-			return "", fmt.Errorf("cannot invalidate refresh token")
+		if err := DB.UpdateRefreshToken(c.UserContext(), params); err != nil {
+			return "", apierr.Wrap(err)
 		}
 
-		return "refresh token has expired, login required", nil
+		return "", apierr.New(apierr.InvalidToken, "Refresh token has expired, login required")
+	}
+	if err != nil || !token.Valid {
+		return "", apierr.New(apierr.InvalidInput, "Refresh token cannot be parsed")
+	}
+
+	// Suspending revokes the refresh tokens a user has, this also covers any that escaped the revocation
+	user, err := DB.GetUserByID(c.UserContext(), dbRefreshToken.UserID)
+	if err != nil {
+		return "", apierr.Wrap(err)
+	}
+	if user.SuspendedAt.Valid {
+		return "", apierr.New(apierr.Forbidden, "Account is suspended")
 	}
+
 	// Extract the claims and use them to generate a new access token
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", fmt.Errorf("invalid token claims")
+		return "", apierr.Wrap(fmt.Errorf("invalid token claims"))
+	}
+
+	return a.signAccessToken(claims["user_id"], claims["username"], claims["email"])
+}
+
+// ParseAccessToken verifies an access token and returns its claims
+func (a *Authenticator) ParseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := a.parseToken(tokenString, PurposeAccess)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid or expired access token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
 	}
+	return claims, nil
+}
 
+// Sign a short lived access token for the given user
+func (a *Authenticator) signAccessToken(userID any, username any, email any) (string, error) {
 	accessClaims := jwt.MapClaims{
-		"user_id":  claims["user_id"],
-		"username": claims["username"],
-		"email":    claims["email"],
-		"exp":      time.Now().Add(15 * time.Minute).Unix(),
+		"user_id":  userID,
+		"username": username,
+		"email":    email,
+		"exp":      time.Now().Add(a.Config.AccessTokenTTL).Unix(),
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(jwtAccessSecret)
+	accessTokenString, err := a.sign(PurposeAccess, accessClaims)
 	if err != nil {
 		return "", fmt.Errorf("could not generate access token")
 	}
 	return accessTokenString, nil
 }
+
+// Sign claims with the current key for purpose, naming it in the kid header
+func (a *Authenticator) sign(purpose string, claims jwt.MapClaims) (string, error) {
+	kid, secret := a.signingKey(purpose, time.Now().UTC())
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString([]byte(secret))
+}
+
+// Parse an HMAC signed token with the key for purpose it names, rejecting any other signing method
+func (a *Authenticator) parseToken(tokenString string, purpose string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		secret, err := a.verificationKey(purpose, kid, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		return []byte(secret), nil
+	})
+}
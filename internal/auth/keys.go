@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/lifecycle"
+)
+
+// The purposes a signing key can have, as stored in signing_keys.purpose
+const (
+	PurposeAccess  = "access"
+	PurposeRefresh = "refresh"
+)
+
+// KeyReloadInterval is how often a running server reloads the signing keys. Rotated keys only start
+// signing after every instance has had the chance to load them
+const KeyReloadInterval = 30 * time.Second
+
+// ConfigKeyID is the kid of the signing_keys row standing for the configured secret of a purpose.
+// Tokens without a kid header are verified as if they named it
+func ConfigKeyID(purpose string) string {
+	return "config-" + purpose
+}
+
+// GenerateKeySecret returns a new random secret for a signing key
+func GenerateKeySecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Secrets encrypted by EncryptKeySecret start with this, anything else in signing_keys.secret was stored
+// before secrets were encrypted
+const encryptedSecretPrefix = "enc:v1:"
+
+// ErrNoKeyEncryptionKey is returned when a signing key secret has to be encrypted or decrypted but
+// KEY_ENCRYPTION_KEY is not set
+var ErrNoKeyEncryptionKey = errors.New("KEY_ENCRYPTION_KEY is not set")
+
+// The AES-256-GCM cipher signing key secrets are encrypted with, keyed by a hash of KEY_ENCRYPTION_KEY
+func (a *Authenticator) keyCipher() (cipher.AEAD, error) {
+	if a.Config.KeyEncryptionKey == "" {
+		return nil, ErrNoKeyEncryptionKey
+	}
+	key := sha256.Sum256([]byte(a.Config.KeyEncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptKeySecret encrypts the secret of the signing key keyID for storing in signing_keys. The key id
+// is authenticated with it, so a secret copied to another row does not decrypt
+func (a *Authenticator) EncryptKeySecret(keyID string, secret string) (string, error) {
+	aead, err := a.keyCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), []byte(keyID))
+	return encryptedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Reverse EncryptKeySecret. Secrets stored before encryption was introduced are returned as they are
+func (a *Authenticator) decryptKeySecret(keyID string, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, encryptedSecretPrefix)
+	if !ok {
+		return stored, nil
+	}
+
+	aead, err := a.keyCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("signing key %q has a malformed secret", keyID)
+	}
+	secret, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt signing key %q, is KEY_ENCRYPTION_KEY the one it was encrypted with? %w", keyID, err)
+	}
+	return string(secret), nil
+}
+
+// IsKeySecretEncrypted reports whether a signing_keys.secret was written by EncryptKeySecret. The empty
+// secret of a configured-secret row has nothing to encrypt and counts as encrypted
+func IsKeySecretEncrypted(stored string) bool {
+	return stored == "" || strings.HasPrefix(stored, encryptedSecretPrefix)
+}
+
+// SetKeys replaces the signing keys tokens are signed and verified with, newest first as GetSigningKeys
+// returns them. Without any keys the configured secrets are used, and tokens carry no kid
+func (a *Authenticator) SetKeys(keys []database.SigningKey) {
+	a.keys.Store(&keys)
+}
+
+// LoadKeys reads the signing keys from the database and decrypts their secrets, see SetKeys
+func (a *Authenticator) LoadKeys(ctx context.Context, db database.Querier) error {
+	keys, err := db.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range keys {
+		if !IsKeySecretEncrypted(keys[i].Secret) {
+			slog.WarnContext(ctx, "signing key is stored unencrypted, ember admin rotate-keys encrypts it", "kid", keys[i].ID)
+		}
+		if keys[i].Secret, err = a.decryptKeySecret(keys[i].ID, keys[i].Secret); err != nil {
+			return err
+		}
+	}
+
+	a.SetKeys(keys)
+	return nil
+}
+
+// Return the configured secret for purpose
+func (a *Authenticator) configSecret(purpose string) string {
+	if purpose == PurposeRefresh {
+		return a.Config.RefreshSecret
+	}
+	return a.Config.AccessSecret
+}
+
+// Pick the key to sign a new token with: the newest active key of purpose, or the configured secret
+// with no kid when there is none
+func (a *Authenticator) signingKey(purpose string, now time.Time) (string, string) {
+	if keys := a.keys.Load(); keys != nil {
+		for _, key := range *keys {
+			if key.Purpose != purpose || key.Secret == "" || key.ActivatesAt.After(now) {
+				continue
+			}
+			if key.RetiresAt.Valid && !key.RetiresAt.Time.After(now) {
+				continue
+			}
+			return key.ID, key.Secret
+		}
+	}
+	return "", a.configSecret(purpose)
+}
+
+// Find the secret to verify a token naming kid with. Keys are accepted from the moment they are
+// created, before they start signing, so every instance knows a key by the time any of them uses it
+func (a *Authenticator) verificationKey(purpose string, kid string, now time.Time) (string, error) {
+	if kid == "" {
+		kid = ConfigKeyID(purpose)
+	}
+
+	if keys := a.keys.Load(); keys != nil {
+		for _, key := range *keys {
+			if key.ID != kid || key.Purpose != purpose {
+				continue
+			}
+			if key.RetiresAt.Valid && !key.RetiresAt.Time.After(now) {
+				return "", fmt.Errorf("signing key %q has been retired", kid)
+			}
+			if key.Secret == "" {
+				return a.configSecret(purpose), nil
+			}
+			return key.Secret, nil
+		}
+	}
+
+	// Until the configured secret is rotated out for the first time it has no row
+	if kid == ConfigKeyID(purpose) {
+		return a.configSecret(purpose), nil
+	}
+	return "", fmt.Errorf("unknown signing key %q", kid)
+}
+
+// KeyLoader reloads the signing keys every Interval, so keys rotated with ember admin rotate-keys are
+// picked up without a restart
+type KeyLoader struct {
+	Auth     *Authenticator
+	DB       database.Querier
+	Interval time.Duration
+	// Beats after every successful reload
+	Heartbeat lifecycle.Heartbeat
+}
+
+func NewKeyLoader(authenticator *Authenticator, db database.Querier, interval time.Duration) *KeyLoader {
+	return &KeyLoader{
+		Auth:     authenticator,
+		DB:       db,
+		Interval: interval,
+	}
+}
+
+// Run reloads the keys every Interval until ctx is cancelled
+func (l *KeyLoader) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.Auth.LoadKeys(ctx, l.DB); err != nil {
+			slog.ErrorContext(ctx, "cannot reload signing keys", "error", err)
+		} else {
+			l.Heartbeat.Beat()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
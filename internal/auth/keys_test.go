@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/config"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func newTestAuthenticator() *Authenticator {
+	return NewAuthenticator(config.Auth{
+		AccessSecret:    "test-access-secret",
+		RefreshSecret:   "test-refresh-secret",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+	})
+}
+
+func signTestToken(t *testing.T, a *Authenticator) string {
+	t.Helper()
+
+	token, err := a.signAccessToken(uuid.NewString(), "alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func tokenKeyID(t *testing.T, tokenString string) string {
+	t.Helper()
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func at(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value, Valid: true}
+}
+
+func TestConfiguredSecretWithoutKeys(t *testing.T) {
+	a := newTestAuthenticator()
+
+	token := signTestToken(t, a)
+	if kid := tokenKeyID(t, token); kid != "" {
+		t.Errorf("got kid %q, want none while no keys have been rotated in", kid)
+	}
+	if _, err := a.ParseAccessToken(token); err != nil {
+		t.Errorf("token signed with the configured secret: %v", err)
+	}
+}
+
+func TestRotatedKeys(t *testing.T) {
+	a := newTestAuthenticator()
+	legacy := signTestToken(t, a)
+	now := time.Now().UTC()
+
+	// The state right after a rotation: the configured secret retires in an hour, the new key is
+	// active and a newer one has been created but does not sign yet
+	keys := []database.SigningKey{
+		{ID: "pending", Purpose: PurposeAccess, Secret: "pending-secret", CreatedAt: now, ActivatesAt: now.Add(time.Minute)},
+		{ID: "current", Purpose: PurposeAccess, Secret: "current-secret", CreatedAt: now, ActivatesAt: now.Add(-time.Minute)},
+		{ID: ConfigKeyID(PurposeAccess), Purpose: PurposeAccess, CreatedAt: now, ActivatesAt: now, RetiresAt: at(now.Add(time.Hour))},
+	}
+	a.SetKeys(keys)
+
+	token := signTestToken(t, a)
+	if kid := tokenKeyID(t, token); kid != "current" {
+		t.Errorf("got kid %q, want the newest active key", kid)
+	}
+	if _, err := a.ParseAccessToken(token); err != nil {
+		t.Errorf("token signed with the current key: %v", err)
+	}
+	if _, err := a.ParseAccessToken(legacy); err != nil {
+		t.Errorf("token signed with the configured secret before it retires: %v", err)
+	}
+
+	// Refresh tokens keep using their own configured secret
+	if kid, _ := a.signingKey(PurposeRefresh, now); kid != "" {
+		t.Errorf("got refresh kid %q, want none", kid)
+	}
+
+	keys[2].RetiresAt = at(now.Add(-time.Second))
+	a.SetKeys(keys)
+	if _, err := a.ParseAccessToken(legacy); err == nil {
+		t.Error("token signed with the retired configured secret was accepted")
+	}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": uuid.NewString()})
+	forged.Header["kid"] = "unknown"
+	forgedString, err := forged.SignedString([]byte("current-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ParseAccessToken(forgedString); err == nil {
+		t.Error("token naming an unknown key was accepted")
+	}
+}
+
+func TestKeySecretEncryption(t *testing.T) {
+	a := newTestAuthenticator()
+	a.Config.KeyEncryptionKey = "test-key-encryption-key-of-32-chars"
+
+	stored, err := a.EncryptKeySecret("current", "current-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsKeySecretEncrypted(stored) || strings.Contains(stored, "current-secret") {
+		t.Fatalf("got stored secret %q, want it encrypted", stored)
+	}
+
+	if secret, err := a.decryptKeySecret("current", stored); err != nil || secret != "current-secret" {
+		t.Errorf("decrypting: got %q, %v", secret, err)
+	}
+
+	// The key id is authenticated, so a secret moved to another row does not decrypt
+	if _, err := a.decryptKeySecret("other", stored); err == nil {
+		t.Error("secret decrypted under another key id")
+	}
+
+	// Secrets stored before encryption are used as they are
+	if secret, err := a.decryptKeySecret("legacy", "legacy-secret"); err != nil || secret != "legacy-secret" {
+		t.Errorf("plaintext secret: got %q, %v", secret, err)
+	}
+
+	wrongKey := newTestAuthenticator()
+	wrongKey.Config.KeyEncryptionKey = "another-key-encryption-key-32-chars"
+	if _, err := wrongKey.decryptKeySecret("current", stored); err == nil {
+		t.Error("secret decrypted with the wrong KEY_ENCRYPTION_KEY")
+	}
+
+	if _, err := newTestAuthenticator().decryptKeySecret("current", stored); !errors.Is(err, ErrNoKeyEncryptionKey) {
+		t.Errorf("without KEY_ENCRYPTION_KEY: got error %v, want %v", err, ErrNoKeyEncryptionKey)
+	}
+}
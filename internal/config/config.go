@@ -0,0 +1,273 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds every setting the server reads at startup. Each field can be set in the config file
+// under its yaml/toml key, or through the environment variable in its env tag
+type Config struct {
+	Server   Server   `yaml:"server" toml:"server"`
+	Database Database `yaml:"database" toml:"database"`
+	Auth     Auth     `yaml:"auth" toml:"auth"`
+	Tracing  Tracing  `yaml:"tracing" toml:"tracing"`
+	Log      Log      `yaml:"log" toml:"log"`
+}
+
+type Server struct {
+	Port string `yaml:"port" toml:"port" env:"SERVER_PORT"`
+	// Origins allowed to make cross-origin requests, "*" allows any
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins" env:"CORS_ORIGINS"`
+	// Avatars and other uploads live on the local filesystem under this directory
+	BlobDir string `yaml:"blob_dir" toml:"blob_dir" env:"BLOB_DIR"`
+	// How long in-flight requests and background workers get to finish after SIGINT or SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// How long /readyz reports draining before shutdown starts, so load balancers stop routing here first
+	DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay" env:"DRAIN_DELAY"`
+	// When set, /metrics is served on this port instead of the public one
+	MetricsPort string `yaml:"metrics_port" toml:"metrics_port" env:"METRICS_PORT"`
+}
+
+type Database struct {
+	URL string `yaml:"url" toml:"url" env:"DB_URL"`
+	// How long startup keeps retrying a database that is not reachable yet
+	ConnectTimeout time.Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	// Zero leaves the number of open connections unlimited
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	// Apply pending migrations before serving
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate" env:"AUTO_MIGRATE"`
+}
+
+type Auth struct {
+	AccessSecret    string        `yaml:"access_secret" toml:"access_secret" env:"ACCESS_SECRET_KEY"`
+	RefreshSecret   string        `yaml:"refresh_secret" toml:"refresh_secret" env:"REFRESH_SECRET_KEY"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" toml:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+	BcryptCost      int           `yaml:"bcrypt_cost" toml:"bcrypt_cost" env:"BCRYPT_COST"`
+	// Encrypts the secrets of the signing keys rotated in with ember admin rotate-keys. It is needed
+	// from the first rotation on, and must stay the same for as long as those keys are in use
+	KeyEncryptionKey string `yaml:"key_encryption_key" toml:"key_encryption_key" env:"KEY_ENCRYPTION_KEY"`
+}
+
+type Tracing struct {
+	// Where spans go: none, otlp, stdout or file
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+	// host:port of an OTLP/HTTP collector. Empty falls back to OTEL_EXPORTER_OTLP_ENDPOINT, then localhost:4318
+	OTLPEndpoint string `yaml:"otlp_endpoint" toml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" toml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+	// The file exporter appends one JSON span per line here
+	File string `yaml:"file" toml:"file" env:"TRACING_FILE"`
+	// Fraction of new traces that are sampled. Requests arriving with a trace context follow the caller
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+	ServiceName string  `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME"`
+}
+
+type Log struct {
+	// debug, info, warn or error
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	// json for log collectors, text for reading in a terminal
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+}
+
+// Default returns the settings used for anything the file and environment leave unset
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			CORSOrigins:     []string{"*"},
+			BlobDir:         "data/blobs",
+			ShutdownTimeout: 30 * time.Second,
+			DrainDelay:      5 * time.Second,
+		},
+		Database: Database{
+			ConnectTimeout:  30 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Auth: Auth{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+			BcryptCost:      14,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "ember",
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// Validate reports every invalid setting at once, naming the environment variable for each so the
+// operator knows what to fix
+func (c *Config) Validate() error {
+	var p problems
+	c.validateServer(&p)
+	c.validateBlobDir(&p)
+	c.validateDatabase(&p)
+	c.validateSecrets(&p)
+	c.validateAuth(&p)
+	c.validateTracing(&p)
+	c.validateLog(&p)
+	return p.err()
+}
+
+// ValidateCommand is Validate for the ember migrate and ember admin subcommands. They never listen or
+// sign tokens with the configured secrets, so those settings are not checked and may be left unset
+func (c *Config) ValidateCommand() error {
+	var p problems
+	c.validateBlobDir(&p)
+	c.validateDatabase(&p)
+	c.validateAuth(&p)
+	c.validateTracing(&p)
+	c.validateLog(&p)
+	return p.err()
+}
+
+// The invalid settings found so far
+type problems []error
+
+func (p *problems) add(format string, args ...any) {
+	*p = append(*p, fmt.Errorf(format, args...))
+}
+
+func (p problems) err() error {
+	if len(p) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(p...))
+	}
+	return nil
+}
+
+func (c *Config) validateServer(p *problems) {
+	if c.Server.Port == "" {
+		p.add("SERVER_PORT is required")
+	} else if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		p.add("SERVER_PORT must be a port number, got %q", c.Server.Port)
+	}
+
+	if c.Server.MetricsPort != "" {
+		if port, err := strconv.Atoi(c.Server.MetricsPort); err != nil || port < 1 || port > 65535 {
+			p.add("METRICS_PORT must be a port number, got %q", c.Server.MetricsPort)
+		} else if c.Server.MetricsPort == c.Server.Port {
+			p.add("METRICS_PORT must differ from SERVER_PORT")
+		}
+	}
+
+	for _, origin := range c.Server.CORSOrigins {
+		if origin == "*" {
+			if len(c.Server.CORSOrigins) > 1 {
+				p.add("CORS_ORIGINS cannot mix \"*\" with other origins")
+			}
+			continue
+		}
+		if parsed, err := url.Parse(origin); err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Path != "" {
+			p.add("CORS_ORIGINS entries must look like https://example.com, got %q", origin)
+		}
+	}
+
+	if c.Server.ShutdownTimeout <= 0 {
+		p.add("SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.Server.DrainDelay < 0 {
+		p.add("DRAIN_DELAY cannot be negative")
+	}
+}
+
+func (c *Config) validateBlobDir(p *problems) {
+	if c.Server.BlobDir == "" {
+		p.add("BLOB_DIR cannot be empty")
+	}
+}
+
+func (c *Config) validateDatabase(p *problems) {
+	if c.Database.URL == "" {
+		p.add("DB_URL is required")
+	}
+	if c.Database.ConnectTimeout <= 0 {
+		p.add("DB_CONNECT_TIMEOUT must be positive")
+	}
+	if c.Database.MaxOpenConns < 0 {
+		p.add("DB_MAX_OPEN_CONNS cannot be negative")
+	}
+	if c.Database.MaxIdleConns < 0 {
+		p.add("DB_MAX_IDLE_CONNS cannot be negative")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		p.add("DB_MAX_IDLE_CONNS (%d) cannot exceed DB_MAX_OPEN_CONNS (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		p.add("DB_CONN_MAX_LIFETIME cannot be negative")
+	}
+}
+
+func (c *Config) validateSecrets(p *problems) {
+	// An empty secret would sign tokens with an empty key, and a shared one would let a refresh token
+	// pass as an access token
+	if c.Auth.AccessSecret == "" {
+		p.add("ACCESS_SECRET_KEY is required")
+	}
+	if c.Auth.RefreshSecret == "" {
+		p.add("REFRESH_SECRET_KEY is required")
+	}
+	if c.Auth.AccessSecret != "" && c.Auth.AccessSecret == c.Auth.RefreshSecret {
+		p.add("ACCESS_SECRET_KEY and REFRESH_SECRET_KEY must be different")
+	}
+}
+
+// KEY_ENCRYPTION_KEY is hashed into an AES-256 key, so anything shorter only makes it easier to guess
+const minKeyEncryptionKeyLength = 32
+
+func (c *Config) validateAuth(p *problems) {
+	if c.Auth.AccessTokenTTL <= 0 {
+		p.add("ACCESS_TOKEN_TTL must be positive")
+	}
+	if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		p.add("REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL")
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		p.add("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.Auth.BcryptCost)
+	}
+	if c.Auth.KeyEncryptionKey != "" && len(c.Auth.KeyEncryptionKey) < minKeyEncryptionKeyLength {
+		p.add("KEY_ENCRYPTION_KEY must be at least %d characters", minKeyEncryptionKeyLength)
+	}
+}
+
+func (c *Config) validateTracing(p *problems) {
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	case "file":
+		if c.Tracing.File == "" {
+			p.add("TRACING_FILE is required when TRACING_EXPORTER is file")
+		}
+	default:
+		p.add("TRACING_EXPORTER must be none, otlp, stdout or file, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		p.add("TRACING_SAMPLE_RATIO must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+	if c.Tracing.ServiceName == "" {
+		p.add("TRACING_SERVICE_NAME cannot be empty")
+	}
+}
+
+func (c *Config) validateLog(p *problems) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		p.add("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		p.add("LOG_FORMAT must be json or text, got %q", c.Log.Format)
+	}
+}
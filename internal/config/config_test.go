@@ -0,0 +1,176 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/config"
+)
+
+// Run Load from an empty directory holding the given files, with only the given variables set
+func load(t *testing.T, files map[string]string, env map[string]string) (*config.Config, error) {
+	t.Helper()
+	return loadWith(t, config.Load, files, env)
+}
+
+// Like load, but with loader in place of Load
+func loadWith(t *testing.T, loader func() (*config.Config, error), files map[string]string, env map[string]string) (*config.Config, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	for _, key := range []string{config.EnvFile, "SERVER_PORT", "DB_URL", "ACCESS_SECRET_KEY", "REFRESH_SECRET_KEY", "ACCESS_TOKEN_TTL", "REFRESH_TOKEN_TTL", "BCRYPT_COST", "KEY_ENCRYPTION_KEY", "CORS_ORIGINS", "BLOB_DIR", "DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "AUTO_MIGRATE", "SHUTDOWN_TIMEOUT", "DRAIN_DELAY", "DB_CONNECT_TIMEOUT", "METRICS_PORT", "TRACING_EXPORTER", "TRACING_FILE", "TRACING_SAMPLE_RATIO", "LOG_LEVEL", "LOG_FORMAT"} {
+		value, ok := env[key]
+		t.Setenv(key, value)
+		if !ok {
+			os.Unsetenv(key)
+		}
+	}
+
+	return loader()
+}
+
+var required = map[string]string{
+	"SERVER_PORT":        "8080",
+	"DB_URL":             "postgres://localhost/ember",
+	"ACCESS_SECRET_KEY":  "access",
+	"REFRESH_SECRET_KEY": "refresh",
+}
+
+func with(base map[string]string, extra map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range extra {
+		merged[key] = value
+	}
+	return merged
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(t, nil, required)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Auth.AccessTokenTTL != 15*time.Minute || cfg.Auth.BcryptCost != 14 || cfg.Server.BlobDir != "data/blobs" || cfg.Server.CORSOrigins[0] != "*" {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	files := map[string]string{
+		"ember.yaml": "server:\n  port: \"7000\"\n  blob_dir: /from/file\nauth:\n  access_token_ttl: 5m\n  bcrypt_cost: 10\n",
+		".env":       "BLOB_DIR=/from/dotenv\nBCRYPT_COST=11\n",
+	}
+	env := with(required, map[string]string{config.EnvFile: "ember.yaml", "BCRYPT_COST": "12"})
+	delete(env, "SERVER_PORT")
+
+	cfg, err := load(t, files, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file beats the defaults, .env beats the file and the environment beats .env
+	if cfg.Server.Port != "7000" || cfg.Auth.AccessTokenTTL != 5*time.Minute {
+		t.Errorf("file settings: got %+v", cfg)
+	}
+	if cfg.Server.BlobDir != "/from/dotenv" {
+		t.Errorf("BLOB_DIR: got %q, want the .env value", cfg.Server.BlobDir)
+	}
+	if cfg.Auth.BcryptCost != 12 {
+		t.Errorf("BCRYPT_COST: got %d, want the environment value", cfg.Auth.BcryptCost)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	files := map[string]string{
+		"ember.toml": "[database]\nmax_open_conns = 50\nconn_max_lifetime = \"1h\"\n\n[server]\ncors_origins = [\"https://a.example\", \"https://b.example\"]\n",
+	}
+
+	cfg, err := load(t, files, with(required, map[string]string{config.EnvFile: "ember.toml"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Database.MaxOpenConns != 50 || cfg.Database.ConnMaxLifetime != time.Hour || len(cfg.Server.CORSOrigins) != 2 {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestLoadRejectsUnknownSettings(t *testing.T) {
+	for name, content := range map[string]string{
+		"ember.yaml": "auth:\n  acess_secret: typo\n",
+		"ember.toml": "[auth]\nacess_secret = \"typo\"\n",
+	} {
+		_, err := load(t, map[string]string{name: content}, with(required, map[string]string{config.EnvFile: name}))
+		if err == nil || !strings.Contains(err.Error(), "acess_secret") {
+			t.Errorf("%s: got %v, want an error naming the unknown setting", name, err)
+		}
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	_, err := load(t, nil, map[string]string{
+		"SERVER_PORT":        "http",
+		"ACCESS_SECRET_KEY":  "same",
+		"REFRESH_SECRET_KEY": "same",
+		"BCRYPT_COST":        "99",
+		"KEY_ENCRYPTION_KEY": "too short",
+		"CORS_ORIGINS":       "*, https://example.com",
+		"LOG_LEVEL":          "verbose",
+	})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	for _, want := range []string{"SERVER_PORT", "DB_URL is required", "must be different", "BCRYPT_COST", "KEY_ENCRYPTION_KEY", "CORS_ORIGINS", "LOG_LEVEL"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedValues(t *testing.T) {
+	_, err := load(t, nil, with(required, map[string]string{"ACCESS_TOKEN_TTL": "fifteen minutes"}))
+	if err == nil || !strings.Contains(err.Error(), "ACCESS_TOKEN_TTL") {
+		t.Errorf("got %v, want an error naming ACCESS_TOKEN_TTL", err)
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	env := map[string]string{"DB_URL": "postgres://localhost/ember"}
+
+	// The subcommands neither listen nor sign tokens with the configured secrets
+	if _, err := loadWith(t, config.Load, nil, env); err == nil {
+		t.Error("Load: got no error without the server settings")
+	}
+	cfg, err := loadWith(t, config.LoadCommand, nil, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.URL != env["DB_URL"] {
+		t.Errorf("DB_URL: got %q", cfg.Database.URL)
+	}
+
+	// What they do use is still checked
+	_, err = loadWith(t, config.LoadCommand, nil, map[string]string{"BCRYPT_COST": "99"})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	for _, want := range []string{"DB_URL is required", "BCRYPT_COST"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
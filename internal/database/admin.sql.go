@@ -0,0 +1,74 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: admin.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const getDatabaseSize = `-- name: GetDatabaseSize :one
+SELECT pg_database_size(current_database())::BIGINT AS size
+`
+
+func (q *Queries) GetDatabaseSize(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getDatabaseSize)
+	var size int64
+	err := row.Scan(&size)
+	return size, err
+}
+
+const getStorageStats = `-- name: GetStorageStats :one
+SELECT
+    (SELECT COUNT(*) FROM users) AS users,
+    (SELECT COUNT(*) FROM users WHERE users.suspended_at IS NOT NULL) AS suspended_users,
+    (SELECT COUNT(*) FROM users WHERE users.is_admin = true) AS admins,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = false AND messages.delivered_at IS NOT NULL) AS delivered_messages,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = false AND messages.delivered_at IS NULL) AS scheduled_messages,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = false AND messages.expires_at <= $1) AS expired_messages,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = true) AS deleted_messages,
+    (SELECT COUNT(*) FROM refresh_tokens WHERE refresh_tokens.is_valid = true) AS valid_refresh_tokens,
+    (SELECT COUNT(*) FROM contacts) AS contacts,
+    (SELECT COUNT(*) FROM blocks) AS blocks,
+    (SELECT COUNT(*) FROM webhooks) AS webhooks,
+    (SELECT COUNT(*) FROM outbox WHERE outbox.processed_at IS NULL) AS pending_events
+`
+
+type GetStorageStatsRow struct {
+	Users              int64
+	SuspendedUsers     int64
+	Admins             int64
+	DeliveredMessages  int64
+	ScheduledMessages  int64
+	ExpiredMessages    int64
+	DeletedMessages    int64
+	ValidRefreshTokens int64
+	Contacts           int64
+	Blocks             int64
+	Webhooks           int64
+	PendingEvents      int64
+}
+
+// Row counts for ember admin stats. Expired messages are the ones the reaper has not got to yet
+func (q *Queries) GetStorageStats(ctx context.Context, now sql.NullTime) (GetStorageStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getStorageStats, now)
+	var i GetStorageStatsRow
+	err := row.Scan(
+		&i.Users,
+		&i.SuspendedUsers,
+		&i.Admins,
+		&i.DeliveredMessages,
+		&i.ScheduledMessages,
+		&i.ExpiredMessages,
+		&i.DeletedMessages,
+		&i.ValidRefreshTokens,
+		&i.Contacts,
+		&i.Blocks,
+		&i.Webhooks,
+		&i.PendingEvents,
+	)
+	return i, err
+}
@@ -0,0 +1,89 @@
+package database_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestGetStorageStats(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	createMessage(t, store, alice, bob, "delivered", now())
+	scheduleMessage(t, store, alice, bob, "scheduled", now().Add(time.Hour))
+	deleteMessage(t, store, createMessage(t, store, bob, alice, "deleted", now()))
+
+	_, err := store.CreateMessage(ctx, database.CreateMessageParams{
+		ID:          uuid.New(),
+		SenderID:    alice.ID,
+		RecipientID: bob.ID,
+		Content:     "expired",
+		CreatedAt:   now(),
+		ExpiresAt:   validTime(now().Add(-time.Minute)),
+		DeliveredAt: validTime(now()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := store.GetStorageStats(ctx, sql.NullTime{Time: now(), Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := database.GetStorageStatsRow{
+		Users:             2,
+		DeliveredMessages: 2,
+		ScheduledMessages: 1,
+		ExpiredMessages:   1,
+		DeletedMessages:   1,
+	}
+	if stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+
+	if size, err := store.GetDatabaseSize(ctx); err != nil || size <= 0 {
+		t.Errorf("GetDatabaseSize: got %d, %v", size, err)
+	}
+}
+
+func TestSigningKeys(t *testing.T) {
+	store := newStore(t)
+
+	for i, id := range []string{"old", "new"} {
+		_, err := store.CreateSigningKey(ctx, database.CreateSigningKeyParams{
+			ID:          id,
+			Purpose:     "access",
+			Secret:      id + "-secret",
+			CreatedAt:   now(),
+			ActivatesAt: now().Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := store.CreateSigningKey(ctx, database.CreateSigningKeyParams{ID: "bad", Purpose: "signing", CreatedAt: now(), ActivatesAt: now()})
+	assertPQCode(t, err, "23514")
+
+	keys, err := store.GetSigningKeys(ctx)
+	if err != nil || len(keys) != 2 || keys[0].ID != "new" {
+		t.Fatalf("GetSigningKeys: got %+v, %v, want the newest first", keys, err)
+	}
+
+	retired, err := store.RetireSigningKeys(ctx, database.RetireSigningKeysParams{RetiresAt: validTime(now()), Purpose: "access"})
+	if err != nil || retired != 2 {
+		t.Errorf("RetireSigningKeys: got %d, %v", retired, err)
+	}
+
+	// Keys that already retire keep their time
+	retired, err = store.RetireSigningKeys(ctx, database.RetireSigningKeysParams{RetiresAt: validTime(now().Add(time.Hour)), Purpose: "access"})
+	if err != nil || retired != 0 {
+		t.Errorf("retiring again: got %d, %v", retired, err)
+	}
+}
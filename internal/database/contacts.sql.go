@@ -0,0 +1,397 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: contacts.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const acceptContactRequest = `-- name: AcceptContactRequest :execrows
+UPDATE contacts SET status = 'accepted', updated_at = $1
+WHERE requester_id = $2 AND addressee_id = $3 AND status = 'pending'
+`
+
+type AcceptContactRequestParams struct {
+	UpdatedAt   time.Time
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+}
+
+func (q *Queries) AcceptContactRequest(ctx context.Context, arg AcceptContactRequestParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acceptContactRequest, arg.UpdatedAt, arg.RequesterID, arg.AddresseeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const areContacts = `-- name: AreContacts :one
+SELECT EXISTS (
+    SELECT 1 FROM contacts
+    WHERE ((requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1))
+    AND status = 'accepted'
+)
+`
+
+type AreContactsParams struct {
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+}
+
+func (q *Queries) AreContacts(ctx context.Context, arg AreContactsParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, areContacts, arg.RequesterID, arg.AddresseeID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const createBlock = `-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type CreateBlockParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateBlock(ctx context.Context, arg CreateBlockParams) error {
+	_, err := q.db.ExecContext(ctx, createBlock, arg.BlockerID, arg.BlockedID, arg.CreatedAt)
+	return err
+}
+
+const createContactRequest = `-- name: CreateContactRequest :one
+INSERT INTO contacts (requester_id, addressee_id, status, created_at, updated_at)
+VALUES ($1, $2, 'pending', $3, $3)
+RETURNING requester_id, addressee_id, status, created_at, updated_at
+`
+
+type CreateContactRequestParams struct {
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+	CreatedAt   time.Time
+}
+
+func (q *Queries) CreateContactRequest(ctx context.Context, arg CreateContactRequestParams) (Contact, error) {
+	row := q.db.QueryRowContext(ctx, createContactRequest, arg.RequesterID, arg.AddresseeID, arg.CreatedAt)
+	var i Contact
+	err := row.Scan(
+		&i.RequesterID,
+		&i.AddresseeID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const declineContactRequest = `-- name: DeclineContactRequest :execrows
+DELETE FROM contacts
+WHERE requester_id = $1 AND addressee_id = $2 AND status = 'pending'
+`
+
+type DeclineContactRequestParams struct {
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+}
+
+func (q *Queries) DeclineContactRequest(ctx context.Context, arg DeclineContactRequestParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, declineContactRequest, arg.RequesterID, arg.AddresseeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteBlock = `-- name: DeleteBlock :execrows
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2
+`
+
+type DeleteBlockParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) DeleteBlock(ctx context.Context, arg DeleteBlockParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteBlock, arg.BlockerID, arg.BlockedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteContact = `-- name: DeleteContact :execrows
+DELETE FROM contacts
+WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1)
+`
+
+type DeleteContactParams struct {
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+}
+
+func (q *Queries) DeleteContact(ctx context.Context, arg DeleteContactParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteContact, arg.RequesterID, arg.AddresseeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getBlockedUsers = `-- name: GetBlockedUsers :many
+SELECT users.id, users.username, blocks.created_at
+FROM blocks
+JOIN users ON users.id = blocks.blocked_id
+WHERE blocks.blocker_id = $1
+ORDER BY blocks.created_at DESC
+`
+
+type GetBlockedUsersRow struct {
+	ID        uuid.UUID
+	Username  string
+	CreatedAt time.Time
+}
+
+func (q *Queries) GetBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]GetBlockedUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBlockedUsers, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBlockedUsersRow
+	for rows.Next() {
+		var i GetBlockedUsersRow
+		if err := rows.Scan(&i.ID, &i.Username, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getContactBetween = `-- name: GetContactBetween :one
+SELECT requester_id, addressee_id, status, created_at, updated_at FROM contacts
+WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1)
+`
+
+type GetContactBetweenParams struct {
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+}
+
+func (q *Queries) GetContactBetween(ctx context.Context, arg GetContactBetweenParams) (Contact, error) {
+	row := q.db.QueryRowContext(ctx, getContactBetween, arg.RequesterID, arg.AddresseeID)
+	var i Contact
+	err := row.Scan(
+		&i.RequesterID,
+		&i.AddresseeID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getContacts = `-- name: GetContacts :many
+SELECT users.id, users.username, contacts.status, contacts.created_at, contacts.updated_at
+FROM contacts
+JOIN users ON users.id = (CASE WHEN contacts.requester_id = $1 THEN contacts.addressee_id ELSE contacts.requester_id END)
+WHERE (contacts.requester_id = $1 OR contacts.addressee_id = $1) AND contacts.status = 'accepted'
+ORDER BY users.username
+`
+
+type GetContactsRow struct {
+	ID        uuid.UUID
+	Username  string
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (q *Queries) GetContacts(ctx context.Context, requesterID uuid.UUID) ([]GetContactsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getContacts, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetContactsRow
+	for rows.Next() {
+		var i GetContactsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getContactsPresenceInfo = `-- name: GetContactsPresenceInfo :many
+SELECT users.id, users.username, users.hide_last_seen
+FROM contacts
+JOIN users ON users.id = (CASE WHEN contacts.requester_id = $1 THEN contacts.addressee_id ELSE contacts.requester_id END)
+WHERE (contacts.requester_id = $1 OR contacts.addressee_id = $1) AND contacts.status = 'accepted'
+ORDER BY users.username
+`
+
+type GetContactsPresenceInfoRow struct {
+	ID           uuid.UUID
+	Username     string
+	HideLastSeen bool
+}
+
+func (q *Queries) GetContactsPresenceInfo(ctx context.Context, requesterID uuid.UUID) ([]GetContactsPresenceInfoRow, error) {
+	rows, err := q.db.QueryContext(ctx, getContactsPresenceInfo, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetContactsPresenceInfoRow
+	for rows.Next() {
+		var i GetContactsPresenceInfoRow
+		if err := rows.Scan(&i.ID, &i.Username, &i.HideLastSeen); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getIncomingContactRequests = `-- name: GetIncomingContactRequests :many
+SELECT users.id, users.username, contacts.status, contacts.created_at, contacts.updated_at
+FROM contacts
+JOIN users ON users.id = contacts.requester_id
+WHERE contacts.addressee_id = $1 AND contacts.status = 'pending'
+ORDER BY contacts.created_at DESC
+`
+
+type GetIncomingContactRequestsRow struct {
+	ID        uuid.UUID
+	Username  string
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (q *Queries) GetIncomingContactRequests(ctx context.Context, addresseeID uuid.UUID) ([]GetIncomingContactRequestsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getIncomingContactRequests, addresseeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetIncomingContactRequestsRow
+	for rows.Next() {
+		var i GetIncomingContactRequestsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOutgoingContactRequests = `-- name: GetOutgoingContactRequests :many
+SELECT users.id, users.username, contacts.status, contacts.created_at, contacts.updated_at
+FROM contacts
+JOIN users ON users.id = contacts.addressee_id
+WHERE contacts.requester_id = $1 AND contacts.status = 'pending'
+ORDER BY contacts.created_at DESC
+`
+
+type GetOutgoingContactRequestsRow struct {
+	ID        uuid.UUID
+	Username  string
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (q *Queries) GetOutgoingContactRequests(ctx context.Context, requesterID uuid.UUID) ([]GetOutgoingContactRequestsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOutgoingContactRequests, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOutgoingContactRequestsRow
+	for rows.Next() {
+		var i GetOutgoingContactRequestsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isBlocked = `-- name: IsBlocked :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2
+)
+`
+
+type IsBlockedParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) IsBlocked(ctx context.Context, arg IsBlockedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isBlocked, arg.BlockerID, arg.BlockedID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,192 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+)
+
+func TestContactRequests(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	carol := createUser(t, store, "carol")
+
+	requestedAt := now()
+	contact, err := store.CreateContactRequest(ctx, database.CreateContactRequestParams{RequesterID: alice.ID, AddresseeID: bob.ID, CreatedAt: requestedAt})
+	if err != nil || contact.Status != "pending" || !contact.UpdatedAt.Equal(requestedAt) {
+		t.Fatalf("CreateContactRequest: got %+v, %v", contact, err)
+	}
+
+	_, err = store.CreateContactRequest(ctx, database.CreateContactRequestParams{RequesterID: carol.ID, AddresseeID: bob.ID, CreatedAt: requestedAt.Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.CreateContactRequest(ctx, database.CreateContactRequestParams{RequesterID: alice.ID, AddresseeID: bob.ID, CreatedAt: now()})
+	assertPQCode(t, err, "23505")
+
+	// Only one row per pair, whichever way round it was requested
+	_, err = store.CreateContactRequest(ctx, database.CreateContactRequestParams{RequesterID: bob.ID, AddresseeID: alice.ID, CreatedAt: now()})
+	assertPQCode(t, err, "23505")
+
+	_, err = store.CreateContactRequest(ctx, database.CreateContactRequestParams{RequesterID: alice.ID, AddresseeID: alice.ID, CreatedAt: now()})
+	assertPQCode(t, err, "23514")
+
+	// The pair is found from either side
+	between, err := store.GetContactBetween(ctx, database.GetContactBetweenParams{RequesterID: bob.ID, AddresseeID: alice.ID})
+	if err != nil || between.RequesterID != alice.ID {
+		t.Errorf("GetContactBetween: got %+v, %v", between, err)
+	}
+
+	incoming, err := store.GetIncomingContactRequests(ctx, bob.ID)
+	if err != nil || len(incoming) != 2 || incoming[0].Username != "carol" || incoming[1].Username != "alice" {
+		t.Errorf("GetIncomingContactRequests: got %+v, %v", incoming, err)
+	}
+
+	outgoing, err := store.GetOutgoingContactRequests(ctx, alice.ID)
+	if err != nil || len(outgoing) != 1 || outgoing[0].ID != bob.ID {
+		t.Errorf("GetOutgoingContactRequests: got %+v, %v", outgoing, err)
+	}
+
+	areContacts, err := store.AreContacts(ctx, database.AreContactsParams{RequesterID: alice.ID, AddresseeID: bob.ID})
+	if err != nil || areContacts {
+		t.Errorf("AreContacts before accepting: got %v, %v", areContacts, err)
+	}
+
+	// Only the addressee side of a pending request can accept it
+	accepted, err := store.AcceptContactRequest(ctx, database.AcceptContactRequestParams{UpdatedAt: now(), RequesterID: bob.ID, AddresseeID: alice.ID})
+	if err != nil || accepted != 0 {
+		t.Errorf("accepting in the wrong direction: got %d, %v", accepted, err)
+	}
+
+	accepted, err = store.AcceptContactRequest(ctx, database.AcceptContactRequestParams{UpdatedAt: now(), RequesterID: alice.ID, AddresseeID: bob.ID})
+	if err != nil || accepted != 1 {
+		t.Errorf("AcceptContactRequest: got %d, %v", accepted, err)
+	}
+
+	areContacts, err = store.AreContacts(ctx, database.AreContactsParams{RequesterID: bob.ID, AddresseeID: alice.ID})
+	if err != nil || !areContacts {
+		t.Errorf("AreContacts after accepting: got %v, %v", areContacts, err)
+	}
+
+	declined, err := store.DeclineContactRequest(ctx, database.DeclineContactRequestParams{RequesterID: alice.ID, AddresseeID: bob.ID})
+	if err != nil || declined != 0 {
+		t.Errorf("declining an accepted contact: got %d, %v", declined, err)
+	}
+
+	declined, err = store.DeclineContactRequest(ctx, database.DeclineContactRequestParams{RequesterID: carol.ID, AddresseeID: bob.ID})
+	if err != nil || declined != 1 {
+		t.Errorf("DeclineContactRequest: got %d, %v", declined, err)
+	}
+
+	if _, err := store.GetContactBetween(ctx, database.GetContactBetweenParams{RequesterID: carol.ID, AddresseeID: bob.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("declined request still exists (err %v)", err)
+	}
+}
+
+func TestContactLists(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	carol := createUser(t, store, "carol")
+	dave := createUser(t, store, "dave")
+
+	befriend := func(requester, addressee database.User) {
+		t.Helper()
+
+		if _, err := store.CreateContactRequest(ctx, database.CreateContactRequestParams{RequesterID: requester.ID, AddresseeID: addressee.ID, CreatedAt: now()}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.AcceptContactRequest(ctx, database.AcceptContactRequestParams{UpdatedAt: now(), RequesterID: requester.ID, AddresseeID: addressee.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	befriend(alice, carol)
+	befriend(bob, alice)
+	if _, err := store.CreateContactRequest(ctx, database.CreateContactRequestParams{RequesterID: alice.ID, AddresseeID: dave.ID, CreatedAt: now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.UpdateUserPrivacySettings(ctx, database.UpdateUserPrivacySettingsParams{
+		HideLastSeen: sql.NullBool{Bool: true, Valid: true},
+		UpdatedAt:    now(),
+		ID:           carol.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Accepted contacts only, from either side, ordered by username
+	contacts, err := store.GetContacts(ctx, alice.ID)
+	if err != nil || len(contacts) != 2 || contacts[0].Username != "bob" || contacts[1].Username != "carol" {
+		t.Errorf("GetContacts: got %+v, %v", contacts, err)
+	}
+
+	presenceInfo, err := store.GetContactsPresenceInfo(ctx, alice.ID)
+	if err != nil || len(presenceInfo) != 2 || presenceInfo[0].HideLastSeen || !presenceInfo[1].HideLastSeen {
+		t.Errorf("GetContactsPresenceInfo: got %+v, %v", presenceInfo, err)
+	}
+
+	deleted, err := store.DeleteContact(ctx, database.DeleteContactParams{RequesterID: carol.ID, AddresseeID: alice.ID})
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteContact: got %d, %v", deleted, err)
+	}
+
+	contacts, err = store.GetContacts(ctx, alice.ID)
+	if err != nil || len(contacts) != 1 || contacts[0].ID != bob.ID {
+		t.Errorf("GetContacts after deleting carol: got %+v, %v", contacts, err)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	carol := createUser(t, store, "carol")
+
+	blockedAt := now()
+	if err := store.CreateBlock(ctx, database.CreateBlockParams{BlockerID: alice.ID, BlockedID: bob.ID, CreatedAt: blockedAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateBlock(ctx, database.CreateBlockParams{BlockerID: alice.ID, BlockedID: carol.ID, CreatedAt: blockedAt.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocking twice is a no-op
+	if err := store.CreateBlock(ctx, database.CreateBlockParams{BlockerID: alice.ID, BlockedID: bob.ID, CreatedAt: now()}); err != nil {
+		t.Errorf("blocking twice: %v", err)
+	}
+
+	isBlocked, err := store.IsBlocked(ctx, database.IsBlockedParams{BlockerID: alice.ID, BlockedID: bob.ID})
+	if err != nil || !isBlocked {
+		t.Errorf("IsBlocked: got %v, %v", isBlocked, err)
+	}
+
+	// Blocks are one-directional
+	isBlocked, err = store.IsBlocked(ctx, database.IsBlockedParams{BlockerID: bob.ID, BlockedID: alice.ID})
+	if err != nil || isBlocked {
+		t.Errorf("IsBlocked in reverse: got %v, %v", isBlocked, err)
+	}
+
+	blocked, err := store.GetBlockedUsers(ctx, alice.ID)
+	if err != nil || len(blocked) != 2 || blocked[0].Username != "carol" || !blocked[1].CreatedAt.Equal(blockedAt) {
+		t.Errorf("GetBlockedUsers: got %+v, %v", blocked, err)
+	}
+
+	unblocked, err := store.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: alice.ID, BlockedID: bob.ID})
+	if err != nil || unblocked != 1 {
+		t.Errorf("DeleteBlock: got %d, %v", unblocked, err)
+	}
+
+	isBlocked, err = store.IsBlocked(ctx, database.IsBlockedParams{BlockerID: alice.ID, BlockedID: bob.ID})
+	if err != nil || isBlocked {
+		t.Errorf("IsBlocked after unblocking: got %v, %v", isBlocked, err)
+	}
+
+	err = store.CreateBlock(ctx, database.CreateBlockParams{BlockerID: alice.ID, BlockedID: alice.ID, CreatedAt: now()})
+	assertPQCode(t, err, "23514")
+}
@@ -0,0 +1,168 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/dbtest"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ctx = context.Background()
+
+// A migrated schema of the test's own, see dbtest.New
+func newStore(t *testing.T) *database.SQLStore {
+	t.Helper()
+	t.Parallel()
+
+	return database.NewStore(dbtest.New(t))
+}
+
+// The current time at the precision Postgres stores, so values survive a round trip unchanged
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func validTime(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value, Valid: true}
+}
+
+func createUser(t *testing.T, q database.Querier, username string) database.User {
+	t.Helper()
+
+	user, err := q.CreateUser(ctx, database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UpdatedAt: now(),
+		Username:  username,
+		Email:     username + "@example.com",
+		Password:  "hash",
+	})
+	if err != nil {
+		t.Fatalf("creating user %s: %v", username, err)
+	}
+	return user
+}
+
+// A delivered message from sender to recipient, created at createdAt
+func createMessage(t *testing.T, q database.Querier, sender database.User, recipient database.User, content string, createdAt time.Time) database.Message {
+	t.Helper()
+
+	message, err := q.CreateMessage(ctx, database.CreateMessageParams{
+		ID:          uuid.New(),
+		SenderID:    sender.ID,
+		RecipientID: recipient.ID,
+		Content:     content,
+		CreatedAt:   createdAt,
+		DeliveredAt: validTime(createdAt),
+	})
+	if err != nil {
+		t.Fatalf("creating message %q: %v", content, err)
+	}
+	return message
+}
+
+// A message scheduled for deliverAt that has not been delivered yet
+func scheduleMessage(t *testing.T, q database.Querier, sender database.User, recipient database.User, content string, deliverAt time.Time) database.Message {
+	t.Helper()
+
+	message, err := q.CreateMessage(ctx, database.CreateMessageParams{
+		ID:          uuid.New(),
+		SenderID:    sender.ID,
+		RecipientID: recipient.ID,
+		Content:     content,
+		CreatedAt:   now(),
+		TtlSeconds:  sql.NullInt32{Int32: 60, Valid: true},
+		DeliverAt:   validTime(deliverAt),
+	})
+	if err != nil {
+		t.Fatalf("scheduling message %q: %v", content, err)
+	}
+	return message
+}
+
+// Soft-delete a message directly, the state the reaper leaves expired messages in
+func deleteMessage(t *testing.T, store *database.SQLStore, message database.Message) {
+	t.Helper()
+
+	if _, err := store.Conn.ExecContext(ctx, "UPDATE messages SET deleted = true WHERE id = $1", message.ID); err != nil {
+		t.Fatalf("deleting message %q: %v", message.Content, err)
+	}
+}
+
+func messageContents(messages []database.Message) []string {
+	contents := make([]string, len(messages))
+	for i := range messages {
+		contents[i] = messages[i].Content
+	}
+	return contents
+}
+
+func assertContents(t *testing.T, messages []database.Message, want ...string) {
+	t.Helper()
+
+	got := messageContents(messages)
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func assertPQCode(t *testing.T, err error, code pq.ErrorCode) {
+	t.Helper()
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != code {
+		t.Fatalf("got error %v, want Postgres error %s", err, code)
+	}
+}
+
+func TestExecTxRollsBack(t *testing.T) {
+	store := newStore(t)
+
+	failure := errors.New("rollback please")
+	err := store.ExecTx(ctx, func(q database.Querier) error {
+		createUser(t, q, "alice")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+
+	if _, err := store.GetUserByUsername(ctx, "alice"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("user from a rolled back transaction is visible (err %v)", err)
+	}
+
+	err = store.ExecTx(ctx, func(q database.Querier) error {
+		createUser(t, q, "bob")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetUserByUsername(ctx, "bob"); err != nil {
+		t.Errorf("user from a committed transaction is missing: %v", err)
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	for query, want := range map[string]string{
+		"-- name: GetUserByID :one\nSELECT * FROM users WHERE id = $1": "GetUserByID",
+		"-- name: ExpireMessages :many\nUPDATE messages":               "ExpireMessages",
+		"SELECT 1": "unknown",
+	} {
+		if got := database.QueryName(query); got != want {
+			t.Errorf("QueryName(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+)
+
+func (s *Store) GetStorageStats(ctx context.Context, now sql.NullTime) (database.GetStorageStatsRow, error) {
+	defer s.lock()()
+
+	var stats database.GetStorageStatsRow
+	for _, user := range s.data.users {
+		stats.Users++
+		if user.SuspendedAt.Valid {
+			stats.SuspendedUsers++
+		}
+		if user.IsAdmin {
+			stats.Admins++
+		}
+	}
+
+	for _, message := range s.data.messages {
+		switch {
+		case message.Deleted:
+			stats.DeletedMessages++
+			continue
+		case message.DeliveredAt.Valid:
+			stats.DeliveredMessages++
+		default:
+			stats.ScheduledMessages++
+		}
+		if notAfter(message.ExpiresAt, now) {
+			stats.ExpiredMessages++
+		}
+	}
+
+	for _, token := range s.data.refreshTokens {
+		if token.IsValid {
+			stats.ValidRefreshTokens++
+		}
+	}
+
+	for _, event := range s.data.outbox {
+		if !event.ProcessedAt.Valid {
+			stats.PendingEvents++
+		}
+	}
+
+	stats.Contacts = int64(len(s.data.contacts))
+	stats.Blocks = int64(len(s.data.blocks))
+	stats.Webhooks = int64(len(s.data.webhooks))
+	return stats, nil
+}
+
+// There is no database file behind the store, so it never takes up any space
+func (s *Store) GetDatabaseSize(ctx context.Context) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,313 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	contactPending  = "pending"
+	contactAccepted = "accepted"
+)
+
+// Whether contact links a and b, in either direction
+func between(contact database.Contact, a uuid.UUID, b uuid.UUID) bool {
+	return (contact.RequesterID == a && contact.AddresseeID == b) ||
+		(contact.RequesterID == b && contact.AddresseeID == a)
+}
+
+func (s *Store) CreateContactRequest(ctx context.Context, arg database.CreateContactRequestParams) (database.Contact, error) {
+	defer s.lock()()
+
+	if arg.RequesterID == arg.AddresseeID {
+		return database.Contact{}, checkViolation("contacts_check")
+	}
+	if !s.data.userExists(arg.RequesterID) {
+		return database.Contact{}, foreignKeyViolation("contacts_requester_id_fkey")
+	}
+	if !s.data.userExists(arg.AddresseeID) {
+		return database.Contact{}, foreignKeyViolation("contacts_addressee_id_fkey")
+	}
+	if slices.ContainsFunc(s.data.contacts, func(contact database.Contact) bool {
+		return contact.RequesterID == arg.RequesterID && contact.AddresseeID == arg.AddresseeID
+	}) {
+		return database.Contact{}, uniqueViolation("contacts_pkey")
+	}
+	if slices.ContainsFunc(s.data.contacts, func(contact database.Contact) bool {
+		return contact.RequesterID == arg.AddresseeID && contact.AddresseeID == arg.RequesterID
+	}) {
+		return database.Contact{}, uniqueViolation("contacts_pair_idx")
+	}
+
+	contact := database.Contact{
+		RequesterID: arg.RequesterID,
+		AddresseeID: arg.AddresseeID,
+		Status:      contactPending,
+		CreatedAt:   arg.CreatedAt,
+		UpdatedAt:   arg.CreatedAt,
+	}
+	s.data.contacts = append(s.data.contacts, contact)
+	return contact, nil
+}
+
+func (s *Store) GetContactBetween(ctx context.Context, arg database.GetContactBetweenParams) (database.Contact, error) {
+	defer s.lock()()
+
+	for _, contact := range s.data.contacts {
+		if between(contact, arg.RequesterID, arg.AddresseeID) {
+			return contact, nil
+		}
+	}
+	return database.Contact{}, sql.ErrNoRows
+}
+
+func (s *Store) AcceptContactRequest(ctx context.Context, arg database.AcceptContactRequestParams) (int64, error) {
+	defer s.lock()()
+
+	var rows int64
+	for i, contact := range s.data.contacts {
+		if contact.RequesterID == arg.RequesterID && contact.AddresseeID == arg.AddresseeID && contact.Status == contactPending {
+			contact.Status = contactAccepted
+			contact.UpdatedAt = arg.UpdatedAt
+			s.data.contacts[i] = contact
+			rows++
+		}
+	}
+	return rows, nil
+}
+
+// Delete the contacts matching remove and return how many there were
+func (s *Store) deleteContacts(remove func(contact database.Contact) bool) int64 {
+	before := len(s.data.contacts)
+	s.data.contacts = slices.DeleteFunc(s.data.contacts, remove)
+	return int64(before - len(s.data.contacts))
+}
+
+func (s *Store) DeclineContactRequest(ctx context.Context, arg database.DeclineContactRequestParams) (int64, error) {
+	defer s.lock()()
+
+	return s.deleteContacts(func(contact database.Contact) bool {
+		return contact.RequesterID == arg.RequesterID && contact.AddresseeID == arg.AddresseeID && contact.Status == contactPending
+	}), nil
+}
+
+func (s *Store) DeleteContact(ctx context.Context, arg database.DeleteContactParams) (int64, error) {
+	defer s.lock()()
+
+	return s.deleteContacts(func(contact database.Contact) bool {
+		return between(contact, arg.RequesterID, arg.AddresseeID)
+	}), nil
+}
+
+func (s *Store) AreContacts(ctx context.Context, arg database.AreContactsParams) (bool, error) {
+	defer s.lock()()
+
+	return slices.ContainsFunc(s.data.contacts, func(contact database.Contact) bool {
+		return between(contact, arg.RequesterID, arg.AddresseeID) && contact.Status == contactAccepted
+	}), nil
+}
+
+// The accepted contacts of userID, with the user on the other side, ordered by username
+func (s *Store) acceptedContacts(userID uuid.UUID) ([]database.Contact, []database.User) {
+	var contacts []database.Contact
+	var users []database.User
+	for _, contact := range s.data.contacts {
+		if contact.Status != contactAccepted || (contact.RequesterID != userID && contact.AddresseeID != userID) {
+			continue
+		}
+
+		otherID := contact.RequesterID
+		if contact.RequesterID == userID {
+			otherID = contact.AddresseeID
+		}
+		if i := s.data.userIndex(otherID); i >= 0 {
+			contacts = append(contacts, contact)
+			users = append(users, s.data.users[i])
+		}
+	}
+
+	order := make([]int, len(users))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return strings.Compare(users[a].Username, users[b].Username)
+	})
+
+	sortedContacts := make([]database.Contact, len(order))
+	sortedUsers := make([]database.User, len(order))
+	for i, j := range order {
+		sortedContacts[i] = contacts[j]
+		sortedUsers[i] = users[j]
+	}
+	return sortedContacts, sortedUsers
+}
+
+func (s *Store) GetContacts(ctx context.Context, requesterID uuid.UUID) ([]database.GetContactsRow, error) {
+	defer s.lock()()
+
+	contacts, users := s.acceptedContacts(requesterID)
+
+	var items []database.GetContactsRow
+	for i := range contacts {
+		items = append(items, database.GetContactsRow{
+			ID:        users[i].ID,
+			Username:  users[i].Username,
+			Status:    contacts[i].Status,
+			CreatedAt: contacts[i].CreatedAt,
+			UpdatedAt: contacts[i].UpdatedAt,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) GetContactsPresenceInfo(ctx context.Context, requesterID uuid.UUID) ([]database.GetContactsPresenceInfoRow, error) {
+	defer s.lock()()
+
+	_, users := s.acceptedContacts(requesterID)
+
+	var items []database.GetContactsPresenceInfoRow
+	for _, user := range users {
+		items = append(items, database.GetContactsPresenceInfoRow{
+			ID:           user.ID,
+			Username:     user.Username,
+			HideLastSeen: user.HideLastSeen,
+		})
+	}
+	return items, nil
+}
+
+// Pending requests matching keep, newest first, with the user on the side picked by other
+func (s *Store) pendingRequests(keep func(contact database.Contact) bool, other func(contact database.Contact) uuid.UUID) []database.GetContactsRow {
+	var pending []database.Contact
+	for _, contact := range s.data.contacts {
+		if contact.Status == contactPending && keep(contact) {
+			pending = append(pending, contact)
+		}
+	}
+
+	slices.SortStableFunc(pending, func(a, b database.Contact) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	var items []database.GetContactsRow
+	for _, contact := range pending {
+		i := s.data.userIndex(other(contact))
+		if i < 0 {
+			continue
+		}
+
+		items = append(items, database.GetContactsRow{
+			ID:        s.data.users[i].ID,
+			Username:  s.data.users[i].Username,
+			Status:    contact.Status,
+			CreatedAt: contact.CreatedAt,
+			UpdatedAt: contact.UpdatedAt,
+		})
+	}
+	return items
+}
+
+func (s *Store) GetIncomingContactRequests(ctx context.Context, addresseeID uuid.UUID) ([]database.GetIncomingContactRequestsRow, error) {
+	defer s.lock()()
+
+	rows := s.pendingRequests(
+		func(contact database.Contact) bool { return contact.AddresseeID == addresseeID },
+		func(contact database.Contact) uuid.UUID { return contact.RequesterID },
+	)
+
+	var items []database.GetIncomingContactRequestsRow
+	for _, row := range rows {
+		items = append(items, database.GetIncomingContactRequestsRow(row))
+	}
+	return items, nil
+}
+
+func (s *Store) GetOutgoingContactRequests(ctx context.Context, requesterID uuid.UUID) ([]database.GetOutgoingContactRequestsRow, error) {
+	defer s.lock()()
+
+	rows := s.pendingRequests(
+		func(contact database.Contact) bool { return contact.RequesterID == requesterID },
+		func(contact database.Contact) uuid.UUID { return contact.AddresseeID },
+	)
+
+	var items []database.GetOutgoingContactRequestsRow
+	for _, row := range rows {
+		items = append(items, database.GetOutgoingContactRequestsRow(row))
+	}
+	return items, nil
+}
+
+func (s *Store) CreateBlock(ctx context.Context, arg database.CreateBlockParams) error {
+	defer s.lock()()
+
+	if arg.BlockerID == arg.BlockedID {
+		return checkViolation("blocks_check")
+	}
+	if !s.data.userExists(arg.BlockerID) {
+		return foreignKeyViolation("blocks_blocker_id_fkey")
+	}
+	if !s.data.userExists(arg.BlockedID) {
+		return foreignKeyViolation("blocks_blocked_id_fkey")
+	}
+
+	// ON CONFLICT DO NOTHING
+	if slices.ContainsFunc(s.data.blocks, func(block database.Block) bool {
+		return block.BlockerID == arg.BlockerID && block.BlockedID == arg.BlockedID
+	}) {
+		return nil
+	}
+
+	s.data.blocks = append(s.data.blocks, database.Block(arg))
+	return nil
+}
+
+func (s *Store) DeleteBlock(ctx context.Context, arg database.DeleteBlockParams) (int64, error) {
+	defer s.lock()()
+
+	before := len(s.data.blocks)
+	s.data.blocks = slices.DeleteFunc(s.data.blocks, func(block database.Block) bool {
+		return block.BlockerID == arg.BlockerID && block.BlockedID == arg.BlockedID
+	})
+	return int64(before - len(s.data.blocks)), nil
+}
+
+func (s *Store) IsBlocked(ctx context.Context, arg database.IsBlockedParams) (bool, error) {
+	defer s.lock()()
+
+	return slices.ContainsFunc(s.data.blocks, func(block database.Block) bool {
+		return block.BlockerID == arg.BlockerID && block.BlockedID == arg.BlockedID
+	}), nil
+}
+
+func (s *Store) GetBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]database.GetBlockedUsersRow, error) {
+	defer s.lock()()
+
+	var blocks []database.Block
+	for _, block := range s.data.blocks {
+		if block.BlockerID == blockerID {
+			blocks = append(blocks, block)
+		}
+	}
+
+	slices.SortStableFunc(blocks, func(a, b database.Block) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	var items []database.GetBlockedUsersRow
+	for _, block := range blocks {
+		if i := s.data.userIndex(block.BlockedID); i >= 0 {
+			items = append(items, database.GetBlockedUsersRow{
+				ID:        block.BlockedID,
+				Username:  s.data.users[i].Username,
+				CreatedAt: block.CreatedAt,
+			})
+		}
+	}
+	return items, nil
+}
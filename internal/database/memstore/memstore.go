@@ -0,0 +1,137 @@
+// Package memstore is an in-memory database.Store for tests. Every method mirrors the SQL query of the
+// same name in internal/sql/queries: the same filters (deleted, delivered, expired), the same ordering,
+// sql.ErrNoRows for missing rows, and *pq.Error values for unique and foreign key violations, so
+// handlers behave the same against it as they do against Postgres
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Store is safe for concurrent use. Every call, and every ExecTx as a whole, runs under one lock, so
+// transactions are fully serialized
+type Store struct {
+	mu   *sync.Mutex
+	data *tables
+	inTx bool
+}
+
+// The rows of every table, kept in insertion order
+type tables struct {
+	users          []database.User
+	refreshTokens  []database.RefreshToken
+	messages       []database.Message
+	contacts       []database.Contact
+	blocks         []database.Block
+	outbox         []database.Outbox
+	webhooks       []database.Webhook
+	deliveries     []database.WebhookDelivery
+	signingKeys    []database.SigningKey
+	nextTokenID    int32
+	nextOutboxID   int64
+	nextDeliveryID int64
+}
+
+func New() *Store {
+	return &Store{
+		mu:   &sync.Mutex{},
+		data: &tables{},
+	}
+}
+
+// Take the store lock, unless we are already inside a transaction that holds it. Use as
+// defer s.lock()()
+func (s *Store) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// ExecTx runs fn against the store, undoing every change fn made if it returns an error
+func (s *Store) ExecTx(ctx context.Context, fn func(q database.Querier) error) error {
+	// Nested transactions simply join the outer one
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.data.clone()
+	tx := &Store{mu: s.mu, data: s.data, inTx: true}
+	if err := fn(tx); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+// Rows are only ever replaced, never modified in place, so copying the slices is enough
+func (t *tables) clone() *tables {
+	return &tables{
+		users:          slices.Clone(t.users),
+		refreshTokens:  slices.Clone(t.refreshTokens),
+		messages:       slices.Clone(t.messages),
+		contacts:       slices.Clone(t.contacts),
+		blocks:         slices.Clone(t.blocks),
+		outbox:         slices.Clone(t.outbox),
+		webhooks:       slices.Clone(t.webhooks),
+		deliveries:     slices.Clone(t.deliveries),
+		signingKeys:    slices.Clone(t.signingKeys),
+		nextTokenID:    t.nextTokenID,
+		nextOutboxID:   t.nextOutboxID,
+		nextDeliveryID: t.nextDeliveryID,
+	}
+}
+
+func (t *tables) userIndex(id uuid.UUID) int {
+	return slices.IndexFunc(t.users, func(user database.User) bool {
+		return user.ID == id
+	})
+}
+
+func (t *tables) userExists(id uuid.UUID) bool {
+	return t.userIndex(id) >= 0
+}
+
+// The errors Postgres reports for constraint violations, as lib/pq surfaces them
+func uniqueViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23505",
+		Message:    fmt.Sprintf("duplicate key value violates unique constraint \"%s\"", constraint),
+		Constraint: constraint,
+	}
+}
+
+func foreignKeyViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23503",
+		Message:    fmt.Sprintf("insert or update violates foreign key constraint \"%s\"", constraint),
+		Constraint: constraint,
+	}
+}
+
+func checkViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23514",
+		Message:    fmt.Sprintf("new row violates check constraint \"%s\"", constraint),
+		Constraint: constraint,
+	}
+}
+
+// Report column <= value the way SQL does, where a NULL on either side never matches
+func notAfter(column sql.NullTime, value sql.NullTime) bool {
+	return column.Valid && value.Valid && !column.Time.After(value.Time)
+}
+
+var _ database.Store = (*Store)(nil)
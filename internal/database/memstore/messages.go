@@ -0,0 +1,388 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	defer s.lock()()
+
+	if slices.ContainsFunc(s.data.messages, func(message database.Message) bool { return message.ID == arg.ID }) {
+		return database.Message{}, uniqueViolation("messages_pkey")
+	}
+	if !s.data.userExists(arg.SenderID) {
+		return database.Message{}, foreignKeyViolation("messages_sender_id_fkey")
+	}
+	if !s.data.userExists(arg.RecipientID) {
+		return database.Message{}, foreignKeyViolation("messages_recipient_id_fkey")
+	}
+
+	message := database.Message{
+		ID:          arg.ID,
+		SenderID:    arg.SenderID,
+		RecipientID: arg.RecipientID,
+		Content:     arg.Content,
+		CreatedAt:   arg.CreatedAt,
+		TtlSeconds:  arg.TtlSeconds,
+		ExpiresAt:   arg.ExpiresAt,
+		DeliverAt:   arg.DeliverAt,
+		DeliveredAt: arg.DeliveredAt,
+	}
+	s.data.messages = append(s.data.messages, message)
+	return message, nil
+}
+
+// When message reached its recipient, COALESCE(delivered_at, created_at) in the queries
+func sentAt(message database.Message) time.Time {
+	if message.DeliveredAt.Valid {
+		return message.DeliveredAt.Time
+	}
+	return message.CreatedAt
+}
+
+// Messages matching keep, newest first by sentAt
+func (s *Store) newestMessages(keep func(message database.Message) bool) []database.Message {
+	var items []database.Message
+	for _, message := range s.data.messages {
+		if keep(message) {
+			items = append(items, message)
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b database.Message) int {
+		return sentAt(b).Compare(sentAt(a))
+	})
+	return items
+}
+
+// Messages that have been delivered and not deleted, the ones the history queries may return
+func visible(message database.Message) bool {
+	return !message.Deleted && message.DeliveredAt.Valid
+}
+
+func (s *Store) GetSentMessagesFromThisUser(ctx context.Context, senderID uuid.UUID) ([]database.Message, error) {
+	defer s.lock()()
+
+	return s.newestMessages(func(message database.Message) bool {
+		return message.SenderID == senderID && visible(message)
+	}), nil
+}
+
+func (s *Store) GetSentMessagesToNamedUser(ctx context.Context, arg database.GetSentMessagesToNamedUserParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	return s.newestMessages(func(message database.Message) bool {
+		return message.SenderID == arg.SenderID && message.RecipientID == arg.RecipientID && visible(message)
+	}), nil
+}
+
+func (s *Store) GetReceivedMessagesFromNamedUser(ctx context.Context, arg database.GetReceivedMessagesFromNamedUserParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	return s.newestMessages(func(message database.Message) bool {
+		return message.RecipientID == arg.RecipientID && message.SenderID == arg.SenderID && visible(message)
+	}), nil
+}
+
+func (s *Store) GetReceivedMessagesToThisUser(ctx context.Context, recipientID uuid.UUID) ([]database.Message, error) {
+	defer s.lock()()
+
+	return s.newestMessages(func(message database.Message) bool {
+		return message.RecipientID == recipientID && visible(message)
+	}), nil
+}
+
+func (s *Store) GetUserMessageHistory(ctx context.Context, senderID uuid.UUID) ([]database.Message, error) {
+	defer s.lock()()
+
+	return s.newestMessages(func(message database.Message) bool {
+		return (message.SenderID == senderID || message.RecipientID == senderID) && visible(message)
+	}), nil
+}
+
+func (s *Store) GetMessageHistoryWithNamedUser(ctx context.Context, arg database.GetMessageHistoryWithNamedUserParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	return s.newestMessages(func(message database.Message) bool {
+		between := (message.SenderID == arg.SenderID && message.RecipientID == arg.RecipientID) ||
+			(message.SenderID == arg.RecipientID && message.RecipientID == arg.SenderID)
+		return between && visible(message)
+	}), nil
+}
+
+// Messages that are still waiting for the dispatcher
+func scheduled(message database.Message) bool {
+	return !message.Deleted && !message.DeliveredAt.Valid
+}
+
+func (s *Store) GetScheduledMessagesFromThisUser(ctx context.Context, senderID uuid.UUID) ([]database.Message, error) {
+	defer s.lock()()
+
+	var items []database.Message
+	for _, message := range s.data.messages {
+		if message.SenderID == senderID && scheduled(message) {
+			items = append(items, message)
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b database.Message) int {
+		return a.DeliverAt.Time.Compare(b.DeliverAt.Time)
+	})
+	return items, nil
+}
+
+func (s *Store) GetScheduledMessage(ctx context.Context, arg database.GetScheduledMessageParams) (database.Message, error) {
+	defer s.lock()()
+
+	for _, message := range s.data.messages {
+		if message.ID == arg.ID && message.SenderID == arg.SenderID && scheduled(message) {
+			return message, nil
+		}
+	}
+	return database.Message{}, sql.ErrNoRows
+}
+
+func (s *Store) UpdateScheduledMessage(ctx context.Context, arg database.UpdateScheduledMessageParams) (database.Message, error) {
+	defer s.lock()()
+
+	for i, message := range s.data.messages {
+		if message.ID == arg.ID && message.SenderID == arg.SenderID && scheduled(message) {
+			message.Content = arg.Content
+			message.TtlSeconds = arg.TtlSeconds
+			message.DeliverAt = arg.DeliverAt
+			s.data.messages[i] = message
+			return message, nil
+		}
+	}
+	return database.Message{}, sql.ErrNoRows
+}
+
+func (s *Store) CancelScheduledMessage(ctx context.Context, arg database.CancelScheduledMessageParams) (int64, error) {
+	defer s.lock()()
+
+	var rows int64
+	for i, message := range s.data.messages {
+		if message.ID == arg.ID && message.SenderID == arg.SenderID && scheduled(message) {
+			message.Deleted = true
+			s.data.messages[i] = message
+			rows++
+		}
+	}
+	return rows, nil
+}
+
+func (s *Store) GetDueScheduledMessages(ctx context.Context, arg database.GetDueScheduledMessagesParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	var items []database.Message
+	for _, message := range s.data.messages {
+		if scheduled(message) && notAfter(message.DeliverAt, arg.DeliverAt) {
+			items = append(items, message)
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b database.Message) int {
+		return a.DeliverAt.Time.Compare(b.DeliverAt.Time)
+	})
+	return items[:min(len(items), int(arg.Limit))], nil
+}
+
+func (s *Store) MarkMessageDelivered(ctx context.Context, arg database.MarkMessageDeliveredParams) error {
+	defer s.lock()()
+
+	for i, message := range s.data.messages {
+		if message.ID == arg.ID && !message.DeliveredAt.Valid {
+			message.DeliveredAt = arg.DeliveredAt
+			message.ExpiresAt = arg.ExpiresAt
+			s.data.messages[i] = message
+		}
+	}
+	return nil
+}
+
+// Lower-cased search terms, an approximation of websearch_to_tsquery without stemming or operators
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// SearchMessages matches messages containing every term of the query, case-insensitively. The snippet
+// is the whole content with the matches wrapped in <mark> tags, like ts_headline for short messages
+func (s *Store) SearchMessages(ctx context.Context, arg database.SearchMessagesParams) ([]database.SearchMessagesRow, error) {
+	defer s.lock()()
+
+	terms := searchTerms(arg.Query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(terms))
+	for i := range terms {
+		quoted[i] = regexp.QuoteMeta(terms[i])
+	}
+	highlight := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	matches := s.newestMessages(func(message database.Message) bool {
+		if message.SenderID != arg.UserID && message.RecipientID != arg.UserID {
+			return false
+		}
+		if !visible(message) || (message.ExpiresAt.Valid && !message.ExpiresAt.Time.After(arg.Now.Time)) {
+			return false
+		}
+		if arg.CounterpartID.Valid && message.SenderID != arg.CounterpartID.UUID && message.RecipientID != arg.CounterpartID.UUID {
+			return false
+		}
+		if arg.SentAfter.Valid && sentAt(message).Before(arg.SentAfter.Time) {
+			return false
+		}
+		if arg.SentBefore.Valid && !sentAt(message).Before(arg.SentBefore.Time) {
+			return false
+		}
+
+		content := strings.ToLower(message.Content)
+		for _, term := range terms {
+			if !strings.Contains(content, term) {
+				return false
+			}
+		}
+		return true
+	})
+
+	offset := min(len(matches), int(arg.PageOffset))
+	matches = matches[offset:min(len(matches), offset+int(arg.PageLimit))]
+
+	var items []database.SearchMessagesRow
+	for _, message := range matches {
+		items = append(items, database.SearchMessagesRow{
+			Message: message,
+			Snippet: markMatches(message.Content, highlight),
+		})
+	}
+	return items, nil
+}
+
+// Wrap every match of highlight in <mark>, escaping the content around and inside the matches as
+// SearchMessages does before ts_headline marks them
+func markMatches(content string, highlight *regexp.Regexp) string {
+	var snippet strings.Builder
+	last := 0
+	for _, match := range highlight.FindAllStringIndex(content, -1) {
+		snippet.WriteString(html.EscapeString(content[last:match[0]]))
+		snippet.WriteString("<mark>" + html.EscapeString(content[match[0]:match[1]]) + "</mark>")
+		last = match[1]
+	}
+	snippet.WriteString(html.EscapeString(content[last:]))
+	return snippet.String()
+}
+
+func (s *Store) GetConversationSummaries(ctx context.Context, arg database.GetConversationSummariesParams) ([]database.GetConversationSummariesRow, error) {
+	defer s.lock()()
+
+	summaries := make(map[uuid.UUID]*database.GetConversationSummariesRow)
+	for _, message := range s.newestMessages(visible) {
+		if message.SenderID != arg.UserID && message.RecipientID != arg.UserID {
+			continue
+		}
+		if message.ExpiresAt.Valid && !message.ExpiresAt.Time.After(arg.Now.Time) {
+			continue
+		}
+
+		counterpartID := message.SenderID
+		if message.SenderID == arg.UserID {
+			counterpartID = message.RecipientID
+		}
+
+		// Messages come newest first, so the first one seen per counterpart is the latest
+		summary, ok := summaries[counterpartID]
+		if !ok {
+			i := s.data.userIndex(counterpartID)
+			if i < 0 {
+				continue
+			}
+
+			summary = &database.GetConversationSummariesRow{
+				CounterpartUsername: s.data.users[i].Username,
+				CounterpartID:       counterpartID,
+				LastMessageID:       message.ID,
+				LastMessageSenderID: message.SenderID,
+				LastMessageContent:  message.Content,
+				LastActivityAt:      sentAt(message),
+			}
+			summaries[counterpartID] = summary
+		}
+
+		if message.RecipientID == arg.UserID && !message.ReadAt.Valid {
+			summary.UnreadCount++
+		}
+	}
+
+	var items []database.GetConversationSummariesRow
+	for _, summary := range summaries {
+		items = append(items, *summary)
+	}
+
+	slices.SortFunc(items, func(a, b database.GetConversationSummariesRow) int {
+		return cmp.Or(
+			b.LastActivityAt.Compare(a.LastActivityAt),
+			strings.Compare(a.CounterpartUsername, b.CounterpartUsername),
+		)
+	})
+	return items, nil
+}
+
+func (s *Store) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) ([]uuid.UUID, error) {
+	defer s.lock()()
+
+	var items []uuid.UUID
+	for i, message := range s.data.messages {
+		if message.RecipientID != arg.RecipientID || message.SenderID != arg.SenderID {
+			continue
+		}
+		if message.ReadAt.Valid || !visible(message) || sentAt(message).After(arg.UpTo) {
+			continue
+		}
+
+		message.ReadAt = arg.ReadAt
+		s.data.messages[i] = message
+		items = append(items, message.ID)
+	}
+	return items, nil
+}
+
+func (s *Store) ExpireMessages(ctx context.Context, arg database.ExpireMessagesParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	var expiring []int
+	for i, message := range s.data.messages {
+		if !message.Deleted && notAfter(message.ExpiresAt, arg.Now) {
+			expiring = append(expiring, i)
+		}
+	}
+
+	slices.SortStableFunc(expiring, func(a, b int) int {
+		return s.data.messages[a].ExpiresAt.Time.Compare(s.data.messages[b].ExpiresAt.Time)
+	})
+	expiring = expiring[:min(len(expiring), int(arg.BatchSize))]
+
+	var items []database.Message
+	for _, i := range expiring {
+		message := s.data.messages[i]
+		message.Deleted = true
+		message.Content = ""
+		s.data.messages[i] = message
+		items = append(items, message)
+	}
+	return items, nil
+}
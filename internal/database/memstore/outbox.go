@@ -0,0 +1,84 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+)
+
+func (s *Store) outboxIndex(id int64) int {
+	return slices.IndexFunc(s.data.outbox, func(event database.Outbox) bool {
+		return event.ID == id
+	})
+}
+
+func (s *Store) CreateOutboxEvent(ctx context.Context, arg database.CreateOutboxEventParams) (database.Outbox, error) {
+	defer s.lock()()
+
+	s.data.nextOutboxID++
+	event := database.Outbox{
+		ID:        s.data.nextOutboxID,
+		EventType: arg.EventType,
+		Payload:   arg.Payload,
+		UserIds:   arg.UserIds,
+		CreatedAt: arg.CreatedAt,
+	}
+	s.data.outbox = append(s.data.outbox, event)
+	return event, nil
+}
+
+// Events are appended in id order, so the slice order is already ORDER BY id
+func (s *Store) GetUnprocessedOutboxEvents(ctx context.Context, limit int32) ([]database.Outbox, error) {
+	defer s.lock()()
+
+	var items []database.Outbox
+	for _, event := range s.data.outbox {
+		if len(items) == int(limit) {
+			break
+		}
+		if !event.ProcessedAt.Valid {
+			items = append(items, event)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) MarkOutboxEventProcessed(ctx context.Context, arg database.MarkOutboxEventProcessedParams) error {
+	defer s.lock()()
+
+	if i := s.outboxIndex(arg.ID); i >= 0 {
+		s.data.outbox[i].ProcessedAt = arg.ProcessedAt
+	}
+	return nil
+}
+
+func (s *Store) GetOutboxEvent(ctx context.Context, id int64) (database.Outbox, error) {
+	defer s.lock()()
+
+	i := s.outboxIndex(id)
+	if i < 0 {
+		return database.Outbox{}, sql.ErrNoRows
+	}
+	return s.data.outbox[i], nil
+}
+
+func (s *Store) PruneOutboxEvents(ctx context.Context, arg database.PruneOutboxEventsParams) (int64, error) {
+	defer s.lock()()
+
+	var pruned int64
+	s.data.outbox = slices.DeleteFunc(s.data.outbox, func(event database.Outbox) bool {
+		if pruned == int64(arg.BatchSize) || !event.ProcessedAt.Valid || !event.ProcessedAt.Time.Before(arg.Before) {
+			return false
+		}
+		if slices.ContainsFunc(s.data.deliveries, func(delivery database.WebhookDelivery) bool {
+			return delivery.EventID == event.ID
+		}) {
+			return false
+		}
+		pruned++
+		return true
+	})
+	return pruned, nil
+}
@@ -0,0 +1,70 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+)
+
+func (s *Store) CreateSigningKey(ctx context.Context, arg database.CreateSigningKeyParams) (database.SigningKey, error) {
+	defer s.lock()()
+
+	if arg.Purpose != "access" && arg.Purpose != "refresh" {
+		return database.SigningKey{}, checkViolation("signing_keys_purpose_check")
+	}
+
+	for _, key := range s.data.signingKeys {
+		if key.ID == arg.ID {
+			return database.SigningKey{}, uniqueViolation("signing_keys_pkey")
+		}
+	}
+
+	key := database.SigningKey{
+		ID:          arg.ID,
+		Purpose:     arg.Purpose,
+		Secret:      arg.Secret,
+		CreatedAt:   arg.CreatedAt,
+		ActivatesAt: arg.ActivatesAt,
+	}
+	s.data.signingKeys = append(s.data.signingKeys, key)
+	return key, nil
+}
+
+func (s *Store) GetSigningKeys(ctx context.Context) ([]database.SigningKey, error) {
+	defer s.lock()()
+
+	items := slices.Clone(s.data.signingKeys)
+	slices.SortStableFunc(items, func(a, b database.SigningKey) int {
+		if c := b.ActivatesAt.Compare(a.ActivatesAt); c != 0 {
+			return c
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return items, nil
+}
+
+func (s *Store) RetireSigningKeys(ctx context.Context, arg database.RetireSigningKeysParams) (int64, error) {
+	defer s.lock()()
+
+	var retired int64
+	for i, key := range s.data.signingKeys {
+		if key.Purpose == arg.Purpose && !key.RetiresAt.Valid {
+			key.RetiresAt = arg.RetiresAt
+			s.data.signingKeys[i] = key
+			retired++
+		}
+	}
+	return retired, nil
+}
+
+func (s *Store) UpdateSigningKeySecret(ctx context.Context, arg database.UpdateSigningKeySecretParams) error {
+	defer s.lock()()
+
+	for i, key := range s.data.signingKeys {
+		if key.ID == arg.ID {
+			s.data.signingKeys[i].Secret = arg.Secret
+		}
+	}
+	return nil
+}
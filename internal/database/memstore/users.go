@@ -0,0 +1,321 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	defer s.lock()()
+
+	for _, user := range s.data.users {
+		if user.Username == arg.Username {
+			return database.User{}, uniqueViolation("users_username_key")
+		}
+		if user.Email == arg.Email {
+			return database.User{}, uniqueViolation("users_email_key")
+		}
+	}
+
+	user := database.User{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Username:  arg.Username,
+		Email:     arg.Email,
+		Password:  arg.Password,
+		Timezone:  "UTC",
+	}
+	s.data.users = append(s.data.users, user)
+	return user, nil
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (database.User, error) {
+	defer s.lock()()
+
+	for _, user := range s.data.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserLoginInfo(ctx context.Context, email string) (database.GetUserLoginInfoRow, error) {
+	defer s.lock()()
+
+	for _, user := range s.data.users {
+		if user.Email == email {
+			return database.GetUserLoginInfoRow{
+				ID:          user.ID,
+				Username:    user.Username,
+				Email:       user.Email,
+				Password:    user.Password,
+				SuspendedAt: user.SuspendedAt,
+			}, nil
+		}
+	}
+	return database.GetUserLoginInfoRow{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	defer s.lock()()
+
+	i := s.data.userIndex(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.data.users[i], nil
+}
+
+func (s *Store) IsUserAdmin(ctx context.Context, id uuid.UUID) (bool, error) {
+	defer s.lock()()
+
+	i := s.data.userIndex(id)
+	if i < 0 {
+		return false, sql.ErrNoRows
+	}
+	return s.data.users[i].IsAdmin, nil
+}
+
+func (s *Store) IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error) {
+	defer s.lock()()
+
+	i := s.data.userIndex(id)
+	if i < 0 {
+		return false, sql.ErrNoRows
+	}
+	return s.data.users[i].SuspendedAt.Valid, nil
+}
+
+// Apply update to the user with the given id and return the updated row
+func (s *Store) updateUser(id uuid.UUID, update func(user *database.User)) (database.User, error) {
+	i := s.data.userIndex(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+
+	user := s.data.users[i]
+	update(&user)
+	s.data.users[i] = user
+	return user, nil
+}
+
+func (s *Store) UpdateUserPrivacySettings(ctx context.Context, arg database.UpdateUserPrivacySettingsParams) (database.User, error) {
+	defer s.lock()()
+
+	return s.updateUser(arg.ID, func(user *database.User) {
+		if arg.ContactsOnly.Valid {
+			user.ContactsOnly = arg.ContactsOnly.Bool
+		}
+		if arg.HideLastSeen.Valid {
+			user.HideLastSeen = arg.HideLastSeen.Bool
+		}
+		user.UpdatedAt = arg.UpdatedAt
+	})
+}
+
+func (s *Store) UpdateUserProfile(ctx context.Context, arg database.UpdateUserProfileParams) (database.User, error) {
+	defer s.lock()()
+
+	return s.updateUser(arg.ID, func(user *database.User) {
+		if arg.DisplayName.Valid {
+			user.DisplayName = arg.DisplayName.String
+		}
+		if arg.Bio.Valid {
+			user.Bio = arg.Bio.String
+		}
+		if arg.StatusText.Valid {
+			user.StatusText = arg.StatusText.String
+		}
+		if arg.Timezone.Valid {
+			user.Timezone = arg.Timezone.String
+		}
+		user.UpdatedAt = arg.UpdatedAt
+	})
+}
+
+func (s *Store) UpdateUserAvatar(ctx context.Context, arg database.UpdateUserAvatarParams) (database.User, error) {
+	defer s.lock()()
+
+	return s.updateUser(arg.ID, func(user *database.User) {
+		user.AvatarKey = arg.AvatarKey
+		user.UpdatedAt = arg.UpdatedAt
+	})
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	defer s.lock()()
+
+	if !s.data.userExists(arg.UserID) {
+		return database.RefreshToken{}, foreignKeyViolation("refresh_tokens_user_id_fkey")
+	}
+
+	s.data.nextTokenID++
+	refreshToken := database.RefreshToken{
+		ID:           s.data.nextTokenID,
+		RefreshToken: arg.RefreshToken,
+		IsValid:      arg.IsValid,
+		CreatedAt:    arg.CreatedAt,
+		UpdatedAt:    arg.UpdatedAt,
+		UserID:       arg.UserID,
+	}
+	s.data.refreshTokens = append(s.data.refreshTokens, refreshToken)
+	return refreshToken, nil
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, refreshToken string) (database.RefreshToken, error) {
+	defer s.lock()()
+
+	for _, token := range s.data.refreshTokens {
+		if token.RefreshToken == refreshToken {
+			return token, nil
+		}
+	}
+	return database.RefreshToken{}, sql.ErrNoRows
+}
+
+func (s *Store) GetAllUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	defer s.lock()()
+
+	var items []database.RefreshToken
+	for _, token := range s.data.refreshTokens {
+		if token.UserID == userID {
+			items = append(items, token)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) CountActiveRefreshTokens(ctx context.Context, createdAfter time.Time) (int64, error) {
+	defer s.lock()()
+
+	var count int64
+	for _, token := range s.data.refreshTokens {
+		if token.IsValid && token.CreatedAt.After(createdAfter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) UpdateRefreshToken(ctx context.Context, arg database.UpdateRefreshTokenParams) error {
+	defer s.lock()()
+
+	for i, token := range s.data.refreshTokens {
+		if token.RefreshToken == arg.RefreshToken {
+			token.IsValid = arg.IsValid
+			token.UpdatedAt = arg.UpdatedAt
+			s.data.refreshTokens[i] = token
+		}
+	}
+	return nil
+}
+
+func (s *Store) SetUserAdmin(ctx context.Context, arg database.SetUserAdminParams) (database.User, error) {
+	defer s.lock()()
+
+	return s.updateUser(arg.ID, func(user *database.User) {
+		user.IsAdmin = arg.IsAdmin
+		user.UpdatedAt = arg.UpdatedAt
+	})
+}
+
+func (s *Store) UpdateUserSuspension(ctx context.Context, arg database.UpdateUserSuspensionParams) (database.User, error) {
+	defer s.lock()()
+
+	return s.updateUser(arg.ID, func(user *database.User) {
+		user.SuspendedAt = arg.SuspendedAt
+		user.UpdatedAt = arg.UpdatedAt
+	})
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	defer s.lock()()
+
+	return s.updateUser(arg.ID, func(user *database.User) {
+		user.Password = arg.Password
+		user.UpdatedAt = arg.UpdatedAt
+	})
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	defer s.lock()()
+
+	if !s.data.userExists(id) {
+		return 0, nil
+	}
+
+	// Everything with a foreign key to the user cascades, and webhook deliveries with the webhooks
+	s.data.users = slices.DeleteFunc(s.data.users, func(user database.User) bool {
+		return user.ID == id
+	})
+	s.data.refreshTokens = slices.DeleteFunc(s.data.refreshTokens, func(token database.RefreshToken) bool {
+		return token.UserID == id
+	})
+	s.data.messages = slices.DeleteFunc(s.data.messages, func(message database.Message) bool {
+		return message.SenderID == id || message.RecipientID == id
+	})
+	s.data.contacts = slices.DeleteFunc(s.data.contacts, func(contact database.Contact) bool {
+		return contact.RequesterID == id || contact.AddresseeID == id
+	})
+	s.data.blocks = slices.DeleteFunc(s.data.blocks, func(block database.Block) bool {
+		return block.BlockerID == id || block.BlockedID == id
+	})
+
+	var webhookIDs []uuid.UUID
+	s.data.webhooks = slices.DeleteFunc(s.data.webhooks, func(webhook database.Webhook) bool {
+		if webhook.OwnerID.Valid && webhook.OwnerID.UUID == id {
+			webhookIDs = append(webhookIDs, webhook.ID)
+			return true
+		}
+		return false
+	})
+	s.data.deliveries = slices.DeleteFunc(s.data.deliveries, func(delivery database.WebhookDelivery) bool {
+		return slices.Contains(webhookIDs, delivery.WebhookID)
+	})
+	return 1, nil
+}
+
+// Invalidate the valid refresh tokens match accepts and return how many there were
+func (s *Store) revokeRefreshTokens(updatedAt time.Time, match func(token database.RefreshToken) bool) int64 {
+	var revoked int64
+	for i, token := range s.data.refreshTokens {
+		if token.IsValid && match(token) {
+			token.IsValid = false
+			token.UpdatedAt = updatedAt
+			s.data.refreshTokens[i] = token
+			revoked++
+		}
+	}
+	return revoked
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, arg database.RevokeUserRefreshTokensParams) (int64, error) {
+	defer s.lock()()
+
+	return s.revokeRefreshTokens(arg.UpdatedAt, func(token database.RefreshToken) bool {
+		return token.UserID == arg.UserID
+	}), nil
+}
+
+func (s *Store) RevokeRefreshTokenByID(ctx context.Context, arg database.RevokeRefreshTokenByIDParams) (int64, error) {
+	defer s.lock()()
+
+	return s.revokeRefreshTokens(arg.UpdatedAt, func(token database.RefreshToken) bool {
+		return token.ID == arg.ID
+	}), nil
+}
+
+func (s *Store) RevokeAllRefreshTokens(ctx context.Context, updatedAt time.Time) (int64, error) {
+	defer s.lock()()
+
+	return s.revokeRefreshTokens(updatedAt, func(token database.RefreshToken) bool {
+		return true
+	}), nil
+}
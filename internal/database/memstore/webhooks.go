@@ -0,0 +1,268 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) webhookIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.data.webhooks, func(webhook database.Webhook) bool {
+		return webhook.ID == id
+	})
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	defer s.lock()()
+
+	if s.webhookIndex(arg.ID) >= 0 {
+		return database.Webhook{}, uniqueViolation("webhooks_pkey")
+	}
+	if arg.OwnerID.Valid && !s.data.userExists(arg.OwnerID.UUID) {
+		return database.Webhook{}, foreignKeyViolation("webhooks_owner_id_fkey")
+	}
+
+	webhook := database.Webhook{
+		ID:        arg.ID,
+		OwnerID:   arg.OwnerID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    arg.Events,
+		Enabled:   true,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.CreatedAt,
+	}
+	s.data.webhooks = append(s.data.webhooks, webhook)
+	return webhook, nil
+}
+
+// Webhooks matching keep, newest first
+func (s *Store) newestWebhooks(keep func(webhook database.Webhook) bool) []database.Webhook {
+	var items []database.Webhook
+	for _, webhook := range s.data.webhooks {
+		if keep(webhook) {
+			items = append(items, webhook)
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b database.Webhook) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return items
+}
+
+func (s *Store) GetWebhooksByOwner(ctx context.Context, ownerID uuid.NullUUID) ([]database.Webhook, error) {
+	defer s.lock()()
+
+	// owner_id = NULL is never true in SQL
+	return s.newestWebhooks(func(webhook database.Webhook) bool {
+		return ownerID.Valid && webhook.OwnerID == ownerID
+	}), nil
+}
+
+func (s *Store) GetGlobalWebhooks(ctx context.Context) ([]database.Webhook, error) {
+	defer s.lock()()
+
+	return s.newestWebhooks(func(webhook database.Webhook) bool {
+		return !webhook.OwnerID.Valid
+	}), nil
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id uuid.UUID) (database.Webhook, error) {
+	defer s.lock()()
+
+	i := s.webhookIndex(id)
+	if i < 0 {
+		return database.Webhook{}, sql.ErrNoRows
+	}
+	return s.data.webhooks[i], nil
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, arg database.UpdateWebhookParams) (database.Webhook, error) {
+	defer s.lock()()
+
+	i := s.webhookIndex(arg.ID)
+	if i < 0 {
+		return database.Webhook{}, sql.ErrNoRows
+	}
+
+	webhook := s.data.webhooks[i]
+	webhook.Url = arg.Url
+	webhook.Events = arg.Events
+	webhook.Enabled = arg.Enabled
+	if arg.Enabled {
+		webhook.ConsecutiveFailures = 0
+		webhook.DisabledAt = sql.NullTime{Valid: false}
+	}
+	webhook.UpdatedAt = arg.UpdatedAt
+	s.data.webhooks[i] = webhook
+	return webhook, nil
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, id uuid.UUID) (int64, error) {
+	defer s.lock()()
+
+	if s.webhookIndex(id) < 0 {
+		return 0, nil
+	}
+
+	// Deliveries cascade with their webhook
+	s.data.webhooks = slices.DeleteFunc(s.data.webhooks, func(webhook database.Webhook) bool {
+		return webhook.ID == id
+	})
+	s.data.deliveries = slices.DeleteFunc(s.data.deliveries, func(delivery database.WebhookDelivery) bool {
+		return delivery.WebhookID == id
+	})
+	return 1, nil
+}
+
+func (s *Store) GetWebhooksForEvent(ctx context.Context, arg database.GetWebhooksForEventParams) ([]database.Webhook, error) {
+	defer s.lock()()
+
+	var items []database.Webhook
+	for _, webhook := range s.data.webhooks {
+		if !webhook.Enabled || !slices.Contains(webhook.Events, arg.EventType) {
+			continue
+		}
+		if !webhook.OwnerID.Valid || slices.Contains(arg.UserIds, webhook.OwnerID.UUID) {
+			items = append(items, webhook)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error {
+	defer s.lock()()
+
+	if s.webhookIndex(arg.WebhookID) < 0 {
+		return foreignKeyViolation("webhook_deliveries_webhook_id_fkey")
+	}
+	if s.outboxIndex(arg.EventID) < 0 {
+		return foreignKeyViolation("webhook_deliveries_event_id_fkey")
+	}
+
+	// ON CONFLICT (webhook_id, event_id) DO NOTHING
+	if slices.ContainsFunc(s.data.deliveries, func(delivery database.WebhookDelivery) bool {
+		return delivery.WebhookID == arg.WebhookID && delivery.EventID == arg.EventID
+	}) {
+		return nil
+	}
+
+	s.data.nextDeliveryID++
+	s.data.deliveries = append(s.data.deliveries, database.WebhookDelivery{
+		ID:            s.data.nextDeliveryID,
+		WebhookID:     arg.WebhookID,
+		EventID:       arg.EventID,
+		EventType:     arg.EventType,
+		Status:        "pending",
+		NextAttemptAt: arg.NextAttemptAt,
+		CreatedAt:     arg.NextAttemptAt,
+		UpdatedAt:     arg.NextAttemptAt,
+	})
+	return nil
+}
+
+func (s *Store) ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	defer s.lock()()
+
+	var due []int
+	for i, delivery := range s.data.deliveries {
+		if delivery.Status == "pending" && !delivery.NextAttemptAt.After(arg.Now) {
+			due = append(due, i)
+		}
+	}
+
+	slices.SortStableFunc(due, func(a, b int) int {
+		return s.data.deliveries[a].NextAttemptAt.Compare(s.data.deliveries[b].NextAttemptAt)
+	})
+	due = due[:min(len(due), int(arg.BatchSize))]
+
+	var items []database.WebhookDelivery
+	for _, i := range due {
+		delivery := s.data.deliveries[i]
+		delivery.NextAttemptAt = arg.LeaseUntil
+		s.data.deliveries[i] = delivery
+		items = append(items, delivery)
+	}
+	return items, nil
+}
+
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, arg database.UpdateWebhookDeliveryParams) error {
+	defer s.lock()()
+
+	for i, delivery := range s.data.deliveries {
+		if delivery.ID == arg.ID {
+			delivery.Status = arg.Status
+			delivery.Attempts = arg.Attempts
+			delivery.NextAttemptAt = arg.NextAttemptAt
+			delivery.LastStatusCode = arg.LastStatusCode
+			delivery.LastError = arg.LastError
+			delivery.UpdatedAt = arg.UpdatedAt
+			s.data.deliveries[i] = delivery
+		}
+	}
+	return nil
+}
+
+func (s *Store) RecordWebhookSuccess(ctx context.Context, id uuid.UUID) error {
+	defer s.lock()()
+
+	if i := s.webhookIndex(id); i >= 0 {
+		s.data.webhooks[i].ConsecutiveFailures = 0
+	}
+	return nil
+}
+
+func (s *Store) RecordWebhookFailure(ctx context.Context, arg database.RecordWebhookFailureParams) (database.Webhook, error) {
+	defer s.lock()()
+
+	i := s.webhookIndex(arg.ID)
+	if i < 0 {
+		return database.Webhook{}, sql.ErrNoRows
+	}
+
+	webhook := s.data.webhooks[i]
+	if webhook.ConsecutiveFailures+1 >= arg.DisableAfter {
+		if webhook.Enabled {
+			webhook.DisabledAt = sql.NullTime{Time: arg.Now, Valid: true}
+		}
+		webhook.Enabled = false
+	}
+	webhook.ConsecutiveFailures++
+	webhook.UpdatedAt = arg.Now
+	s.data.webhooks[i] = webhook
+	return webhook, nil
+}
+
+func (s *Store) GetWebhookDeliveries(ctx context.Context, arg database.GetWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	defer s.lock()()
+
+	var items []database.WebhookDelivery
+	for _, delivery := range s.data.deliveries {
+		if delivery.WebhookID == arg.WebhookID {
+			items = append(items, delivery)
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b database.WebhookDelivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return items[:min(len(items), int(arg.Limit))], nil
+}
+
+func (s *Store) PruneWebhookDeliveries(ctx context.Context, arg database.PruneWebhookDeliveriesParams) (int64, error) {
+	defer s.lock()()
+
+	var pruned int64
+	s.data.deliveries = slices.DeleteFunc(s.data.deliveries, func(delivery database.WebhookDelivery) bool {
+		if pruned == int64(arg.BatchSize) || delivery.Status == "pending" || !delivery.UpdatedAt.Before(arg.Before) {
+			return false
+		}
+		pruned++
+		return true
+	})
+	return pruned, nil
+}
@@ -13,10 +13,28 @@ import (
 	"github.com/google/uuid"
 )
 
+const cancelScheduledMessage = `-- name: CancelScheduledMessage :execrows
+UPDATE messages SET deleted = true
+WHERE id = $1 AND sender_id = $2 AND deleted = false AND delivered_at IS NULL
+`
+
+type CancelScheduledMessageParams struct {
+	ID       uuid.UUID
+	SenderID uuid.UUID
+}
+
+func (q *Queries) CancelScheduledMessage(ctx context.Context, arg CancelScheduledMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelScheduledMessage, arg.ID, arg.SenderID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createMessage = `-- name: CreateMessage :one
-INSERT INTO messages (id, sender_id, recipient_id, content, created_at, ttl_seconds, expires_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
-RETURNING id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted
+INSERT INTO messages (id, sender_id, recipient_id, content, created_at, ttl_seconds, expires_at, deliver_at, delivered_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at
 `
 
 type CreateMessageParams struct {
@@ -27,6 +45,8 @@ type CreateMessageParams struct {
 	CreatedAt   time.Time
 	TtlSeconds  sql.NullInt32
 	ExpiresAt   sql.NullTime
+	DeliverAt   sql.NullTime
+	DeliveredAt sql.NullTime
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -38,6 +58,8 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.CreatedAt,
 		arg.TtlSeconds,
 		arg.ExpiresAt,
+		arg.DeliverAt,
+		arg.DeliveredAt,
 	)
 	var i Message
 	err := row.Scan(
@@ -50,14 +72,189 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.TtlSeconds,
 		&i.ExpiresAt,
 		&i.Deleted,
+		&i.DeliverAt,
+		&i.DeliveredAt,
 	)
 	return i, err
 }
 
+const expireMessages = `-- name: ExpireMessages :many
+UPDATE messages SET deleted = true, content = ''
+WHERE id IN (
+    SELECT expiring.id FROM messages AS expiring
+    WHERE expiring.deleted = false AND expiring.expires_at <= $1
+    ORDER BY expiring.expires_at ASC
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at
+`
+
+type ExpireMessagesParams struct {
+	Now       sql.NullTime
+	BatchSize int32
+}
+
+func (q *Queries) ExpireMessages(ctx context.Context, arg ExpireMessagesParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, expireMessages, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderID,
+			&i.RecipientID,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ReadAt,
+			&i.TtlSeconds,
+			&i.ExpiresAt,
+			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getConversationSummaries = `-- name: GetConversationSummaries :many
+WITH visible AS (
+    SELECT id, sender_id, recipient_id, content, read_at, expires_at,
+    COALESCE(delivered_at, created_at) AS sent_at,
+    (CASE WHEN sender_id = $1 THEN recipient_id ELSE sender_id END)::uuid AS counterpart_id
+    FROM messages
+    WHERE (sender_id = $1 OR recipient_id = $1)
+    AND deleted = false AND delivered_at IS NOT NULL
+    AND (expires_at IS NULL OR expires_at > $2)
+), latest AS (
+    SELECT DISTINCT ON (counterpart_id) counterpart_id, id, sender_id, content, sent_at
+    FROM visible
+    ORDER BY counterpart_id, sent_at DESC
+), unread AS (
+    SELECT counterpart_id, COUNT(*) AS unread_count
+    FROM visible
+    WHERE recipient_id = $1 AND read_at IS NULL
+    GROUP BY counterpart_id
+)
+SELECT users.username AS counterpart_username, latest.counterpart_id,
+latest.id AS last_message_id, latest.sender_id AS last_message_sender_id,
+latest.content AS last_message_content, latest.sent_at AS last_activity_at,
+COALESCE(unread.unread_count, 0)::bigint AS unread_count
+FROM latest
+JOIN users ON users.id = latest.counterpart_id
+LEFT JOIN unread ON unread.counterpart_id = latest.counterpart_id
+ORDER BY latest.sent_at DESC
+`
+
+type GetConversationSummariesParams struct {
+	UserID uuid.UUID
+	Now    sql.NullTime
+}
+
+type GetConversationSummariesRow struct {
+	CounterpartUsername string
+	CounterpartID       uuid.UUID
+	LastMessageID       uuid.UUID
+	LastMessageSenderID uuid.UUID
+	LastMessageContent  string
+	LastActivityAt      time.Time
+	UnreadCount         int64
+}
+
+func (q *Queries) GetConversationSummaries(ctx context.Context, arg GetConversationSummariesParams) ([]GetConversationSummariesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationSummaries, arg.UserID, arg.Now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetConversationSummariesRow
+	for rows.Next() {
+		var i GetConversationSummariesRow
+		if err := rows.Scan(
+			&i.CounterpartUsername,
+			&i.CounterpartID,
+			&i.LastMessageID,
+			&i.LastMessageSenderID,
+			&i.LastMessageContent,
+			&i.LastActivityAt,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDueScheduledMessages = `-- name: GetDueScheduledMessages :many
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE delivered_at IS NULL AND deleted = false AND deliver_at <= $1
+ORDER BY deliver_at ASC LIMIT $2 FOR UPDATE SKIP LOCKED
+`
+
+type GetDueScheduledMessagesParams struct {
+	DeliverAt sql.NullTime
+	Limit     int32
+}
+
+func (q *Queries) GetDueScheduledMessages(ctx context.Context, arg GetDueScheduledMessagesParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, getDueScheduledMessages, arg.DeliverAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderID,
+			&i.RecipientID,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ReadAt,
+			&i.TtlSeconds,
+			&i.ExpiresAt,
+			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getMessageHistoryWithNamedUser = `-- name: GetMessageHistoryWithNamedUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE (sender_id = $1 AND recipient_id = $2) OR (sender_id = $2 AND recipient_id = $1) 
-AND deleted = false ORDER BY created_at DESC
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE ((sender_id = $1 AND recipient_id = $2) OR (sender_id = $2 AND recipient_id = $1))
+AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC
 `
 
 type GetMessageHistoryWithNamedUserParams struct {
@@ -84,6 +281,8 @@ func (q *Queries) GetMessageHistoryWithNamedUser(ctx context.Context, arg GetMes
 			&i.TtlSeconds,
 			&i.ExpiresAt,
 			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -99,8 +298,8 @@ func (q *Queries) GetMessageHistoryWithNamedUser(ctx context.Context, arg GetMes
 }
 
 const getReceivedMessagesFromNamedUser = `-- name: GetReceivedMessagesFromNamedUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE recipient_id = $1 AND sender_id = $2 AND deleted = false ORDER BY created_at DESC
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE recipient_id = $1 AND sender_id = $2 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC
 `
 
 type GetReceivedMessagesFromNamedUserParams struct {
@@ -127,6 +326,8 @@ func (q *Queries) GetReceivedMessagesFromNamedUser(ctx context.Context, arg GetR
 			&i.TtlSeconds,
 			&i.ExpiresAt,
 			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -142,8 +343,8 @@ func (q *Queries) GetReceivedMessagesFromNamedUser(ctx context.Context, arg GetR
 }
 
 const getReceivedMessagesToThisUser = `-- name: GetReceivedMessagesToThisUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE recipient_id = $1 AND deleted = false ORDER BY created_at DESC
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE recipient_id = $1 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC
 `
 
 func (q *Queries) GetReceivedMessagesToThisUser(ctx context.Context, recipientID uuid.UUID) ([]Message, error) {
@@ -165,6 +366,77 @@ func (q *Queries) GetReceivedMessagesToThisUser(ctx context.Context, recipientID
 			&i.TtlSeconds,
 			&i.ExpiresAt,
 			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getScheduledMessage = `-- name: GetScheduledMessage :one
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE id = $1 AND sender_id = $2 AND deleted = false AND delivered_at IS NULL
+`
+
+type GetScheduledMessageParams struct {
+	ID       uuid.UUID
+	SenderID uuid.UUID
+}
+
+func (q *Queries) GetScheduledMessage(ctx context.Context, arg GetScheduledMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, getScheduledMessage, arg.ID, arg.SenderID)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ReadAt,
+		&i.TtlSeconds,
+		&i.ExpiresAt,
+		&i.Deleted,
+		&i.DeliverAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const getScheduledMessagesFromThisUser = `-- name: GetScheduledMessagesFromThisUser :many
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE sender_id = $1 AND deleted = false AND delivered_at IS NULL ORDER BY deliver_at ASC
+`
+
+func (q *Queries) GetScheduledMessagesFromThisUser(ctx context.Context, senderID uuid.UUID) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, getScheduledMessagesFromThisUser, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderID,
+			&i.RecipientID,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ReadAt,
+			&i.TtlSeconds,
+			&i.ExpiresAt,
+			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -180,8 +452,8 @@ func (q *Queries) GetReceivedMessagesToThisUser(ctx context.Context, recipientID
 }
 
 const getSentMessagesFromThisUser = `-- name: GetSentMessagesFromThisUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE sender_id = $1 AND deleted = false ORDER BY created_at DESC
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE sender_id = $1 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC
 `
 
 func (q *Queries) GetSentMessagesFromThisUser(ctx context.Context, senderID uuid.UUID) ([]Message, error) {
@@ -203,6 +475,8 @@ func (q *Queries) GetSentMessagesFromThisUser(ctx context.Context, senderID uuid
 			&i.TtlSeconds,
 			&i.ExpiresAt,
 			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -218,8 +492,8 @@ func (q *Queries) GetSentMessagesFromThisUser(ctx context.Context, senderID uuid
 }
 
 const getSentMessagesToNamedUser = `-- name: GetSentMessagesToNamedUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE sender_id = $1 AND recipient_id = $2 AND deleted = false ORDER BY created_at DESC
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE sender_id = $1 AND recipient_id = $2 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC
 `
 
 type GetSentMessagesToNamedUserParams struct {
@@ -246,6 +520,8 @@ func (q *Queries) GetSentMessagesToNamedUser(ctx context.Context, arg GetSentMes
 			&i.TtlSeconds,
 			&i.ExpiresAt,
 			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -261,8 +537,8 @@ func (q *Queries) GetSentMessagesToNamedUser(ctx context.Context, arg GetSentMes
 }
 
 const getUserMessageHistory = `-- name: GetUserMessageHistory :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE sender_id = $1 OR recipient_id = $1 AND deleted = false ORDER BY created_at DESC
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE (sender_id = $1 OR recipient_id = $1) AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC
 `
 
 func (q *Queries) GetUserMessageHistory(ctx context.Context, senderID uuid.UUID) ([]Message, error) {
@@ -284,6 +560,8 @@ func (q *Queries) GetUserMessageHistory(ctx context.Context, senderID uuid.UUID)
 			&i.TtlSeconds,
 			&i.ExpiresAt,
 			&i.Deleted,
+			&i.DeliverAt,
+			&i.DeliveredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -297,3 +575,183 @@ func (q *Queries) GetUserMessageHistory(ctx context.Context, senderID uuid.UUID)
 	}
 	return items, nil
 }
+
+const markConversationRead = `-- name: MarkConversationRead :many
+UPDATE messages SET read_at = $1
+WHERE recipient_id = $2 AND sender_id = $3
+AND read_at IS NULL AND deleted = false AND delivered_at IS NOT NULL
+AND COALESCE(delivered_at, created_at) <= $4::timestamp
+RETURNING id
+`
+
+type MarkConversationReadParams struct {
+	ReadAt      sql.NullTime
+	RecipientID uuid.UUID
+	SenderID    uuid.UUID
+	UpTo        time.Time
+}
+
+func (q *Queries) MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, markConversationRead,
+		arg.ReadAt,
+		arg.RecipientID,
+		arg.SenderID,
+		arg.UpTo,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markMessageDelivered = `-- name: MarkMessageDelivered :exec
+UPDATE messages SET
+delivered_at = $1, expires_at = $2
+WHERE id = $3 AND delivered_at IS NULL
+`
+
+type MarkMessageDeliveredParams struct {
+	DeliveredAt sql.NullTime
+	ExpiresAt   sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) MarkMessageDelivered(ctx context.Context, arg MarkMessageDeliveredParams) error {
+	_, err := q.db.ExecContext(ctx, markMessageDelivered, arg.DeliveredAt, arg.ExpiresAt, arg.ID)
+	return err
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT messages.id, messages.sender_id, messages.recipient_id, messages.content, messages.created_at, messages.read_at, messages.ttl_seconds, messages.expires_at, messages.deleted, messages.deliver_at, messages.delivered_at,
+ts_headline('english',
+    replace(replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+    websearch_to_tsquery('english', $1),
+    'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')::text AS snippet
+FROM messages
+WHERE (sender_id = $2 OR recipient_id = $2)
+AND to_tsvector('english', content) @@ websearch_to_tsquery('english', $1)
+AND deleted = false AND delivered_at IS NOT NULL
+AND (expires_at IS NULL OR expires_at > $3)
+AND ($4::uuid IS NULL OR sender_id = $4 OR recipient_id = $4)
+AND ($5::timestamp IS NULL OR COALESCE(delivered_at, created_at) >= $5)
+AND ($6::timestamp IS NULL OR COALESCE(delivered_at, created_at) < $6)
+ORDER BY COALESCE(delivered_at, created_at) DESC
+LIMIT $8 OFFSET $7
+`
+
+type SearchMessagesParams struct {
+	Query         string
+	UserID        uuid.UUID
+	Now           sql.NullTime
+	CounterpartID uuid.NullUUID
+	SentAfter     sql.NullTime
+	SentBefore    sql.NullTime
+	PageOffset    int32
+	PageLimit     int32
+}
+
+type SearchMessagesRow struct {
+	Message Message
+	Snippet string
+}
+
+// The snippet is HTML, so the content is escaped (as html.EscapeString does) before the matches are marked
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchMessages,
+		arg.Query,
+		arg.UserID,
+		arg.Now,
+		arg.CounterpartID,
+		arg.SentAfter,
+		arg.SentBefore,
+		arg.PageOffset,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchMessagesRow
+	for rows.Next() {
+		var i SearchMessagesRow
+		if err := rows.Scan(
+			&i.Message.ID,
+			&i.Message.SenderID,
+			&i.Message.RecipientID,
+			&i.Message.Content,
+			&i.Message.CreatedAt,
+			&i.Message.ReadAt,
+			&i.Message.TtlSeconds,
+			&i.Message.ExpiresAt,
+			&i.Message.Deleted,
+			&i.Message.DeliverAt,
+			&i.Message.DeliveredAt,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateScheduledMessage = `-- name: UpdateScheduledMessage :one
+UPDATE messages SET
+content = $1, ttl_seconds = $2, deliver_at = $3
+WHERE id = $4 AND sender_id = $5 AND deleted = false AND delivered_at IS NULL
+RETURNING id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at
+`
+
+type UpdateScheduledMessageParams struct {
+	Content    string
+	TtlSeconds sql.NullInt32
+	DeliverAt  sql.NullTime
+	ID         uuid.UUID
+	SenderID   uuid.UUID
+}
+
+func (q *Queries) UpdateScheduledMessage(ctx context.Context, arg UpdateScheduledMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledMessage,
+		arg.Content,
+		arg.TtlSeconds,
+		arg.DeliverAt,
+		arg.ID,
+		arg.SenderID,
+	)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ReadAt,
+		&i.TtlSeconds,
+		&i.ExpiresAt,
+		&i.Deleted,
+		&i.DeliverAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
@@ -0,0 +1,552 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+// alice, bob and carol with a few delivered messages a minute apart, oldest first:
+// alice->bob "one", bob->alice "two", alice->carol "three", carol->bob "four"
+func seedConversation(t *testing.T, store *database.SQLStore) (alice, bob, carol database.User, messages []database.Message) {
+	t.Helper()
+
+	alice = createUser(t, store, "alice")
+	bob = createUser(t, store, "bob")
+	carol = createUser(t, store, "carol")
+
+	base := now().Add(-time.Hour)
+	messages = []database.Message{
+		createMessage(t, store, alice, bob, "one", base),
+		createMessage(t, store, bob, alice, "two", base.Add(time.Minute)),
+		createMessage(t, store, alice, carol, "three", base.Add(2*time.Minute)),
+		createMessage(t, store, carol, bob, "four", base.Add(3*time.Minute)),
+	}
+	return alice, bob, carol, messages
+}
+
+func TestMessageHistoryQueries(t *testing.T) {
+	store := newStore(t)
+	alice, bob, carol, _ := seedConversation(t, store)
+
+	sent, err := store.GetSentMessagesFromThisUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, sent, "three", "one")
+
+	sentTo, err := store.GetSentMessagesToNamedUser(ctx, database.GetSentMessagesToNamedUserParams{SenderID: alice.ID, RecipientID: bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, sentTo, "one")
+
+	received, err := store.GetReceivedMessagesToThisUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, received, "four", "one")
+
+	receivedFrom, err := store.GetReceivedMessagesFromNamedUser(ctx, database.GetReceivedMessagesFromNamedUserParams{RecipientID: bob.ID, SenderID: carol.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, receivedFrom, "four")
+
+	history, err := store.GetUserMessageHistory(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, history, "three", "two", "one")
+
+	withBob, err := store.GetMessageHistoryWithNamedUser(ctx, database.GetMessageHistoryWithNamedUserParams{SenderID: bob.ID, RecipientID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, withBob, "two", "one")
+}
+
+// Every history query hides deleted messages, in both directions. GetUserMessageHistory used to
+// leak deleted sent messages because of AND/OR precedence
+func TestMessageHistoryHidesDeletedMessages(t *testing.T) {
+	store := newStore(t)
+	alice, bob, carol, messages := seedConversation(t, store)
+
+	deleteMessage(t, store, messages[0]) // alice->bob "one"
+	deleteMessage(t, store, messages[1]) // bob->alice "two"
+
+	history, err := store.GetUserMessageHistory(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, history, "three")
+
+	history, err = store.GetUserMessageHistory(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, history, "four")
+
+	sent, err := store.GetSentMessagesFromThisUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, sent, "three")
+
+	sentTo, err := store.GetSentMessagesToNamedUser(ctx, database.GetSentMessagesToNamedUserParams{SenderID: alice.ID, RecipientID: bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, sentTo)
+
+	received, err := store.GetReceivedMessagesToThisUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, received)
+
+	receivedFrom, err := store.GetReceivedMessagesFromNamedUser(ctx, database.GetReceivedMessagesFromNamedUserParams{RecipientID: alice.ID, SenderID: bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, receivedFrom)
+
+	withBob, err := store.GetMessageHistoryWithNamedUser(ctx, database.GetMessageHistoryWithNamedUserParams{SenderID: alice.ID, RecipientID: bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, withBob)
+
+	withCarol, err := store.GetMessageHistoryWithNamedUser(ctx, database.GetMessageHistoryWithNamedUserParams{SenderID: carol.ID, RecipientID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, withCarol, "three")
+}
+
+func TestMessageHistoryHidesScheduledMessages(t *testing.T) {
+	store := newStore(t)
+	alice, bob, _, _ := seedConversation(t, store)
+	scheduleMessage(t, store, alice, bob, "later", now().Add(time.Hour))
+
+	history, err := store.GetMessageHistoryWithNamedUser(ctx, database.GetMessageHistoryWithNamedUserParams{SenderID: bob.ID, RecipientID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, history, "two", "one")
+
+	received, err := store.GetReceivedMessagesToThisUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, received, "four", "one")
+}
+
+func TestCreateMessageRequiresExistingUsers(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+
+	_, err := store.CreateMessage(ctx, database.CreateMessageParams{
+		ID:          uuid.New(),
+		SenderID:    alice.ID,
+		RecipientID: uuid.New(),
+		Content:     "hello?",
+		CreatedAt:   now(),
+		DeliveredAt: validTime(now()),
+	})
+	assertPQCode(t, err, "23503")
+}
+
+func TestScheduledMessageQueries(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	soon := scheduleMessage(t, store, alice, bob, "soon", now().Add(time.Minute))
+	later := scheduleMessage(t, store, alice, bob, "later", now().Add(time.Hour))
+	createMessage(t, store, alice, bob, "delivered", now())
+
+	scheduled, err := store.GetScheduledMessagesFromThisUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, scheduled, "soon", "later")
+
+	got, err := store.GetScheduledMessage(ctx, database.GetScheduledMessageParams{ID: soon.ID, SenderID: alice.ID})
+	if err != nil || got.Content != "soon" {
+		t.Errorf("GetScheduledMessage: got %+v, %v", got, err)
+	}
+
+	// Only the sender can see or edit a scheduled message
+	if _, err := store.GetScheduledMessage(ctx, database.GetScheduledMessageParams{ID: soon.ID, SenderID: bob.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetScheduledMessage as the recipient: got %v, want sql.ErrNoRows", err)
+	}
+
+	updated, err := store.UpdateScheduledMessage(ctx, database.UpdateScheduledMessageParams{
+		Content:    "sooner",
+		TtlSeconds: sql.NullInt32{Valid: false},
+		DeliverAt:  validTime(now().Add(30 * time.Second)),
+		ID:         soon.ID,
+		SenderID:   alice.ID,
+	})
+	if err != nil || updated.Content != "sooner" || updated.TtlSeconds.Valid {
+		t.Errorf("UpdateScheduledMessage: got %+v, %v", updated, err)
+	}
+
+	if _, err := store.UpdateScheduledMessage(ctx, database.UpdateScheduledMessageParams{Content: "x", ID: soon.ID, SenderID: bob.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateScheduledMessage as the recipient: got %v, want sql.ErrNoRows", err)
+	}
+
+	cancelled, err := store.CancelScheduledMessage(ctx, database.CancelScheduledMessageParams{ID: later.ID, SenderID: alice.ID})
+	if err != nil || cancelled != 1 {
+		t.Errorf("CancelScheduledMessage: got %d, %v", cancelled, err)
+	}
+
+	cancelled, err = store.CancelScheduledMessage(ctx, database.CancelScheduledMessageParams{ID: later.ID, SenderID: alice.ID})
+	if err != nil || cancelled != 0 {
+		t.Errorf("CancelScheduledMessage twice: got %d, %v", cancelled, err)
+	}
+
+	scheduled, err = store.GetScheduledMessagesFromThisUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, scheduled, "sooner")
+}
+
+func TestDispatchingScheduledMessages(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	scheduleMessage(t, store, alice, bob, "due first", now().Add(-2*time.Minute))
+	scheduleMessage(t, store, alice, bob, "due second", now().Add(-time.Minute))
+	scheduleMessage(t, store, alice, bob, "not due", now().Add(time.Hour))
+
+	due, err := store.GetDueScheduledMessages(ctx, database.GetDueScheduledMessagesParams{DeliverAt: validTime(now()), Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, due, "due first", "due second")
+
+	limited, err := store.GetDueScheduledMessages(ctx, database.GetDueScheduledMessagesParams{DeliverAt: validTime(now()), Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, limited, "due first")
+
+	deliveredAt := now()
+	err = store.MarkMessageDelivered(ctx, database.MarkMessageDeliveredParams{
+		DeliveredAt: validTime(deliveredAt),
+		ExpiresAt:   validTime(deliveredAt.Add(time.Minute)),
+		ID:          due[0].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := store.GetReceivedMessagesToThisUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, received, "due first")
+	if !received[0].DeliveredAt.Time.Equal(deliveredAt) || !received[0].ExpiresAt.Valid {
+		t.Errorf("delivered message: got %+v", received[0])
+	}
+
+	// Delivering twice does not move delivered_at
+	err = store.MarkMessageDelivered(ctx, database.MarkMessageDeliveredParams{
+		DeliveredAt: validTime(deliveredAt.Add(time.Hour)),
+		ID:          due[0].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received, err = store.GetReceivedMessagesToThisUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received[0].DeliveredAt.Time.Equal(deliveredAt) {
+		t.Errorf("second delivery changed delivered_at to %v", received[0].DeliveredAt.Time)
+	}
+
+	due, err = store.GetDueScheduledMessages(ctx, database.GetDueScheduledMessagesParams{DeliverAt: validTime(now()), Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, due, "due second")
+}
+
+// A scheduled message sorts by when it was delivered, not when it was written
+func TestDeliveredScheduledMessagesSortByDelivery(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	deliveredAt := now()
+	scheduled, err := store.CreateMessage(ctx, database.CreateMessageParams{
+		ID:          uuid.New(),
+		SenderID:    alice.ID,
+		RecipientID: bob.ID,
+		Content:     "scheduled",
+		CreatedAt:   deliveredAt.Add(-10 * time.Minute),
+		DeliverAt:   validTime(deliveredAt),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createMessage(t, store, alice, bob, "sent directly", deliveredAt.Add(-5*time.Minute))
+	err = store.MarkMessageDelivered(ctx, database.MarkMessageDeliveredParams{DeliveredAt: validTime(deliveredAt), ID: scheduled.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := store.GetReceivedMessagesToThisUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, received, "scheduled", "sent directly")
+
+	summaries, err := store.GetConversationSummaries(ctx, database.GetConversationSummariesParams{UserID: bob.ID, Now: validTime(now())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].LastMessageID != scheduled.ID || !summaries[0].LastActivityAt.Equal(deliveredAt) {
+		t.Errorf("got summaries %+v, want the scheduled message delivered at %v last", summaries, deliveredAt)
+	}
+
+	// Search filters on the delivery time too, not on when the message was scheduled
+	rows, err := store.SearchMessages(ctx, database.SearchMessagesParams{
+		Query:     "scheduled",
+		UserID:    bob.ID,
+		Now:       validTime(now()),
+		SentAfter: validTime(deliveredAt.Add(-time.Minute)),
+		PageLimit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Message.ID != scheduled.ID {
+		t.Errorf("SearchMessages: got %+v, want the scheduled message", rows)
+	}
+
+	// Reading up to before the delivery leaves the scheduled message unread
+	marked, err := store.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:      validTime(now()),
+		RecipientID: bob.ID,
+		SenderID:    alice.ID,
+		UpTo:        deliveredAt.Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(marked) != 1 || marked[0] == scheduled.ID {
+		t.Errorf("MarkConversationRead: got %v, want only the message sent directly", marked)
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	carol := createUser(t, store, "carol")
+
+	base := now().Add(-time.Hour)
+	createMessage(t, store, alice, bob, "The cats were running home", base)
+	createMessage(t, store, bob, alice, "A cat runs faster than a dog", base.Add(time.Minute))
+	createMessage(t, store, carol, alice, "My cat is running late", base.Add(2*time.Minute))
+	createMessage(t, store, alice, bob, "Nothing to see here", base.Add(3*time.Minute))
+	deleted := createMessage(t, store, bob, alice, "Deleted cat runs", base.Add(4*time.Minute))
+	deleteMessage(t, store, deleted)
+
+	_, err := store.CreateMessage(ctx, database.CreateMessageParams{
+		ID:          uuid.New(),
+		SenderID:    bob.ID,
+		RecipientID: alice.ID,
+		Content:     "Expired cat runs",
+		CreatedAt:   base.Add(5 * time.Minute),
+		ExpiresAt:   validTime(now().Add(-time.Minute)),
+		DeliveredAt: validTime(base.Add(5 * time.Minute)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(params database.SearchMessagesParams) []database.SearchMessagesRow {
+		t.Helper()
+
+		params.UserID = alice.ID
+		params.Now = validTime(now())
+		if params.PageLimit == 0 {
+			params.PageLimit = 10
+		}
+
+		rows, err := store.SearchMessages(ctx, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	rowMessages := func(rows []database.SearchMessagesRow) []database.Message {
+		messages := make([]database.Message, len(rows))
+		for i := range rows {
+			messages[i] = rows[i].Message
+		}
+		return messages
+	}
+
+	// Stemming matches "cats"/"cat" and "running"/"runs"
+	rows := search(database.SearchMessagesParams{Query: "cat run"})
+	assertContents(t, rowMessages(rows), "My cat is running late", "A cat runs faster than a dog", "The cats were running home")
+	if !strings.Contains(rows[0].Snippet, "<mark>") {
+		t.Errorf("snippet %q does not highlight the match", rows[0].Snippet)
+	}
+
+	rows = search(database.SearchMessagesParams{Query: "cat", CounterpartID: uuid.NullUUID{UUID: bob.ID, Valid: true}})
+	assertContents(t, rowMessages(rows), "A cat runs faster than a dog", "The cats were running home")
+
+	rows = search(database.SearchMessagesParams{Query: "cat", SentAfter: validTime(base.Add(time.Minute)), SentBefore: validTime(base.Add(2 * time.Minute))})
+	assertContents(t, rowMessages(rows), "A cat runs faster than a dog")
+
+	rows = search(database.SearchMessagesParams{Query: "cat", PageOffset: 1, PageLimit: 1})
+	assertContents(t, rowMessages(rows), "A cat runs faster than a dog")
+
+	rows = search(database.SearchMessagesParams{Query: "cat -dog"})
+	assertContents(t, rowMessages(rows), "My cat is running late", "The cats were running home")
+
+	// The snippet is HTML, so markup in the content comes back escaped
+	createMessage(t, store, bob, alice, `<img src=x onerror="alert('cat')"> & a cat`, base.Add(6*time.Minute))
+	rows = search(database.SearchMessagesParams{Query: "alert"})
+	if len(rows) != 1 || strings.Contains(rows[0].Snippet, "<img") || !strings.Contains(rows[0].Snippet, "&lt;img") || !strings.Contains(rows[0].Snippet, "<mark>alert</mark>") {
+		t.Errorf("got snippets %+v, want the content escaped with alert marked", rows)
+	}
+
+	// Messages between other users never show up
+	rows, err = store.SearchMessages(ctx, database.SearchMessagesParams{Query: "cat", UserID: carol.ID, Now: validTime(now()), PageLimit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, rowMessages(rows), "My cat is running late")
+}
+
+func TestConversationSummariesAndMarkRead(t *testing.T) {
+	store := newStore(t)
+	alice, bob, carol, messages := seedConversation(t, store)
+	createMessage(t, store, bob, alice, "five", messages[3].CreatedAt.Add(time.Minute))
+
+	summaries, err := store.GetConversationSummaries(ctx, database.GetConversationSummariesParams{UserID: alice.ID, Now: validTime(now())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("got %d conversations, want 2: %+v", len(summaries), summaries)
+	}
+
+	withBob, withCarol := summaries[0], summaries[1]
+	if withBob.CounterpartID != bob.ID || withBob.LastMessageContent != "five" || withBob.UnreadCount != 2 {
+		t.Errorf("conversation with bob: got %+v", withBob)
+	}
+	if withCarol.CounterpartUsername != "carol" || withCarol.LastMessageSenderID != alice.ID || withCarol.UnreadCount != 0 {
+		t.Errorf("conversation with carol: got %+v", withCarol)
+	}
+
+	// Only bob's messages up to "two" are marked
+	readAt := now()
+	marked, err := store.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:      validTime(readAt),
+		RecipientID: alice.ID,
+		SenderID:    bob.ID,
+		UpTo:        messages[1].CreatedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(marked) != 1 || marked[0] != messages[1].ID {
+		t.Errorf("MarkConversationRead: got %v, want only %s", marked, messages[1].ID)
+	}
+
+	marked, err = store.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:      validTime(readAt),
+		RecipientID: alice.ID,
+		SenderID:    bob.ID,
+		UpTo:        now(),
+	})
+	if err != nil || len(marked) != 1 {
+		t.Errorf("marking the rest: got %v, %v", marked, err)
+	}
+
+	summaries, err = store.GetConversationSummaries(ctx, database.GetConversationSummariesParams{UserID: alice.ID, Now: validTime(now())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summaries[0].UnreadCount != 0 {
+		t.Errorf("after marking read: got %+v", summaries[0])
+	}
+
+	// Deleted messages drop out of the summaries
+	for _, message := range messages {
+		if message.SenderID == carol.ID || message.RecipientID == carol.ID {
+			deleteMessage(t, store, message)
+		}
+	}
+	summaries, err = store.GetConversationSummaries(ctx, database.GetConversationSummariesParams{UserID: alice.ID, Now: validTime(now())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].CounterpartID != bob.ID {
+		t.Errorf("after deleting carol's conversation: got %+v", summaries)
+	}
+}
+
+func TestExpireMessages(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	for i, content := range []string{"expired first", "expired second", "not expired"} {
+		expiresAt := now().Add(time.Duration(i-2) * time.Minute)
+		if i == 2 {
+			expiresAt = now().Add(time.Hour)
+		}
+
+		_, err := store.CreateMessage(ctx, database.CreateMessageParams{
+			ID:          uuid.New(),
+			SenderID:    alice.ID,
+			RecipientID: bob.ID,
+			Content:     content,
+			CreatedAt:   now(),
+			TtlSeconds:  sql.NullInt32{Int32: 60, Valid: true},
+			ExpiresAt:   validTime(expiresAt),
+			DeliveredAt: validTime(now()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expired, err := store.ExpireMessages(ctx, database.ExpireMessagesParams{Now: validTime(now()), BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || !expired[0].Deleted || expired[0].Content != "" {
+		t.Fatalf("first batch: got %+v", expired)
+	}
+
+	expired, err = store.ExpireMessages(ctx, database.ExpireMessagesParams{Now: validTime(now()), BatchSize: 10})
+	if err != nil || len(expired) != 1 {
+		t.Fatalf("second batch: got %+v, %v", expired, err)
+	}
+
+	received, err := store.GetReceivedMessagesToThisUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, received, "not expired")
+}
@@ -6,11 +6,26 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Contact struct {
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+	Status      string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
 type Message struct {
 	ID          uuid.UUID
 	SenderID    uuid.UUID
@@ -21,6 +36,17 @@ type Message struct {
 	TtlSeconds  sql.NullInt32
 	ExpiresAt   sql.NullTime
 	Deleted     bool
+	DeliverAt   sql.NullTime
+	DeliveredAt sql.NullTime
+}
+
+type Outbox struct {
+	ID          int64
+	EventType   string
+	Payload     json.RawMessage
+	UserIds     []uuid.UUID
+	CreatedAt   time.Time
+	ProcessedAt sql.NullTime
 }
 
 type RefreshToken struct {
@@ -32,11 +58,56 @@ type RefreshToken struct {
 	UserID       uuid.UUID
 }
 
+type SigningKey struct {
+	ID          string
+	Purpose     string
+	Secret      string
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RetiresAt   sql.NullTime
+}
+
 type User struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Username  string
-	Password  string
-	Email     string
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Username     string
+	Password     string
+	Email        string
+	ContactsOnly bool
+	DisplayName  string
+	Bio          string
+	AvatarKey    string
+	StatusText   string
+	Timezone     string
+	HideLastSeen bool
+	IsAdmin      bool
+	SuspendedAt  sql.NullTime
+}
+
+type Webhook struct {
+	ID                  uuid.UUID
+	OwnerID             uuid.NullUUID
+	Url                 string
+	Secret              string
+	Events              []string
+	Enabled             bool
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      uuid.UUID
+	EventID        int64
+	EventType      string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
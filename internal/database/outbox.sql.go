@@ -0,0 +1,143 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: outbox.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createOutboxEvent = `-- name: CreateOutboxEvent :one
+INSERT INTO outbox (event_type, payload, user_ids, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id, event_type, payload, user_ids, created_at, processed_at
+`
+
+type CreateOutboxEventParams struct {
+	EventType string
+	Payload   json.RawMessage
+	UserIds   []uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) (Outbox, error) {
+	row := q.db.QueryRowContext(ctx, createOutboxEvent,
+		arg.EventType,
+		arg.Payload,
+		pq.Array(arg.UserIds),
+		arg.CreatedAt,
+	)
+	var i Outbox
+	err := row.Scan(
+		&i.ID,
+		&i.EventType,
+		&i.Payload,
+		pq.Array(&i.UserIds),
+		&i.CreatedAt,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getOutboxEvent = `-- name: GetOutboxEvent :one
+SELECT id, event_type, payload, user_ids, created_at, processed_at FROM outbox WHERE id = $1
+`
+
+func (q *Queries) GetOutboxEvent(ctx context.Context, id int64) (Outbox, error) {
+	row := q.db.QueryRowContext(ctx, getOutboxEvent, id)
+	var i Outbox
+	err := row.Scan(
+		&i.ID,
+		&i.EventType,
+		&i.Payload,
+		pq.Array(&i.UserIds),
+		&i.CreatedAt,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getUnprocessedOutboxEvents = `-- name: GetUnprocessedOutboxEvents :many
+SELECT id, event_type, payload, user_ids, created_at, processed_at FROM outbox
+WHERE processed_at IS NULL
+ORDER BY id ASC
+LIMIT $1 FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetUnprocessedOutboxEvents(ctx context.Context, limit int32) ([]Outbox, error) {
+	rows, err := q.db.QueryContext(ctx, getUnprocessedOutboxEvents, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventType,
+			&i.Payload,
+			pq.Array(&i.UserIds),
+			&i.CreatedAt,
+			&i.ProcessedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markOutboxEventProcessed = `-- name: MarkOutboxEventProcessed :exec
+UPDATE outbox SET processed_at = $1 WHERE id = $2
+`
+
+type MarkOutboxEventProcessedParams struct {
+	ProcessedAt sql.NullTime
+	ID          int64
+}
+
+func (q *Queries) MarkOutboxEventProcessed(ctx context.Context, arg MarkOutboxEventProcessedParams) error {
+	_, err := q.db.ExecContext(ctx, markOutboxEventProcessed, arg.ProcessedAt, arg.ID)
+	return err
+}
+
+const pruneOutboxEvents = `-- name: PruneOutboxEvents :execrows
+DELETE FROM outbox
+WHERE id IN (
+    SELECT pruned.id FROM outbox AS pruned
+    WHERE pruned.processed_at < $1::timestamp
+    AND NOT EXISTS (SELECT 1 FROM webhook_deliveries WHERE webhook_deliveries.event_id = pruned.id)
+    ORDER BY pruned.id ASC
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type PruneOutboxEventsParams struct {
+	Before    time.Time
+	BatchSize int32
+}
+
+// Events are kept until every delivery made for them has been pruned, so pending retries still have a payload
+func (q *Queries) PruneOutboxEvents(ctx context.Context, arg PruneOutboxEventsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneOutboxEvents, arg.Before, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
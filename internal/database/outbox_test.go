@@ -0,0 +1,49 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+)
+
+func TestOutboxQueries(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+
+	first := createOutboxEvent(t, store, "user.registered", alice.ID)
+	second := createOutboxEvent(t, store, "message.created")
+	third := createOutboxEvent(t, store, "message.read")
+
+	got, err := store.GetOutboxEvent(ctx, first.ID)
+	if err != nil || got.EventType != "user.registered" || len(got.UserIds) != 1 || got.UserIds[0] != alice.ID || got.ProcessedAt.Valid {
+		t.Errorf("GetOutboxEvent: got %+v, %v", got, err)
+	}
+	if string(got.Payload) != `{"hello": "world"}` {
+		t.Errorf("payload did not round trip through jsonb: got %s", got.Payload)
+	}
+
+	if err := store.MarkOutboxEventProcessed(ctx, database.MarkOutboxEventProcessedParams{ProcessedAt: validTime(now()), ID: first.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unprocessed events come back oldest first
+	unprocessed, err := store.GetUnprocessedOutboxEvents(ctx, 10)
+	if err != nil || len(unprocessed) != 2 || unprocessed[0].ID != second.ID || unprocessed[1].ID != third.ID {
+		t.Errorf("GetUnprocessedOutboxEvents: got %+v, %v", unprocessed, err)
+	}
+
+	limited, err := store.GetUnprocessedOutboxEvents(ctx, 1)
+	if err != nil || len(limited) != 1 || limited[0].ID != second.ID {
+		t.Errorf("GetUnprocessedOutboxEvents with a limit: got %+v, %v", limited, err)
+	}
+
+	if _, err := store.GetOutboxEvent(ctx, third.ID+1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetOutboxEvent for a missing event: got %v, want sql.ErrNoRows", err)
+	}
+
+	if len(second.UserIds) != 0 {
+		t.Errorf("event without subjects: got user ids %v", second.UserIds)
+	}
+}
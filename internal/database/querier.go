@@ -0,0 +1,105 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Querier interface {
+	AcceptContactRequest(ctx context.Context, arg AcceptContactRequestParams) (int64, error)
+	AreContacts(ctx context.Context, arg AreContactsParams) (bool, error)
+	CancelScheduledMessage(ctx context.Context, arg CancelScheduledMessageParams) (int64, error)
+	// Claiming pushes next_attempt_at out by a lease, so a crashed worker's claims are retried by others
+	ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	// Sessions are refresh tokens that are still valid and younger than the refresh token lifetime
+	CountActiveRefreshTokens(ctx context.Context, createdAfter time.Time) (int64, error)
+	CreateBlock(ctx context.Context, arg CreateBlockParams) error
+	CreateContactRequest(ctx context.Context, arg CreateContactRequestParams) (Contact, error)
+	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
+	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) (Outbox, error)
+	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error)
+	CreateSigningKey(ctx context.Context, arg CreateSigningKeyParams) (SigningKey, error)
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error
+	DeclineContactRequest(ctx context.Context, arg DeclineContactRequestParams) (int64, error)
+	DeleteBlock(ctx context.Context, arg DeleteBlockParams) (int64, error)
+	DeleteContact(ctx context.Context, arg DeleteContactParams) (int64, error)
+	// Messages, contacts, blocks, refresh tokens and webhooks go with the user, see the ON DELETE CASCADE
+	// foreign keys
+	DeleteUser(ctx context.Context, id uuid.UUID) (int64, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) (int64, error)
+	ExpireMessages(ctx context.Context, arg ExpireMessagesParams) ([]Message, error)
+	GetAllUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error)
+	GetBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]GetBlockedUsersRow, error)
+	GetContactBetween(ctx context.Context, arg GetContactBetweenParams) (Contact, error)
+	GetContacts(ctx context.Context, requesterID uuid.UUID) ([]GetContactsRow, error)
+	GetContactsPresenceInfo(ctx context.Context, requesterID uuid.UUID) ([]GetContactsPresenceInfoRow, error)
+	GetConversationSummaries(ctx context.Context, arg GetConversationSummariesParams) ([]GetConversationSummariesRow, error)
+	GetDatabaseSize(ctx context.Context) (int64, error)
+	GetDueScheduledMessages(ctx context.Context, arg GetDueScheduledMessagesParams) ([]Message, error)
+	GetGlobalWebhooks(ctx context.Context) ([]Webhook, error)
+	GetIncomingContactRequests(ctx context.Context, addresseeID uuid.UUID) ([]GetIncomingContactRequestsRow, error)
+	GetMessageHistoryWithNamedUser(ctx context.Context, arg GetMessageHistoryWithNamedUserParams) ([]Message, error)
+	GetOutboxEvent(ctx context.Context, id int64) (Outbox, error)
+	GetOutgoingContactRequests(ctx context.Context, requesterID uuid.UUID) ([]GetOutgoingContactRequestsRow, error)
+	GetReceivedMessagesFromNamedUser(ctx context.Context, arg GetReceivedMessagesFromNamedUserParams) ([]Message, error)
+	GetReceivedMessagesToThisUser(ctx context.Context, recipientID uuid.UUID) ([]Message, error)
+	GetRefreshToken(ctx context.Context, refreshToken string) (RefreshToken, error)
+	GetScheduledMessage(ctx context.Context, arg GetScheduledMessageParams) (Message, error)
+	GetScheduledMessagesFromThisUser(ctx context.Context, senderID uuid.UUID) ([]Message, error)
+	GetSentMessagesFromThisUser(ctx context.Context, senderID uuid.UUID) ([]Message, error)
+	GetSentMessagesToNamedUser(ctx context.Context, arg GetSentMessagesToNamedUserParams) ([]Message, error)
+	// Newest first, which is the order the authenticator picks a key to sign with
+	GetSigningKeys(ctx context.Context) ([]SigningKey, error)
+	// Row counts for ember admin stats. Expired messages are the ones the reaper has not got to yet
+	GetStorageStats(ctx context.Context, now sql.NullTime) (GetStorageStatsRow, error)
+	GetUnprocessedOutboxEvents(ctx context.Context, limit int32) ([]Outbox, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	GetUserLoginInfo(ctx context.Context, email string) (GetUserLoginInfoRow, error)
+	GetUserMessageHistory(ctx context.Context, senderID uuid.UUID) ([]Message, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error)
+	GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	GetWebhooksByOwner(ctx context.Context, ownerID uuid.NullUUID) ([]Webhook, error)
+	GetWebhooksForEvent(ctx context.Context, arg GetWebhooksForEventParams) ([]Webhook, error)
+	IsBlocked(ctx context.Context, arg IsBlockedParams) (bool, error)
+	IsUserAdmin(ctx context.Context, id uuid.UUID) (bool, error)
+	IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error)
+	MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) ([]uuid.UUID, error)
+	MarkMessageDelivered(ctx context.Context, arg MarkMessageDeliveredParams) error
+	MarkOutboxEventProcessed(ctx context.Context, arg MarkOutboxEventProcessedParams) error
+	// Events are kept until every delivery made for them has been pruned, so pending retries still have a payload
+	PruneOutboxEvents(ctx context.Context, arg PruneOutboxEventsParams) (int64, error)
+	// Pending deliveries are never pruned, however old, so a retry in progress is not lost
+	PruneWebhookDeliveries(ctx context.Context, arg PruneWebhookDeliveriesParams) (int64, error)
+	RecordWebhookFailure(ctx context.Context, arg RecordWebhookFailureParams) (Webhook, error)
+	RecordWebhookSuccess(ctx context.Context, id uuid.UUID) error
+	RetireSigningKeys(ctx context.Context, arg RetireSigningKeysParams) (int64, error)
+	RevokeAllRefreshTokens(ctx context.Context, updatedAt time.Time) (int64, error)
+	RevokeRefreshTokenByID(ctx context.Context, arg RevokeRefreshTokenByIDParams) (int64, error)
+	RevokeUserRefreshTokens(ctx context.Context, arg RevokeUserRefreshTokensParams) (int64, error)
+	// The snippet is HTML, so the content is escaped (as html.EscapeString does) before the matches are marked
+	SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error)
+	SetUserAdmin(ctx context.Context, arg SetUserAdminParams) (User, error)
+	UpdateRefreshToken(ctx context.Context, arg UpdateRefreshTokenParams) error
+	UpdateScheduledMessage(ctx context.Context, arg UpdateScheduledMessageParams) (Message, error)
+	UpdateSigningKeySecret(ctx context.Context, arg UpdateSigningKeySecretParams) error
+	UpdateUserAvatar(ctx context.Context, arg UpdateUserAvatarParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserPrivacySettings(ctx context.Context, arg UpdateUserPrivacySettingsParams) (User, error)
+	UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error)
+	// A NULL suspended_at lifts the suspension
+	UpdateUserSuspension(ctx context.Context, arg UpdateUserSuspensionParams) (User, error)
+	UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error)
+	UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error
+}
+
+var _ Querier = (*Queries)(nil)
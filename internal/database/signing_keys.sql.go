@@ -0,0 +1,113 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: signing_keys.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const createSigningKey = `-- name: CreateSigningKey :one
+INSERT INTO signing_keys (id, purpose, secret, created_at, activates_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, purpose, secret, created_at, activates_at, retires_at
+`
+
+type CreateSigningKeyParams struct {
+	ID          string
+	Purpose     string
+	Secret      string
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+}
+
+func (q *Queries) CreateSigningKey(ctx context.Context, arg CreateSigningKeyParams) (SigningKey, error) {
+	row := q.db.QueryRowContext(ctx, createSigningKey,
+		arg.ID,
+		arg.Purpose,
+		arg.Secret,
+		arg.CreatedAt,
+		arg.ActivatesAt,
+	)
+	var i SigningKey
+	err := row.Scan(
+		&i.ID,
+		&i.Purpose,
+		&i.Secret,
+		&i.CreatedAt,
+		&i.ActivatesAt,
+		&i.RetiresAt,
+	)
+	return i, err
+}
+
+const getSigningKeys = `-- name: GetSigningKeys :many
+SELECT id, purpose, secret, created_at, activates_at, retires_at FROM signing_keys ORDER BY activates_at DESC, created_at DESC
+`
+
+// Newest first, which is the order the authenticator picks a key to sign with
+func (q *Queries) GetSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	rows, err := q.db.QueryContext(ctx, getSigningKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SigningKey
+	for rows.Next() {
+		var i SigningKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Purpose,
+			&i.Secret,
+			&i.CreatedAt,
+			&i.ActivatesAt,
+			&i.RetiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const retireSigningKeys = `-- name: RetireSigningKeys :execrows
+UPDATE signing_keys SET retires_at = $1
+WHERE purpose = $2 AND retires_at IS NULL
+`
+
+type RetireSigningKeysParams struct {
+	RetiresAt sql.NullTime
+	Purpose   string
+}
+
+func (q *Queries) RetireSigningKeys(ctx context.Context, arg RetireSigningKeysParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, retireSigningKeys, arg.RetiresAt, arg.Purpose)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateSigningKeySecret = `-- name: UpdateSigningKeySecret :exec
+UPDATE signing_keys SET secret = $1 WHERE id = $2
+`
+
+type UpdateSigningKeySecretParams struct {
+	Secret string
+	ID     string
+}
+
+func (q *Queries) UpdateSigningKeySecret(ctx context.Context, arg UpdateSigningKeySecretParams) error {
+	_, err := q.db.ExecContext(ctx, updateSigningKeySecret, arg.Secret, arg.ID)
+	return err
+}
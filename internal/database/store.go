@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Store is everything the rest of the server needs from the database: the generated queries, plus a
+// way to run several of them in one transaction. SQLStore is the Postgres implementation, and tests can
+// swap in an in-memory one (see the memstore package)
+type Store interface {
+	Querier
+	ExecTx(ctx context.Context, fn func(q Querier) error) error
+}
+
+// Instrument wraps the connection queries run on, to observe every query without touching the
+// generated code. sqlc starts each query with a "-- name: <Method>" comment, which tells them apart
+type Instrument func(db DBTX) DBTX
+
+// QueryName returns the sqlc method name from the "-- name: <Method> :<kind>" comment sqlc puts at the
+// start of every query, or "unknown" for hand-written SQL
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+// SQLStore runs queries against a Postgres connection pool
+type SQLStore struct {
+	*Queries
+	Conn        *sql.DB
+	Instruments []Instrument
+}
+
+// NewStore returns a store on conn. The instruments wrap the pool and every transaction, the first one
+// innermost
+func NewStore(conn *sql.DB, instruments ...Instrument) *SQLStore {
+	s := &SQLStore{
+		Conn:        conn,
+		Instruments: instruments,
+	}
+	s.Queries = New(s.instrument(conn))
+	return s
+}
+
+// ExecTx runs fn in a transaction, committing if it returns nil and rolling back otherwise
+func (s *SQLStore) ExecTx(ctx context.Context, fn func(q Querier) error) error {
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(New(s.instrument(tx))); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) instrument(db DBTX) DBTX {
+	for _, instrument := range s.Instruments {
+		db = instrument(db)
+	}
+	return db
+}
+
+var _ Store = (*SQLStore)(nil)
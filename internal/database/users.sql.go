@@ -7,11 +7,25 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const countActiveRefreshTokens = `-- name: CountActiveRefreshTokens :one
+SELECT COUNT(*) FROM refresh_tokens
+WHERE is_valid = true AND created_at > $1
+`
+
+// Sessions are refresh tokens that are still valid and younger than the refresh token lifetime
+func (q *Queries) CountActiveRefreshTokens(ctx context.Context, createdAfter time.Time) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveRefreshTokens, createdAfter)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens(refresh_token, is_valid, created_at, updated_at, user_id)
 VALUES ($1, $2, $3, $4, $5)
@@ -49,7 +63,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, username, email, password)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, username, password, email
+RETURNING id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at
 `
 
 type CreateUserParams struct {
@@ -78,10 +92,33 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Username,
 		&i.Password,
 		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users WHERE id = $1
+`
+
+// Messages, contacts, blocks, refresh tokens and webhooks go with the user, see the ON DELETE CASCADE
+// foreign keys
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getAllUserRefreshTokens = `-- name: GetAllUserRefreshTokens :many
 SELECT id, refresh_token, is_valid, created_at, updated_at, user_id FROM refresh_tokens WHERE user_id = $1
 `
@@ -134,8 +171,35 @@ func (q *Queries) GetRefreshToken(ctx context.Context, refreshToken string) (Ref
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, created_at, updated_at, username, password, email FROM users WHERE username = $1
+SELECT id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at FROM users WHERE username = $1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -148,19 +212,29 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.Username,
 		&i.Password,
 		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
 const getUserLoginInfo = `-- name: GetUserLoginInfo :one
-SELECT id, username, email, password FROM users WHERE email = $1
+SELECT id, username, email, password, suspended_at FROM users WHERE email = $1
 `
 
 type GetUserLoginInfoRow struct {
-	ID       uuid.UUID
-	Username string
-	Email    string
-	Password string
+	ID          uuid.UUID
+	Username    string
+	Email       string
+	Password    string
+	SuspendedAt sql.NullTime
 }
 
 func (q *Queries) GetUserLoginInfo(ctx context.Context, email string) (GetUserLoginInfoRow, error) {
@@ -171,6 +245,117 @@ func (q *Queries) GetUserLoginInfo(ctx context.Context, email string) (GetUserLo
 		&i.Username,
 		&i.Email,
 		&i.Password,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const isUserAdmin = `-- name: IsUserAdmin :one
+SELECT is_admin FROM users WHERE id = $1
+`
+
+func (q *Queries) IsUserAdmin(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isUserAdmin, id)
+	var is_admin bool
+	err := row.Scan(&is_admin)
+	return is_admin, err
+}
+
+const isUserSuspended = `-- name: IsUserSuspended :one
+SELECT (suspended_at IS NOT NULL)::boolean AS suspended FROM users WHERE id = $1
+`
+
+func (q *Queries) IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isUserSuspended, id)
+	var suspended bool
+	err := row.Scan(&suspended)
+	return suspended, err
+}
+
+const revokeAllRefreshTokens = `-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens SET
+is_valid = false, updated_at = $1
+WHERE is_valid = true
+`
+
+func (q *Queries) RevokeAllRefreshTokens(ctx context.Context, updatedAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAllRefreshTokens, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeRefreshTokenByID = `-- name: RevokeRefreshTokenByID :execrows
+UPDATE refresh_tokens SET
+is_valid = false, updated_at = $1
+WHERE id = $2 AND is_valid = true
+`
+
+type RevokeRefreshTokenByIDParams struct {
+	UpdatedAt time.Time
+	ID        int32
+}
+
+func (q *Queries) RevokeRefreshTokenByID(ctx context.Context, arg RevokeRefreshTokenByIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshTokenByID, arg.UpdatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens SET
+is_valid = false, updated_at = $1
+WHERE user_id = $2 AND is_valid = true
+`
+
+type RevokeUserRefreshTokensParams struct {
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, arg RevokeUserRefreshTokensParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, arg.UpdatedAt, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserAdmin = `-- name: SetUserAdmin :one
+UPDATE users SET
+is_admin = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at
+`
+
+type SetUserAdminParams struct {
+	IsAdmin   bool
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAdmin, arg.IsAdmin, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
@@ -191,3 +376,206 @@ func (q *Queries) UpdateRefreshToken(ctx context.Context, arg UpdateRefreshToken
 	_, err := q.db.ExecContext(ctx, updateRefreshToken, arg.IsValid, arg.UpdatedAt, arg.RefreshToken)
 	return err
 }
+
+const updateUserAvatar = `-- name: UpdateUserAvatar :one
+UPDATE users SET
+avatar_key = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at
+`
+
+type UpdateUserAvatarParams struct {
+	AvatarKey string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateUserAvatar(ctx context.Context, arg UpdateUserAvatarParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserAvatar, arg.AvatarKey, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users SET
+password = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at
+`
+
+type UpdateUserPasswordParams struct {
+	Password  string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.Password, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const updateUserPrivacySettings = `-- name: UpdateUserPrivacySettings :one
+UPDATE users SET
+contacts_only = COALESCE($1, contacts_only),
+hide_last_seen = COALESCE($2, hide_last_seen),
+updated_at = $3
+WHERE id = $4
+RETURNING id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at
+`
+
+type UpdateUserPrivacySettingsParams struct {
+	ContactsOnly sql.NullBool
+	HideLastSeen sql.NullBool
+	UpdatedAt    time.Time
+	ID           uuid.UUID
+}
+
+func (q *Queries) UpdateUserPrivacySettings(ctx context.Context, arg UpdateUserPrivacySettingsParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPrivacySettings,
+		arg.ContactsOnly,
+		arg.HideLastSeen,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users SET
+display_name = COALESCE($1, display_name),
+bio = COALESCE($2, bio),
+status_text = COALESCE($3, status_text),
+timezone = COALESCE($4, timezone),
+updated_at = $5
+WHERE id = $6
+RETURNING id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at
+`
+
+type UpdateUserProfileParams struct {
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	StatusText  sql.NullString
+	Timezone    sql.NullString
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.DisplayName,
+		arg.Bio,
+		arg.StatusText,
+		arg.Timezone,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const updateUserSuspension = `-- name: UpdateUserSuspension :one
+UPDATE users SET
+suspended_at = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, username, password, email, contacts_only, display_name, bio, avatar_key, status_text, timezone, hide_last_seen, is_admin, suspended_at
+`
+
+type UpdateUserSuspensionParams struct {
+	SuspendedAt sql.NullTime
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+// A NULL suspended_at lifts the suspension
+func (q *Queries) UpdateUserSuspension(ctx context.Context, arg UpdateUserSuspensionParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserSuspension, arg.SuspendedAt, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.ContactsOnly,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.StatusText,
+		&i.Timezone,
+		&i.HideLastSeen,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,332 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestCreateAndGetUser(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+
+	if alice.Timezone != "UTC" || alice.ContactsOnly || alice.IsAdmin || alice.DisplayName != "" {
+		t.Errorf("new user does not have the column defaults: %+v", alice)
+	}
+
+	byName, err := store.GetUserByUsername(ctx, "alice")
+	if err != nil || byName.ID != alice.ID {
+		t.Errorf("GetUserByUsername: got %+v, %v", byName, err)
+	}
+
+	byID, err := store.GetUserByID(ctx, alice.ID)
+	if err != nil || byID.Username != "alice" || !byID.CreatedAt.Equal(alice.CreatedAt) {
+		t.Errorf("GetUserByID: got %+v, %v", byID, err)
+	}
+
+	loginInfo, err := store.GetUserLoginInfo(ctx, "alice@example.com")
+	if err != nil || loginInfo.ID != alice.ID || loginInfo.Password != "hash" {
+		t.Errorf("GetUserLoginInfo: got %+v, %v", loginInfo, err)
+	}
+
+	if _, err := store.GetUserByUsername(ctx, "nobody"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByUsername for a missing user: got %v, want sql.ErrNoRows", err)
+	}
+
+	if _, err := store.GetUserByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByID for a missing user: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestCreateUserUniqueness(t *testing.T) {
+	store := newStore(t)
+	createUser(t, store, "alice")
+
+	_, err := store.CreateUser(ctx, database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UpdatedAt: now(),
+		Username:  "alice",
+		Email:     "other@example.com",
+		Password:  "hash",
+	})
+	assertPQCode(t, err, "23505")
+
+	_, err = store.CreateUser(ctx, database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UpdatedAt: now(),
+		Username:  "other",
+		Email:     "alice@example.com",
+		Password:  "hash",
+	})
+	assertPQCode(t, err, "23505")
+}
+
+func TestUpdateUserPrivacySettings(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+
+	updated, err := store.UpdateUserPrivacySettings(ctx, database.UpdateUserPrivacySettingsParams{
+		ContactsOnly: sql.NullBool{Bool: true, Valid: true},
+		UpdatedAt:    now(),
+		ID:           alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.ContactsOnly || updated.HideLastSeen {
+		t.Errorf("after setting contacts_only: got %+v", updated)
+	}
+
+	// NULL leaves a setting unchanged
+	updated, err = store.UpdateUserPrivacySettings(ctx, database.UpdateUserPrivacySettingsParams{
+		HideLastSeen: sql.NullBool{Bool: true, Valid: true},
+		UpdatedAt:    now(),
+		ID:           alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.ContactsOnly || !updated.HideLastSeen {
+		t.Errorf("after setting hide_last_seen: got %+v", updated)
+	}
+}
+
+func TestUpdateUserProfileAndAvatar(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+
+	updated, err := store.UpdateUserProfile(ctx, database.UpdateUserProfileParams{
+		DisplayName: sql.NullString{String: "Alice", Valid: true},
+		Timezone:    sql.NullString{String: "Europe/Berlin", Valid: true},
+		UpdatedAt:   now(),
+		ID:          alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DisplayName != "Alice" || updated.Timezone != "Europe/Berlin" || updated.Bio != "" {
+		t.Errorf("UpdateUserProfile: got %+v", updated)
+	}
+
+	updated, err = store.UpdateUserProfile(ctx, database.UpdateUserProfileParams{
+		Bio:       sql.NullString{String: "Hello", Valid: true},
+		UpdatedAt: now(),
+		ID:        alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DisplayName != "Alice" || updated.Bio != "Hello" {
+		t.Errorf("UpdateUserProfile with only a bio changed other fields: %+v", updated)
+	}
+
+	updated, err = store.UpdateUserAvatar(ctx, database.UpdateUserAvatarParams{
+		AvatarKey: "avatars/alice.png",
+		UpdatedAt: now(),
+		ID:        alice.ID,
+	})
+	if err != nil || updated.AvatarKey != "avatars/alice.png" {
+		t.Errorf("UpdateUserAvatar: got %+v, %v", updated, err)
+	}
+
+	if _, err := store.UpdateUserAvatar(ctx, database.UpdateUserAvatarParams{UpdatedAt: now(), ID: uuid.New()}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateUserAvatar for a missing user: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestIsUserAdmin(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+
+	isAdmin, err := store.IsUserAdmin(ctx, alice.ID)
+	if err != nil || isAdmin {
+		t.Errorf("new user: got %v, %v", isAdmin, err)
+	}
+
+	if _, err := store.Conn.ExecContext(ctx, "UPDATE users SET is_admin = true WHERE id = $1", alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	isAdmin, err = store.IsUserAdmin(ctx, alice.ID)
+	if err != nil || !isAdmin {
+		t.Errorf("promoted user: got %v, %v", isAdmin, err)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	for _, token := range []struct {
+		value string
+		user  database.User
+	}{
+		{"alice-1", alice},
+		{"alice-2", alice},
+		{"bob-1", bob},
+	} {
+		_, err := store.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			RefreshToken: token.value,
+			IsValid:      true,
+			CreatedAt:    now(),
+			UpdatedAt:    now(),
+			UserID:       token.user.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokens, err := store.GetAllUserRefreshTokens(ctx, alice.ID)
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("GetAllUserRefreshTokens: got %+v, %v", tokens, err)
+	}
+
+	if err := store.UpdateRefreshToken(ctx, database.UpdateRefreshTokenParams{
+		IsValid:      false,
+		UpdatedAt:    now(),
+		RefreshToken: "alice-1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := store.GetRefreshToken(ctx, "alice-1")
+	if err != nil || token.IsValid || token.UserID != alice.ID {
+		t.Errorf("invalidated token: got %+v, %v", token, err)
+	}
+
+	token, err = store.GetRefreshToken(ctx, "alice-2")
+	if err != nil || !token.IsValid {
+		t.Errorf("untouched token: got %+v, %v", token, err)
+	}
+
+	// alice-1 was invalidated, and a cutoff after every token counts none of them
+	active, err := store.CountActiveRefreshTokens(ctx, now().Add(-time.Hour))
+	if err != nil || active != 2 {
+		t.Errorf("CountActiveRefreshTokens: got %d, %v", active, err)
+	}
+
+	active, err = store.CountActiveRefreshTokens(ctx, now().Add(time.Hour))
+	if err != nil || active != 0 {
+		t.Errorf("CountActiveRefreshTokens with a later cutoff: got %d, %v", active, err)
+	}
+
+	if _, err := store.GetRefreshToken(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing token: got %v, want sql.ErrNoRows", err)
+	}
+
+	_, err = store.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		RefreshToken: "orphan",
+		IsValid:      true,
+		CreatedAt:    now(),
+		UpdatedAt:    now(),
+		UserID:       uuid.New(),
+	})
+	assertPQCode(t, err, "23503")
+}
+
+func TestAdminUserQueries(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+
+	promoted, err := store.SetUserAdmin(ctx, database.SetUserAdminParams{IsAdmin: true, UpdatedAt: now(), ID: alice.ID})
+	if err != nil || !promoted.IsAdmin {
+		t.Errorf("SetUserAdmin: got %+v, %v", promoted, err)
+	}
+
+	suspendedAt := now()
+	suspended, err := store.UpdateUserSuspension(ctx, database.UpdateUserSuspensionParams{SuspendedAt: validTime(suspendedAt), UpdatedAt: now(), ID: alice.ID})
+	if err != nil || !suspended.SuspendedAt.Time.Equal(suspendedAt) {
+		t.Errorf("UpdateUserSuspension: got %+v, %v", suspended, err)
+	}
+
+	loginInfo, err := store.GetUserLoginInfo(ctx, "alice@example.com")
+	if err != nil || !loginInfo.SuspendedAt.Valid {
+		t.Errorf("GetUserLoginInfo of a suspended user: got %+v, %v", loginInfo, err)
+	}
+
+	lifted, err := store.UpdateUserSuspension(ctx, database.UpdateUserSuspensionParams{UpdatedAt: now(), ID: alice.ID})
+	if err != nil || lifted.SuspendedAt.Valid {
+		t.Errorf("lifting the suspension: got %+v, %v", lifted, err)
+	}
+
+	updated, err := store.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{Password: "new-hash", UpdatedAt: now(), ID: alice.ID})
+	if err != nil || updated.Password != "new-hash" {
+		t.Errorf("UpdateUserPassword: got %+v, %v", updated, err)
+	}
+}
+
+func TestDeleteUserCascades(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	createMessage(t, store, alice, bob, "hello", now())
+	createWebhook(t, store, uuid.NullUUID{UUID: alice.ID, Valid: true}, now(), "message.created")
+
+	deleted, err := store.DeleteUser(ctx, alice.ID)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteUser: got %d, %v", deleted, err)
+	}
+
+	if _, err := store.GetUserByID(ctx, alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted user: got %v, want sql.ErrNoRows", err)
+	}
+
+	history, err := store.GetUserMessageHistory(ctx, bob.ID)
+	if err != nil || len(history) != 0 {
+		t.Errorf("messages of the deleted user: got %+v, %v", history, err)
+	}
+
+	webhooks, err := store.GetWebhooksByOwner(ctx, uuid.NullUUID{UUID: alice.ID, Valid: true})
+	if err != nil || len(webhooks) != 0 {
+		t.Errorf("webhooks of the deleted user: got %+v, %v", webhooks, err)
+	}
+
+	if deleted, err := store.DeleteUser(ctx, alice.ID); err != nil || deleted != 0 {
+		t.Errorf("deleting again: got %d, %v", deleted, err)
+	}
+}
+
+func TestRevokeRefreshTokens(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	var tokens []database.RefreshToken
+	for _, user := range []database.User{alice, alice, bob, bob} {
+		token, err := store.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			RefreshToken: uuid.NewString(),
+			IsValid:      true,
+			CreatedAt:    now(),
+			UpdatedAt:    now(),
+			UserID:       user.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	revoked, err := store.RevokeRefreshTokenByID(ctx, database.RevokeRefreshTokenByIDParams{UpdatedAt: now(), ID: tokens[0].ID})
+	if err != nil || revoked != 1 {
+		t.Errorf("RevokeRefreshTokenByID: got %d, %v", revoked, err)
+	}
+
+	// Tokens that are already revoked are not counted again
+	revoked, err = store.RevokeUserRefreshTokens(ctx, database.RevokeUserRefreshTokensParams{UpdatedAt: now(), UserID: alice.ID})
+	if err != nil || revoked != 1 {
+		t.Errorf("RevokeUserRefreshTokens: got %d, %v", revoked, err)
+	}
+
+	revoked, err = store.RevokeAllRefreshTokens(ctx, now())
+	if err != nil || revoked != 2 {
+		t.Errorf("RevokeAllRefreshTokens: got %d, %v", revoked, err)
+	}
+}
@@ -0,0 +1,480 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = $1
+WHERE id IN (
+    SELECT webhook_deliveries.id FROM webhook_deliveries
+    WHERE webhook_deliveries.status = 'pending' AND webhook_deliveries.next_attempt_at <= $2
+    ORDER BY webhook_deliveries.next_attempt_at ASC
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, webhook_id, event_id, event_type, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil time.Time
+	Now        time.Time
+	BatchSize  int32
+}
+
+// Claiming pushes next_attempt_at out by a lease, so a crashed worker's claims are retried by others
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.EventType,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, owner_id, url, secret, events, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+RETURNING id, owner_id, url, secret, events, enabled, consecutive_failures, disabled_at, created_at, updated_at
+`
+
+type CreateWebhookParams struct {
+	ID        uuid.UUID
+	OwnerID   uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.ID,
+		arg.OwnerID,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.Events),
+		arg.CreatedAt,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, status, next_attempt_at, created_at, updated_at)
+VALUES ($1, $2, $3, 'pending', $4, $4, $4)
+ON CONFLICT (webhook_id, event_id) DO NOTHING
+`
+
+type CreateWebhookDeliveryParams struct {
+	WebhookID     uuid.UUID
+	EventID       int64
+	EventType     string
+	NextAttemptAt time.Time
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDelivery,
+		arg.WebhookID,
+		arg.EventID,
+		arg.EventType,
+		arg.NextAttemptAt,
+	)
+	return err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getGlobalWebhooks = `-- name: GetGlobalWebhooks :many
+SELECT id, owner_id, url, secret, events, enabled, consecutive_failures, disabled_at, created_at, updated_at FROM webhooks
+WHERE owner_id IS NULL
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetGlobalWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getGlobalWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, owner_id, url, secret, events, enabled, consecutive_failures, disabled_at, created_at, updated_at FROM webhooks WHERE id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+SELECT id, webhook_id, event_id, event_type, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	Limit     int32
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries, arg.WebhookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.EventType,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksByOwner = `-- name: GetWebhooksByOwner :many
+SELECT id, owner_id, url, secret, events, enabled, consecutive_failures, disabled_at, created_at, updated_at FROM webhooks
+WHERE owner_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetWebhooksByOwner(ctx context.Context, ownerID uuid.NullUUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksByOwner, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksForEvent = `-- name: GetWebhooksForEvent :many
+SELECT id, owner_id, url, secret, events, enabled, consecutive_failures, disabled_at, created_at, updated_at FROM webhooks
+WHERE enabled = true
+AND $1::text = ANY(events)
+AND (owner_id IS NULL OR owner_id = ANY($2::uuid[]))
+`
+
+type GetWebhooksForEventParams struct {
+	EventType string
+	UserIds   []uuid.UUID
+}
+
+func (q *Queries) GetWebhooksForEvent(ctx context.Context, arg GetWebhooksForEventParams) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksForEvent, arg.EventType, pq.Array(arg.UserIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneWebhookDeliveries = `-- name: PruneWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE id IN (
+    SELECT pruned.id FROM webhook_deliveries AS pruned
+    WHERE pruned.status <> 'pending' AND pruned.updated_at < $1
+    ORDER BY pruned.id ASC
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type PruneWebhookDeliveriesParams struct {
+	Before    time.Time
+	BatchSize int32
+}
+
+// Pending deliveries are never pruned, however old, so a retry in progress is not lost
+func (q *Queries) PruneWebhookDeliveries(ctx context.Context, arg PruneWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneWebhookDeliveries, arg.Before, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordWebhookFailure = `-- name: RecordWebhookFailure :one
+UPDATE webhooks SET
+consecutive_failures = consecutive_failures + 1,
+enabled = CASE WHEN consecutive_failures + 1 >= $1::int THEN false ELSE enabled END,
+disabled_at = CASE WHEN consecutive_failures + 1 >= $1::int AND enabled THEN $2 ELSE disabled_at END,
+updated_at = $2
+WHERE id = $3
+RETURNING id, owner_id, url, secret, events, enabled, consecutive_failures, disabled_at, created_at, updated_at
+`
+
+type RecordWebhookFailureParams struct {
+	DisableAfter int32
+	Now          time.Time
+	ID           uuid.UUID
+}
+
+func (q *Queries) RecordWebhookFailure(ctx context.Context, arg RecordWebhookFailureParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookFailure, arg.DisableAfter, arg.Now, arg.ID)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const recordWebhookSuccess = `-- name: RecordWebhookSuccess :exec
+UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1
+`
+
+func (q *Queries) RecordWebhookSuccess(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookSuccess, id)
+	return err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks SET
+url = $1,
+events = $2,
+enabled = $3,
+consecutive_failures = CASE WHEN $3::boolean THEN 0 ELSE consecutive_failures END,
+disabled_at = CASE WHEN $3::boolean THEN NULL ELSE disabled_at END,
+updated_at = $4
+WHERE id = $5
+RETURNING id, owner_id, url, secret, events, enabled, consecutive_failures, disabled_at, created_at, updated_at
+`
+
+type UpdateWebhookParams struct {
+	Url       string
+	Events    []string
+	Enabled   bool
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, updateWebhook,
+		arg.Url,
+		pq.Array(arg.Events),
+		arg.Enabled,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries SET
+status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, updated_at = $6
+WHERE id = $7
+`
+
+type UpdateWebhookDeliveryParams struct {
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	UpdatedAt      time.Time
+	ID             int64
+}
+
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebhookDelivery,
+		arg.Status,
+		arg.Attempts,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
@@ -0,0 +1,330 @@
+package database_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+func createWebhook(t *testing.T, store *database.SQLStore, owner uuid.NullUUID, createdAt time.Time, events ...string) database.Webhook {
+	t.Helper()
+
+	webhook, err := store.CreateWebhook(ctx, database.CreateWebhookParams{
+		ID:        uuid.New(),
+		OwnerID:   owner,
+		Url:       "https://example.com/hook",
+		Secret:    "whsec_test",
+		Events:    events,
+		CreatedAt: createdAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webhook
+}
+
+func createOutboxEvent(t *testing.T, store *database.SQLStore, eventType string, userIDs ...uuid.UUID) database.Outbox {
+	t.Helper()
+
+	if userIDs == nil {
+		userIDs = []uuid.UUID{}
+	}
+
+	event, err := store.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType: eventType,
+		Payload:   json.RawMessage(`{"hello":"world"}`),
+		UserIds:   userIDs,
+		CreatedAt: now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestWebhookQueries(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	owner := uuid.NullUUID{UUID: alice.ID, Valid: true}
+
+	older := createWebhook(t, store, owner, now().Add(-time.Minute), "message.created")
+	newer := createWebhook(t, store, owner, now(), "message.created", "message.read")
+	global := createWebhook(t, store, uuid.NullUUID{Valid: false}, now(), "user.registered")
+
+	if !older.Enabled || older.ConsecutiveFailures != 0 || !older.UpdatedAt.Equal(older.CreatedAt) {
+		t.Errorf("new webhook does not have the column defaults: %+v", older)
+	}
+
+	owned, err := store.GetWebhooksByOwner(ctx, owner)
+	if err != nil || len(owned) != 2 || owned[0].ID != newer.ID || owned[1].ID != older.ID {
+		t.Errorf("GetWebhooksByOwner: got %+v, %v", owned, err)
+	}
+
+	globals, err := store.GetGlobalWebhooks(ctx)
+	if err != nil || len(globals) != 1 || globals[0].ID != global.ID {
+		t.Errorf("GetGlobalWebhooks: got %+v, %v", globals, err)
+	}
+
+	got, err := store.GetWebhook(ctx, newer.ID)
+	if err != nil || len(got.Events) != 2 || got.Events[1] != "message.read" {
+		t.Errorf("GetWebhook: got %+v, %v", got, err)
+	}
+
+	updated, err := store.UpdateWebhook(ctx, database.UpdateWebhookParams{
+		Url:       "https://example.com/other",
+		Events:    []string{"message.expired"},
+		Enabled:   false,
+		UpdatedAt: now(),
+		ID:        older.ID,
+	})
+	if err != nil || updated.Url != "https://example.com/other" || updated.Enabled || updated.Events[0] != "message.expired" {
+		t.Errorf("UpdateWebhook: got %+v, %v", updated, err)
+	}
+
+	deleted, err := store.DeleteWebhook(ctx, older.ID)
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteWebhook: got %d, %v", deleted, err)
+	}
+
+	if _, err := store.GetWebhook(ctx, older.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetWebhook after deleting: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestGetWebhooksForEvent(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	aliceHook := createWebhook(t, store, uuid.NullUUID{UUID: alice.ID, Valid: true}, now(), "message.created")
+	bobHook := createWebhook(t, store, uuid.NullUUID{UUID: bob.ID, Valid: true}, now(), "message.created")
+	global := createWebhook(t, store, uuid.NullUUID{Valid: false}, now(), "message.created", "user.registered")
+	createWebhook(t, store, uuid.NullUUID{UUID: alice.ID, Valid: true}, now(), "message.read")
+
+	disabled := createWebhook(t, store, uuid.NullUUID{UUID: alice.ID, Valid: true}, now(), "message.created")
+	if _, err := store.UpdateWebhook(ctx, database.UpdateWebhookParams{Url: disabled.Url, Events: disabled.Events, Enabled: false, UpdatedAt: now(), ID: disabled.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(webhooks []database.Webhook) map[uuid.UUID]bool {
+		result := make(map[uuid.UUID]bool)
+		for _, webhook := range webhooks {
+			result[webhook.ID] = true
+		}
+		return result
+	}
+
+	// Only enabled webhooks subscribed to the event, owned by one of the subjects or global
+	matched, err := store.GetWebhooksForEvent(ctx, database.GetWebhooksForEventParams{EventType: "message.created", UserIds: []uuid.UUID{alice.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(matched); len(got) != 2 || !got[aliceHook.ID] || !got[global.ID] {
+		t.Errorf("message.created for alice: got %+v", matched)
+	}
+
+	matched, err = store.GetWebhooksForEvent(ctx, database.GetWebhooksForEventParams{EventType: "message.created", UserIds: []uuid.UUID{alice.ID, bob.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(matched); len(got) != 3 || !got[bobHook.ID] {
+		t.Errorf("message.created for alice and bob: got %+v", matched)
+	}
+
+	matched, err = store.GetWebhooksForEvent(ctx, database.GetWebhooksForEventParams{EventType: "user.registered", UserIds: []uuid.UUID{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(matched); len(got) != 1 || !got[global.ID] {
+		t.Errorf("user.registered: got %+v", matched)
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	webhook := createWebhook(t, store, uuid.NullUUID{UUID: alice.ID, Valid: true}, now(), "message.created")
+	first := createOutboxEvent(t, store, "message.created", alice.ID)
+	second := createOutboxEvent(t, store, "message.created", alice.ID)
+
+	dueAt := now().Add(-time.Minute)
+	for _, event := range []database.Outbox{first, second, first} {
+		// The third insert hits ON CONFLICT DO NOTHING
+		err := store.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.EventType,
+			NextAttemptAt: dueAt,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	leaseUntil := now().Add(time.Minute)
+	claimed, err := store.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{LeaseUntil: leaseUntil, Now: now(), BatchSize: 10})
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("ClaimDueWebhookDeliveries: got %+v, %v", claimed, err)
+	}
+	if claimed[0].Status != "pending" || !claimed[0].NextAttemptAt.Equal(leaseUntil) {
+		t.Errorf("claimed delivery: got %+v", claimed[0])
+	}
+
+	// Leased deliveries are not claimed again until the lease runs out
+	again, err := store.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{LeaseUntil: leaseUntil, Now: now(), BatchSize: 10})
+	if err != nil || len(again) != 0 {
+		t.Errorf("claiming during the lease: got %+v, %v", again, err)
+	}
+
+	err = store.UpdateWebhookDelivery(ctx, database.UpdateWebhookDeliveryParams{
+		Status:         "succeeded",
+		Attempts:       1,
+		NextAttemptAt:  now(),
+		LastStatusCode: sql.NullInt32{Int32: 204, Valid: true},
+		UpdatedAt:      now(),
+		ID:             claimed[0].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 10})
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("GetWebhookDeliveries: got %+v, %v", deliveries, err)
+	}
+
+	var succeeded int
+	for _, delivery := range deliveries {
+		if delivery.Status == "succeeded" && delivery.Attempts == 1 && delivery.LastStatusCode.Int32 == 204 {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("got %d succeeded deliveries, want 1: %+v", succeeded, deliveries)
+	}
+
+	limited, err := store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 1})
+	if err != nil || len(limited) != 1 {
+		t.Errorf("GetWebhookDeliveries with a limit: got %+v, %v", limited, err)
+	}
+
+	// Deliveries go with their webhook
+	if _, err := store.DeleteWebhook(ctx, webhook.ID); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err = store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 10})
+	if err != nil || len(deliveries) != 0 {
+		t.Errorf("deliveries after deleting the webhook: got %+v, %v", deliveries, err)
+	}
+}
+
+func TestWebhookFailureTracking(t *testing.T) {
+	store := newStore(t)
+	webhook := createWebhook(t, store, uuid.NullUUID{Valid: false}, now(), "user.registered")
+
+	failedAt := now()
+	for attempt := 1; attempt <= 2; attempt++ {
+		updated, err := store.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{DisableAfter: 3, Now: failedAt, ID: webhook.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !updated.Enabled || updated.ConsecutiveFailures != int32(attempt) {
+			t.Errorf("after %d failures: got %+v", attempt, updated)
+		}
+	}
+
+	if err := store.RecordWebhookSuccess(ctx, webhook.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		updated, err := store.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{DisableAfter: 3, Now: failedAt, ID: webhook.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		disabled := attempt == 3
+		if updated.Enabled == disabled || updated.DisabledAt.Valid != disabled {
+			t.Errorf("after %d failures following a success: got %+v", attempt, updated)
+		}
+	}
+
+	// Re-enabling resets the failure count and disabled_at
+	updated, err := store.UpdateWebhook(ctx, database.UpdateWebhookParams{Url: webhook.Url, Events: webhook.Events, Enabled: true, UpdatedAt: now(), ID: webhook.ID})
+	if err != nil || !updated.Enabled || updated.ConsecutiveFailures != 0 || updated.DisabledAt.Valid {
+		t.Errorf("re-enabling: got %+v, %v", updated, err)
+	}
+}
+
+func TestPruneWebhookDeliveriesAndOutbox(t *testing.T) {
+	store := newStore(t)
+	alice := createUser(t, store, "alice")
+	webhook := createWebhook(t, store, uuid.NullUUID{UUID: alice.ID, Valid: true}, now(), "message.created")
+
+	old := now().Add(-2 * time.Hour)
+	cutoff := now().Add(-time.Hour)
+
+	// An event per case, processed before the cutoff, with a delivery last updated at updatedAt in status
+	createEvent := func(status string, updatedAt time.Time) database.Outbox {
+		t.Helper()
+
+		event := createOutboxEvent(t, store, "message.created", alice.ID)
+		if err := store.MarkOutboxEventProcessed(ctx, database.MarkOutboxEventProcessedParams{ProcessedAt: validTime(old), ID: event.ID}); err != nil {
+			t.Fatal(err)
+		}
+		if status == "" {
+			return event
+		}
+
+		deliveryParams := database.CreateWebhookDeliveryParams{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.EventType,
+			NextAttemptAt: updatedAt,
+		}
+		if err := store.CreateWebhookDelivery(ctx, deliveryParams); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Conn.ExecContext(ctx, "UPDATE webhook_deliveries SET status = $1, updated_at = $2 WHERE event_id = $3", status, updatedAt, event.ID); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	succeeded := createEvent("succeeded", old)
+	undelivered := createEvent("", old)
+	retrying := createEvent("pending", old)
+	failedRecently := createEvent("failed", now())
+	unprocessed := createOutboxEvent(t, store, "message.created", alice.ID)
+
+	deliveries, err := store.PruneWebhookDeliveries(ctx, database.PruneWebhookDeliveriesParams{Before: cutoff, BatchSize: 10})
+	if err != nil || deliveries != 1 {
+		t.Errorf("PruneWebhookDeliveries: got %d, %v, want 1", deliveries, err)
+	}
+
+	events, err := store.PruneOutboxEvents(ctx, database.PruneOutboxEventsParams{Before: cutoff, BatchSize: 10})
+	if err != nil || events != 2 {
+		t.Errorf("PruneOutboxEvents: got %d, %v, want 2", events, err)
+	}
+
+	for _, event := range []database.Outbox{succeeded, undelivered} {
+		if _, err := store.GetOutboxEvent(ctx, event.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("event %d was not pruned (err %v)", event.ID, err)
+		}
+	}
+	for _, event := range []database.Outbox{retrying, failedRecently, unprocessed} {
+		if _, err := store.GetOutboxEvent(ctx, event.ID); err != nil {
+			t.Errorf("event %d was pruned: %v", event.ID, err)
+		}
+	}
+
+	remaining, err := store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 10})
+	if err != nil || len(remaining) != 2 {
+		t.Errorf("deliveries after pruning: got %+v, %v, want the pending and the recent one", remaining, err)
+	}
+}
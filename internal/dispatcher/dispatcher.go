@@ -0,0 +1,116 @@
+package dispatcher
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/lifecycle"
+)
+
+// Dispatcher periodically delivers scheduled messages whose deliver_at has passed. All of its state
+// lives in the messages table, so messages that come due while the server is down are picked up on the
+// first tick after a restart, and several instances can run side by side without delivering a message twice
+type Dispatcher struct {
+	Tx        *events.TxManager
+	Interval  time.Duration
+	BatchSize int32
+	// Beats after every pass that finished without errors
+	Heartbeat lifecycle.Heartbeat
+}
+
+func NewDispatcher(txManager *events.TxManager, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		Tx:        txManager,
+		Interval:  interval,
+		BatchSize: 100,
+	}
+}
+
+// Run delivers due messages every Interval until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back, so a backlog does not wait several ticks
+		for {
+			delivered, err := d.DispatchDue(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "dispatcher pass failed", "error", err)
+				break
+			}
+			if delivered < int(d.BatchSize) {
+				d.Heartbeat.Beat()
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue delivers one batch of due messages and returns how many were delivered. Each delivery
+// emits a MessageCreated event in the same transaction, so the outbox sees every delivery exactly once
+// and its consumers get at-least-once delivery on top of that
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	var delivered int
+	err := d.Tx.WithTx(ctx, func(tx *events.Tx) error {
+		now := time.Now().UTC()
+
+		// Rows are locked with SKIP LOCKED, so concurrent dispatchers split the work instead of blocking
+		dueParams := database.GetDueScheduledMessagesParams{
+			DeliverAt: sql.NullTime{
+				Time:  now,
+				Valid: true,
+			},
+			Limit: d.BatchSize,
+		}
+		messages, err := tx.GetDueScheduledMessages(ctx, dueParams)
+		if err != nil {
+			return err
+		}
+
+		for i := range messages {
+			// The TTL of a scheduled message starts counting once it is delivered
+			var expiresAt sql.NullTime
+			if messages[i].TtlSeconds.Valid {
+				expiresAt = sql.NullTime{
+					Time:  now.Add(time.Duration(messages[i].TtlSeconds.Int32) * time.Second),
+					Valid: true,
+				}
+			}
+
+			markParams := database.MarkMessageDeliveredParams{
+				DeliveredAt: sql.NullTime{
+					Time:  now,
+					Valid: true,
+				},
+				ExpiresAt: expiresAt,
+				ID:        messages[i].ID,
+			}
+			if err := tx.MarkMessageDelivered(ctx, markParams); err != nil {
+				return err
+			}
+
+			messages[i].DeliveredAt = markParams.DeliveredAt
+			messages[i].ExpiresAt = expiresAt
+
+			if err := tx.Emit(ctx, events.MessageCreated{MessageInfo: events.NewMessageInfo(messages[i])}); err != nil {
+				return err
+			}
+		}
+
+		delivered = len(messages)
+		return nil
+	})
+
+	return delivered, err
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+type Handler func(ctx context.Context, event Event)
+
+// Bus delivers committed events to in-process subscribers. Delivery is synchronous and best effort:
+// anything that must not miss an event should consume the durable outbox instead
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe registers handler for events of the given type, or for every event if eventType is "*"
+func (b *Bus) Subscribe(eventType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers[event.Type()])+len(b.handlers["*"]))
+	handlers = append(handlers, b.handlers[event.Type()]...)
+	handlers = append(handlers, b.handlers["*"]...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		b.call(ctx, handler, event)
+	}
+}
+
+// A misbehaving subscriber must not take down the request that published the event
+func (b *Bus) call(ctx context.Context, handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "event subscriber panicked", "event", event.Type(), "panic", r)
+		}
+	}()
+	handler(ctx, event)
+}
@@ -0,0 +1,51 @@
+package events_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/google/uuid"
+)
+
+func TestBusFanOut(t *testing.T) {
+	bus := events.NewBus()
+
+	var calls []string
+	record := func(name string) events.Handler {
+		return func(ctx context.Context, event events.Event) {
+			calls = append(calls, name+":"+event.Type())
+		}
+	}
+	bus.Subscribe(events.TypeUserRegistered, record("first"))
+	bus.Subscribe(events.TypeUserRegistered, record("second"))
+	bus.Subscribe(events.TypeMessageRead, record("other"))
+	bus.Subscribe("*", record("all"))
+
+	bus.Publish(context.Background(), events.UserRegistered{UserID: uuid.New()})
+	bus.Publish(context.Background(), events.MessageExpired{})
+
+	// Type subscribers run in the order they subscribed, before the wildcard ones
+	want := []string{"first:user.registered", "second:user.registered", "all:user.registered", "all:message.expired"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+}
+
+func TestBusRecoversFromPanickingSubscriber(t *testing.T) {
+	bus := events.NewBus()
+
+	delivered := false
+	bus.Subscribe(events.TypeUserRegistered, func(ctx context.Context, event events.Event) {
+		panic("subscriber bug")
+	})
+	bus.Subscribe(events.TypeUserRegistered, func(ctx context.Context, event events.Event) {
+		delivered = true
+	})
+
+	bus.Publish(context.Background(), events.UserRegistered{UserID: uuid.New()})
+	if !delivered {
+		t.Error("the subscriber after a panicking one was not called")
+	}
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	TypeUserRegistered = "user.registered"
+	TypeMessageCreated = "message.created"
+	TypeMessageRead    = "message.read"
+	TypeMessageExpired = "message.expired"
+)
+
+// Event is a domain event. Events are stored as their JSON encoding in the outbox
+type Event interface {
+	// Type is the stable name of the event, e.g. "message.created"
+	Type() string
+	// Subjects are the users the event concerns
+	Subjects() []uuid.UUID
+}
+
+type UserRegistered struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e UserRegistered) Type() string { return TypeUserRegistered }
+
+func (e UserRegistered) Subjects() []uuid.UUID { return []uuid.UUID{e.UserID} }
+
+// MessageInfo describes a message in event payloads. It deliberately leaves out the content,
+// consumers only learn that something happened to a message
+type MessageInfo struct {
+	MessageID   uuid.UUID  `json:"message_id"`
+	SenderID    uuid.UUID  `json:"sender_id"`
+	RecipientID uuid.UUID  `json:"recipient_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+func NewMessageInfo(message database.Message) MessageInfo {
+	info := MessageInfo{
+		MessageID:   message.ID,
+		SenderID:    message.SenderID,
+		RecipientID: message.RecipientID,
+		CreatedAt:   message.CreatedAt,
+	}
+	if message.ExpiresAt.Valid {
+		info.ExpiresAt = &message.ExpiresAt.Time
+	}
+	return info
+}
+
+// MessageCreated is emitted when a message becomes visible to its recipient, which for a
+// scheduled message is when it is delivered rather than when it is created
+type MessageCreated struct {
+	MessageInfo
+}
+
+func (e MessageCreated) Type() string { return TypeMessageCreated }
+
+func (e MessageCreated) Subjects() []uuid.UUID { return []uuid.UUID{e.SenderID, e.RecipientID} }
+
+type MessageExpired struct {
+	MessageInfo
+}
+
+func (e MessageExpired) Type() string { return TypeMessageExpired }
+
+func (e MessageExpired) Subjects() []uuid.UUID { return []uuid.UUID{e.SenderID, e.RecipientID} }
+
+// MessageRead covers every message a reader marked as read in one go
+type MessageRead struct {
+	ReaderID   uuid.UUID   `json:"reader_id"`
+	SenderID   uuid.UUID   `json:"sender_id"`
+	MessageIDs []uuid.UUID `json:"message_ids"`
+	ReadAt     time.Time   `json:"read_at"`
+}
+
+func (e MessageRead) Type() string { return TypeMessageRead }
+
+func (e MessageRead) Subjects() []uuid.UUID { return []uuid.UUID{e.ReaderID, e.SenderID} }
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/google/uuid"
+)
+
+// Tx is a transaction-bound Querier that can also emit events. Emitted events are written to the
+// outbox inside the transaction, and handed to the in-process bus only once it has committed
+type Tx struct {
+	database.Querier
+	emitted []Event
+}
+
+func (tx *Tx) Emit(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	subjects := event.Subjects()
+	if subjects == nil {
+		subjects = []uuid.UUID{}
+	}
+
+	outboxParams := database.CreateOutboxEventParams{
+		EventType: event.Type(),
+		Payload:   payload,
+		UserIds:   subjects,
+		CreatedAt: time.Now().UTC(),
+	}
+	if _, err := tx.CreateOutboxEvent(ctx, outboxParams); err != nil {
+		return err
+	}
+
+	tx.emitted = append(tx.emitted, event)
+	return nil
+}
+
+// TxManager runs units of work in a transaction and publishes their events after commit
+type TxManager struct {
+	Store database.Store
+	Bus   *Bus
+}
+
+func NewTxManager(store database.Store, bus *Bus) *TxManager {
+	return &TxManager{
+		Store: store,
+		Bus:   bus,
+	}
+}
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling back otherwise
+func (m *TxManager) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	var tx *Tx
+	err := m.Store.ExecTx(ctx, func(q database.Querier) error {
+		tx = &Tx{Querier: q}
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range tx.emitted {
+		m.Bus.Publish(ctx, event)
+	}
+	return nil
+}
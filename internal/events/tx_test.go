@@ -0,0 +1,169 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/dbtest"
+	"github.com/PlatosRepublic7/ember/internal/database/memstore"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/google/uuid"
+)
+
+var ctx = context.Background()
+
+// The transaction tests run against the in-memory store and, when EMBER_TEST_DB_URL is set, Postgres
+var stores = map[string]func(t *testing.T) database.Store{
+	"memstore": func(t *testing.T) database.Store { return memstore.New() },
+	"postgres": func(t *testing.T) database.Store { return database.NewStore(dbtest.New(t)) },
+}
+
+// A TxManager over the store, and the events its bus has published so far
+func newTxManager(t *testing.T, store database.Store) (*events.TxManager, *[]events.Event) {
+	t.Helper()
+
+	published := &[]events.Event{}
+	bus := events.NewBus()
+	bus.Subscribe("*", func(ctx context.Context, event events.Event) {
+		*published = append(*published, event)
+	})
+	return events.NewTxManager(store, bus), published
+}
+
+func createUser(ctx context.Context, q database.Querier, username string) (database.User, error) {
+	return q.CreateUser(ctx, database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Username:  username,
+		Email:     username + "@example.com",
+		Password:  "hash",
+	})
+}
+
+func outboxEvents(t *testing.T, store database.Store) []database.Outbox {
+	t.Helper()
+
+	outbox, err := store.GetUnprocessedOutboxEvents(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outbox
+}
+
+func TestWithTxCommit(t *testing.T) {
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			txManager, published := newTxManager(t, store)
+
+			var registered events.UserRegistered
+			err := txManager.WithTx(ctx, func(tx *events.Tx) error {
+				user, err := createUser(ctx, tx, "alice")
+				if err != nil {
+					return err
+				}
+				registered = events.UserRegistered{UserID: user.ID, Username: user.Username, CreatedAt: user.CreatedAt}
+				if err := tx.Emit(ctx, registered); err != nil {
+					return err
+				}
+
+				// Nothing is published until the transaction has committed
+				if len(*published) != 0 {
+					t.Errorf("published %d events inside the transaction, want none", len(*published))
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("WithTx: %v", err)
+			}
+
+			if len(*published) != 1 || (*published)[0] != registered {
+				t.Errorf("got published events %+v, want only %+v", *published, registered)
+			}
+
+			outbox := outboxEvents(t, store)
+			if len(outbox) != 1 {
+				t.Fatalf("got %d outbox events, want 1", len(outbox))
+			}
+			if outbox[0].EventType != events.TypeUserRegistered {
+				t.Errorf("got event type %q, want %q", outbox[0].EventType, events.TypeUserRegistered)
+			}
+			if !slices.Equal(outbox[0].UserIds, []uuid.UUID{registered.UserID}) {
+				t.Errorf("got user ids %v, want %v", outbox[0].UserIds, []uuid.UUID{registered.UserID})
+			}
+
+			var payload events.UserRegistered
+			if err := json.Unmarshal(outbox[0].Payload, &payload); err != nil {
+				t.Fatalf("decoding payload: %v", err)
+			}
+			if payload.UserID != registered.UserID || payload.Username != "alice" {
+				t.Errorf("got payload %+v, want %+v", payload, registered)
+			}
+		})
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			txManager, published := newTxManager(t, store)
+
+			errAbort := errors.New("abort")
+			err := txManager.WithTx(ctx, func(tx *events.Tx) error {
+				user, err := createUser(ctx, tx, "alice")
+				if err != nil {
+					return err
+				}
+				if err := tx.Emit(ctx, events.UserRegistered{UserID: user.ID, Username: user.Username}); err != nil {
+					return err
+				}
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Fatalf("got error %v, want %v", err, errAbort)
+			}
+
+			// The emitted event goes down with the rest of the transaction
+			if len(*published) != 0 {
+				t.Errorf("got published events %+v, want none", *published)
+			}
+			if outbox := outboxEvents(t, store); len(outbox) != 0 {
+				t.Errorf("got %d outbox events, want none", len(outbox))
+			}
+			if _, err := store.GetUserByUsername(ctx, "alice"); err == nil {
+				t.Error("the user created in the rolled back transaction exists")
+			}
+		})
+	}
+}
+
+func TestEmitWithoutSubjects(t *testing.T) {
+	store := memstore.New()
+	txManager, _ := newTxManager(t, store)
+
+	// Events without subjects are stored with an empty list rather than NULL
+	err := txManager.WithTx(ctx, func(tx *events.Tx) error {
+		return tx.Emit(ctx, subjectless{})
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	outbox := outboxEvents(t, store)
+	if len(outbox) != 1 || outbox[0].UserIds == nil || len(outbox[0].UserIds) != 0 {
+		t.Errorf("got outbox events %+v, want one with an empty user list", outbox)
+	}
+}
+
+type subjectless struct{}
+
+func (subjectless) Type() string { return "test.subjectless" }
+
+func (subjectless) Subjects() []uuid.UUID { return nil }
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/model_converter"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ContactHandler struct {
+	DB database.Querier
+	Tx *events.TxManager
+}
+
+func NewContactHandler(db database.Querier, txManager *events.TxManager) *ContactHandler {
+	return &ContactHandler{DB: db, Tx: txManager}
+}
+
+// Report whether blockerID has blocked blockedID. Callers must fail the request when the lookup
+// fails, treating it as "not blocked" would let blocked users through
+func isBlockedBy(ctx context.Context, db database.Querier, blockerID uuid.UUID, blockedID uuid.UUID) (bool, error) {
+	isBlockedParams := database.IsBlockedParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	}
+	return db.IsBlocked(ctx, isBlockedParams)
+}
+
+// Look up the user named in the path, hiding users who have blocked the requesting user behind a
+// not found error with the given detail
+func (h *ContactHandler) getVisibleUser(c *fiber.Ctx, userID uuid.UUID, notFound string) (database.User, error) {
+	qUser, err := h.DB.GetUserByUsername(c.UserContext(), c.Params("username"))
+	if err != nil {
+		return database.User{}, apierr.New(apierr.NotFound, notFound)
+	}
+
+	blocked, err := isBlockedBy(c.UserContext(), h.DB, qUser.ID, userID)
+	if err != nil {
+		return database.User{}, apierr.Wrap(err)
+	}
+	if blocked {
+		return database.User{}, apierr.New(apierr.NotFound, notFound)
+	}
+
+	return qUser, nil
+}
+
+// Handler for listing the requesting user's accepted contacts
+func (h *ContactHandler) HandlerGetContacts(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	dbContacts, err := h.DB.GetContacts(c.UserContext(), userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	contacts := make([]model_converter.Contact, len(dbContacts))
+	for i := range dbContacts {
+		contacts[i] = model_converter.DatabaseContactToContact(dbContacts[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(contacts)
+}
+
+// Handler for listing pending contact requests, both received (incoming) and sent (outgoing)
+func (h *ContactHandler) HandlerGetContactRequests(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	dbIncoming, err := h.DB.GetIncomingContactRequests(c.UserContext(), userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	dbOutgoing, err := h.DB.GetOutgoingContactRequests(c.UserContext(), userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	incoming := make([]model_converter.Contact, len(dbIncoming))
+	for i := range dbIncoming {
+		incoming[i] = model_converter.DatabaseContactToContact(database.GetContactsRow(dbIncoming[i]))
+	}
+
+	outgoing := make([]model_converter.Contact, len(dbOutgoing))
+	for i := range dbOutgoing {
+		outgoing[i] = model_converter.DatabaseContactToContact(database.GetContactsRow(dbOutgoing[i]))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"incoming": incoming,
+		"outgoing": outgoing,
+	})
+}
+
+// Handler for sending a contact request. If the other user has already sent us a request,
+// the two requests cancel out and the contact is accepted straight away
+func (h *ContactHandler) HandlerCreateContactRequest(c *fiber.Ctx) error {
+	type createContactRequest struct {
+		Username string `json:"username" validate:"required"`
+	}
+
+	var req createContactRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	qUser, err := h.DB.GetUserByUsername(c.UserContext(), req.Username)
+	if err != nil {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", req.Username))
+	}
+
+	blocked, err := isBlockedBy(c.UserContext(), h.DB, qUser.ID, userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+	if blocked {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", req.Username))
+	}
+
+	if qUser.ID == userID {
+		return apierr.Invalid(apierr.FieldError{Field: "username", Message: "cannot add yourself as a contact"})
+	}
+
+	// A request would let the blocked user accept it and message the requester again
+	blocking, err := isBlockedBy(c.UserContext(), h.DB, userID, qUser.ID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+	if blocking {
+		return apierr.Invalid(apierr.FieldError{Field: "username", Message: "cannot add a user you have blocked, unblock them first"})
+	}
+
+	contactBetweenParams := database.GetContactBetweenParams{
+		RequesterID: userID,
+		AddresseeID: qUser.ID,
+	}
+	existing, err := h.DB.GetContactBetween(c.UserContext(), contactBetweenParams)
+	if err == nil {
+		if existing.Status == "accepted" {
+			return apierr.New(apierr.Conflict, "Already a contact")
+		}
+
+		if existing.RequesterID == userID {
+			return apierr.New(apierr.Conflict, "Contact request already sent")
+		}
+
+		// The other user asked first, so treat this as accepting their request
+		acceptParams := database.AcceptContactRequestParams{
+			UpdatedAt:   time.Now().UTC(),
+			RequesterID: qUser.ID,
+			AddresseeID: userID,
+		}
+		if _, err := h.DB.AcceptContactRequest(c.UserContext(), acceptParams); err != nil {
+			return apierr.Wrap(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"Success": "Contact request accepted",
+		})
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return apierr.Wrap(err)
+	}
+
+	createParams := database.CreateContactRequestParams{
+		RequesterID: userID,
+		AddresseeID: qUser.ID,
+		CreatedAt:   time.Now().UTC(),
+	}
+	// Losing a race with a request the other way round trips the unique index on the pair
+	if _, err := h.DB.CreateContactRequest(c.UserContext(), createParams); apierr.IsUniqueViolation(err) {
+		return apierr.New(apierr.Conflict, "A contact request between you already exists")
+	} else if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"Success": "Contact request sent",
+	})
+}
+
+// Handler for accepting a pending contact request sent by the user named in the path
+func (h *ContactHandler) HandlerAcceptContactRequest(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	qUser, err := h.getVisibleUser(c, userID, "Contact request not found")
+	if err != nil {
+		return err
+	}
+
+	acceptParams := database.AcceptContactRequestParams{
+		UpdatedAt:   time.Now().UTC(),
+		RequesterID: qUser.ID,
+		AddresseeID: userID,
+	}
+	accepted, err := h.DB.AcceptContactRequest(c.UserContext(), acceptParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	if accepted == 0 {
+		return apierr.New(apierr.NotFound, "Contact request not found")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"Success": "Contact request accepted",
+	})
+}
+
+// Handler for declining a pending contact request sent by the user named in the path
+func (h *ContactHandler) HandlerDeclineContactRequest(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	qUser, err := h.getVisibleUser(c, userID, "Contact request not found")
+	if err != nil {
+		return err
+	}
+
+	declineParams := database.DeclineContactRequestParams{
+		RequesterID: qUser.ID,
+		AddresseeID: userID,
+	}
+	declined, err := h.DB.DeclineContactRequest(c.UserContext(), declineParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	if declined == 0 {
+		return apierr.New(apierr.NotFound, "Contact request not found")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"Success": "Contact request declined",
+	})
+}
+
+// Handler for removing a contact, or withdrawing a contact request we have sent
+func (h *ContactHandler) HandlerDeleteContact(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	qUser, err := h.getVisibleUser(c, userID, "Contact not found")
+	if err != nil {
+		return err
+	}
+
+	deleteParams := database.DeleteContactParams{
+		RequesterID: userID,
+		AddresseeID: qUser.ID,
+	}
+	deleted, err := h.DB.DeleteContact(c.UserContext(), deleteParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	if deleted == 0 {
+		return apierr.New(apierr.NotFound, "Contact not found")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"Success": "Contact removed",
+	})
+}
+
+// Handler for listing the users the requesting user has blocked
+func (h *ContactHandler) HandlerGetBlockedUsers(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	dbBlocks, err := h.DB.GetBlockedUsers(c.UserContext(), userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	blockedUsers := make([]model_converter.BlockedUser, len(dbBlocks))
+	for i := range dbBlocks {
+		blockedUsers[i] = model_converter.DatabaseBlockedUserToBlockedUser(dbBlocks[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(blockedUsers)
+}
+
+// Handler for blocking a user. Any contact or pending request between the two users is removed,
+// and messages from the blocked user are silently dropped from now on
+func (h *ContactHandler) HandlerBlockUser(c *fiber.Ctx) error {
+	type blockUserRequest struct {
+		Username string `json:"username" validate:"required"`
+	}
+
+	var req blockUserRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	qUser, err := h.DB.GetUserByUsername(c.UserContext(), req.Username)
+	if err != nil {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", req.Username))
+	}
+
+	if qUser.ID == userID {
+		return apierr.Invalid(apierr.FieldError{Field: "username", Message: "cannot block yourself"})
+	}
+
+	err = h.Tx.WithTx(c.UserContext(), func(tx *events.Tx) error {
+		blockParams := database.CreateBlockParams{
+			BlockerID: userID,
+			BlockedID: qUser.ID,
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := tx.CreateBlock(c.UserContext(), blockParams); err != nil {
+			return err
+		}
+
+		deleteParams := database.DeleteContactParams{
+			RequesterID: userID,
+			AddresseeID: qUser.ID,
+		}
+		_, err := tx.DeleteContact(c.UserContext(), deleteParams)
+		return err
+	})
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"Success": "User blocked",
+	})
+}
+
+// Handler for unblocking the user named in the path
+func (h *ContactHandler) HandlerUnblockUser(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	qUser, err := h.DB.GetUserByUsername(c.UserContext(), c.Params("username"))
+	if err != nil {
+		return apierr.New(apierr.NotFound, "Blocked user not found")
+	}
+
+	unblockParams := database.DeleteBlockParams{
+		BlockerID: userID,
+		BlockedID: qUser.ID,
+	}
+	unblocked, err := h.DB.DeleteBlock(c.UserContext(), unblockParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	if unblocked == 0 {
+		return apierr.New(apierr.NotFound, "Blocked user not found")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"Success": "User unblocked",
+	})
+}
+
+// Handler for updating the requesting user's privacy settings. With contacts_only set, only accepted
+// contacts can send the user messages, and with hide_last_seen set their presence is shown without a
+// last-seen time. Only the fields present in the payload are changed
+func (h *ContactHandler) HandlerUpdatePrivacySettings(c *fiber.Ctx) error {
+	type updatePrivacySettingsRequest struct {
+		ContactsOnly *bool `json:"contacts_only"`
+		HideLastSeen *bool `json:"hide_last_seen"`
+	}
+
+	var req updatePrivacySettingsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	updateParams := database.UpdateUserPrivacySettingsParams{
+		ContactsOnly: nullBool(req.ContactsOnly),
+		HideLastSeen: nullBool(req.HideLastSeen),
+		UpdatedAt:    time.Now().UTC(),
+		ID:           userID,
+	}
+	user, err := h.DB.UpdateUserPrivacySettings(c.UserContext(), updateParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"contacts_only":  user.ContactsOnly,
+		"hide_last_seen": user.HideLastSeen,
+	})
+}
+
+// Map an optional payload field onto a nullable query parameter, where NULL means "leave unchanged"
+func nullBool(value *bool) sql.NullBool {
+	if value == nil {
+		return sql.NullBool{Valid: false}
+	}
+	return sql.NullBool{
+		Bool:  *value,
+		Valid: true,
+	}
+}
@@ -0,0 +1,275 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/memstore"
+	"github.com/PlatosRepublic7/ember/internal/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestContactRequestToBlockedUser(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	newUser(t, app, "bob")
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/blocks", alice, map[string]string{"username": "bob"}, nil); status != fiber.StatusCreated {
+		t.Fatalf("blocking: got status %d, want %d", status, fiber.StatusCreated)
+	}
+
+	var problem apierr.Problem
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests", alice, map[string]string{"username": "bob"}, &problem); status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "username" {
+		t.Errorf("got errors %+v, want one for username", problem.Errors)
+	}
+
+	if status := doRequest(t, app, http.MethodDelete, "/v1/blocks/bob", alice, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("unblocking: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests", alice, map[string]string{"username": "bob"}, nil); status != fiber.StatusCreated {
+		t.Errorf("after unblocking: got status %d, want %d", status, fiber.StatusCreated)
+	}
+}
+
+// A store that never finds an existing contact, as if the other user's request landed between the
+// handler's lookup and its insert
+type racingContactStore struct {
+	*memstore.Store
+}
+
+func (s racingContactStore) GetContactBetween(ctx context.Context, arg database.GetContactBetweenParams) (database.Contact, error) {
+	return database.Contact{}, sql.ErrNoRows
+}
+
+func TestCrossingContactRequestsConflict(t *testing.T) {
+	app := newTestAppWithStore(t, racingContactStore{memstore.New()}, health.NewChecker())
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests", alice, map[string]string{"username": "bob"}, nil); status != fiber.StatusCreated {
+		t.Fatalf("first request: got status %d, want %d", status, fiber.StatusCreated)
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests", bob, map[string]string{"username": "alice"}, nil); status != fiber.StatusConflict {
+		t.Errorf("crossing request: got status %d, want %d", status, fiber.StatusConflict)
+	}
+}
+
+type testContact struct {
+	Username string `json:"username"`
+	Status   string `json:"status"`
+}
+
+type testContactRequests struct {
+	Incoming []testContact `json:"incoming"`
+	Outgoing []testContact `json:"outgoing"`
+}
+
+func contactNames(contacts []testContact) []string {
+	names := make([]string, len(contacts))
+	for i := range contacts {
+		names[i] = contacts[i].Username
+	}
+	return names
+}
+
+func requestContact(t *testing.T, app *fiber.App, token string, username string) int {
+	t.Helper()
+	return doRequest(t, app, http.MethodPost, "/v1/contacts/requests", token, map[string]string{"username": username}, nil)
+}
+
+func TestContactRequestFlow(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+	carol := newUser(t, app, "carol")
+
+	if status := requestContact(t, app, alice, "bob"); status != fiber.StatusCreated {
+		t.Fatalf("requesting: got status %d, want %d", status, fiber.StatusCreated)
+	}
+	if status := requestContact(t, app, alice, "bob"); status != fiber.StatusConflict {
+		t.Errorf("requesting twice: got status %d, want %d", status, fiber.StatusConflict)
+	}
+	if status := requestContact(t, app, alice, "alice"); status != fiber.StatusUnprocessableEntity {
+		t.Errorf("requesting yourself: got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if status := requestContact(t, app, alice, "nobody"); status != fiber.StatusNotFound {
+		t.Errorf("requesting an unknown user: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+
+	var requests testContactRequests
+	doRequest(t, app, http.MethodGet, "/v1/contacts/requests", bob, nil, &requests)
+	if len(requests.Incoming) != 1 || requests.Incoming[0].Username != "alice" || len(requests.Outgoing) != 0 {
+		t.Fatalf("bob's requests: got %+v, want one incoming from alice", requests)
+	}
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests/alice/accept", bob, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("accepting: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests/alice/accept", bob, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("accepting twice: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+	if status := requestContact(t, app, bob, "alice"); status != fiber.StatusConflict {
+		t.Errorf("requesting an existing contact: got status %d, want %d", status, fiber.StatusConflict)
+	}
+
+	for _, token := range []string{alice, bob} {
+		var contacts []testContact
+		doRequest(t, app, http.MethodGet, "/v1/contacts", token, nil, &contacts)
+		if len(contacts) != 1 || contacts[0].Status != "accepted" {
+			t.Errorf("got contacts %+v, want one accepted contact", contacts)
+		}
+	}
+
+	// Declining removes the request without making a contact
+	requestContact(t, app, carol, "alice")
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests/carol/decline", alice, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("declining: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests/carol/accept", alice, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("accepting a declined request: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+	var contacts []testContact
+	doRequest(t, app, http.MethodGet, "/v1/contacts", alice, nil, &contacts)
+	if names := contactNames(contacts); len(names) != 1 || names[0] != "bob" {
+		t.Errorf("after declining: got contacts %q, want only bob", names)
+	}
+
+	if status := doRequest(t, app, http.MethodDelete, "/v1/contacts/bob", alice, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("removing: got status %d, want %d", status, fiber.StatusOK)
+	}
+	doRequest(t, app, http.MethodGet, "/v1/contacts", bob, nil, &contacts)
+	if len(contacts) != 0 {
+		t.Errorf("after removing: got contacts %q, want none", contactNames(contacts))
+	}
+}
+
+func TestCrossingContactRequestsAccept(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	requestContact(t, app, alice, "bob")
+
+	// Asking someone who already asked you accepts their request
+	if status := requestContact(t, app, bob, "alice"); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	var contacts []testContact
+	doRequest(t, app, http.MethodGet, "/v1/contacts", alice, nil, &contacts)
+	if len(contacts) != 1 || contacts[0].Username != "bob" || contacts[0].Status != "accepted" {
+		t.Errorf("got contacts %+v, want bob accepted", contacts)
+	}
+}
+
+func TestBlockingAndUnblocking(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	requestContact(t, app, alice, "bob")
+	doRequest(t, app, http.MethodPost, "/v1/contacts/requests/alice/accept", bob, nil, nil)
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/blocks", alice, map[string]string{"username": "alice"}, nil); status != fiber.StatusUnprocessableEntity {
+		t.Errorf("blocking yourself: got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/blocks", bob, map[string]string{"username": "alice"}, nil); status != fiber.StatusCreated {
+		t.Fatalf("blocking: got status %d, want %d", status, fiber.StatusCreated)
+	}
+
+	var blocked []testContact
+	doRequest(t, app, http.MethodGet, "/v1/blocks", bob, nil, &blocked)
+	if names := contactNames(blocked); len(names) != 1 || names[0] != "alice" {
+		t.Errorf("got blocked users %q, want alice", names)
+	}
+
+	// Blocking ends the contact, and the blocked user can no longer find the blocker
+	var contacts []testContact
+	doRequest(t, app, http.MethodGet, "/v1/contacts", alice, nil, &contacts)
+	if len(contacts) != 0 {
+		t.Errorf("contacts after blocking: got %q, want none", contactNames(contacts))
+	}
+	if status := requestContact(t, app, alice, "bob"); status != fiber.StatusNotFound {
+		t.Errorf("requesting the blocker: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+
+	if status := doRequest(t, app, http.MethodDelete, "/v1/blocks/alice", bob, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("unblocking: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if status := doRequest(t, app, http.MethodDelete, "/v1/blocks/alice", bob, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("unblocking twice: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+	if status := requestContact(t, app, alice, "bob"); status != fiber.StatusCreated {
+		t.Errorf("requesting after the unblock: got status %d, want %d", status, fiber.StatusCreated)
+	}
+}
+
+func TestPrivacySettings(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+	carol := newUser(t, app, "carol")
+
+	var settings map[string]bool
+	if status := doRequest(t, app, http.MethodPut, "/v1/settings/privacy", alice, map[string]bool{"contacts_only": true}, &settings); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+	if !settings["contacts_only"] || settings["hide_last_seen"] {
+		t.Errorf("got settings %v, want only contacts_only", settings)
+	}
+
+	// Fields left out keep their value
+	doRequest(t, app, http.MethodPut, "/v1/settings/privacy", alice, map[string]bool{"hide_last_seen": true}, &settings)
+	if !settings["contacts_only"] || !settings["hide_last_seen"] {
+		t.Errorf("got settings %v, want both set", settings)
+	}
+
+	// With contacts_only, only contacts get through
+	requestContact(t, app, bob, "alice")
+	doRequest(t, app, http.MethodPost, "/v1/contacts/requests/bob/accept", alice, nil, nil)
+	if status, _ := sendMessage(t, app, bob, map[string]any{"username": "alice", "content": "hi"}); status != fiber.StatusCreated {
+		t.Errorf("contact: got status %d, want %d", status, fiber.StatusCreated)
+	}
+	if status, _ := sendMessage(t, app, carol, map[string]any{"username": "alice", "content": "hi"}); status != fiber.StatusForbidden {
+		t.Errorf("stranger: got status %d, want %d", status, fiber.StatusForbidden)
+	}
+
+	doRequest(t, app, http.MethodPut, "/v1/settings/privacy", alice, map[string]bool{"contacts_only": false}, &settings)
+	if status, _ := sendMessage(t, app, carol, map[string]any{"username": "alice", "content": "hi"}); status != fiber.StatusCreated {
+		t.Errorf("stranger after contacts_only is lifted: got status %d, want %d", status, fiber.StatusCreated)
+	}
+}
+
+func TestGetUserHidesBlockers(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/bob", alice, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("before the block: got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	doRequest(t, app, http.MethodPost, "/v1/blocks", bob, map[string]string{"username": "alice"}, nil)
+
+	// A user who blocked you answers exactly like one that does not exist
+	var blockedProblem, missingProblem apierr.Problem
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/bob", alice, nil, &blockedProblem); status != fiber.StatusNotFound {
+		t.Errorf("blocker: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+	doRequest(t, app, http.MethodGet, "/v1/users/nobody", alice, nil, &missingProblem)
+	if blockedProblem.Title != missingProblem.Title || blockedProblem.Code != missingProblem.Code {
+		t.Errorf("blocker answered %+v, unknown user %+v", blockedProblem, missingProblem)
+	}
+
+	// The blocker can still look up the user they blocked
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/alice", bob, nil, nil); status != fiber.StatusOK {
+		t.Errorf("blocked user seen by the blocker: got status %d, want %d", status, fiber.StatusOK)
+	}
+}
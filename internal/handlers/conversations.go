@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/model_converter"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ConversationHandler struct {
+	DB database.Querier
+	Tx *events.TxManager
+}
+
+func NewConversationHandler(db database.Querier, txManager *events.TxManager) *ConversationHandler {
+	return &ConversationHandler{DB: db, Tx: txManager}
+}
+
+// Handler for listing the requesting user's conversations (one per counterpart), most recent first.
+// Each entry carries a preview of the latest message and the number of unread messages
+func (h *ConversationHandler) HandlerGetConversations(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	summaryParams := database.GetConversationSummariesParams{
+		UserID: userID,
+		Now: sql.NullTime{
+			Time:  time.Now().UTC(),
+			Valid: true,
+		},
+	}
+	summaries, err := h.DB.GetConversationSummaries(c.UserContext(), summaryParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	conversations := make([]model_converter.Conversation, len(summaries))
+	for i := range summaries {
+		conversations[i] = model_converter.DatabaseConversationToConversation(summaries[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(conversations)
+}
+
+// Handler for marking every message received from the named user as read. An optional up_to timestamp
+// in the payload limits this to messages delivered at or before that point, otherwise everything is marked
+func (h *ConversationHandler) HandlerMarkConversationRead(c *fiber.Ctx) error {
+	type markConversationReadRequest struct {
+		UpTo *time.Time `json:"up_to"`
+	}
+
+	// The payload is optional, so only an empty body skips parsing
+	var req markConversationReadRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	reqUsername := c.Params("username")
+	qUser, err := h.DB.GetUserByUsername(c.UserContext(), reqUsername)
+	if err != nil {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	now := time.Now().UTC()
+	upTo := now
+	if req.UpTo != nil {
+		upTo = req.UpTo.UTC()
+	}
+
+	markParams := database.MarkConversationReadParams{
+		ReadAt: sql.NullTime{
+			Time:  now,
+			Valid: true,
+		},
+		RecipientID: userID,
+		SenderID:    qUser.ID,
+		UpTo:        upTo,
+	}
+	var markedIDs []uuid.UUID
+	err = h.Tx.WithTx(c.UserContext(), func(tx *events.Tx) error {
+		markedIDs, err = tx.MarkConversationRead(c.UserContext(), markParams)
+		if err != nil || len(markedIDs) == 0 {
+			return err
+		}
+
+		// One MessageRead event covers every message marked by this request
+		read := events.MessageRead{
+			ReaderID:   userID,
+			SenderID:   qUser.ID,
+			MessageIDs: markedIDs,
+			ReadAt:     now,
+		}
+		return tx.Emit(c.UserContext(), read)
+	})
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"marked_read": len(markedIDs),
+	})
+}
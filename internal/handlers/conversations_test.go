@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/gofiber/fiber/v2"
+)
+
+type testConversation struct {
+	CounterpartUsername string `json:"counterpart_username"`
+	UnreadCount         int64  `json:"unread_count"`
+}
+
+func markRead(t *testing.T, app *fiber.App, token string, username string, body any) (int, int) {
+	t.Helper()
+
+	var marked struct {
+		MarkedRead int `json:"marked_read"`
+	}
+	status := doRequest(t, app, http.MethodPost, "/v1/conversations/"+username+"/read", token, body, &marked)
+	return status, marked.MarkedRead
+}
+
+func unreadFrom(t *testing.T, app *fiber.App, token string, username string) int64 {
+	t.Helper()
+
+	var conversations []testConversation
+	if status := doRequest(t, app, http.MethodGet, "/v1/conversations", token, nil, &conversations); status != fiber.StatusOK {
+		t.Fatalf("listing conversations: got status %d, want %d", status, fiber.StatusOK)
+	}
+	for _, conversation := range conversations {
+		if conversation.CounterpartUsername == username {
+			return conversation.UnreadCount
+		}
+	}
+	t.Fatalf("no conversation with %s", username)
+	return 0
+}
+
+func TestMarkConversationRead(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	sendMessage(t, app, bob, map[string]any{"username": "alice", "content": "first"})
+	before := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+	sendMessage(t, app, bob, map[string]any{"username": "alice", "content": "second"})
+
+	if got := unreadFrom(t, app, alice, "bob"); got != 2 {
+		t.Fatalf("got %d unread, want 2", got)
+	}
+
+	// up_to leaves later messages unread
+	if status, marked := markRead(t, app, alice, "bob", map[string]any{"up_to": before}); status != fiber.StatusOK || marked != 1 {
+		t.Fatalf("marking up to a time: got status %d and %d marked, want %d and 1", status, marked, fiber.StatusOK)
+	}
+	if got := unreadFrom(t, app, alice, "bob"); got != 1 {
+		t.Errorf("after marking up to a time: got %d unread, want 1", got)
+	}
+
+	// Without a body everything is marked, and marking again finds nothing left
+	if status, marked := markRead(t, app, alice, "bob", nil); status != fiber.StatusOK || marked != 1 {
+		t.Fatalf("marking everything: got status %d and %d marked, want %d and 1", status, marked, fiber.StatusOK)
+	}
+	if status, marked := markRead(t, app, alice, "bob", nil); status != fiber.StatusOK || marked != 0 {
+		t.Errorf("marking again: got status %d and %d marked, want %d and 0", status, marked, fiber.StatusOK)
+	}
+
+	// Only messages received from the user are marked, never the ones sent to them
+	sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "reply"})
+	if _, marked := markRead(t, app, alice, "bob", nil); marked != 0 {
+		t.Errorf("own messages: got %d marked, want 0", marked)
+	}
+	if got := unreadFrom(t, app, bob, "alice"); got != 1 {
+		t.Errorf("bob's side: got %d unread, want 1", got)
+	}
+}
+
+func TestMarkConversationReadErrors(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	newUser(t, app, "bob")
+
+	tests := []struct {
+		name     string
+		username string
+		body     any
+		status   int
+		code     apierr.Code
+	}{
+		{"unknown user", "nobody", nil, fiber.StatusNotFound, apierr.NotFound},
+		{"not an object", "bob", "everything", fiber.StatusBadRequest, apierr.MalformedRequest},
+		{"unparseable up_to", "bob", map[string]any{"up_to": "yesterday"}, fiber.StatusBadRequest, apierr.MalformedRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem apierr.Problem
+			if status := doRequest(t, app, http.MethodPost, "/v1/conversations/"+tt.username+"/read", alice, tt.body, &problem); status != tt.status {
+				t.Fatalf("got status %d, want %d", status, tt.status)
+			}
+			if problem.Code != tt.code {
+				t.Errorf("got code %q, want %q", problem.Code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/blob"
+	"github.com/PlatosRepublic7/ember/internal/config"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/memstore"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/health"
+	"github.com/PlatosRepublic7/ember/internal/presence"
+	"github.com/PlatosRepublic7/ember/internal/routes"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse battery staple"
+
+func TestMain(m *testing.M) {
+	// Keep the suite offline: every domain except invalid.test accepts mail
+	auth.LookupMX = func(domain string) ([]*net.MX, error) {
+		if domain == "invalid.test" {
+			return nil, errors.New("no such host")
+		}
+		return []*net.MX{{Host: "mx." + domain + ".", Pref: 10}}, nil
+	}
+
+	os.Exit(m.Run())
+}
+
+// The full route table backed by an in-memory store
+func newTestApp(t *testing.T) (*fiber.App, *memstore.Store) {
+	t.Helper()
+	return newTestAppWithChecker(t, health.NewChecker())
+}
+
+// Like newTestApp, with the readiness checks of checker
+func newTestAppWithChecker(t *testing.T, checker *health.Checker) (*fiber.App, *memstore.Store) {
+	t.Helper()
+
+	store := memstore.New()
+	return newTestAppWithStore(t, store, checker), store
+}
+
+// The full route table on top of store, for tests that wrap the in-memory store to inject failures
+func newTestAppWithStore(t *testing.T, store database.Store, checker *health.Checker) *fiber.App {
+	t.Helper()
+
+	blobStore, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txManager := events.NewTxManager(store, events.NewBus())
+	tracker := presence.NewTracker(presence.NewMemoryStore())
+
+	// Cheap hashes keep the suite fast
+	authenticator := auth.NewAuthenticator(config.Auth{
+		AccessSecret:    "test-access-secret",
+		RefreshSecret:   "test-refresh-secret",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+		BcryptCost:      bcrypt.MinCost,
+	})
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierr.ErrorHandler})
+	routes.SetupRoutes(app, store, txManager, blobStore, tracker, authenticator, checker)
+	return app
+}
+
+// Send a request with an optional JSON body and bearer token, decode the JSON response into out (if
+// not nil) and return the status code
+func doRequest(t *testing.T, app *fiber.App, method string, path string, token string, body any, out any) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func emailFor(username string) string {
+	return strings.ToLower(username) + "@example.com"
+}
+
+// Register username with testPassword and an email derived from the username
+func registerUser(t *testing.T, app *fiber.App, username string) {
+	t.Helper()
+
+	body := map[string]string{
+		"username": username,
+		"email":    emailFor(username),
+		"password": testPassword,
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/register", "", body, nil); status != fiber.StatusCreated {
+		t.Fatalf("registering %s: got status %d", username, status)
+	}
+}
+
+type tokenPair struct {
+	Access  string `json:"access"`
+	Refresh string `json:"refresh"`
+}
+
+func loginUser(t *testing.T, app *fiber.App, username string) tokenPair {
+	t.Helper()
+
+	body := map[string]string{
+		"email":    emailFor(username),
+		"password": testPassword,
+	}
+	var tokens tokenPair
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/login", "", body, &tokens); status != fiber.StatusCreated {
+		t.Fatalf("logging in %s: got status %d", username, status)
+	}
+	return tokens
+}
+
+// Register and log in a user, returning its access token
+func newUser(t *testing.T, app *fiber.App, username string) string {
+	t.Helper()
+
+	registerUser(t, app, username)
+	return loginUser(t, app, username).Access
+}
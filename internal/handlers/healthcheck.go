@@ -1,9 +1,33 @@
 package handlers
 
 import (
+	"github.com/PlatosRepublic7/ember/internal/health"
 	"github.com/gofiber/fiber/v2"
 )
 
 func HealthCheck(c *fiber.Ctx) error {
 	return c.Status(200).JSON(fiber.Map{"Message": "Hello, World!"})
 }
+
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{Checker: checker}
+}
+
+// Handler for the liveness probe. It only shows the process can serve requests, dependencies are left
+// to the readiness probe so an outage elsewhere does not get the server restarted
+func (h *HealthHandler) HandlerLivez(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": health.StatusOK})
+}
+
+// Handler for the readiness probe, with the result and latency of every dependency check
+func (h *HealthHandler) HandlerReadyz(c *fiber.Ctx) error {
+	report := h.Checker.Run(c.UserContext())
+	if report.Status != health.StatusOK {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+	return c.Status(fiber.StatusOK).JSON(report)
+}
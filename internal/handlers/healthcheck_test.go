@@ -0,0 +1,59 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLivez(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Add("database", func(ctx context.Context) error { return errors.New("connection refused") })
+	app, _ := newTestAppWithChecker(t, checker)
+
+	// Liveness ignores dependencies
+	if status := doRequest(t, app, http.MethodGet, "/livez", "", nil, nil); status != fiber.StatusOK {
+		t.Errorf("got status %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	failing := errors.New("connection refused")
+	var databaseErr error
+
+	checker := health.NewChecker()
+	checker.Add("database", func(ctx context.Context) error { return databaseErr })
+	checker.Add("migrations", func(ctx context.Context) error { return nil })
+	app, _ := newTestAppWithChecker(t, checker)
+
+	var report health.Report
+	if status := doRequest(t, app, http.MethodGet, "/readyz", "", nil, &report); status != fiber.StatusOK {
+		t.Fatalf("healthy: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if report.Status != health.StatusOK || len(report.Checks) != 2 || report.Checks["database"].Status != health.StatusOK {
+		t.Errorf("healthy: got %+v", report)
+	}
+
+	databaseErr = failing
+	report = health.Report{}
+	if status := doRequest(t, app, http.MethodGet, "/readyz", "", nil, &report); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("database down: got status %d, want %d", status, fiber.StatusServiceUnavailable)
+	}
+	if report.Status != health.StatusFailing || report.Checks["database"].Error != failing.Error() || report.Checks["migrations"].Status != health.StatusOK {
+		t.Errorf("database down: got %+v", report)
+	}
+
+	databaseErr = nil
+	checker.Drain()
+	report = health.Report{}
+	if status := doRequest(t, app, http.MethodGet, "/readyz", "", nil, &report); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("draining: got status %d, want %d", status, fiber.StatusServiceUnavailable)
+	}
+	if report.Status != health.StatusDraining {
+		t.Errorf("draining: got %+v", report)
+	}
+}
@@ -2,22 +2,26 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/PlatosRepublic7/ember/internal/apierr"
 	"github.com/PlatosRepublic7/ember/internal/auth"
 	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
 	"github.com/PlatosRepublic7/ember/internal/model_converter"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 type MessageHandler struct {
-	DB *database.Queries
+	DB database.Querier
+	Tx *events.TxManager
 }
 
-func NewMessageHandler(db *database.Queries) *MessageHandler {
-	return &MessageHandler{DB: db}
+func NewMessageHandler(db database.Querier, txManager *events.TxManager) *MessageHandler {
+	return &MessageHandler{DB: db, Tx: txManager}
 }
 
 // This handler will create a new message in the database (we will eventually add encrypion logic here
@@ -25,31 +29,51 @@ func NewMessageHandler(db *database.Queries) *MessageHandler {
 func (h *MessageHandler) HandlerCreateMessage(c *fiber.Ctx) error {
 	// Define the expected request payload as a struct
 	type createMessageRequest struct {
-		Username   string `json:"username"`
-		Content    string `json:"content"`
-		TtlSeconds *int32 `json:"ttl_seconds"`
+		Username   string     `json:"username" validate:"required"`
+		Content    string     `json:"content" validate:"content"`
+		TtlSeconds *int32     `json:"ttl_seconds" validate:"ttl"`
+		DeliverAt  *time.Time `json:"deliver_at"`
 	}
 
 	var req createMessageRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Malformed payload",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	// Search the database for the recipient's id
 	rUser, err := h.DB.GetUserByUsername(c.UserContext(), req.Username)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"Error": "Requested recipient does not exist",
-		})
+		return apierr.New(apierr.NotFound, "Requested recipient does not exist")
 	}
 
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": fmt.Sprintf("cannot access claims: %v", err),
-		})
+		return apierr.Wrap(err)
+	}
+
+	if rUser.ID == userID {
+		return apierr.Invalid(apierr.FieldError{Field: "username", Message: "cannot send a message to yourself"})
+	}
+
+	// Recipients who only accept messages from their contacts reject everyone else outright. Blocked
+	// senders are not told about the block, their messages are just never stored (see below)
+	blocked, err := isBlockedBy(c.UserContext(), h.DB, rUser.ID, userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+	if rUser.ContactsOnly && !blocked {
+		areContactsParams := database.AreContactsParams{
+			RequesterID: userID,
+			AddresseeID: rUser.ID,
+		}
+		areContacts, err := h.DB.AreContacts(c.UserContext(), areContactsParams)
+		if err != nil {
+			return apierr.Wrap(err)
+		}
+
+		if !areContacts {
+			return apierr.New(apierr.Forbidden, "Recipient only accepts messages from contacts")
+		}
 	}
 
 	// We need to check whether TtlSeconds is null (nil) or present
@@ -65,10 +89,27 @@ func (h *MessageHandler) HandlerCreateMessage(c *fiber.Ctx) error {
 		}
 	}
 
+	// A message with a future deliver_at is scheduled: it stays invisible to the recipient until the
+	// dispatcher delivers it, and its TTL only starts counting from that point
+	now := time.Now().UTC()
+	var deliverAt sql.NullTime
+	var deliveredAt sql.NullTime
+	if req.DeliverAt != nil && req.DeliverAt.After(now) {
+		deliverAt = sql.NullTime{
+			Time:  req.DeliverAt.UTC(),
+			Valid: true,
+		}
+	} else {
+		deliveredAt = sql.NullTime{
+			Time:  now,
+			Valid: true,
+		}
+	}
+
 	// And now calculate the expiration time (if needed)
 	var expiresAt sql.NullTime
-	if ttlSeconds.Valid {
-		expirationTime := time.Now().Add(time.Duration(ttlSeconds.Int32) * time.Second).UTC()
+	if ttlSeconds.Valid && deliveredAt.Valid {
+		expirationTime := now.Add(time.Duration(ttlSeconds.Int32) * time.Second)
 		expiresAt = sql.NullTime{
 			Time:  expirationTime,
 			Valid: true,
@@ -85,16 +126,44 @@ func (h *MessageHandler) HandlerCreateMessage(c *fiber.Ctx) error {
 		SenderID:    userID,
 		RecipientID: rUser.ID,
 		Content:     req.Content,
-		CreatedAt:   time.Now().UTC(),
+		CreatedAt:   now,
 		TtlSeconds:  ttlSeconds,
 		ExpiresAt:   expiresAt,
+		DeliverAt:   deliverAt,
+		DeliveredAt: deliveredAt,
+	}
+
+	// Answer a blocked sender exactly as if the message had been sent
+	if blocked {
+		droppedMessage := database.Message{
+			ID:          createMessageParams.ID,
+			SenderID:    createMessageParams.SenderID,
+			RecipientID: createMessageParams.RecipientID,
+			Content:     createMessageParams.Content,
+			CreatedAt:   createMessageParams.CreatedAt,
+			TtlSeconds:  createMessageParams.TtlSeconds,
+			ExpiresAt:   createMessageParams.ExpiresAt,
+			DeliverAt:   createMessageParams.DeliverAt,
+			DeliveredAt: createMessageParams.DeliveredAt,
+		}
+		return c.Status(fiber.StatusCreated).JSON(model_converter.DatabaseMessageToMessage(droppedMessage))
 	}
 
-	message, err := h.DB.CreateMessage(c.UserContext(), createMessageParams)
+	var message database.Message
+	err = h.Tx.WithTx(c.UserContext(), func(tx *events.Tx) error {
+		message, err = tx.CreateMessage(c.UserContext(), createMessageParams)
+		if err != nil {
+			return err
+		}
+
+		// Scheduled messages get their MessageCreated event from the dispatcher once they are delivered
+		if !message.DeliveredAt.Valid {
+			return nil
+		}
+		return tx.Emit(c.UserContext(), events.MessageCreated{MessageInfo: events.NewMessageInfo(message)})
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": fmt.Sprintf("%v", err),
-		})
+		return apierr.Wrap(err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(model_converter.DatabaseMessageToMessage(message))
@@ -118,9 +187,7 @@ func (h *MessageHandler) HandlerGetMessages(c *fiber.Ctx) error {
 		// username query-tag is included and non-empty. We need to get their id from the database
 		qUser, err := h.DB.GetUserByUsername(c.UserContext(), reqUsername)
 		if err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"Error": fmt.Sprintf("Requested user '%s' does not exist. %v", reqUsername, err),
-			})
+			return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
 		}
 
 		qUserID = qUser.ID
@@ -130,9 +197,7 @@ func (h *MessageHandler) HandlerGetMessages(c *fiber.Ctx) error {
 	// We first need to get the requesting user's id from the token
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": fmt.Sprintf("cannot access claims: %v", err),
-		})
+		return apierr.Wrap(err)
 	}
 
 	var messages []database.Message
@@ -147,17 +212,13 @@ func (h *MessageHandler) HandlerGetMessages(c *fiber.Ctx) error {
 
 			messages, err = h.DB.GetSentMessagesToNamedUser(c.UserContext(), getSentMessagesToNamedUserParams)
 			if err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"Error": fmt.Sprintf("%v", err),
-				})
+				return apierr.Wrap(err)
 			}
 		} else {
 			// Query the database for all messages sent by this user (that have not been deleted)
 			messages, err = h.DB.GetSentMessagesFromThisUser(c.UserContext(), userID)
 			if err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"Error": fmt.Sprintf("%v", err),
-				})
+				return apierr.Wrap(err)
 			}
 		}
 
@@ -170,16 +231,12 @@ func (h *MessageHandler) HandlerGetMessages(c *fiber.Ctx) error {
 
 			messages, err = h.DB.GetReceivedMessagesFromNamedUser(c.UserContext(), getReceivedMessagesFromNamedUserParams)
 			if err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"Error": fmt.Sprintf("%v", err),
-				})
+				return apierr.Wrap(err)
 			}
 		} else {
 			messages, err = h.DB.GetReceivedMessagesToThisUser(c.UserContext(), userID)
 			if err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"Error": fmt.Sprintf("%v", err),
-				})
+				return apierr.Wrap(err)
 			}
 		}
 
@@ -192,16 +249,12 @@ func (h *MessageHandler) HandlerGetMessages(c *fiber.Ctx) error {
 
 			messages, err = h.DB.GetMessageHistoryWithNamedUser(c.UserContext(), getMessageHistoryWithNamedUserParams)
 			if err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"Error": fmt.Sprintf("%v", err),
-				})
+				return apierr.Wrap(err)
 			}
 		} else {
 			messages, err = h.DB.GetUserMessageHistory(c.UserContext(), userID)
 			if err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"Error": fmt.Sprintf("%v", err),
-				})
+				return apierr.Wrap(err)
 			}
 		}
 	}
@@ -215,3 +268,218 @@ func (h *MessageHandler) HandlerGetMessages(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(convertedMessages)
 }
+
+// Handler for listing the requesting user's scheduled messages that have not been delivered yet
+func (h *MessageHandler) HandlerGetScheduledMessages(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	messages, err := h.DB.GetScheduledMessagesFromThisUser(c.UserContext(), userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	convertedMessages := make([]model_converter.Message, len(messages))
+	for i := range messages {
+		convertedMessages[i] = model_converter.DatabaseMessageToMessage(messages[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(convertedMessages)
+}
+
+// Handler for editing a pending scheduled message. Only the fields present in the payload are changed,
+// and only the sender can edit a message that has not been delivered yet
+func (h *MessageHandler) HandlerUpdateScheduledMessage(c *fiber.Ctx) error {
+	type updateScheduledMessageRequest struct {
+		Content    *string    `json:"content" validate:"content"`
+		TtlSeconds *int32     `json:"ttl_seconds" validate:"ttl"`
+		DeliverAt  *time.Time `json:"deliver_at" validate:"future"`
+	}
+
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return apierr.New(apierr.MalformedRequest, "Invalid message id")
+	}
+
+	var req updateScheduledMessageRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	getScheduledMessageParams := database.GetScheduledMessageParams{
+		ID:       messageID,
+		SenderID: userID,
+	}
+	message, err := h.DB.GetScheduledMessage(c.UserContext(), getScheduledMessageParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierr.New(apierr.NotFound, "Scheduled message not found")
+	} else if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	// Start from the stored values and overwrite whatever the client sent
+	updateParams := database.UpdateScheduledMessageParams{
+		Content:    message.Content,
+		TtlSeconds: message.TtlSeconds,
+		DeliverAt:  message.DeliverAt,
+		ID:         message.ID,
+		SenderID:   userID,
+	}
+
+	if req.Content != nil {
+		updateParams.Content = *req.Content
+	}
+
+	// An explicit "ttl_seconds": null removes the TTL, leaving it out keeps the stored one
+	if req.TtlSeconds != nil {
+		updateParams.TtlSeconds = sql.NullInt32{
+			Int32: *req.TtlSeconds,
+			Valid: true,
+		}
+	} else if explicitNull(c, "ttl_seconds") {
+		updateParams.TtlSeconds = sql.NullInt32{}
+	}
+
+	if req.DeliverAt != nil {
+		updateParams.DeliverAt = sql.NullTime{
+			Time:  req.DeliverAt.UTC(),
+			Valid: true,
+		}
+	}
+
+	// The dispatcher may have delivered the message since we looked it up, in which case nothing is updated
+	updatedMessage, err := h.DB.UpdateScheduledMessage(c.UserContext(), updateParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierr.New(apierr.Conflict, "Scheduled message has already been delivered")
+	} else if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseMessageToMessage(updatedMessage))
+}
+
+// Handler for cancelling a pending scheduled message, it will never be delivered to the recipient
+func (h *MessageHandler) HandlerCancelScheduledMessage(c *fiber.Ctx) error {
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return apierr.New(apierr.MalformedRequest, "Invalid message id")
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	cancelParams := database.CancelScheduledMessageParams{
+		ID:       messageID,
+		SenderID: userID,
+	}
+	rows, err := h.DB.CancelScheduledMessage(c.UserContext(), cancelParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	if rows == 0 {
+		return apierr.New(apierr.NotFound, "Scheduled message not found")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"Success": "Scheduled message cancelled",
+	})
+}
+
+// Handler for full-text search over the messages the requesting user has sent or received.
+// Query-string parameters: q (required), username, from and to (RFC 3339), limit and offset
+func (h *MessageHandler) HandlerSearchMessages(c *fiber.Ctx) error {
+	type searchMessagesQuery struct {
+		Query  string `query:"q" validate:"required"`
+		Limit  int32  `query:"limit" validate:"min=1,max=100"`
+		Offset int32  `query:"offset" validate:"min=0"`
+	}
+
+	req := searchMessagesQuery{Limit: 20}
+	if err := parseQuery(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	searchParams := database.SearchMessagesParams{
+		Query:  req.Query,
+		UserID: userID,
+		Now: sql.NullTime{
+			Time:  time.Now().UTC(),
+			Valid: true,
+		},
+		PageLimit:  req.Limit,
+		PageOffset: req.Offset,
+	}
+
+	// ?username=SomeUsername restricts results to the conversation with that user
+	if reqUsername := c.Query("username", ""); reqUsername != "" {
+		qUser, err := h.DB.GetUserByUsername(c.UserContext(), reqUsername)
+		if err != nil {
+			return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
+		}
+		searchParams.CounterpartID = uuid.NullUUID{
+			UUID:  qUser.ID,
+			Valid: true,
+		}
+	}
+
+	// ?from=...&to=... restricts results to messages sent in a date range. Both are checked before either is reported
+	var fields []apierr.FieldError
+	if reqFrom := c.Query("from", ""); reqFrom != "" {
+		from, err := time.Parse(time.RFC3339, reqFrom)
+		if err != nil {
+			fields = append(fields, apierr.FieldError{Field: "from", Message: "must be an RFC 3339 timestamp"})
+		}
+		searchParams.SentAfter = sql.NullTime{
+			Time:  from.UTC(),
+			Valid: true,
+		}
+	}
+
+	if reqTo := c.Query("to", ""); reqTo != "" {
+		to, err := time.Parse(time.RFC3339, reqTo)
+		if err != nil {
+			fields = append(fields, apierr.FieldError{Field: "to", Message: "must be an RFC 3339 timestamp"})
+		}
+		searchParams.SentBefore = sql.NullTime{
+			Time:  to.UTC(),
+			Valid: true,
+		}
+	}
+
+	// Messages carry only text, so there is nothing for ?has_attachment= to filter on. It is refused
+	// rather than ignored, so clients do not mistake unfiltered results for filtered ones
+	if c.Query("has_attachment", "") != "" {
+		fields = append(fields, apierr.FieldError{Field: "has_attachment", Message: "is not supported, messages have no attachments"})
+	}
+
+	if len(fields) > 0 {
+		return apierr.Invalid(fields...)
+	}
+
+	results, err := h.DB.SearchMessages(c.UserContext(), searchParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	convertedResults := make([]model_converter.MessageSearchResult, len(results))
+	for i := range results {
+		convertedResults[i] = model_converter.DatabaseSearchRowToSearchResult(results[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(convertedResults)
+}
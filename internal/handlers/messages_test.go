@@ -0,0 +1,467 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/memstore"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/health"
+	"github.com/PlatosRepublic7/ember/internal/validate"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type testMessage struct {
+	ID          string `json:"id"`
+	SenderID    string `json:"sender_id"`
+	RecipientID string `json:"recipient_id"`
+	Content     string `json:"content"`
+}
+
+func sendMessage(t *testing.T, app *fiber.App, token string, body map[string]any) (int, testMessage) {
+	t.Helper()
+
+	var message testMessage
+	status := doRequest(t, app, http.MethodPost, "/v1/messages", token, body, &message)
+	return status, message
+}
+
+func listMessages(t *testing.T, app *fiber.App, token string, query string) []testMessage {
+	t.Helper()
+
+	var messages []testMessage
+	if status := doRequest(t, app, http.MethodGet, "/v1/messages"+query, token, nil, &messages); status != fiber.StatusOK {
+		t.Fatalf("GET /v1/messages%s: got status %d, want %d", query, status, fiber.StatusOK)
+	}
+	return messages
+}
+
+func contents(messages []testMessage) []string {
+	result := make([]string, len(messages))
+	for i := range messages {
+		result[i] = messages[i].Content
+	}
+	return result
+}
+
+func TestSendAndListMessages(t *testing.T) {
+	app, store := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+	carol := newUser(t, app, "carol")
+
+	for _, send := range []struct {
+		token    string
+		username string
+		content  string
+	}{
+		{alice, "bob", "hi bob"},
+		{bob, "alice", "hi alice"},
+		{alice, "carol", "hi carol"},
+		{carol, "bob", "carol to bob"},
+	} {
+		status, message := sendMessage(t, app, send.token, map[string]any{"username": send.username, "content": send.content})
+		if status != fiber.StatusCreated {
+			t.Fatalf("sending %q: got status %d, want %d", send.content, status, fiber.StatusCreated)
+		}
+		if message.Content != send.content || message.ID == "" {
+			t.Fatalf("sending %q: unexpected message in response %+v", send.content, message)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		query string
+		want  []string
+	}{
+		{"all of alice's messages, newest first", alice, "", []string{"hi carol", "hi alice", "hi bob"}},
+		{"sent by alice", alice, "?type=sent", []string{"hi carol", "hi bob"}},
+		{"received by alice", alice, "?type=received", []string{"hi alice"}},
+		{"sent by alice to bob", alice, "?type=sent&username=bob", []string{"hi bob"}},
+		{"received by bob from carol", bob, "?type=received&username=carol", []string{"carol to bob"}},
+		{"history between alice and bob", bob, "?username=alice", []string{"hi alice", "hi bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contents(listMessages(t, app, tt.token, tt.query))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+
+	outbox, err := store.GetUnprocessedOutboxEvents(context.Background(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created int
+	for _, event := range outbox {
+		if event.EventType == events.TypeMessageCreated {
+			created++
+		}
+	}
+	if created != 4 {
+		t.Errorf("got %d %s events in the outbox, want 4", created, events.TypeMessageCreated)
+	}
+}
+
+func TestSendMessageToUnknownUser(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+
+	if status, _ := sendMessage(t, app, alice, map[string]any{"username": "nobody", "content": "hello?"}); status != fiber.StatusNotFound {
+		t.Errorf("got status %d, want %d", status, fiber.StatusNotFound)
+	}
+
+	if status := doRequest(t, app, http.MethodGet, "/v1/messages?username=nobody", alice, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("listing with an unknown user: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+}
+
+func TestSendMessageRejectsInvalidPayloads(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	newUser(t, app, "bob")
+
+	tests := []struct {
+		name  string
+		body  map[string]any
+		field string
+	}{
+		{"empty content", map[string]any{"username": "bob", "content": "   "}, "content"},
+		{"content too long", map[string]any{"username": "bob", "content": strings.Repeat("a", validate.MaxContentLength+1)}, "content"},
+		{"negative ttl", map[string]any{"username": "bob", "content": "hi", "ttl_seconds": -5}, "ttl_seconds"},
+		{"ttl too long", map[string]any{"username": "bob", "content": "hi", "ttl_seconds": validate.MaxTTLSeconds + 1}, "ttl_seconds"},
+		{"message to yourself", map[string]any{"username": "alice", "content": "hi me"}, "username"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem apierr.Problem
+			if status := doRequest(t, app, http.MethodPost, "/v1/messages", alice, tt.body, &problem); status != fiber.StatusUnprocessableEntity {
+				t.Fatalf("got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+			}
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field {
+				t.Errorf("got errors %+v, want one for %s", problem.Errors, tt.field)
+			}
+		})
+	}
+}
+
+func TestSearchMessagesRejectsMalformedDates(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+
+	// Both bad dates are reported at once
+	var problem apierr.Problem
+	if status := doRequest(t, app, http.MethodGet, "/v1/messages/search?q=hello&from=yesterday&to=2024-13-01", alice, nil, &problem); status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "from" || problem.Errors[1].Field != "to" {
+		t.Errorf("got errors %+v, want one for from and one for to", problem.Errors)
+	}
+
+	if status := doRequest(t, app, http.MethodGet, "/v1/messages/search?q=hello&from=2024-01-01T00:00:00Z", alice, nil, nil); status != fiber.StatusOK {
+		t.Errorf("valid date: got status %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+func TestSearchMessagesRejectsAttachmentFilter(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+
+	var problem apierr.Problem
+	if status := doRequest(t, app, http.MethodGet, "/v1/messages/search?q=hello&has_attachment=true", alice, nil, &problem); status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "has_attachment" {
+		t.Errorf("got errors %+v, want one for has_attachment", problem.Errors)
+	}
+}
+
+func TestSearchSnippetsEscapeContent(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	sendMessage(t, app, alice, map[string]any{"username": "bob", "content": `<script>alert("hi")</script> & bye`})
+
+	var results []struct {
+		Snippet string `json:"snippet"`
+	}
+	if status := doRequest(t, app, http.MethodGet, "/v1/messages/search?q=alert", bob, nil, &results); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	want := `&lt;script&gt;<mark>alert</mark>(&#34;hi&#34;)&lt;/script&gt; &amp; bye`
+	if len(results) != 1 || results[0].Snippet != want {
+		t.Errorf("got %+v, want one snippet %q", results, want)
+	}
+}
+
+func TestExpiredMessagesAreHidden(t *testing.T) {
+	app, store := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "short lived", "ttl_seconds": 1})
+	sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "forever"})
+
+	// Run the reaper's query as if the TTL had already passed
+	expireParams := database.ExpireMessagesParams{
+		Now:       sql.NullTime{Time: time.Now().UTC().Add(time.Minute), Valid: true},
+		BatchSize: 10,
+	}
+	expired, err := store.ExpireMessages(context.Background(), expireParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 {
+		t.Fatalf("expired %d messages, want 1", len(expired))
+	}
+
+	for _, token := range []string{alice, bob} {
+		got := contents(listMessages(t, app, token, ""))
+		if len(got) != 1 || got[0] != "forever" {
+			t.Errorf("got %q, want only the message without a TTL", got)
+		}
+	}
+}
+
+func TestScheduledMessagesWaitForDelivery(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	deliverAt := time.Now().UTC().Add(time.Hour)
+	status, scheduled := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "later", "deliver_at": deliverAt})
+	if status != fiber.StatusCreated {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusCreated)
+	}
+
+	if got := listMessages(t, app, bob, ""); len(got) != 0 {
+		t.Errorf("recipient sees scheduled messages: %q", contents(got))
+	}
+
+	var pending []testMessage
+	doRequest(t, app, http.MethodGet, "/v1/messages/scheduled", alice, nil, &pending)
+	if len(pending) != 1 || pending[0].ID != scheduled.ID {
+		t.Fatalf("got scheduled messages %+v, want only %s", pending, scheduled.ID)
+	}
+
+	if status := doRequest(t, app, http.MethodDelete, "/v1/messages/scheduled/"+scheduled.ID, alice, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("cancelling: got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	if status := doRequest(t, app, http.MethodDelete, "/v1/messages/scheduled/"+scheduled.ID, alice, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("cancelling twice: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+}
+
+func TestDeliveredScheduledMessagesListByDelivery(t *testing.T) {
+	app, store := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	_, scheduled := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "later", "deliver_at": time.Now().UTC().Add(time.Hour)})
+	sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "now"})
+
+	// Deliver the scheduled message as the dispatcher would, after the other one was sent
+	scheduledID, err := uuid.Parse(scheduled.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.MarkMessageDelivered(context.Background(), database.MarkMessageDeliveredParams{
+		DeliveredAt: sql.NullTime{Time: time.Now().UTC().Add(time.Minute), Valid: true},
+		ID:          scheduledID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := contents(listMessages(t, app, bob, ""))
+	if len(got) != 2 || got[0] != "later" || got[1] != "now" {
+		t.Errorf("got %q, want the scheduled message first", got)
+	}
+
+	// Search dates go by delivery as well, so the message scheduled before the range still matches
+	from := url.QueryEscape(time.Now().UTC().Add(30 * time.Second).Format(time.RFC3339))
+	var results []struct {
+		Snippet string `json:"snippet"`
+	}
+	if status := doRequest(t, app, http.MethodGet, "/v1/messages/search?q=later&from="+from, bob, nil, &results); status != fiber.StatusOK {
+		t.Fatalf("search: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if len(results) != 1 {
+		t.Errorf("search: got %+v, want the scheduled message", results)
+	}
+}
+
+func TestBlockedSendersAreDroppedSilently(t *testing.T) {
+	app, store := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/blocks", bob, map[string]string{"username": "alice"}, nil); status != fiber.StatusCreated {
+		t.Fatalf("blocking: got status %d, want %d", status, fiber.StatusCreated)
+	}
+
+	if status, _ := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "can you hear me?"}); status != fiber.StatusCreated {
+		t.Errorf("blocked sender: got status %d, want %d", status, fiber.StatusCreated)
+	}
+
+	if got := listMessages(t, app, bob, ""); len(got) != 0 {
+		t.Errorf("recipient received messages from a blocked sender: %q", contents(got))
+	}
+
+	aliceUser, err := store.GetUserByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := store.GetSentMessagesFromThisUser(context.Background(), aliceUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("message from a blocked sender was stored")
+	}
+}
+
+func TestUpdateScheduledMessageClearsTTL(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	newUser(t, app, "bob")
+
+	_, scheduled := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "later", "ttl_seconds": 60, "deliver_at": time.Now().UTC().Add(time.Hour)})
+
+	type ttlMessage struct {
+		Content    string `json:"content"`
+		TtlSeconds struct {
+			Int32 int32
+			Valid bool
+		} `json:"ttl_seconds"`
+	}
+
+	// Leaving ttl_seconds out keeps it
+	var updated ttlMessage
+	if status := doRequest(t, app, http.MethodPatch, "/v1/messages/scheduled/"+scheduled.ID, alice, map[string]any{"content": "edited"}, &updated); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+	if updated.Content != "edited" || !updated.TtlSeconds.Valid || updated.TtlSeconds.Int32 != 60 {
+		t.Errorf("got %+v, want the new content and the TTL kept", updated)
+	}
+
+	// An explicit null removes it
+	updated = ttlMessage{}
+	if status := doRequest(t, app, http.MethodPatch, "/v1/messages/scheduled/"+scheduled.ID, alice, map[string]any{"ttl_seconds": nil}, &updated); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+	if updated.Content != "edited" || updated.TtlSeconds.Valid {
+		t.Errorf("got %+v, want the TTL removed", updated)
+	}
+}
+
+// A store whose scheduled message updates fail with err, as if the message changed underneath the handler
+type failingUpdateStore struct {
+	*memstore.Store
+	err error
+}
+
+func (s failingUpdateStore) UpdateScheduledMessage(ctx context.Context, arg database.UpdateScheduledMessageParams) (database.Message, error) {
+	return database.Message{}, s.err
+}
+
+func TestUpdateScheduledMessageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"delivered in the meantime", sql.ErrNoRows, fiber.StatusConflict},
+		{"database failure", errors.New("connection refused"), fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestAppWithStore(t, failingUpdateStore{memstore.New(), tt.err}, health.NewChecker())
+			alice := newUser(t, app, "alice")
+			newUser(t, app, "bob")
+
+			_, scheduled := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "later", "deliver_at": time.Now().UTC().Add(time.Hour)})
+			if status := doRequest(t, app, http.MethodPatch, "/v1/messages/scheduled/"+scheduled.ID, alice, map[string]any{"content": "edited"}, nil); status != tt.want {
+				t.Errorf("got status %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+// A store whose block lookups fail, as they would while the database is unreachable
+type failingBlockStore struct {
+	*memstore.Store
+}
+
+func (s failingBlockStore) IsBlocked(ctx context.Context, arg database.IsBlockedParams) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestFailingBlockCheckRejectsRequests(t *testing.T) {
+	store := memstore.New()
+	app := newTestAppWithStore(t, failingBlockStore{store}, health.NewChecker())
+	alice := newUser(t, app, "alice")
+	newUser(t, app, "bob")
+
+	// A block check that cannot be answered must not let a possibly blocked sender through
+	if status, _ := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "hello"}); status != fiber.StatusInternalServerError {
+		t.Errorf("sending a message: got status %d, want %d", status, fiber.StatusInternalServerError)
+	}
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/bob", alice, nil, nil); status != fiber.StatusInternalServerError {
+		t.Errorf("viewing a profile: got status %d, want %d", status, fiber.StatusInternalServerError)
+	}
+
+	bob, err := store.GetUserByUsername(context.Background(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received, err := store.GetReceivedMessagesToThisUser(context.Background(), bob.ID); err != nil || len(received) != 0 {
+		t.Errorf("message was stored despite the failed block check: %d, %v", len(received), err)
+	}
+}
+
+func TestContactsOnlyRecipients(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	if status := doRequest(t, app, http.MethodPut, "/v1/settings/privacy", bob, map[string]bool{"contacts_only": true}, nil); status != fiber.StatusOK {
+		t.Fatalf("updating privacy settings: got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	if status, _ := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "hello stranger"}); status != fiber.StatusForbidden {
+		t.Errorf("non-contact: got status %d, want %d", status, fiber.StatusForbidden)
+	}
+
+	doRequest(t, app, http.MethodPost, "/v1/contacts/requests", alice, map[string]string{"username": "bob"}, nil)
+	if status := doRequest(t, app, http.MethodPost, "/v1/contacts/requests/alice/accept", bob, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("accepting the contact request: got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	if status, _ := sendMessage(t, app, alice, map[string]any{"username": "bob", "content": "hello friend"}); status != fiber.StatusCreated {
+		t.Errorf("contact: got status %d, want %d", status, fiber.StatusCreated)
+	}
+}
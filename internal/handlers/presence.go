@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/model_converter"
+	"github.com/PlatosRepublic7/ember/internal/presence"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type PresenceHandler struct {
+	DB      database.Querier
+	Tracker *presence.Tracker
+}
+
+func NewPresenceHandler(db database.Querier, tracker *presence.Tracker) *PresenceHandler {
+	return &PresenceHandler{DB: db, Tracker: tracker}
+}
+
+// Handler for listing the presence of every contact of the requesting user
+func (h *PresenceHandler) HandlerGetContactsPresence(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	contacts, err := h.DB.GetContactsPresenceInfo(c.UserContext(), userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	contactIDs := make([]uuid.UUID, len(contacts))
+	for i := range contacts {
+		contactIDs[i] = contacts[i].ID
+	}
+
+	presences, err := h.Tracker.Lookup(c.UserContext(), contactIDs)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	convertedPresences := make([]model_converter.Presence, len(presences))
+	for i := range presences {
+		convertedPresences[i] = model_converter.PresenceToPresence(presences[i], contacts[i].Username, contacts[i].HideLastSeen)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(convertedPresences)
+}
+
+// Handler for signalling that the requesting user is typing to the user named in the path.
+// Clients resend it every few seconds while the user keeps typing, each signal expires on its own
+func (h *PresenceHandler) HandlerSetTyping(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	reqUsername := c.Params("username")
+	qUser, err := h.DB.GetUserByUsername(c.UserContext(), reqUsername)
+	if err != nil {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
+	}
+
+	blocked, err := isBlockedBy(c.UserContext(), h.DB, qUser.ID, userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+	if blocked {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
+	}
+
+	if err := h.Tracker.SetTyping(c.UserContext(), userID, qUser.ID); err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Handler for checking whether the user named in the path is currently typing to the requesting user
+func (h *PresenceHandler) HandlerGetTyping(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	reqUsername := c.Params("username")
+	qUser, err := h.DB.GetUserByUsername(c.UserContext(), reqUsername)
+	if err != nil {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
+	}
+
+	blocked, err := isBlockedBy(c.UserContext(), h.DB, qUser.ID, userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+	if blocked {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
+	}
+
+	// Typing signals from users we have blocked are never shown
+	hidden, err := isBlockedBy(c.UserContext(), h.DB, userID, qUser.ID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	typing := false
+	if !hidden {
+		typing, err = h.Tracker.IsTyping(c.UserContext(), qUser.ID, userID)
+		if err != nil {
+			return apierr.Wrap(err)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"typing": typing,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type testPresence struct {
+	Username string       `json:"username"`
+	Status   string       `json:"status"`
+	LastSeen sql.NullTime `json:"last_seen"`
+}
+
+func contactsPresence(t *testing.T, app *fiber.App, token string) map[string]testPresence {
+	t.Helper()
+
+	var presences []testPresence
+	if status := doRequest(t, app, http.MethodGet, "/v1/contacts/presence", token, nil, &presences); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+	byUsername := make(map[string]testPresence, len(presences))
+	for _, p := range presences {
+		byUsername[p.Username] = p
+	}
+	return byUsername
+}
+
+func TestContactsPresence(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+	newUser(t, app, "carol")
+
+	requestContact(t, app, alice, "bob")
+	doRequest(t, app, http.MethodPost, "/v1/contacts/requests/alice/accept", bob, nil, nil)
+
+	// Only contacts are listed, and every request counts as activity
+	presences := contactsPresence(t, app, alice)
+	if len(presences) != 1 {
+		t.Fatalf("got presences %+v, want only bob", presences)
+	}
+	if p := presences["bob"]; p.Status != "online" || !p.LastSeen.Valid {
+		t.Errorf("got %+v, want bob online with a last-seen time", p)
+	}
+}
+
+func TestContactsPresenceHidesLastSeen(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	requestContact(t, app, alice, "bob")
+	doRequest(t, app, http.MethodPost, "/v1/contacts/requests/alice/accept", bob, nil, nil)
+	doRequest(t, app, http.MethodPut, "/v1/settings/privacy", bob, map[string]bool{"hide_last_seen": true}, nil)
+
+	// Hiding the last-seen time keeps the status visible
+	if p := contactsPresence(t, app, alice)["bob"]; p.Status != "online" || p.LastSeen.Valid {
+		t.Errorf("got %+v, want bob online without a last-seen time", p)
+	}
+
+	// The setting only hides bob's own time, not that of their contacts
+	if p := contactsPresence(t, app, bob)["alice"]; !p.LastSeen.Valid {
+		t.Errorf("got %+v, want alice with a last-seen time", p)
+	}
+
+	doRequest(t, app, http.MethodPut, "/v1/settings/privacy", bob, map[string]bool{"hide_last_seen": false}, nil)
+	if p := contactsPresence(t, app, alice)["bob"]; !p.LastSeen.Valid {
+		t.Errorf("after the setting is lifted: got %+v, want a last-seen time", p)
+	}
+}
+
+func TestContactsPresenceLeavesOutBlockers(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	requestContact(t, app, alice, "bob")
+	doRequest(t, app, http.MethodPost, "/v1/contacts/requests/alice/accept", bob, nil, nil)
+	doRequest(t, app, http.MethodPost, "/v1/blocks", bob, map[string]string{"username": "alice"}, nil)
+
+	if presences := contactsPresence(t, app, alice); len(presences) != 0 {
+		t.Errorf("got presences %+v, want none", presences)
+	}
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/blob"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/model_converter"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Largest avatar image we accept, in bytes
+const maxAvatarSize = 2 << 20
+
+// Accepted avatar content types (as sniffed from the upload) and the extension they are stored under
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type ProfileHandler struct {
+	DB    database.Querier
+	Blobs blob.Store
+}
+
+func NewProfileHandler(db database.Querier, blobs blob.Store) *ProfileHandler {
+	return &ProfileHandler{DB: db, Blobs: blobs}
+}
+
+// Handler for getting the requesting user's own profile, including private fields such as their email
+func (h *ProfileHandler) HandlerGetProfile(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	user, err := h.DB.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return apierr.New(apierr.NotFound, "User not found")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseUserToProfile(user))
+}
+
+// Handler for editing the requesting user's profile. Only the fields present in the payload are changed
+func (h *ProfileHandler) HandlerUpdateProfile(c *fiber.Ctx) error {
+	type updateProfileRequest struct {
+		// The limits mirror the column sizes in the users table
+		DisplayName *string `json:"display_name" validate:"max=100"`
+		Bio         *string `json:"bio" validate:"max=500"`
+		StatusText  *string `json:"status_text" validate:"max=140"`
+		Timezone    *string `json:"timezone" validate:"timezone"`
+	}
+
+	var req updateProfileRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	updateParams := database.UpdateUserProfileParams{
+		DisplayName: nullString(req.DisplayName),
+		Bio:         nullString(req.Bio),
+		StatusText:  nullString(req.StatusText),
+		Timezone:    nullString(req.Timezone),
+		UpdatedAt:   time.Now().UTC(),
+		ID:          userID,
+	}
+	user, err := h.DB.UpdateUserProfile(c.UserContext(), updateParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseUserToProfile(user))
+}
+
+// Handler for uploading a new avatar as the "avatar" field of a multipart form. The previous avatar,
+// if any, is removed from the blob store once the new one is in place
+func (h *ProfileHandler) HandlerUploadAvatar(c *fiber.Ctx) error {
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		return apierr.New(apierr.InvalidInput, "Missing 'avatar' file in multipart form")
+	}
+
+	if fileHeader.Size > maxAvatarSize {
+		return apierr.New(apierr.PayloadTooLarge, fmt.Sprintf("Avatar cannot be larger than %d bytes", maxAvatarSize))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return apierr.New(apierr.InvalidInput, "Cannot read uploaded avatar")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarSize+1))
+	if err != nil || len(data) > maxAvatarSize {
+		return apierr.New(apierr.InvalidInput, "Cannot read uploaded avatar")
+	}
+
+	// Trust the bytes rather than the Content-Type the client claims
+	extension, ok := avatarExtensions[http.DetectContentType(data)]
+	if !ok {
+		return apierr.New(apierr.UnsupportedMediaType, "Avatar must be a PNG, JPEG, GIF or WebP image")
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	previous, err := h.DB.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return apierr.New(apierr.NotFound, "User not found")
+	}
+
+	avatarKey := fmt.Sprintf("avatars/%s/%s%s", userID, uuid.New(), extension)
+	if err := h.Blobs.Put(c.UserContext(), avatarKey, bytes.NewReader(data)); err != nil {
+		return apierr.Wrap(err)
+	}
+
+	updateParams := database.UpdateUserAvatarParams{
+		AvatarKey: avatarKey,
+		UpdatedAt: time.Now().UTC(),
+		ID:        userID,
+	}
+	user, err := h.DB.UpdateUserAvatar(c.UserContext(), updateParams)
+	if err != nil {
+		h.deleteAvatar(c.UserContext(), avatarKey)
+		return apierr.Wrap(err)
+	}
+
+	if previous.AvatarKey != "" {
+		h.deleteAvatar(c.UserContext(), previous.AvatarKey)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseUserToProfile(user))
+}
+
+// Handler for removing the requesting user's avatar
+func (h *ProfileHandler) HandlerDeleteAvatar(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	previous, err := h.DB.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return apierr.New(apierr.NotFound, "User not found")
+	}
+
+	updateParams := database.UpdateUserAvatarParams{
+		AvatarKey: "",
+		UpdatedAt: time.Now().UTC(),
+		ID:        userID,
+	}
+	user, err := h.DB.UpdateUserAvatar(c.UserContext(), updateParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	if previous.AvatarKey != "" {
+		h.deleteAvatar(c.UserContext(), previous.AvatarKey)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseUserToProfile(user))
+}
+
+// Handler for serving the avatar image of the user named in the path
+func (h *ProfileHandler) HandlerGetAvatar(c *fiber.Ctx) error {
+	user, err := h.DB.GetUserByUsername(c.UserContext(), c.Params("username"))
+	if err != nil || user.AvatarKey == "" {
+		return apierr.New(apierr.NotFound, "Avatar not found")
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	blocked, err := isBlockedBy(c.UserContext(), h.DB, user.ID, userID)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+	if blocked {
+		return apierr.New(apierr.NotFound, "Avatar not found")
+	}
+
+	avatar, err := h.Blobs.Open(c.UserContext(), user.AvatarKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		return apierr.New(apierr.NotFound, "Avatar not found")
+	} else if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	// Fiber closes the reader once the body has been written
+	c.Type(strings.TrimPrefix(path.Ext(user.AvatarKey), "."))
+	return c.Status(fiber.StatusOK).SendStream(avatar)
+}
+
+// The request has already succeeded or failed by the time an avatar is removed, so a failed delete only
+// leaves an orphaned blob behind. It is logged for the operator to clean up rather than returned
+func (h *ProfileHandler) deleteAvatar(ctx context.Context, key string) {
+	if err := h.Blobs.Delete(ctx, key); err != nil {
+		slog.WarnContext(ctx, "cannot delete avatar", "key", key, "error", err)
+	}
+}
+
+// Map an optional payload field onto a nullable query parameter, where NULL means "leave unchanged"
+func nullString(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{Valid: false}
+	}
+	return sql.NullString{
+		String: *value,
+		Valid:  true,
+	}
+}
@@ -0,0 +1,185 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Just enough of a PNG for content sniffing to recognise it
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+// Upload data as the avatar of the user behind token, under the given form field, and return the
+// status code. The response is decoded into out if it is not nil
+func uploadAvatar(t *testing.T, app *fiber.App, token string, field string, data []byte, out any) int {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(field, "avatar.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/me/avatar", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("uploading avatar: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("uploading avatar: decoding response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestUploadAvatarValidation(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+
+	tests := []struct {
+		name   string
+		field  string
+		data   []byte
+		status int
+		code   apierr.Code
+	}{
+		{"wrong field", "picture", testPNG, fiber.StatusBadRequest, apierr.InvalidInput},
+		{"not an image", "avatar", []byte("just some text"), fiber.StatusUnsupportedMediaType, apierr.UnsupportedMediaType},
+		{"HTML named as a PNG", "avatar", []byte("<html><body>hi</body></html>"), fiber.StatusUnsupportedMediaType, apierr.UnsupportedMediaType},
+		{"too large", "avatar", append(bytes.Clone(testPNG), make([]byte, 2<<20)...), fiber.StatusRequestEntityTooLarge, apierr.PayloadTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem apierr.Problem
+			if status := uploadAvatar(t, app, alice, tt.field, tt.data, &problem); status != tt.status {
+				t.Fatalf("got status %d, want %d", status, tt.status)
+			}
+			if problem.Code != tt.code {
+				t.Errorf("got code %q, want %q", problem.Code, tt.code)
+			}
+		})
+	}
+
+	// Nothing was stored by the rejected uploads
+	var profile map[string]any
+	doRequest(t, app, http.MethodGet, "/v1/me", alice, nil, &profile)
+	if profile["avatar_url"] != "" {
+		t.Errorf("got avatar_url %q, want none", profile["avatar_url"])
+	}
+}
+
+func TestAvatarLifecycle(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	var profile map[string]any
+	if status := uploadAvatar(t, app, alice, "avatar", testPNG, &profile); status != fiber.StatusOK {
+		t.Fatalf("uploading: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if profile["avatar_url"] != "/v1/users/alice/avatar" {
+		t.Errorf("got avatar_url %q, want /v1/users/alice/avatar", profile["avatar_url"])
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/alice/avatar", nil)
+	req.Header.Set("Authorization", "Bearer "+bob)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("fetching: got status %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Errorf("got content type %q, want image/png", got)
+	}
+	if !bytes.Equal(data, testPNG) {
+		t.Error("fetched avatar differs from the uploaded one")
+	}
+
+	// A blocked user cannot fetch the blocker's avatar
+	doRequest(t, app, http.MethodPost, "/v1/blocks", alice, map[string]string{"username": "bob"}, nil)
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/alice/avatar", bob, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("fetching as a blocked user: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+
+	if status := doRequest(t, app, http.MethodDelete, "/v1/me/avatar", alice, nil, &profile); status != fiber.StatusOK {
+		t.Fatalf("deleting: got status %d, want %d", status, fiber.StatusOK)
+	}
+	if profile["avatar_url"] != "" {
+		t.Errorf("after deleting: got avatar_url %q, want none", profile["avatar_url"])
+	}
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/alice/avatar", alice, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("fetching after deleting: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+}
+
+func TestPublicProfile(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+	bob := newUser(t, app, "bob")
+
+	update := map[string]string{
+		"display_name": "Bob B.",
+		"bio":          "Likes long walks",
+		"status_text":  "busy",
+		"timezone":     "Europe/Paris",
+	}
+	if status := doRequest(t, app, http.MethodPatch, "/v1/me", bob, update, nil); status != fiber.StatusOK {
+		t.Fatalf("updating: got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	var profile map[string]any
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/bob", alice, nil, &profile); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+	for field, want := range update {
+		if profile[field] != want {
+			t.Errorf("got %s %q, want %q", field, profile[field], want)
+		}
+	}
+
+	// Neither the email address nor the private settings are ever shown to other users
+	for _, field := range []string{"email", "contacts_only", "hide_last_seen"} {
+		if _, ok := profile[field]; ok {
+			t.Errorf("public profile exposes %s", field)
+		}
+	}
+
+	// The user's own profile does carry them
+	doRequest(t, app, http.MethodGet, "/v1/me", bob, nil, &profile)
+	if profile["email"] != emailFor("bob") {
+		t.Errorf("own profile: got email %q, want %q", profile["email"], emailFor("bob"))
+	}
+}
+
+func TestUpdateProfileValidation(t *testing.T) {
+	app, _ := newTestApp(t)
+	alice := newUser(t, app, "alice")
+
+	var problem apierr.Problem
+	if status := doRequest(t, app, http.MethodPatch, "/v1/me", alice, map[string]string{"timezone": "Mars/Olympus"}, &problem); status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "timezone" {
+		t.Errorf("got errors %+v, want one for timezone", problem.Errors)
+	}
+}
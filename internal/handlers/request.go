@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/validate"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Parse the request body into req and check it against the validate tags on its fields
+func parseBody(c *fiber.Ctx, req any) error {
+	if err := c.BodyParser(req); err != nil {
+		return apierr.New(apierr.MalformedRequest, "Malformed payload")
+	}
+	return validate.Struct(req)
+}
+
+// Report whether the JSON body sets field to null. parseBody leaves the pointer for a null field nil,
+// the same as for one that was left out, so handlers that clear a value on null check for it here
+func explicitNull(c *fiber.Ctx, field string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &fields); err != nil {
+		return false
+	}
+	raw, ok := fields[field]
+	return ok && string(raw) == "null"
+}
+
+// Parse the query string into req and check it against the validate tags on its fields
+func parseQuery(c *fiber.Ctx, req any) error {
+	if err := c.QueryParser(req); err != nil {
+		return apierr.New(apierr.MalformedRequest, "Malformed query string")
+	}
+	return validate.Struct(req)
+}
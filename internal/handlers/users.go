@@ -1,61 +1,51 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
 	"time"
 
+	"github.com/PlatosRepublic7/ember/internal/apierr"
 	"github.com/PlatosRepublic7/ember/internal/auth"
 	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/metrics"
 	"github.com/PlatosRepublic7/ember/internal/model_converter"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 type UserHandler struct {
-	DB *database.Queries
+	DB   database.Querier
+	Tx   *events.TxManager
+	Auth *auth.Authenticator
 }
 
-func NewUserHandler(db *database.Queries) *UserHandler {
-	return &UserHandler{DB: db}
+func NewUserHandler(db database.Querier, txManager *events.TxManager, authenticator *auth.Authenticator) *UserHandler {
+	return &UserHandler{DB: db, Tx: txManager, Auth: authenticator}
 }
 
 // Handler for registering a new user
 func (h *UserHandler) HandlerCreateUser(c *fiber.Ctx) error {
 	// Define the struct matching the expected request payload
 	type createUserRequest struct {
-		Username string `json:"username"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Username string `json:"username" validate:"required,username"`
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required,password"`
 	}
 
 	var req createUserRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"Error": "Invalid Request Payload",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	// Validate the input
-	if req.Username == "" || req.Password == "" || req.Email == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Payload is missing required fields",
-		})
+	// A well formed address can still be on a domain that does not take mail
+	if !auth.IsEmailValid(req.Email) {
+		return apierr.Invalid(apierr.FieldError{Field: "email", Message: "cannot be validated, the domain does not accept mail"})
 	}
 
-	// Validate the email address
-	ok := auth.IsEmailValid(req.Email)
-	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "The provided email address cannot be validated",
-		})
-	}
-
-	hashedPassword, err := auth.HashPassword(req.Password)
+	hashedPassword, err := h.Auth.HashPassword(c.UserContext(), req.Password)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": fmt.Sprintf("%v", err),
-		})
+		return apierr.Wrap(err)
 	}
 
 	// Prepare the parameters for the sqlc generated CreateUser function
@@ -68,66 +58,69 @@ func (h *UserHandler) HandlerCreateUser(c *fiber.Ctx) error {
 		Password:  hashedPassword,
 	}
 
-	user, err := h.DB.CreateUser(c.UserContext(), params)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Failed to create user",
-		})
+	// The user and its UserRegistered event are written together, or not at all
+	var user database.User
+	err = h.Tx.WithTx(c.UserContext(), func(tx *events.Tx) error {
+		user, err = tx.CreateUser(c.UserContext(), params)
+		if err != nil {
+			return err
+		}
+
+		registered := events.UserRegistered{
+			UserID:    user.ID,
+			Username:  user.Username,
+			CreatedAt: user.CreatedAt,
+		}
+		return tx.Emit(c.UserContext(), registered)
+	})
+	if apierr.IsUniqueViolation(err) {
+		return apierr.New(apierr.Conflict, "Username or email is already taken")
+	} else if err != nil {
+		return apierr.Wrap(err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(model_converter.DatabaseUserToUser(user))
 }
 
-// Handler for getting a user from the database by username, returns only one user
+// Handler for getting the public profile of a user by the username in the path, returns only one user
 func (h *UserHandler) HandlerGetUser(c *fiber.Ctx) error {
-	type getUserRequest struct {
-		Username string `json:"username"`
+	reqUsername := c.Params("username")
+
+	user, err := h.DB.GetUserByUsername(c.UserContext(), reqUsername)
+	if err != nil {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
 	}
 
-	var req getUserRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Malformed payload",
-		})
+	// Users who have blocked the requesting user look exactly like users that do not exist
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return apierr.Wrap(err)
 	}
 
-	user, err := h.DB.GetUserByUsername(c.UserContext(), req.Username)
+	blocked, err := isBlockedBy(c.UserContext(), h.DB, user.ID, userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"GET Error": fmt.Sprintf("%v", err),
-		})
+		return apierr.Wrap(err)
+	}
+	if blocked {
+		return apierr.New(apierr.NotFound, fmt.Sprintf("Requested user '%s' does not exist", reqUsername))
 	}
 
-	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseUserToUser(user))
+	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseUserToPublicProfile(user))
 }
 
 // Generate a new access token, or respond with an error
 func (h *UserHandler) HandlerRefreshToken(c *fiber.Ctx) error {
 	type getRefreshTokenRequest struct {
-		RefreshToken string `json:"refresh_token"`
+		RefreshToken string `json:"refresh_token" validate:"required"`
 	}
 	var req getRefreshTokenRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Malformed payload",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	accessToken, err := auth.AnalyzeRefreshToken(h.DB, c, req.RefreshToken)
+	accessToken, err := h.Auth.AnalyzeRefreshToken(h.DB, c, req.RefreshToken)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": fmt.Sprintf("%v", err),
-		})
-	}
-
-	if accessToken == "refresh token has expired, login required" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"Token": accessToken,
-		})
-	} else if accessToken == "refresh token is blacklisted, login required" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"Token": accessToken,
-		})
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
@@ -138,84 +131,84 @@ func (h *UserHandler) HandlerRefreshToken(c *fiber.Ctx) error {
 // This will generate an access-refresh token pair if successfull
 func (h *UserHandler) HandlerLoginUser(c *fiber.Ctx) error {
 	type getUserLoginRequest struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
 	}
 
 	// Get Request body
 	var req getUserLoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Malformed payload",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	// Retrieve the user from the database
 	user, err := h.DB.GetUserLoginInfo(c.UserContext(), req.Email)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"Error": fmt.Sprintf("%v", err),
-		})
+		metrics.LoginAttempts.WithLabelValues(metrics.LoginUnknownUser).Inc()
+		return apierr.New(apierr.NotFound, "No user is registered with that email")
 	}
 
 	// Check the given password against the one in the database
-	ok := auth.CheckPasswordHash(req.Password, user.Password)
+	ok := auth.CheckPasswordHash(c.UserContext(), req.Password, user.Password)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Incorrect password",
-		})
+		metrics.LoginAttempts.WithLabelValues(metrics.LoginWrongPassword).Inc()
+		return apierr.New(apierr.InvalidInput, "Incorrect password")
 	}
 
-	// We need to check that if there are any refresh tokens in the database for this user,
-	// then we need to invalidate them and generate a new one.
-	refreshTokenList, err := h.DB.GetAllUserRefreshTokens(context.Background(), user.ID)
+	// Only tell whoever knows the password that the account is suspended, see ember admin suspend
+	if user.SuspendedAt.Valid {
+		metrics.LoginAttempts.WithLabelValues(metrics.LoginSuspended).Inc()
+		return apierr.New(apierr.Forbidden, "Account is suspended")
+	}
+
+	// Generate the access and refresh tokens
+	accessTokenString, refreshTokenString, err := h.Auth.GenerateTokenPair(user)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": fmt.Sprintf("%v", err),
-		})
+		return apierr.Wrap(err)
 	}
 
-	for i := range refreshTokenList {
-		if refreshTokenList[i].IsValid {
-			refreshTokenUpdateParams := database.UpdateRefreshTokenParams{
-				IsValid:      false,
-				UpdatedAt:    time.Now().UTC(),
-				RefreshToken: refreshTokenList[i].RefreshToken,
-			}
+	// We need to check that if there are any refresh tokens in the database for this user,
+	// then we need to invalidate them and generate a new one. Both happen in one transaction so a
+	// failure half way never leaves the user logged out everywhere without a new token
+	var dbRefreshToken database.RefreshToken
+	err = h.Tx.WithTx(c.UserContext(), func(tx *events.Tx) error {
+		refreshTokenList, err := tx.GetAllUserRefreshTokens(c.UserContext(), user.ID)
+		if err != nil {
+			return err
+		}
 
-			err := h.DB.UpdateRefreshToken(c.UserContext(), refreshTokenUpdateParams)
-			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"Error": fmt.Sprintf("%v", err),
-				})
+		for i := range refreshTokenList {
+			if refreshTokenList[i].IsValid {
+				refreshTokenUpdateParams := database.UpdateRefreshTokenParams{
+					IsValid:      false,
+					UpdatedAt:    time.Now().UTC(),
+					RefreshToken: refreshTokenList[i].RefreshToken,
+				}
+
+				if err := tx.UpdateRefreshToken(c.UserContext(), refreshTokenUpdateParams); err != nil {
+					return err
+				}
 			}
 		}
-	}
 
-	// Generate the access and refresh tokens
-	accessTokenString, refreshTokenString, err := auth.GenerateTokenPair(user)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": fmt.Sprintf("%v", err),
-		})
-	}
-
-	// Store the newly created Refresh Token in the database
-	refreshTokenParams := database.CreateRefreshTokenParams{
-		RefreshToken: refreshTokenString,
-		IsValid:      true,
-		CreatedAt:    time.Now().UTC(),
-		UpdatedAt:    time.Now().UTC(),
-		UserID:       user.ID,
-	}
+		// Store the newly created Refresh Token in the database
+		refreshTokenParams := database.CreateRefreshTokenParams{
+			RefreshToken: refreshTokenString,
+			IsValid:      true,
+			CreatedAt:    time.Now().UTC(),
+			UpdatedAt:    time.Now().UTC(),
+			UserID:       user.ID,
+		}
 
-	dbRefreshToken, err := h.DB.CreateRefreshToken(c.UserContext(), refreshTokenParams)
+		dbRefreshToken, err = tx.CreateRefreshToken(c.UserContext(), refreshTokenParams)
+		return err
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": "Unable to store refresh token",
-		})
+		return apierr.Wrap(err)
 	}
 
+	metrics.LoginAttempts.WithLabelValues(metrics.LoginSuccess).Inc()
+
 	// Return the pair of tokens to the client
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"access":  accessTokenString,
@@ -227,21 +220,17 @@ func (h *UserHandler) HandlerLoginUser(c *fiber.Ctx) error {
 // preventing any ability to generate new access tokens from it
 func (h *UserHandler) HandlerLogoutUser(c *fiber.Ctx) error {
 	type updateRefreshToken struct {
-		RefreshToken string `json:"refresh_token"`
+		RefreshToken string `json:"refresh_token" validate:"required"`
 	}
 
 	var req updateRefreshToken
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": "Malformed payload",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	dbRefreshToken, err := h.DB.GetRefreshToken(c.UserContext(), req.RefreshToken)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"Error": "Refresh token not found",
-		})
+		return apierr.New(apierr.NotFound, "Refresh token not found")
 	}
 	// Construct the Refresh Token parameters for invalidation
 	refreshTokenParams := database.UpdateRefreshTokenParams{
@@ -252,9 +241,7 @@ func (h *UserHandler) HandlerLogoutUser(c *fiber.Ctx) error {
 
 	err = h.DB.UpdateRefreshToken(c.UserContext(), refreshTokenParams)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": fmt.Sprintf("%v", err),
-		})
+		return apierr.Wrap(err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
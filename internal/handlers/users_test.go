@@ -0,0 +1,335 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRegisterUser(t *testing.T) {
+	app, store := newTestApp(t)
+
+	body := map[string]string{
+		"username": "alice",
+		"email":    "alice@example.com",
+		"password": testPassword,
+	}
+	var user struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/register", "", body, &user); status != fiber.StatusCreated {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusCreated)
+	}
+
+	if user.Username != "alice" || user.Email != "alice@example.com" || user.ID == "" {
+		t.Errorf("unexpected user in response: %+v", user)
+	}
+	if user.Password != "" {
+		t.Error("response leaks the password hash")
+	}
+
+	stored, err := store.GetUserByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password == testPassword {
+		t.Error("password was stored in plain text")
+	}
+
+	outbox, err := store.GetUnprocessedOutboxEvents(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 1 || outbox[0].EventType != events.TypeUserRegistered {
+		t.Errorf("got outbox %+v, want a single %s event", outbox, events.TypeUserRegistered)
+	}
+}
+
+func TestRegisterUserRejectsBadInput(t *testing.T) {
+	app, _ := newTestApp(t)
+	registerUser(t, app, "alice")
+
+	tests := []struct {
+		name   string
+		body   map[string]string
+		status int
+	}{
+		{"missing password", map[string]string{"username": "bob", "email": "bob@example.com"}, fiber.StatusUnprocessableEntity},
+		{"missing username", map[string]string{"email": "bob@example.com", "password": testPassword}, fiber.StatusUnprocessableEntity},
+		{"username with spaces", map[string]string{"username": "bob smith", "email": "bob@example.com", "password": testPassword}, fiber.StatusUnprocessableEntity},
+		{"short password", map[string]string{"username": "bob", "email": "bob@example.com", "password": "hunter2"}, fiber.StatusUnprocessableEntity},
+		{"unresolvable email domain", map[string]string{"username": "bob", "email": "bob@invalid.test", "password": testPassword}, fiber.StatusUnprocessableEntity},
+		{"malformed email", map[string]string{"username": "bob", "email": "not an email", "password": testPassword}, fiber.StatusUnprocessableEntity},
+		{"duplicate username", map[string]string{"username": "alice", "email": "other@example.com", "password": testPassword}, fiber.StatusConflict},
+		{"duplicate email", map[string]string{"username": "bob", "email": emailFor("alice"), "password": testPassword}, fiber.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := doRequest(t, app, http.MethodPost, "/v1/auth/register", "", tt.body, nil); status != tt.status {
+				t.Errorf("got status %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestRegisterUserListsEveryInvalidField(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	body := map[string]string{"username": "a", "email": "not an email"}
+	var problem apierr.Problem
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/register", "", body, &problem); status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+
+	if problem.Code != apierr.ValidationFailed || len(problem.Errors) != 3 {
+		t.Fatalf("got %+v, want username, email and password listed", problem)
+	}
+	for i, field := range []string{"username", "email", "password"} {
+		if problem.Errors[i].Field != field || problem.Errors[i].Message == "" {
+			t.Errorf("error %d: got %+v, want one for %s", i, problem.Errors[i], field)
+		}
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	app, _ := newTestApp(t)
+	registerUser(t, app, "alice")
+
+	tokens := loginUser(t, app, "alice")
+	if tokens.Access == "" || tokens.Refresh == "" {
+		t.Fatalf("got tokens %+v, want both an access and a refresh token", tokens)
+	}
+
+	// The access token opens the protected routes
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", tokens.Access, nil, nil); status != fiber.StatusOK {
+		t.Errorf("protected route: got status %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+func TestLoginUserFailures(t *testing.T) {
+	app, _ := newTestApp(t)
+	registerUser(t, app, "alice")
+
+	wrongPassword := map[string]string{"email": emailFor("alice"), "password": "wrong"}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/login", "", wrongPassword, nil); status != fiber.StatusBadRequest {
+		t.Errorf("wrong password: got status %d, want %d", status, fiber.StatusBadRequest)
+	}
+
+	unknownEmail := map[string]string{"email": "nobody@example.com", "password": testPassword}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/login", "", unknownEmail, nil); status != fiber.StatusNotFound {
+		t.Errorf("unknown email: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+}
+
+func TestLoginSuspendedUser(t *testing.T) {
+	app, store := newTestApp(t)
+	registerUser(t, app, "alice")
+
+	alice, err := store.GetUserByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.UpdateUserSuspension(context.Background(), database.UpdateUserSuspensionParams{
+		SuspendedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		UpdatedAt:   time.Now().UTC(),
+		ID:          alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := map[string]string{"email": emailFor("alice"), "password": testPassword}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/login", "", credentials, nil); status != fiber.StatusForbidden {
+		t.Errorf("suspended user: got status %d, want %d", status, fiber.StatusForbidden)
+	}
+
+	// Without the password a suspended account looks like any other
+	wrongPassword := map[string]string{"email": emailFor("alice"), "password": "wrong"}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/login", "", wrongPassword, nil); status != fiber.StatusBadRequest {
+		t.Errorf("suspended user with the wrong password: got status %d, want %d", status, fiber.StatusBadRequest)
+	}
+}
+
+func TestRefreshSuspendedUser(t *testing.T) {
+	app, store := newTestApp(t)
+	registerUser(t, app, "alice")
+	tokens := loginUser(t, app, "alice")
+
+	alice, err := store.GetUserByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only suspend, so the refresh token stays valid and the suspension alone has to stop it
+	_, err = store.UpdateUserSuspension(context.Background(), database.UpdateUserSuspensionParams{
+		SuspendedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		UpdatedAt:   time.Now().UTC(),
+		ID:          alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]string{"refresh_token": tokens.Refresh}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/refresh", "", body, nil); status != fiber.StatusForbidden {
+		t.Errorf("suspended user: got status %d, want %d", status, fiber.StatusForbidden)
+	}
+}
+
+func TestAccessTokenOfSuspendedUser(t *testing.T) {
+	app, store := newTestApp(t)
+	registerUser(t, app, "alice")
+	tokens := loginUser(t, app, "alice")
+
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", tokens.Access, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("before suspension: got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	alice, err := store.GetUserByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.UpdateUserSuspension(context.Background(), database.UpdateUserSuspensionParams{
+		SuspendedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		UpdatedAt:   time.Now().UTC(),
+		ID:          alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The access token has not expired, but the suspension applies to it right away
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", tokens.Access, nil, nil); status != fiber.StatusForbidden {
+		t.Errorf("after suspension: got status %d, want %d", status, fiber.StatusForbidden)
+	}
+
+	if _, err := store.DeleteUser(context.Background(), alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", tokens.Access, nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("after deletion: got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestLoginInvalidatesPreviousRefreshTokens(t *testing.T) {
+	app, _ := newTestApp(t)
+	registerUser(t, app, "alice")
+
+	first := loginUser(t, app, "alice")
+	second := loginUser(t, app, "alice")
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": first.Refresh}, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("refresh with the old token: got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": second.Refresh}, nil); status != fiber.StatusCreated {
+		t.Errorf("refresh with the new token: got status %d, want %d", status, fiber.StatusCreated)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	app, _ := newTestApp(t)
+	registerUser(t, app, "alice")
+	tokens := loginUser(t, app, "alice")
+
+	var refreshed struct {
+		Access string `json:"access"`
+	}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": tokens.Refresh}, &refreshed); status != fiber.StatusCreated {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusCreated)
+	}
+
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", refreshed.Access, nil, nil); status != fiber.StatusOK {
+		t.Errorf("refreshed access token: got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	// Refresh tokens are signed with their own secret, so they do not pass as access tokens
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", tokens.Refresh, nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("refresh token used as an access token: got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": "not-a-token"}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("unknown refresh token: got status %d, want %d", status, fiber.StatusBadRequest)
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	app, _ := newTestApp(t)
+	registerUser(t, app, "alice")
+	tokens := loginUser(t, app, "alice")
+
+	body := map[string]string{"refresh_token": tokens.Refresh}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/logout", "", body, nil); status != fiber.StatusOK {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+	}
+
+	// A logged out refresh token cannot mint new access tokens
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/refresh", "", body, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("refresh after logout: got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+
+	unknown := map[string]string{"refresh_token": "not-a-token"}
+	if status := doRequest(t, app, http.MethodPost, "/v1/auth/logout", "", unknown, nil); status != fiber.StatusNotFound {
+		t.Errorf("unknown refresh token: got status %d, want %d", status, fiber.StatusNotFound)
+	}
+}
+
+func TestProtectedRoutesRequireAccessToken(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", "", nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("no token: got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+
+	if status := doRequest(t, app, http.MethodGet, "/v1/test", "garbage", nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("invalid token: got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestMalformedAuthorizationHeader(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	for _, header := range []string{"Bearer", "Basic dXNlcjpwYXNz", "Bearer a b"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+		req.Header.Set("Authorization", header)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var problem apierr.Problem
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != fiber.StatusUnauthorized || problem.Code != apierr.Unauthenticated {
+			t.Errorf("%q: got status %d and code %q, want %d and %q", header, resp.StatusCode, problem.Code, fiber.StatusUnauthorized, apierr.Unauthenticated)
+		}
+	}
+}
+
+func TestErrorsAreProblems(t *testing.T) {
+	app, _ := newTestApp(t)
+	token := newUser(t, app, "alice")
+
+	var problem apierr.Problem
+	if status := doRequest(t, app, http.MethodGet, "/v1/users/nobody", token, nil, &problem); status != fiber.StatusNotFound {
+		t.Fatalf("got status %d, want %d", status, fiber.StatusNotFound)
+	}
+	if problem.Code != apierr.NotFound || problem.Status != fiber.StatusNotFound || problem.Instance != "/v1/users/nobody" || problem.Detail == "" {
+		t.Errorf("got %+v", problem)
+	}
+}
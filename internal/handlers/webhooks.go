@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/model_converter"
+	"github.com/PlatosRepublic7/ember/internal/webhooks"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// WebhookHandler manages either the requesting user's own webhooks, or (with Global set, behind the
+// admin middleware) the global webhooks that receive every event
+type WebhookHandler struct {
+	DB     database.Querier
+	Global bool
+}
+
+func NewWebhookHandler(db database.Querier, global bool) *WebhookHandler {
+	return &WebhookHandler{DB: db, Global: global}
+}
+
+// The owner that webhooks managed through this handler belong to, NULL for global webhooks
+func (h *WebhookHandler) ownerID(c *fiber.Ctx) (uuid.NullUUID, error) {
+	if h.Global {
+		return uuid.NullUUID{Valid: false}, nil
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+// Look up the webhook named in the path, as long as it is managed through this handler
+func (h *WebhookHandler) getOwnedWebhook(c *fiber.Ctx, ownerID uuid.NullUUID) (database.Webhook, bool) {
+	webhookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return database.Webhook{}, false
+	}
+
+	webhook, err := h.DB.GetWebhook(c.UserContext(), webhookID)
+	if err != nil || webhook.OwnerID != ownerID {
+		return database.Webhook{}, false
+	}
+	return webhook, true
+}
+
+// Check that every event can be subscribed to through this handler
+func (h *WebhookHandler) checkEvents(events []string) error {
+	allowed := webhooks.UserEvents
+	if h.Global {
+		allowed = webhooks.AllEvents
+	}
+
+	for _, event := range events {
+		if !slices.Contains(allowed, event) {
+			return apierr.Invalid(apierr.FieldError{Field: "events", Message: fmt.Sprintf("unsupported event '%s'", event)})
+		}
+	}
+
+	return nil
+}
+
+// Reject webhook URLs on the server's own host or network up front. Hostnames resolving to such
+// addresses are caught when a delivery dials them, see webhooks.NewClient
+func checkWebhookURL(rawURL string) error {
+	if err := webhooks.CheckURL(rawURL); err != nil {
+		return apierr.Invalid(apierr.FieldError{Field: "url", Message: "must not point at a loopback, private or link-local address"})
+	}
+	return nil
+}
+
+// Handler for registering a webhook. The response carries the signing secret, which is never shown again
+func (h *WebhookHandler) HandlerCreateWebhook(c *fiber.Ctx) error {
+	type createWebhookRequest struct {
+		URL    string   `json:"url" validate:"required,url"`
+		Events []string `json:"events" validate:"required"`
+	}
+
+	var req createWebhookRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.checkEvents(req.Events); err != nil {
+		return err
+	}
+
+	if err := checkWebhookURL(req.URL); err != nil {
+		return err
+	}
+
+	ownerID, err := h.ownerID(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	createParams := database.CreateWebhookParams{
+		ID:        uuid.New(),
+		OwnerID:   ownerID,
+		Url:       req.URL,
+		Secret:    secret,
+		Events:    slices.Compact(slices.Sorted(slices.Values(req.Events))),
+		CreatedAt: time.Now().UTC(),
+	}
+	webhook, err := h.DB.CreateWebhook(c.UserContext(), createParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	convertedWebhook := model_converter.DatabaseWebhookToWebhook(webhook)
+	convertedWebhook.Secret = webhook.Secret
+	return c.Status(fiber.StatusCreated).JSON(convertedWebhook)
+}
+
+// Handler for listing webhooks
+func (h *WebhookHandler) HandlerGetWebhooks(c *fiber.Ctx) error {
+	ownerID, err := h.ownerID(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	var dbWebhooks []database.Webhook
+	if ownerID.Valid {
+		dbWebhooks, err = h.DB.GetWebhooksByOwner(c.UserContext(), ownerID)
+	} else {
+		dbWebhooks, err = h.DB.GetGlobalWebhooks(c.UserContext())
+	}
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	convertedWebhooks := make([]model_converter.Webhook, len(dbWebhooks))
+	for i := range dbWebhooks {
+		convertedWebhooks[i] = model_converter.DatabaseWebhookToWebhook(dbWebhooks[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(convertedWebhooks)
+}
+
+// Handler for editing a webhook. Setting enabled back to true re-activates a webhook that was
+// disabled after repeated failures and resets its failure count
+func (h *WebhookHandler) HandlerUpdateWebhook(c *fiber.Ctx) error {
+	type updateWebhookRequest struct {
+		URL     *string   `json:"url" validate:"url"`
+		Events  *[]string `json:"events" validate:"min=1"`
+		Enabled *bool     `json:"enabled"`
+	}
+
+	var req updateWebhookRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ownerID, err := h.ownerID(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	webhook, ok := h.getOwnedWebhook(c, ownerID)
+	if !ok {
+		return apierr.New(apierr.NotFound, "Webhook not found")
+	}
+
+	updateParams := database.UpdateWebhookParams{
+		Url:       webhook.Url,
+		Events:    webhook.Events,
+		Enabled:   webhook.Enabled,
+		UpdatedAt: time.Now().UTC(),
+		ID:        webhook.ID,
+	}
+
+	if req.URL != nil {
+		if err := checkWebhookURL(*req.URL); err != nil {
+			return err
+		}
+		updateParams.Url = *req.URL
+	}
+
+	if req.Events != nil {
+		updateParams.Events = slices.Compact(slices.Sorted(slices.Values(*req.Events)))
+	}
+
+	if req.Enabled != nil {
+		updateParams.Enabled = *req.Enabled
+	}
+
+	if err := h.checkEvents(updateParams.Events); err != nil {
+		return err
+	}
+
+	updatedWebhook, err := h.DB.UpdateWebhook(c.UserContext(), updateParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(model_converter.DatabaseWebhookToWebhook(updatedWebhook))
+}
+
+// Handler for deleting a webhook along with its delivery log
+func (h *WebhookHandler) HandlerDeleteWebhook(c *fiber.Ctx) error {
+	ownerID, err := h.ownerID(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	webhook, ok := h.getOwnedWebhook(c, ownerID)
+	if !ok {
+		return apierr.New(apierr.NotFound, "Webhook not found")
+	}
+
+	if _, err := h.DB.DeleteWebhook(c.UserContext(), webhook.ID); err != nil {
+		return apierr.Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"Success": "Webhook deleted",
+	})
+}
+
+// Handler for the delivery log of a webhook, most recent first. ?limit= defaults to 50 (at most 200)
+func (h *WebhookHandler) HandlerGetWebhookDeliveries(c *fiber.Ctx) error {
+	ownerID, err := h.ownerID(c)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	webhook, ok := h.getOwnedWebhook(c, ownerID)
+	if !ok {
+		return apierr.New(apierr.NotFound, "Webhook not found")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		return apierr.New(apierr.InvalidInput, "limit must be between 1 and 200")
+	}
+
+	deliveriesParams := database.GetWebhookDeliveriesParams{
+		WebhookID: webhook.ID,
+		Limit:     int32(limit),
+	}
+	dbDeliveries, err := h.DB.GetWebhookDeliveries(c.UserContext(), deliveriesParams)
+	if err != nil {
+		return apierr.Wrap(err)
+	}
+
+	deliveries := make([]model_converter.WebhookDelivery, len(dbDeliveries))
+	for i := range dbDeliveries {
+		deliveries[i] = model_converter.DatabaseDeliveryToDelivery(dbDeliveries[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(deliveries)
+}
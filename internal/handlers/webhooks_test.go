@@ -0,0 +1,32 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreateWebhookRejectsLocalAddresses(t *testing.T) {
+	app, _ := newTestApp(t)
+	registerUser(t, app, "alice")
+	tokens := loginUser(t, app, "alice")
+
+	for _, url := range []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+	} {
+		body := map[string]any{"url": url, "events": []string{"message.created"}}
+		if status := doRequest(t, app, http.MethodPost, "/v1/webhooks", tokens.Access, body, nil); status != fiber.StatusUnprocessableEntity {
+			t.Errorf("%s: got status %d, want %d", url, status, fiber.StatusUnprocessableEntity)
+		}
+	}
+
+	body := map[string]any{"url": "https://example.com/hook", "events": []string{"message.created"}}
+	if status := doRequest(t, app, http.MethodPost, "/v1/webhooks", tokens.Access, body, nil); status != fiber.StatusCreated {
+		t.Errorf("public URL: got status %d, want %d", status, fiber.StatusCreated)
+	}
+}
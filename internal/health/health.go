@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/lifecycle"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining"
+)
+
+// Check reports whether one dependency is usable, returning nil if it is
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of every check, Status is only ok if every check passed and the server is not
+// draining
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks. Once Drain is called it reports draining regardless of the
+// checks, so load balancers stop sending traffic before the server stops accepting it
+type Checker struct {
+	// How long a single check may take before it counts as failing
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{Timeout: 2 * time.Second}
+}
+
+// Add registers a check under name
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain makes every following report fail with StatusDraining
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Run runs every check concurrently and collects the results
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, named := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := c.run(ctx, named.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[named.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFailing
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.Draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// HeartbeatCheck fails when the worker behind heartbeat has not completed a successful pass within
+// maxAge, which should be a few of its intervals so a single slow pass does not flip readiness
+func HeartbeatCheck(heartbeat *lifecycle.Heartbeat, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		last := heartbeat.Last()
+		if last.IsZero() {
+			return fmt.Errorf("no successful pass yet")
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("last successful pass was %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// WaitForDB pings the database until it answers, doubling the pause between attempts up to ten
+// seconds. sql.Open does not connect, so without this a database that is down only shows up on the
+// first request. It gives up with the last ping error when ctx is done
+func WaitForDB(ctx context.Context, conn *sql.DB) error {
+	backoff := 500 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := conn.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		slog.WarnContext(ctx, "database is not reachable, retrying", "attempt", attempt, "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database is not reachable: %w", err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, 10*time.Second)
+	}
+}
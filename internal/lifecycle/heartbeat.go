@@ -0,0 +1,27 @@
+package lifecycle
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat records when a background worker last completed a pass without errors. Readiness checks
+// compare it against the worker's interval to spot a worker that is stuck or keeps failing. The zero
+// value is ready to use
+type Heartbeat struct {
+	last atomic.Int64
+}
+
+// Beat marks a successful pass as of now
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Last returns the time of the last successful pass, or the zero time if there has been none
+func (h *Heartbeat) Last() time.Time {
+	last := h.last.Load()
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
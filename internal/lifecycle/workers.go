@@ -0,0 +1,60 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Runner is a background worker that runs until its context is cancelled
+type Runner interface {
+	Run(ctx context.Context)
+}
+
+// Workers runs background workers in their own goroutines and stops them one at a time, in the order
+// they were started, so a worker producing work can be stopped before the one consuming it
+type Workers struct {
+	workers []*worker
+}
+
+type worker struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewWorkers() *Workers {
+	return &Workers{}
+}
+
+// Start runs runner until Stop is called
+func (w *Workers) Start(name string, runner Runner) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := &worker{name: name, cancel: cancel, done: make(chan struct{})}
+	w.workers = append(w.workers, started)
+
+	go func() {
+		defer close(started.done)
+		runner.Run(ctx)
+	}()
+}
+
+// Stop cancels each worker and waits for it to return before stopping the next. If ctx is done first
+// the remaining workers are cancelled without waiting and an error names the one that did not finish
+func (w *Workers) Stop(ctx context.Context) error {
+	for i, current := range w.workers {
+		current.cancel()
+
+		select {
+		case <-current.done:
+			slog.Info("stopped worker", "worker", current.name)
+		case <-ctx.Done():
+			for _, remaining := range w.workers[i+1:] {
+				remaining.cancel()
+			}
+			return fmt.Errorf("%s did not stop in time: %w", current.name, ctx.Err())
+		}
+	}
+
+	return nil
+}
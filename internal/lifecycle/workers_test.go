@@ -0,0 +1,66 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/lifecycle"
+)
+
+type runnerFunc func(ctx context.Context)
+
+func (f runnerFunc) Run(ctx context.Context) { f(ctx) }
+
+func TestWorkersStopInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var stopped []string
+
+	workers := lifecycle.NewWorkers()
+	for _, name := range []string{"first", "second", "third"} {
+		workers.Start(name, runnerFunc(func(ctx context.Context) {
+			<-ctx.Done()
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+		}))
+	}
+
+	if err := workers.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stopped) != 3 || stopped[0] != "first" || stopped[1] != "second" || stopped[2] != "third" {
+		t.Errorf("got stop order %v", stopped)
+	}
+}
+
+func TestWorkersStopTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	lastCancelled := make(chan struct{})
+
+	workers := lifecycle.NewWorkers()
+	workers.Start("stuck", runnerFunc(func(ctx context.Context) { <-release }))
+	workers.Start("last", runnerFunc(func(ctx context.Context) {
+		<-ctx.Done()
+		close(lastCancelled)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := workers.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// Workers after the stuck one are still cancelled
+	select {
+	case <-lastCancelled:
+	case <-time.After(time.Second):
+		t.Error("the last worker was not cancelled")
+	}
+}
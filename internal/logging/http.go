@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The header a request ID is read from and echoed back in
+const RequestIDHeader = fiber.HeaderXRequestID
+
+// Middleware gives every request an ID, taken from the X-Request-ID header when the caller sent a
+// usable one, and returns it in the response. The ID is added to the request's user context so
+// anything logged with c.UserContext() carries it, and one line is logged per finished request
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		requestID := c.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Set(RequestIDHeader, requestID)
+
+		ctx := c.UserContext()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+		c.SetUserContext(With(ctx, slog.String("request_id", requestID)))
+
+		err := c.Next()
+
+		// An error returned here is turned into a response by the app's error handler later on, so work
+		// out the status it will get
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = apierr.Status(err)
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		// The path is logged without its query string, which can carry search terms
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("route", c.Route().Path),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("ip", c.IP()),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+
+		// The user context picks up the user ID once the request has been authenticated
+		slog.LogAttrs(c.UserContext(), level, "request", attrs...)
+
+		return err
+	}
+}
+
+// Caller supplied IDs end up in logs and headers, so only short, plain ones are accepted
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Route the default logger into a buffer for the rest of the test
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(New(&buf, config.Log{Level: "info", Format: "json"}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return &buf
+}
+
+// Decode every line logged so far
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("%v: %s", err, line)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestRedaction(t *testing.T) {
+	buf := capture(t)
+
+	slog.Info("login",
+		"username", "alice",
+		"password", "hunter2",
+		slog.Group("body", "refresh_token", "abc", "content", "hello"),
+		"webhook_secret", "shh",
+	)
+
+	for _, secret := range []string{"hunter2", "abc", "hello", "shh"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("log leaks %q: %s", secret, buf)
+		}
+	}
+	if !strings.Contains(buf.String(), "alice") {
+		t.Errorf("log lost an unrelated field: %s", buf)
+	}
+}
+
+func TestWithAddsContextAttributes(t *testing.T) {
+	buf := capture(t)
+
+	ctx := With(context.Background(), slog.String("request_id", "r1"))
+	ctx = With(ctx, slog.String("user_id", "u1"))
+	slog.InfoContext(ctx, "hello")
+
+	record := records(t, buf)[0]
+	if record["request_id"] != "r1" || record["user_id"] != "u1" {
+		t.Errorf("got %v", record)
+	}
+}
+
+func TestLevelFromConfig(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, config.Log{Level: "warn", Format: "text"})
+
+	logger.Info("quiet")
+	logger.Warn("loud")
+
+	if strings.Contains(buf.String(), "quiet") || !strings.Contains(buf.String(), "loud") {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	buf := capture(t)
+
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/v1/users/:username", func(c *fiber.Ctx) error {
+		slog.InfoContext(c.UserContext(), "in handler")
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"from caller", "abc-123", true},
+		{"unsafe from caller", "abc\" injected=1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/alice?q=secret", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			id := resp.Header.Get(RequestIDHeader)
+			if id == "" || (id == tt.incoming) != tt.keep {
+				t.Fatalf("got request ID %q for incoming %q", id, tt.incoming)
+			}
+
+			logged := records(t, buf)
+			if len(logged) != 2 {
+				t.Fatalf("got %d records, want the handler's and the request's", len(logged))
+			}
+			for _, record := range logged {
+				if record["request_id"] != id {
+					t.Errorf("record %v does not carry the request ID", record)
+				}
+			}
+			if request := logged[1]; request["route"] != "/v1/users/:username" || request["status"] != float64(fiber.StatusNoContent) || request["path"] != "/v1/users/alice" {
+				t.Errorf("got request record %v", request)
+			}
+		})
+	}
+}
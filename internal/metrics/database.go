@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentDB times every query run through db, labelled with its sqlc method. It is a
+// database.Instrument, see database.NewStore
+func InstrumentDB(db database.DBTX) database.DBTX {
+	return &instrumentedDB{next: db}
+}
+
+type instrumentedDB struct {
+	next database.DBTX
+}
+
+func (db *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observe(query, time.Now())
+	result, err := db.next.ExecContext(ctx, query, args...)
+	countError(query, err)
+	return result, err
+}
+
+func (db *instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.next.PrepareContext(ctx, query)
+}
+
+func (db *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observe(query, time.Now())
+	rows, err := db.next.QueryContext(ctx, query, args...)
+	countError(query, err)
+	return rows, err
+}
+
+// The row is not scanned yet, so this covers running the query but not reading the result. Errors
+// only surface on Scan and are not counted
+func (db *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observe(query, time.Now())
+	return db.next.QueryRowContext(ctx, query, args...)
+}
+
+func observe(query string, start time.Time) {
+	dbQueryDuration.WithLabelValues(database.QueryName(query)).Observe(time.Since(start).Seconds())
+}
+
+func countError(query string, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		dbQueryErrors.WithLabelValues(database.QueryName(query)).Inc()
+	}
+}
+
+// RegisterActiveSessions exports the number of logged in sessions, counted from the refresh tokens that
+// are still valid and younger than refreshTokenTTL, at scrape time
+func RegisterActiveSessions(db database.Querier, refreshTokenTTL time.Duration) {
+	Registry.MustRegister(&sessionsCollector{db: db, ttl: refreshTokenTTL})
+}
+
+var activeSessionsDesc = prometheus.NewDesc("ember_active_sessions", "Sessions with a valid, unexpired refresh token.", nil, nil)
+
+type sessionsCollector struct {
+	db  database.Querier
+	ttl time.Duration
+}
+
+func (c *sessionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSessionsDesc
+}
+
+func (c *sessionsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	count, err := c.db.CountActiveRefreshTokens(ctx, time.Now().UTC().Add(-c.ttl))
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(activeSessionsDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(activeSessionsDesc, prometheus.GaugeValue, float64(count))
+}
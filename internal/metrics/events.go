@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/PlatosRepublic7/ember/internal/events"
+)
+
+// SubscribeEvents counts messages as they are created, read and expired. The counters only move
+// once the change has committed, because the bus only publishes committed events
+func SubscribeEvents(bus *events.Bus) {
+	bus.Subscribe(events.TypeMessageCreated, func(ctx context.Context, event events.Event) {
+		messagesCreated.Inc()
+	})
+	bus.Subscribe(events.TypeMessageRead, func(ctx context.Context, event events.Event) {
+		if read, ok := event.(events.MessageRead); ok {
+			messagesRead.Add(float64(len(read.MessageIDs)))
+		}
+	})
+	bus.Subscribe(events.TypeMessageExpired, func(ctx context.Context, event events.Event) {
+		messagesExpired.Inc()
+	})
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry holds every ember metric plus the Go runtime and process collectors. It is separate from
+// prometheus.DefaultRegisterer so libraries cannot add metrics to /metrics behind our back
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ember_http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ember_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ember_db_query_duration_seconds",
+		Help:    "Database query latency by sqlc method.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query"})
+
+	dbQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ember_db_query_errors_total",
+		Help: "Database queries that returned an error, by sqlc method. sql.ErrNoRows is not counted.",
+	}, []string{"query"})
+
+	messagesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ember_messages_created_total",
+		Help: "Messages made visible to their recipient, scheduled ones count when they are delivered.",
+	})
+
+	messagesRead = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ember_messages_read_total",
+		Help: "Messages marked as read.",
+	})
+
+	messagesExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ember_messages_expired_total",
+		Help: "Messages removed by the reaper because their TTL ran out.",
+	})
+
+	// LoginAttempts counts logins by result: success, unknown_user, wrong_password or suspended
+	LoginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ember_login_attempts_total",
+		Help: "Login attempts by result.",
+	}, []string{"result"})
+)
+
+const (
+	LoginSuccess       = "success"
+	LoginUnknownUser   = "unknown_user"
+	LoginWrongPassword = "wrong_password"
+	LoginSuspended     = "suspended"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbQueryDuration,
+		dbQueryErrors,
+		messagesCreated,
+		messagesRead,
+		messagesExpired,
+		LoginAttempts,
+	)
+
+	// Start every result at zero so rate() works from the first failure on
+	for _, result := range []string{LoginSuccess, LoginUnknownUser, LoginWrongPassword, LoginSuspended} {
+		LoginAttempts.WithLabelValues(result)
+	}
+}
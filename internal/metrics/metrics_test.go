@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsRouteTemplate(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/v1/users/:username", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	for _, username := range []string{"alice", "bob"} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/v1/users/"+username, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// Both requests share one series instead of one per username
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/v1/users/:username", "204")); got != 2 {
+		t.Errorf("got %v requests, want 2", got)
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	bus := events.NewBus()
+	SubscribeEvents(bus)
+
+	before := testutil.ToFloat64(messagesRead)
+	bus.Publish(context.Background(), events.MessageRead{MessageIDs: []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}})
+
+	if got := testutil.ToFloat64(messagesRead) - before; got != 3 {
+		t.Errorf("got %v messages read, want 3", got)
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminMiddleware only lets administrators through, it must be registered after JWTAuthMiddleware.
+// The flag is read from the database on every request so revoking it takes effect immediately
+func AdminMiddleware(db database.Querier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := auth.GetUserIDFromToken(c)
+		if err != nil {
+			return apierr.New(apierr.InvalidToken, "Invalid token claims")
+		}
+
+		isAdmin, err := db.IsUserAdmin(c.UserContext(), userID)
+		if err != nil || !isAdmin {
+			return apierr.New(apierr.Forbidden, "Administrator access required")
+		}
+
+		return c.Next()
+	}
+}
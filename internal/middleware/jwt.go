@@ -1,60 +1,66 @@
 package middleware
 
 import (
+	"database/sql"
 	"errors"
-	"fmt"
-	"os"
+	"log/slog"
 	"strings"
 
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/logging"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-var jwtAccessSecret = []byte(os.Getenv("ACCESS_SECRET_KEY"))
+// JWTAuthMiddleware validates the access token and turns away suspended and deleted users
+func JWTAuthMiddleware(authenticator *auth.Authenticator, db database.Querier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Get the token from the Authorization Header
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return apierr.New(apierr.Unauthenticated, "Missing Access Token")
+		}
 
-// JWTAuthMiddleware validates the access token
-func JWTAuthMiddleware(c *fiber.Ctx) error {
-	// Get the token from the Authorization Header
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"Error": "Missing Access Token",
-		})
-	}
+		// Identify and remove the "Bearer" prefix
+		authVals := strings.Split(authHeader, " ")
+		if len(authVals) != 2 || authVals[1] == "" {
+			return apierr.New(apierr.Unauthenticated, "Malformed Authorization header")
+		}
 
-	// Identify and remove the "Bearer" prefix
-	authVals := strings.Split(authHeader, " ")
-	if len(authVals) < 2 {
-		return errors.New("malformed authorization header")
-	}
+		if authVals[0] != "Bearer" {
+			return apierr.New(apierr.Unauthenticated, "Authorization header must use the Bearer scheme")
+		}
 
-	if authVals[0] != "Bearer" {
-		return errors.New("incorrect authorization content, expected 'Bearer'")
-	}
+		tokenString := authVals[1]
 
-	tokenString := authVals[1]
+		// Parse and validate the token, then add its claims to the context
+		claims, err := authenticator.ParseAccessToken(tokenString)
+		if err != nil {
+			return apierr.New(apierr.InvalidToken, "Invalid or expired access token")
+		}
 
-	// Parse and validate the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		c.Locals("user", claims)
+
+		// Access tokens outlive a suspension or a deleted account by up to their TTL, so the account is
+		// looked up on every request for either to take effect immediately
+		userID, err := auth.GetUserIDFromToken(c)
+		if err != nil {
+			return apierr.New(apierr.InvalidToken, "Invalid token claims")
+		}
+		suspended, err := db.IsUserSuspended(c.UserContext(), userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apierr.New(apierr.InvalidToken, "Invalid or expired access token")
+		} else if err != nil {
+			return apierr.Wrap(err)
+		}
+		if suspended {
+			return apierr.New(apierr.Forbidden, "Account is suspended")
 		}
-		return jwtAccessSecret, nil
-	})
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"Error": "Invalid or expired access token",
-		})
-	}
 
-	// Extract the claims and add them to the context
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"Error": "Invalid token claims",
-		})
-	}
+		// Everything logged for the rest of the request names the user
+		c.SetUserContext(logging.With(c.UserContext(), slog.String("user_id", userID.String())))
 
-	c.Locals("user", claims)
-	return c.Next()
+		return c.Next()
+	}
 }
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/presence"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PresenceMiddleware records every authenticated request as activity for the presence tracker,
+// it must be registered after JWTAuthMiddleware
+func PresenceMiddleware(tracker *presence.Tracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if userID, err := auth.GetUserIDFromToken(c); err == nil {
+			tracker.Touch(c.UserContext(), userID)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/PlatosRepublic7/ember/internal/sql/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// ErrSchemaBehind is returned by Check when the database is missing migrations this binary was built with
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// NewProvider returns a goose provider for the embedded migrations. Migrating takes a Postgres
+// advisory lock for the duration, so instances started at the same time apply them one after another
+// instead of racing each other
+func NewProvider(conn *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(goose.DialectPostgres, conn, schema.FS, goose.WithSessionLocker(locker))
+}
+
+// Redo rolls back the most recently applied migration and applies it again
+func Redo(ctx context.Context, provider *goose.Provider) ([]*goose.MigrationResult, error) {
+	down, err := provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	up, err := provider.UpByOne(ctx)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Check returns ErrSchemaBehind if any embedded migration has not been applied. A database that is
+// ahead of the binary is fine, so an older instance keeps serving while a newer one rolls out
+func Check(ctx context.Context, provider *goose.Provider) error {
+	pending, err := provider.HasPending(ctx)
+	if err != nil {
+		return fmt.Errorf("checking schema version: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+
+	current, target, err := provider.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("checking schema version: %w", err)
+	}
+
+	return fmt.Errorf("%w: database is at version %d, this build needs %d (run `ember migrate up`)", ErrSchemaBehind, current, target)
+}
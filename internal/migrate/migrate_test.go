@@ -0,0 +1,141 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/database/dbtest"
+	"github.com/PlatosRepublic7/ember/internal/migrate"
+	"github.com/pressly/goose/v3"
+)
+
+var ctx = context.Background()
+
+func newProvider(t *testing.T, conn *sql.DB) *goose.Provider {
+	t.Helper()
+
+	provider, err := migrate.NewProvider(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func latestVersion(provider *goose.Provider) int64 {
+	sources := provider.ListSources()
+	return sources[len(sources)-1].Version
+}
+
+// Count the migrations in each state
+func countStates(t *testing.T, provider *goose.Provider) map[goose.State]int {
+	t.Helper()
+
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	counts := make(map[goose.State]int)
+	for _, status := range statuses {
+		counts[status.State]++
+	}
+	return counts
+}
+
+func TestUpDownStatus(t *testing.T) {
+	provider := newProvider(t, dbtest.NewEmpty(t))
+	total := len(provider.ListSources())
+
+	if counts := countStates(t, provider); counts[goose.StatePending] != total {
+		t.Errorf("before migrating: got states %v, want all %d pending", counts, total)
+	}
+	if err := migrate.Check(ctx, provider); !errors.Is(err, migrate.ErrSchemaBehind) {
+		t.Errorf("Check before migrating: got %v, want %v", err, migrate.ErrSchemaBehind)
+	}
+
+	results, err := provider.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(results) != total {
+		t.Errorf("Up applied %d migrations, want %d", len(results), total)
+	}
+	if counts := countStates(t, provider); counts[goose.StateApplied] != total {
+		t.Errorf("after Up: got states %v, want all %d applied", counts, total)
+	}
+	if err := migrate.Check(ctx, provider); err != nil {
+		t.Errorf("Check after Up: %v", err)
+	}
+
+	result, err := provider.Down(ctx)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if result.Source.Version != latestVersion(provider) {
+		t.Errorf("Down rolled back version %d, want %d", result.Source.Version, latestVersion(provider))
+	}
+	if counts := countStates(t, provider); counts[goose.StatePending] != 1 {
+		t.Errorf("after Down: got states %v, want one pending", counts)
+	}
+	if err := migrate.Check(ctx, provider); !errors.Is(err, migrate.ErrSchemaBehind) {
+		t.Errorf("Check after Down: got %v, want %v", err, migrate.ErrSchemaBehind)
+	}
+
+	// Every down migration undoes its up migration, so the whole history can be replayed
+	if _, err := provider.DownTo(ctx, 0); err != nil {
+		t.Fatalf("rolling everything back: %v", err)
+	}
+	if _, err := provider.Up(ctx); err != nil {
+		t.Fatalf("migrating again: %v", err)
+	}
+	if err := migrate.Check(ctx, provider); err != nil {
+		t.Errorf("Check after replaying: %v", err)
+	}
+}
+
+func TestRedo(t *testing.T) {
+	provider := newProvider(t, dbtest.NewEmpty(t))
+	if _, err := provider.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	results, err := migrate.Redo(ctx, provider)
+	if err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want a down and an up", len(results))
+	}
+	latest := latestVersion(provider)
+	if results[0].Direction != "down" || results[0].Source.Version != latest {
+		t.Errorf("got first result %v, want version %d rolled back", results[0], latest)
+	}
+	if results[1].Direction != "up" || results[1].Source.Version != latest {
+		t.Errorf("got second result %v, want version %d applied", results[1], latest)
+	}
+
+	if version, err := provider.GetDBVersion(ctx); err != nil || version != latest {
+		t.Errorf("got database version %d (%v), want %d", version, err, latest)
+	}
+	if err := migrate.Check(ctx, provider); err != nil {
+		t.Errorf("Check after Redo: %v", err)
+	}
+}
+
+func TestCheckDatabaseAhead(t *testing.T) {
+	conn := dbtest.NewEmpty(t)
+	provider := newProvider(t, conn)
+	if _, err := provider.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// A newer binary has applied a migration this one does not know about
+	if _, err := conn.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)", latestVersion(provider)+1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate.Check(ctx, provider); err != nil {
+		t.Errorf("got %v, want a database ahead of the binary to pass", err)
+	}
+}
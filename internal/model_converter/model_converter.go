@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/presence"
 	"github.com/google/uuid"
 )
 
@@ -54,6 +55,8 @@ type Message struct {
 	TtlSeconds  sql.NullInt32 `json:"ttl_seconds"`
 	ExpiresAt   sql.NullTime  `json:"expires_at"`
 	Deleted     bool          `json:"deleted"`
+	DeliverAt   sql.NullTime  `json:"deliver_at"`
+	DeliveredAt sql.NullTime  `json:"delivered_at"`
 }
 
 func DatabaseMessageToMessage(dbMessage database.Message) Message {
@@ -67,5 +70,224 @@ func DatabaseMessageToMessage(dbMessage database.Message) Message {
 		TtlSeconds:  dbMessage.TtlSeconds,
 		ExpiresAt:   dbMessage.ExpiresAt,
 		Deleted:     dbMessage.Deleted,
+		DeliverAt:   dbMessage.DeliverAt,
+		DeliveredAt: dbMessage.DeliveredAt,
+	}
+}
+
+type MessageSearchResult struct {
+	Message Message `json:"message"`
+	// HTML-escaped excerpt of the content with the matching words wrapped in <mark>
+	Snippet string `json:"snippet"`
+}
+
+func DatabaseSearchRowToSearchResult(dbRow database.SearchMessagesRow) MessageSearchResult {
+	return MessageSearchResult{
+		Message: DatabaseMessageToMessage(dbRow.Message),
+		Snippet: dbRow.Snippet,
+	}
+}
+
+type Conversation struct {
+	CounterpartID       uuid.UUID `json:"counterpart_id"`
+	CounterpartUsername string    `json:"counterpart_username"`
+	LastMessageID       uuid.UUID `json:"last_message_id"`
+	LastMessageSenderID uuid.UUID `json:"last_message_sender_id"`
+	LastMessagePreview  string    `json:"last_message_preview"`
+	LastActivityAt      time.Time `json:"last_activity_at"`
+	UnreadCount         int64     `json:"unread_count"`
+}
+
+// Length (in runes) of the latest message preview returned in conversation summaries
+const conversationPreviewLength = 100
+
+func DatabaseConversationToConversation(dbRow database.GetConversationSummariesRow) Conversation {
+	preview := []rune(dbRow.LastMessageContent)
+	if len(preview) > conversationPreviewLength {
+		preview = preview[:conversationPreviewLength]
+	}
+
+	return Conversation{
+		CounterpartID:       dbRow.CounterpartID,
+		CounterpartUsername: dbRow.CounterpartUsername,
+		LastMessageID:       dbRow.LastMessageID,
+		LastMessageSenderID: dbRow.LastMessageSenderID,
+		LastMessagePreview:  string(preview),
+		LastActivityAt:      dbRow.LastActivityAt,
+		UnreadCount:         dbRow.UnreadCount,
+	}
+}
+
+type Contact struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// The contact list and request queries all share the GetContactsRow shape, so callers convert their rows
+// to database.GetContactsRow before calling this
+func DatabaseContactToContact(dbContact database.GetContactsRow) Contact {
+	return Contact{
+		UserID:    dbContact.ID,
+		Username:  dbContact.Username,
+		Status:    dbContact.Status,
+		CreatedAt: dbContact.CreatedAt,
+		UpdatedAt: dbContact.UpdatedAt,
+	}
+}
+
+type BlockedUser struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func DatabaseBlockedUserToBlockedUser(dbBlock database.GetBlockedUsersRow) BlockedUser {
+	return BlockedUser{
+		UserID:    dbBlock.ID,
+		Username:  dbBlock.Username,
+		CreatedAt: dbBlock.CreatedAt,
+	}
+}
+
+// PublicProfile is what other users get to see about a user. It must never carry the email address
+type PublicProfile struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	StatusText  string    `json:"status_text"`
+	Timezone    string    `json:"timezone"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func DatabaseUserToPublicProfile(dbUser database.User) PublicProfile {
+	return PublicProfile{
+		ID:          dbUser.ID,
+		Username:    dbUser.Username,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		AvatarURL:   avatarURL(dbUser),
+		StatusText:  dbUser.StatusText,
+		Timezone:    dbUser.Timezone,
+		CreatedAt:   dbUser.CreatedAt,
+	}
+}
+
+// Profile is the requesting user's own profile, including their private settings
+type Profile struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	DisplayName  string    `json:"display_name"`
+	Bio          string    `json:"bio"`
+	AvatarURL    string    `json:"avatar_url"`
+	StatusText   string    `json:"status_text"`
+	Timezone     string    `json:"timezone"`
+	ContactsOnly bool      `json:"contacts_only"`
+	HideLastSeen bool      `json:"hide_last_seen"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func DatabaseUserToProfile(dbUser database.User) Profile {
+	return Profile{
+		ID:           dbUser.ID,
+		Username:     dbUser.Username,
+		Email:        dbUser.Email,
+		DisplayName:  dbUser.DisplayName,
+		Bio:          dbUser.Bio,
+		AvatarURL:    avatarURL(dbUser),
+		StatusText:   dbUser.StatusText,
+		Timezone:     dbUser.Timezone,
+		ContactsOnly: dbUser.ContactsOnly,
+		HideLastSeen: dbUser.HideLastSeen,
+		CreatedAt:    dbUser.CreatedAt,
+		UpdatedAt:    dbUser.UpdatedAt,
+	}
+}
+
+// Avatars are served by the API rather than straight from the blob store
+func avatarURL(dbUser database.User) string {
+	if dbUser.AvatarKey == "" {
+		return ""
+	}
+	return "/v1/users/" + dbUser.Username + "/avatar"
+}
+
+type Presence struct {
+	UserID   uuid.UUID    `json:"user_id"`
+	Username string       `json:"username"`
+	Status   string       `json:"status"`
+	LastSeen sql.NullTime `json:"last_seen"`
+}
+
+// The last-seen time is left out for users who chose to hide it, and for users never seen at all
+func PresenceToPresence(p presence.Presence, username string, hideLastSeen bool) Presence {
+	return Presence{
+		UserID:   p.UserID,
+		Username: username,
+		Status:   string(p.Status),
+		LastSeen: sql.NullTime{
+			Time:  p.LastSeen,
+			Valid: !hideLastSeen && !p.LastSeen.IsZero(),
+		},
+	}
+}
+
+// The signing secret is only filled in by the handler that creates the webhook, it is never listed again
+type Webhook struct {
+	ID                  uuid.UUID    `json:"id"`
+	URL                 string       `json:"url"`
+	Events              []string     `json:"events"`
+	Enabled             bool         `json:"enabled"`
+	ConsecutiveFailures int32        `json:"consecutive_failures"`
+	DisabledAt          sql.NullTime `json:"disabled_at"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+	Secret              string       `json:"secret,omitempty"`
+}
+
+func DatabaseWebhookToWebhook(dbWebhook database.Webhook) Webhook {
+	return Webhook{
+		ID:                  dbWebhook.ID,
+		URL:                 dbWebhook.Url,
+		Events:              dbWebhook.Events,
+		Enabled:             dbWebhook.Enabled,
+		ConsecutiveFailures: dbWebhook.ConsecutiveFailures,
+		DisabledAt:          dbWebhook.DisabledAt,
+		CreatedAt:           dbWebhook.CreatedAt,
+		UpdatedAt:           dbWebhook.UpdatedAt,
+	}
+}
+
+type WebhookDelivery struct {
+	ID             int64          `json:"id"`
+	EventID        int64          `json:"event_id"`
+	EventType      string         `json:"event_type"`
+	Status         string         `json:"status"`
+	Attempts       int32          `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt32  `json:"last_status_code"`
+	LastError      sql.NullString `json:"last_error"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+func DatabaseDeliveryToDelivery(dbDelivery database.WebhookDelivery) WebhookDelivery {
+	return WebhookDelivery{
+		ID:             dbDelivery.ID,
+		EventID:        dbDelivery.EventID,
+		EventType:      dbDelivery.EventType,
+		Status:         dbDelivery.Status,
+		Attempts:       dbDelivery.Attempts,
+		NextAttemptAt:  dbDelivery.NextAttemptAt,
+		LastStatusCode: dbDelivery.LastStatusCode,
+		LastError:      dbDelivery.LastError,
+		CreatedAt:      dbDelivery.CreatedAt,
+		UpdatedAt:      dbDelivery.UpdatedAt,
 	}
 }
@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// The document never changes while the server runs, so it is encoded once
+var encoded = sync.OnceValue(func() []byte {
+	data, err := json.Marshal(Build())
+	if err != nil {
+		panic("openapi: cannot encode document: " + err.Error())
+	}
+	return data
+})
+
+// Handler for serving the OpenAPI document
+func Handler(c *fiber.Ctx) error {
+	c.Type("json")
+	return c.Status(fiber.StatusOK).Send(encoded())
+}
+
+// Replaces the Swagger UI default, which points at the petstore example
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// DocsHandler serves the bundled Swagger UI. Mount it with app.Use, under the prefix it should answer on
+func DocsHandler() fiber.Handler {
+	files := filesystem.New(filesystem.Config{
+		Root:  http.FS(swaggerFiles.FS),
+		Index: "index.html",
+	})
+
+	return func(c *fiber.Ctx) error {
+		switch strings.TrimPrefix(c.Path(), c.Route().Path) {
+		case "":
+			// Relative asset links in index.html only resolve under the trailing slash
+			return c.Redirect(c.Path()+"/", fiber.StatusMovedPermanently)
+		case "/swagger-initializer.js":
+			c.Type("js")
+			return c.Status(fiber.StatusOK).SendString(swaggerInitializer)
+		}
+		return files(c)
+	}
+}
@@ -0,0 +1,186 @@
+// Package openapi describes the HTTP API as an OpenAPI 3.1 document, served at /openapi.json together
+// with a Swagger UI at /docs
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+)
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to the operation served for them
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+const bearerAuth = "bearerAuth"
+
+// Build assembles the document from the route table
+func Build() *Document {
+	r := newReflector()
+	problem := r.Ref(apierr.Problem{})
+
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info: Info{
+			Title:       "Ember",
+			Version:     "1.0.0",
+			Description: "Ephemeral messaging API. Errors are RFC 9457 problem details, validation failures list every invalid field.",
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			Schemas: r.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, rt := range routes {
+		op := &Operation{
+			OperationID: rt.id,
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Parameters:  pathParameters(rt.path),
+			Responses:   make(map[string]Response),
+			Security:    []map[string][]string{},
+		}
+		if !rt.public {
+			op.Security = []map[string][]string{{bearerAuth: {}}}
+		}
+		if !slices.ContainsFunc(doc.Tags, func(t Tag) bool { return t.Name == rt.tag }) {
+			doc.Tags = append(doc.Tags, Tag{Name: rt.tag})
+		}
+
+		op.Parameters = append(op.Parameters, rt.query...)
+
+		switch {
+		case rt.multipart != "":
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{"multipart/form-data": {Schema: &Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{rt.multipart: {Type: "string", Format: "binary"}},
+					Required:   []string{rt.multipart},
+				}}},
+			}
+		case rt.body != nil:
+			op.RequestBody = &RequestBody{
+				Required: !rt.optionalBody,
+				Content:  map[string]MediaType{"application/json": {Schema: r.Ref(rt.body)}},
+			}
+		}
+
+		success := Response{Description: http.StatusText(rt.status)}
+		switch {
+		case rt.contentType != "":
+			success.Content = map[string]MediaType{rt.contentType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		case rt.response != nil:
+			success.Content = map[string]MediaType{"application/json": {Schema: r.Ref(rt.response)}}
+		}
+		op.Responses[fmt.Sprint(rt.status)] = success
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{apierr.ProblemContentType: {Schema: problem}},
+		}
+
+		path := openAPIPath(rt.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = op
+	}
+
+	return doc
+}
+
+// Turn a Fiber route path such as /webhooks/:id into the OpenAPI form /webhooks/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParameters(path string) []Parameter {
+	var parameters []Parameter
+	for _, segment := range strings.Split(path, "/") {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+
+		schema := &Schema{Type: "string"}
+		if name == "id" {
+			schema.Format = "uuid"
+		}
+		parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return parameters
+}
@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/PlatosRepublic7/ember/internal/validate"
+)
+
+func TestValidateRulesBecomeConstraints(t *testing.T) {
+	schemas := Build().Components.Schemas
+
+	register := schemas["RegisterRequest"]
+	if !slices.Equal(register.Required, []string{"username", "email", "password"}) {
+		t.Errorf("unexpected required fields %v", register.Required)
+	}
+
+	username := register.Properties["username"]
+	if *username.MinLength != validate.MinUsernameLength || *username.MaxLength != validate.MaxUsernameLength || username.Pattern == "" {
+		t.Errorf("username is not constrained: %+v", username)
+	}
+	if register.Properties["email"].Format != "email" {
+		t.Errorf("email has no email format")
+	}
+
+	update := schemas["UpdateScheduledMessageRequest"]
+	if len(update.Required) != 0 {
+		t.Errorf("optional fields are required: %v", update.Required)
+	}
+	if ttl := update.Properties["ttl_seconds"]; *ttl.Minimum != validate.MinTTLSeconds || *ttl.Maximum != validate.MaxTTLSeconds {
+		t.Errorf("ttl_seconds is not bounded: %+v", ttl)
+	}
+
+	if events := schemas["UpdateWebhookRequest"].Properties["events"]; events.MinItems == nil || *events.MinItems != 1 {
+		t.Errorf("events has no minItems: %+v", events)
+	}
+}
+
+func TestPathsUseOpenAPITemplates(t *testing.T) {
+	if got := openAPIPath("/v1/contacts/requests/:username/accept"); got != "/v1/contacts/requests/{username}/accept" {
+		t.Errorf("unexpected path %s", got)
+	}
+
+	doc := Build()
+	op := doc.Paths["/v1/webhooks/{id}"]["patch"]
+	if op == nil || len(op.Parameters) != 1 || op.Parameters[0].Schema.Format != "uuid" {
+		t.Fatalf("expected a uuid id parameter, got %+v", op)
+	}
+	if len(op.Security) != 1 {
+		t.Errorf("protected routes need bearer auth")
+	}
+	if len(doc.Paths["/v1/auth/login"]["post"].Security) != 0 {
+		t.Errorf("public routes must not need auth")
+	}
+}
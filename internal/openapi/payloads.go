@@ -0,0 +1,114 @@
+package openapi
+
+import (
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/model_converter"
+)
+
+// The handlers declare their request payloads locally and answer some requests with fiber.Map, so the
+// document describes them with these mirrors. Request mirrors carry the same json and validate tags as
+// the handler types they stand for; keep the two in step
+
+type RegisterRequest struct {
+	Username string `json:"username" validate:"required,username"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type SendMessageRequest struct {
+	Username   string     `json:"username" validate:"required"`
+	Content    string     `json:"content" validate:"content"`
+	TtlSeconds *int32     `json:"ttl_seconds" validate:"ttl"`
+	DeliverAt  *time.Time `json:"deliver_at"`
+}
+
+type UpdateScheduledMessageRequest struct {
+	Content    *string    `json:"content" validate:"content"`
+	TtlSeconds *int32     `json:"ttl_seconds" validate:"ttl"`
+	DeliverAt  *time.Time `json:"deliver_at" validate:"future"`
+}
+
+type MarkReadRequest struct {
+	UpTo *time.Time `json:"up_to"`
+}
+
+type UsernameRequest struct {
+	Username string `json:"username" validate:"required"`
+}
+
+type PrivacySettingsRequest struct {
+	ContactsOnly *bool `json:"contacts_only"`
+	HideLastSeen *bool `json:"hide_last_seen"`
+}
+
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" validate:"max=100"`
+	Bio         *string `json:"bio" validate:"max=500"`
+	StatusText  *string `json:"status_text" validate:"max=140"`
+	Timezone    *string `json:"timezone" validate:"timezone"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required"`
+}
+
+type UpdateWebhookRequest struct {
+	URL     *string   `json:"url" validate:"url"`
+	Events  *[]string `json:"events" validate:"min=1"`
+	Enabled *bool     `json:"enabled"`
+}
+
+type TokenPair struct {
+	Access  string `json:"access"`
+	Refresh string `json:"refresh"`
+}
+
+type AccessToken struct {
+	Access string `json:"access"`
+}
+
+type Success struct {
+	Success string `json:"Success"`
+}
+
+type Greeting struct {
+	Message string `json:"Message"`
+}
+
+type AuthTest struct {
+	Message string         `json:"Message"`
+	User    map[string]any `json:"user"`
+}
+
+type Liveness struct {
+	Status string `json:"status"`
+}
+
+type ContactRequests struct {
+	Incoming []model_converter.Contact `json:"incoming"`
+	Outgoing []model_converter.Contact `json:"outgoing"`
+}
+
+type MarkedRead struct {
+	MarkedRead int `json:"marked_read"`
+}
+
+type PrivacySettings struct {
+	ContactsOnly bool `json:"contacts_only"`
+	HideLastSeen bool `json:"hide_last_seen"`
+}
+
+type Typing struct {
+	Typing bool `json:"typing"`
+}
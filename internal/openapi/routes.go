@@ -0,0 +1,115 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/PlatosRepublic7/ember/internal/health"
+	"github.com/PlatosRepublic7/ember/internal/model_converter"
+)
+
+// An operation served by routes.SetupRoutes. The routes test fails when the two disagree, so a new
+// endpoint has to be described here before it can be merged
+type route struct {
+	method  string
+	path    string
+	id      string
+	summary string
+	tag     string
+	public  bool
+
+	query        []Parameter
+	body         any
+	optionalBody bool
+	// Name of the file field, for multipart uploads
+	multipart string
+
+	status   int
+	response any
+	// Set for endpoints that answer with raw bytes rather than JSON
+	contentType string
+}
+
+func query(name string, schema *Schema, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func bounded(min int64, max int64) *Schema {
+	return &Schema{Type: "integer", Format: "int32", Minimum: &min, Maximum: &max}
+}
+
+var (
+	str      = &Schema{Type: "string"}
+	dateTime = &Schema{Type: "string", Format: "date-time"}
+)
+
+var routes = []route{
+	{method: http.MethodGet, path: "/healthc", id: "healthCheck", summary: "Legacy health check", tag: "health", public: true, status: http.StatusOK, response: Greeting{}},
+	{method: http.MethodGet, path: "/livez", id: "livez", summary: "Liveness probe", tag: "health", public: true, status: http.StatusOK, response: Liveness{}},
+	{method: http.MethodGet, path: "/readyz", id: "readyz", summary: "Readiness probe, answers 503 with the same report when a check fails", tag: "health", public: true, status: http.StatusOK, response: health.Report{}},
+	{method: http.MethodGet, path: "/openapi.json", id: "getOpenAPI", summary: "This document", tag: "meta", public: true, status: http.StatusOK, response: map[string]any{}},
+
+	{method: http.MethodPost, path: "/v1/auth/register", id: "register", summary: "Register a new user", tag: "auth", public: true, body: RegisterRequest{}, status: http.StatusCreated, response: model_converter.User{}},
+	{method: http.MethodPost, path: "/v1/auth/login", id: "login", summary: "Log in, invalidating every earlier refresh token", tag: "auth", public: true, body: LoginRequest{}, status: http.StatusCreated, response: TokenPair{}},
+	{method: http.MethodPost, path: "/v1/auth/logout", id: "logout", summary: "Invalidate a refresh token", tag: "auth", public: true, body: RefreshTokenRequest{}, status: http.StatusOK, response: Success{}},
+	{method: http.MethodPost, path: "/v1/auth/refresh", id: "refresh", summary: "Exchange a refresh token for a new access token", tag: "auth", public: true, body: RefreshTokenRequest{}, status: http.StatusCreated, response: AccessToken{}},
+
+	{method: http.MethodGet, path: "/v1/test", id: "authTest", summary: "Echo the claims of the access token", tag: "users", status: http.StatusOK, response: AuthTest{}},
+	{method: http.MethodGet, path: "/v1/users/:username", id: "getUser", summary: "Public profile of a user", tag: "users", status: http.StatusOK, response: model_converter.PublicProfile{}},
+
+	{method: http.MethodPost, path: "/v1/messages", id: "sendMessage", summary: "Send a message, optionally scheduled for later delivery", tag: "messages", body: SendMessageRequest{}, status: http.StatusCreated, response: model_converter.Message{}},
+	{method: http.MethodGet, path: "/v1/messages", id: "getMessages", summary: "Messages sent or received by the requesting user", tag: "messages", status: http.StatusOK, response: []model_converter.Message{},
+		query: []Parameter{
+			query("type", &Schema{Type: "string", Enum: []string{"sent", "received"}}, "Only sent or only received messages"),
+			query("username", str, "Only messages exchanged with this user"),
+		}},
+	{method: http.MethodGet, path: "/v1/messages/search", id: "searchMessages", summary: "Full-text search over the requesting user's messages", tag: "messages", status: http.StatusOK, response: []model_converter.MessageSearchResult{},
+		query: []Parameter{
+			{Name: "q", In: "query", Required: true, Description: "Search terms", Schema: str},
+			query("username", str, "Only messages exchanged with this user"),
+			query("from", dateTime, "Only messages sent at or after this time"),
+			query("to", dateTime, "Only messages sent before this time"),
+			query("limit", bounded(1, 100), "Page size, 20 by default"),
+			query("offset", bounded(0, 1<<31-1), "Results to skip"),
+		}},
+	{method: http.MethodGet, path: "/v1/messages/scheduled", id: "getScheduledMessages", summary: "Scheduled messages that have not been delivered yet", tag: "messages", status: http.StatusOK, response: []model_converter.Message{}},
+	{method: http.MethodPatch, path: "/v1/messages/scheduled/:id", id: "updateScheduledMessage", summary: "Edit a scheduled message before it is delivered, a null ttl_seconds removes its TTL", tag: "messages", body: UpdateScheduledMessageRequest{}, status: http.StatusOK, response: model_converter.Message{}},
+	{method: http.MethodDelete, path: "/v1/messages/scheduled/:id", id: "cancelScheduledMessage", summary: "Cancel a scheduled message", tag: "messages", status: http.StatusOK, response: Success{}},
+
+	{method: http.MethodGet, path: "/v1/conversations", id: "getConversations", summary: "Conversation summaries, most recent first", tag: "conversations", status: http.StatusOK, response: []model_converter.Conversation{}},
+	{method: http.MethodPost, path: "/v1/conversations/:username/read", id: "markConversationRead", summary: "Mark received messages as read, up to an optional time", tag: "conversations", body: MarkReadRequest{}, optionalBody: true, status: http.StatusOK, response: MarkedRead{}},
+
+	{method: http.MethodGet, path: "/v1/contacts", id: "getContacts", summary: "Accepted contacts", tag: "contacts", status: http.StatusOK, response: []model_converter.Contact{}},
+	{method: http.MethodDelete, path: "/v1/contacts/:username", id: "deleteContact", summary: "Remove a contact", tag: "contacts", status: http.StatusOK, response: Success{}},
+	{method: http.MethodGet, path: "/v1/contacts/requests", id: "getContactRequests", summary: "Pending incoming and outgoing contact requests", tag: "contacts", status: http.StatusOK, response: ContactRequests{}},
+	{method: http.MethodPost, path: "/v1/contacts/requests", id: "createContactRequest", summary: "Send a contact request, accepting a pending one from the same user", tag: "contacts", body: UsernameRequest{}, status: http.StatusCreated, response: Success{}},
+	{method: http.MethodPost, path: "/v1/contacts/requests/:username/accept", id: "acceptContactRequest", summary: "Accept a contact request", tag: "contacts", status: http.StatusOK, response: Success{}},
+	{method: http.MethodPost, path: "/v1/contacts/requests/:username/decline", id: "declineContactRequest", summary: "Decline a contact request", tag: "contacts", status: http.StatusOK, response: Success{}},
+	{method: http.MethodGet, path: "/v1/blocks", id: "getBlockedUsers", summary: "Users blocked by the requesting user", tag: "contacts", status: http.StatusOK, response: []model_converter.BlockedUser{}},
+	{method: http.MethodPost, path: "/v1/blocks", id: "blockUser", summary: "Block a user", tag: "contacts", body: UsernameRequest{}, status: http.StatusCreated, response: Success{}},
+	{method: http.MethodDelete, path: "/v1/blocks/:username", id: "unblockUser", summary: "Unblock a user", tag: "contacts", status: http.StatusOK, response: Success{}},
+	{method: http.MethodPut, path: "/v1/settings/privacy", id: "updatePrivacySettings", summary: "Change privacy settings", tag: "contacts", body: PrivacySettingsRequest{}, status: http.StatusOK, response: PrivacySettings{}},
+
+	{method: http.MethodGet, path: "/v1/me", id: "getProfile", summary: "The requesting user's own profile", tag: "profile", status: http.StatusOK, response: model_converter.Profile{}},
+	{method: http.MethodPatch, path: "/v1/me", id: "updateProfile", summary: "Edit the fields present in the payload", tag: "profile", body: UpdateProfileRequest{}, status: http.StatusOK, response: model_converter.Profile{}},
+	{method: http.MethodPut, path: "/v1/me/avatar", id: "uploadAvatar", summary: "Upload a PNG, JPEG, GIF or WebP avatar of at most 2 MiB", tag: "profile", multipart: "avatar", status: http.StatusOK, response: model_converter.Profile{}},
+	{method: http.MethodDelete, path: "/v1/me/avatar", id: "deleteAvatar", summary: "Remove the avatar", tag: "profile", status: http.StatusOK, response: model_converter.Profile{}},
+	{method: http.MethodGet, path: "/v1/users/:username/avatar", id: "getAvatar", summary: "Avatar image of a user", tag: "profile", status: http.StatusOK, contentType: "image/*"},
+
+	{method: http.MethodGet, path: "/v1/contacts/presence", id: "getContactsPresence", summary: "Presence of every contact", tag: "presence", status: http.StatusOK, response: []model_converter.Presence{}},
+	{method: http.MethodPost, path: "/v1/conversations/:username/typing", id: "setTyping", summary: "Signal typing, resend every few seconds while typing continues", tag: "presence", status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/conversations/:username/typing", id: "getTyping", summary: "Whether the user is typing to the requesting user", tag: "presence", status: http.StatusOK, response: Typing{}},
+
+	{method: http.MethodGet, path: "/v1/webhooks", id: "getWebhooks", summary: "The requesting user's webhooks", tag: "webhooks", status: http.StatusOK, response: []model_converter.Webhook{}},
+	{method: http.MethodPost, path: "/v1/webhooks", id: "createWebhook", summary: "Register a webhook, the response carries the signing secret once", tag: "webhooks", body: CreateWebhookRequest{}, status: http.StatusCreated, response: model_converter.Webhook{}},
+	{method: http.MethodPatch, path: "/v1/webhooks/:id", id: "updateWebhook", summary: "Edit a webhook, enabling it again resets its failure count", tag: "webhooks", body: UpdateWebhookRequest{}, status: http.StatusOK, response: model_converter.Webhook{}},
+	{method: http.MethodDelete, path: "/v1/webhooks/:id", id: "deleteWebhook", summary: "Delete a webhook and its delivery log", tag: "webhooks", status: http.StatusOK, response: Success{}},
+	{method: http.MethodGet, path: "/v1/webhooks/:id/deliveries", id: "getWebhookDeliveries", summary: "Delivery log of a webhook, most recent first", tag: "webhooks", status: http.StatusOK, response: []model_converter.WebhookDelivery{},
+		query: []Parameter{query("limit", bounded(1, 200), "Page size, 50 by default")}},
+
+	{method: http.MethodGet, path: "/v1/admin/webhooks", id: "adminGetWebhooks", summary: "Global webhooks", tag: "admin", status: http.StatusOK, response: []model_converter.Webhook{}},
+	{method: http.MethodPost, path: "/v1/admin/webhooks", id: "adminCreateWebhook", summary: "Register a global webhook that receives every event", tag: "admin", body: CreateWebhookRequest{}, status: http.StatusCreated, response: model_converter.Webhook{}},
+	{method: http.MethodPatch, path: "/v1/admin/webhooks/:id", id: "adminUpdateWebhook", summary: "Edit a global webhook", tag: "admin", body: UpdateWebhookRequest{}, status: http.StatusOK, response: model_converter.Webhook{}},
+	{method: http.MethodDelete, path: "/v1/admin/webhooks/:id", id: "adminDeleteWebhook", summary: "Delete a global webhook", tag: "admin", status: http.StatusOK, response: Success{}},
+	{method: http.MethodGet, path: "/v1/admin/webhooks/:id/deliveries", id: "adminGetWebhookDeliveries", summary: "Delivery log of a global webhook", tag: "admin", status: http.StatusOK, response: []model_converter.WebhookDelivery{},
+		query: []Parameter{query("limit", bounded(1, 200), "Page size, 50 by default")}},
+}
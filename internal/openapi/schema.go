@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/validate"
+	"github.com/google/uuid"
+)
+
+// Schema is the subset of JSON Schema used to describe ember's payloads
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int64             `json:"minLength,omitempty"`
+	MaxLength            *int64             `json:"maxLength,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	MinItems             *int64             `json:"minItems,omitempty"`
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	uuidType    = reflect.TypeOf(uuid.UUID{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+	codeType    = reflect.TypeOf(apierr.Code(""))
+)
+
+// Builds schemas from Go types, so the document describes exactly what encoding/json produces. Named
+// struct types become components and are referenced, everything else is inlined
+type reflector struct {
+	schemas map[string]*Schema
+}
+
+func newReflector() *reflector {
+	return &reflector{schemas: make(map[string]*Schema)}
+}
+
+// Ref returns the schema of v's type
+func (r *reflector) Ref(v any) *Schema {
+	return r.schema(reflect.TypeOf(v))
+}
+
+func (r *reflector) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawJSONType:
+		return &Schema{}
+	case codeType:
+		codes := apierr.Codes()
+		enum := make([]string, len(codes))
+		for i := range codes {
+			enum[i] = string(codes[i])
+		}
+		slices.Sort(enum)
+		return &Schema{Type: "string", Enum: enum}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return r.schema(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		// Types declared inside functions have no usable name, so they are inlined
+		if t.Name() == "" || strings.Contains(t.String(), "·") {
+			return r.object(t)
+		}
+		if _, ok := r.schemas[t.Name()]; !ok {
+			// Register first, so a type that refers to itself does not recurse forever
+			r.schemas[t.Name()] = &Schema{}
+			*r.schemas[t.Name()] = *r.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	panic("openapi: cannot describe " + t.String())
+}
+
+// Describe a struct field by field. Fields without omitempty or a pointer type are always present and
+// so are required, unless the struct carries validate tags, in which case only the required rule counts
+func (r *reflector) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	validated := false
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("validate"); ok {
+			validated = true
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := r.schema(field.Type)
+		rules := field.Tag.Get("validate")
+		if rules != "" {
+			property = constrain(property, rules)
+		}
+		object.Properties[name] = property
+
+		switch {
+		case validated && strings.Contains(","+rules+",", ",required,"):
+			object.Required = append(object.Required, name)
+		case !validated && !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer:
+			object.Required = append(object.Required, name)
+		}
+	}
+
+	return object
+}
+
+// Translate validate rules into the matching JSON Schema keywords
+func constrain(property *Schema, rules string) *Schema {
+	constrained := *property
+	length := func(min int64, max int64) {
+		constrained.MinLength, constrained.MaxLength = &min, &max
+	}
+
+	for _, spec := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(spec, "=")
+		switch name {
+		case "min", "max":
+			n, _ := strconv.ParseInt(param, 10, 64)
+			switch {
+			case constrained.Type == "string" && name == "min":
+				constrained.MinLength = &n
+			case constrained.Type == "string":
+				constrained.MaxLength = &n
+			case constrained.Type == "array" && name == "min":
+				constrained.MinItems = &n
+			case name == "min":
+				constrained.Minimum = &n
+			default:
+				constrained.Maximum = &n
+			}
+		case "username":
+			length(validate.MinUsernameLength, validate.MaxUsernameLength)
+			constrained.Pattern = `^[A-Za-z0-9][A-Za-z0-9_.-]*$`
+		case "email":
+			constrained.Format = "email"
+		case "password":
+			length(validate.MinPasswordLength, validate.MaxPasswordLength)
+		case "content":
+			length(1, validate.MaxContentLength)
+		case "ttl":
+			min, max := int64(validate.MinTTLSeconds), int64(validate.MaxTTLSeconds)
+			constrained.Minimum, constrained.Maximum = &min, &max
+		case "url":
+			constrained.Format = "uri"
+		case "timezone":
+			constrained.Description = "IANA time zone name, e.g. Europe/Berlin"
+		case "future":
+			constrained.Description = "Must be in the future"
+		}
+	}
+
+	return &constrained
+}
@@ -0,0 +1,105 @@
+// Package presence derives online/away/offline statuses and typing signals from user activity. The
+// statuses are computed by a Tracker from the raw state in a Store; MemoryStore keeps that state in the
+// memory of one process, so every instance running against the same database needs a shared Store
+// instead, or users would be reported offline by every instance that did not serve their requests
+package presence
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	StatusOnline  Status = "online"
+	StatusAway    Status = "away"
+	StatusOffline Status = "offline"
+)
+
+// Presence is the derived state of a single user
+type Presence struct {
+	UserID   uuid.UUID
+	Status   Status
+	LastSeen time.Time
+}
+
+// Tracker turns the activity recorded in a Store into online/away/offline statuses
+type Tracker struct {
+	Store Store
+	// A user with no activity for AwayAfter is away, and offline after OfflineAfter unless they still
+	// hold an open real-time connection
+	AwayAfter    time.Duration
+	OfflineAfter time.Duration
+	// How long a single typing signal stays visible
+	TypingTTL time.Duration
+}
+
+func NewTracker(store Store) *Tracker {
+	return &Tracker{
+		Store:        store,
+		AwayAfter:    2 * time.Minute,
+		OfflineAfter: 10 * time.Minute,
+		TypingTTL:    6 * time.Second,
+	}
+}
+
+// Touch records activity for userID, e.g. an authenticated request
+func (t *Tracker) Touch(ctx context.Context, userID uuid.UUID) error {
+	return t.Store.Touch(ctx, userID, time.Now().UTC())
+}
+
+// Connect and Disconnect are called by real-time transports as connections open and close
+func (t *Tracker) Connect(ctx context.Context, userID uuid.UUID) error {
+	_, err := t.Store.Connect(ctx, userID, time.Now().UTC())
+	return err
+}
+
+func (t *Tracker) Disconnect(ctx context.Context, userID uuid.UUID) error {
+	_, err := t.Store.Disconnect(ctx, userID, time.Now().UTC())
+	return err
+}
+
+// Lookup returns the presence of every given user, in the same order
+func (t *Tracker) Lookup(ctx context.Context, userIDs []uuid.UUID) ([]Presence, error) {
+	activities, err := t.Store.Lookup(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	presences := make([]Presence, len(userIDs))
+	for i, userID := range userIDs {
+		activity, ok := activities[userID]
+		presences[i] = Presence{
+			UserID:   userID,
+			Status:   StatusOffline,
+			LastSeen: activity.LastSeen,
+		}
+		if !ok {
+			continue
+		}
+
+		idle := now.Sub(activity.LastSeen)
+		switch {
+		case idle < t.AwayAfter:
+			presences[i].Status = StatusOnline
+		case idle < t.OfflineAfter || activity.Connections > 0:
+			presences[i].Status = StatusAway
+		}
+	}
+
+	return presences, nil
+}
+
+// SetTyping signals that userID is typing to counterpartID, the signal expires after TypingTTL
+func (t *Tracker) SetTyping(ctx context.Context, userID uuid.UUID, counterpartID uuid.UUID) error {
+	return t.Store.SetTyping(ctx, userID, counterpartID, time.Now().UTC().Add(t.TypingTTL))
+}
+
+// IsTyping reports whether userID is currently typing to counterpartID
+func (t *Tracker) IsTyping(ctx context.Context, userID uuid.UUID, counterpartID uuid.UUID) (bool, error) {
+	return t.Store.IsTyping(ctx, userID, counterpartID, time.Now().UTC())
+}
@@ -0,0 +1,171 @@
+package presence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestConnectAndDisconnect(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	userID := uuid.New()
+	start := time.Now().UTC()
+
+	for i, want := range []int{1, 2} {
+		got, err := store.Connect(ctx, userID, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		if got != want {
+			t.Errorf("Connect %d: got %d connections, want %d", i+1, got, want)
+		}
+	}
+
+	// Closing more connections than were opened never goes below zero
+	for i, want := range []int{1, 0, 0} {
+		got, err := store.Disconnect(ctx, userID, start.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Disconnect: %v", err)
+		}
+		if got != want {
+			t.Errorf("Disconnect %d: got %d connections, want %d", i+1, got, want)
+		}
+	}
+
+	activities, err := store.Lookup(ctx, []uuid.UUID{userID})
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if got := activities[userID]; got.Connections != 0 || !got.LastSeen.Equal(start.Add(time.Minute)) {
+		t.Errorf("got %+v, want no connections and last seen at the final disconnect", got)
+	}
+}
+
+func TestLastSeenOnlyMovesForward(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	userID := uuid.New()
+	start := time.Now().UTC()
+
+	store.Touch(ctx, userID, start)
+	store.Touch(ctx, userID, start.Add(-time.Hour))
+	store.Connect(ctx, userID, start.Add(-time.Minute))
+	store.Disconnect(ctx, userID, start.Add(-time.Minute))
+
+	activities, err := store.Lookup(ctx, []uuid.UUID{userID})
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if got := activities[userID].LastSeen; !got.Equal(start) {
+		t.Errorf("got last seen %v, want %v", got, start)
+	}
+
+	store.Touch(ctx, userID, start.Add(time.Second))
+	activities, _ = store.Lookup(ctx, []uuid.UUID{userID})
+	if got := activities[userID].LastSeen; !got.Equal(start.Add(time.Second)) {
+		t.Errorf("after a later touch: got last seen %v, want %v", got, start.Add(time.Second))
+	}
+}
+
+func TestLookupStatuses(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	tracker := NewTracker(store)
+	now := time.Now().UTC()
+
+	online, away, connected, offline, unseen := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	store.Touch(ctx, online, now.Add(-time.Minute))
+	store.Touch(ctx, away, now.Add(-5*time.Minute))
+	store.Connect(ctx, connected, now.Add(-time.Hour))
+	store.Touch(ctx, offline, now.Add(-time.Hour))
+
+	presences, err := tracker.Lookup(ctx, []uuid.UUID{online, away, connected, offline, unseen})
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		status Status
+	}{
+		{"recently active", online, StatusOnline},
+		{"idle", away, StatusAway},
+		{"idle with an open connection", connected, StatusAway},
+		{"idle for long", offline, StatusOffline},
+		{"never seen", unseen, StatusOffline},
+	}
+	if len(presences) != len(tests) {
+		t.Fatalf("got %d presences, want %d", len(presences), len(tests))
+	}
+	for i, tt := range tests {
+		if presences[i].UserID != tt.userID {
+			t.Errorf("%s: got user %s at position %d, want %s", tt.name, presences[i].UserID, i, tt.userID)
+		}
+		if presences[i].Status != tt.status {
+			t.Errorf("%s: got status %q, want %q", tt.name, presences[i].Status, tt.status)
+		}
+	}
+	if !presences[4].LastSeen.IsZero() {
+		t.Errorf("never seen: got last seen %v, want none", presences[4].LastSeen)
+	}
+	if !presences[3].LastSeen.Equal(now.Add(-time.Hour)) {
+		t.Errorf("offline: got last seen %v, want %v", presences[3].LastSeen, now.Add(-time.Hour))
+	}
+
+	// Once the last connection closes the idle user goes offline, even though disconnecting counts as activity
+	store.Disconnect(ctx, connected, now.Add(-time.Hour))
+	presences, _ = tracker.Lookup(ctx, []uuid.UUID{connected})
+	if presences[0].Status != StatusOffline {
+		t.Errorf("after disconnecting: got status %q, want %q", presences[0].Status, StatusOffline)
+	}
+}
+
+func TestTrackerConnectMarksOnline(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(NewMemoryStore())
+	userID := uuid.New()
+
+	if err := tracker.Connect(ctx, userID); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	presences, _ := tracker.Lookup(ctx, []uuid.UUID{userID})
+	if presences[0].Status != StatusOnline {
+		t.Errorf("got status %q, want %q", presences[0].Status, StatusOnline)
+	}
+
+	if err := tracker.Disconnect(ctx, userID); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	presences, _ = tracker.Lookup(ctx, []uuid.UUID{userID})
+	if presences[0].Status != StatusOnline {
+		t.Errorf("right after disconnecting: got status %q, want %q", presences[0].Status, StatusOnline)
+	}
+}
+
+func TestTyping(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	tracker := NewTracker(store)
+	alice, bob := uuid.New(), uuid.New()
+
+	if err := tracker.SetTyping(ctx, alice, bob); err != nil {
+		t.Fatalf("SetTyping: %v", err)
+	}
+	if typing, _ := tracker.IsTyping(ctx, alice, bob); !typing {
+		t.Error("got not typing, want typing")
+	}
+	// Typing is directional
+	if typing, _ := tracker.IsTyping(ctx, bob, alice); typing {
+		t.Error("reverse direction: got typing, want not typing")
+	}
+
+	// The signal expires after TypingTTL
+	expiry := time.Now().UTC().Add(tracker.TypingTTL + time.Second)
+	if typing, _ := store.IsTyping(ctx, alice, bob, expiry); typing {
+		t.Error("after the TTL: got typing, want not typing")
+	}
+}
@@ -0,0 +1,128 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store holds the raw presence state. The Tracker derives statuses from it, so a shared implementation
+// (e.g. backed by Redis) is all that is needed to run several instances against the same users
+type Store interface {
+	// Record that userID was active at the given time
+	Touch(ctx context.Context, userID uuid.UUID, at time.Time) error
+	// Register or drop a real-time connection for userID, returning the number of open connections
+	Connect(ctx context.Context, userID uuid.UUID, at time.Time) (int, error)
+	Disconnect(ctx context.Context, userID uuid.UUID, at time.Time) (int, error)
+	// Return the last activity time and open connection count for each of the given users.
+	// Users that were never seen are left out of the result
+	Lookup(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]Activity, error)
+	// Record that userID is typing to counterpartID until the given time
+	SetTyping(ctx context.Context, userID uuid.UUID, counterpartID uuid.UUID, until time.Time) error
+	// Report whether userID is typing to counterpartID at the given time
+	IsTyping(ctx context.Context, userID uuid.UUID, counterpartID uuid.UUID, at time.Time) (bool, error)
+}
+
+type Activity struct {
+	LastSeen    time.Time
+	Connections int
+}
+
+type typingKey struct {
+	userID        uuid.UUID
+	counterpartID uuid.UUID
+}
+
+// MemoryStore is a Store that lives in the memory of a single instance. Its state is lost on restart
+// and is not shared with other instances, so it is only suitable for a deployment of one
+type MemoryStore struct {
+	mu       sync.Mutex
+	activity map[uuid.UUID]Activity
+	typing   map[typingKey]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		activity: make(map[uuid.UUID]Activity),
+		typing:   make(map[typingKey]time.Time),
+	}
+}
+
+func (s *MemoryStore) Touch(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activity := s.activity[userID]
+	if at.After(activity.LastSeen) {
+		activity.LastSeen = at
+	}
+	s.activity[userID] = activity
+	return nil
+}
+
+func (s *MemoryStore) Connect(ctx context.Context, userID uuid.UUID, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activity := s.activity[userID]
+	activity.Connections++
+	if at.After(activity.LastSeen) {
+		activity.LastSeen = at
+	}
+	s.activity[userID] = activity
+	return activity.Connections, nil
+}
+
+func (s *MemoryStore) Disconnect(ctx context.Context, userID uuid.UUID, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activity := s.activity[userID]
+	if activity.Connections > 0 {
+		activity.Connections--
+	}
+	if at.After(activity.LastSeen) {
+		activity.LastSeen = at
+	}
+	s.activity[userID] = activity
+	return activity.Connections, nil
+}
+
+func (s *MemoryStore) Lookup(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]Activity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[uuid.UUID]Activity, len(userIDs))
+	for _, userID := range userIDs {
+		if activity, ok := s.activity[userID]; ok {
+			result[userID] = activity
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) SetTyping(ctx context.Context, userID uuid.UUID, counterpartID uuid.UUID, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Typing entries are short lived, so sweep the expired ones whenever a new one is added
+	now := time.Now()
+	for key, expiry := range s.typing {
+		if !expiry.After(now) {
+			delete(s.typing, key)
+		}
+	}
+
+	s.typing[typingKey{userID: userID, counterpartID: counterpartID}] = until
+	return nil
+}
+
+func (s *MemoryStore) IsTyping(ctx context.Context, userID uuid.UUID, counterpartID uuid.UUID, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.typing[typingKey{userID: userID, counterpartID: counterpartID}]
+	return ok && until.After(at), nil
+}
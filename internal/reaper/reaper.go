@@ -0,0 +1,131 @@
+package reaper
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/lifecycle"
+)
+
+// Reaper periodically deletes messages whose TTL has run out. Expired messages are soft-deleted like
+// any other, but their content is wiped as well so nothing of an ephemeral message outlives its TTL.
+// It also prunes the outbox and webhook deliveries, which would otherwise grow without bound
+type Reaper struct {
+	Tx        *events.TxManager
+	Interval  time.Duration
+	BatchSize int32
+	// Finished webhook deliveries and processed outbox events are deleted once they are this old
+	Retention time.Duration
+	// Beats after every pass that finished without errors
+	Heartbeat lifecycle.Heartbeat
+}
+
+func NewReaper(txManager *events.TxManager, interval time.Duration) *Reaper {
+	return &Reaper{
+		Tx:        txManager,
+		Interval:  interval,
+		BatchSize: 500,
+		Retention: 7 * 24 * time.Hour,
+	}
+}
+
+// Run expires messages and prunes old events every Interval until ctx is cancelled
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		expireErr := r.drain(ctx, r.ExpireDue)
+		if expireErr != nil {
+			slog.ErrorContext(ctx, "reaper pass failed", "error", expireErr)
+		}
+
+		pruneErr := r.drain(ctx, r.PruneEvents)
+		if pruneErr != nil {
+			slog.ErrorContext(ctx, "reaper prune failed", "error", pruneErr)
+		}
+
+		if expireErr == nil && pruneErr == nil {
+			r.Heartbeat.Beat()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run pass until it handles less than a full batch
+func (r *Reaper) drain(ctx context.Context, pass func(context.Context) (int, error)) error {
+	for {
+		handled, err := pass(ctx)
+		if err != nil {
+			return err
+		}
+		if handled < int(r.BatchSize) {
+			return nil
+		}
+	}
+}
+
+// ExpireDue expires one batch of messages and returns how many were expired
+func (r *Reaper) ExpireDue(ctx context.Context) (int, error) {
+	var expired int
+	err := r.Tx.WithTx(ctx, func(tx *events.Tx) error {
+		expireParams := database.ExpireMessagesParams{
+			Now: sql.NullTime{
+				Time:  time.Now().UTC(),
+				Valid: true,
+			},
+			BatchSize: r.BatchSize,
+		}
+		messages, err := tx.ExpireMessages(ctx, expireParams)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			if err := tx.Emit(ctx, events.MessageExpired{MessageInfo: events.NewMessageInfo(message)}); err != nil {
+				return err
+			}
+		}
+
+		expired = len(messages)
+		return nil
+	})
+
+	return expired, err
+}
+
+// PruneEvents deletes one batch of finished webhook deliveries and one batch of processed outbox events
+// older than Retention, and returns the larger of the two counts. Deliveries go first, since an event is
+// kept for as long as any delivery of it is
+func (r *Reaper) PruneEvents(ctx context.Context) (int, error) {
+	before := time.Now().UTC().Add(-r.Retention)
+
+	deliveryParams := database.PruneWebhookDeliveriesParams{
+		Before:    before,
+		BatchSize: r.BatchSize,
+	}
+	deliveries, err := r.Tx.Store.PruneWebhookDeliveries(ctx, deliveryParams)
+	if err != nil {
+		return 0, err
+	}
+
+	outboxParams := database.PruneOutboxEventsParams{
+		Before:    before,
+		BatchSize: r.BatchSize,
+	}
+	outboxEvents, err := r.Tx.Store.PruneOutboxEvents(ctx, outboxParams)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(max(deliveries, outboxEvents)), nil
+}
@@ -0,0 +1,134 @@
+package reaper
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/memstore"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/google/uuid"
+)
+
+var ctx = context.Background()
+
+// Write an outbox event, processed at processedAt unless it is zero, with a delivery in status that was
+// last updated at updatedAt unless status is empty
+func createEvent(t *testing.T, store *memstore.Store, webhook database.Webhook, processedAt, updatedAt time.Time, status string) int64 {
+	t.Helper()
+
+	event, err := store.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType: events.TypeMessageCreated,
+		Payload:   json.RawMessage(`{}`),
+		UserIds:   []uuid.UUID{},
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !processedAt.IsZero() {
+		processedParams := database.MarkOutboxEventProcessedParams{
+			ID:          event.ID,
+			ProcessedAt: sql.NullTime{Time: processedAt, Valid: true},
+		}
+		if err := store.MarkOutboxEventProcessed(ctx, processedParams); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if status == "" {
+		return event.ID
+	}
+
+	deliveryParams := database.CreateWebhookDeliveryParams{
+		WebhookID:     webhook.ID,
+		EventID:       event.ID,
+		EventType:     event.EventType,
+		NextAttemptAt: updatedAt,
+	}
+	if err := store.CreateWebhookDelivery(ctx, deliveryParams); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.EventID != event.ID {
+			continue
+		}
+		updateParams := database.UpdateWebhookDeliveryParams{
+			ID:            delivery.ID,
+			Status:        status,
+			NextAttemptAt: updatedAt,
+			UpdatedAt:     updatedAt,
+		}
+		if err := store.UpdateWebhookDelivery(ctx, updateParams); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return event.ID
+}
+
+func TestPruneEvents(t *testing.T) {
+	store := memstore.New()
+	webhook, err := store.CreateWebhook(ctx, database.CreateWebhookParams{
+		ID:        uuid.New(),
+		Url:       "https://example.com/hook",
+		Secret:    "whsec_test",
+		Events:    []string{events.TypeMessageCreated},
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reaper := NewReaper(events.NewTxManager(store, events.NewBus()), time.Second)
+	reaper.Retention = time.Hour
+	old := time.Now().UTC().Add(-2 * time.Hour)
+	recent := time.Now().UTC().Add(-time.Minute)
+
+	delivered := createEvent(t, store, webhook, old, old, "succeeded")
+	undelivered := createEvent(t, store, webhook, old, old, "")
+	retrying := createEvent(t, store, webhook, old, old, "pending")
+	failedRecently := createEvent(t, store, webhook, old, recent, "failed")
+	processedRecently := createEvent(t, store, webhook, recent, recent, "")
+	unprocessed := createEvent(t, store, webhook, time.Time{}, old, "")
+
+	if _, err := reaper.PruneEvents(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[int64]bool{
+		delivered:         false,
+		undelivered:       false,
+		retrying:          true,
+		failedRecently:    true,
+		processedRecently: true,
+		unprocessed:       true,
+	} {
+		_, err := store.GetOutboxEvent(ctx, id)
+		if kept := err == nil; kept != want {
+			t.Errorf("event %d kept = %v, want %v", id, kept, want)
+		}
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var eventIDs []int64
+	for _, delivery := range deliveries {
+		eventIDs = append(eventIDs, delivery.EventID)
+	}
+	slices.Sort(eventIDs)
+	if !slices.Equal(eventIDs, []int64{retrying, failedRecently}) {
+		t.Errorf("deliveries kept for events %v, want %v", eventIDs, []int64{retrying, failedRecently})
+	}
+}
@@ -3,19 +3,34 @@ package routes
 import (
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/blob"
 	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/events"
 	"github.com/PlatosRepublic7/ember/internal/handlers"
+	"github.com/PlatosRepublic7/ember/internal/health"
 	"github.com/PlatosRepublic7/ember/internal/middleware"
+	"github.com/PlatosRepublic7/ember/internal/openapi"
+	"github.com/PlatosRepublic7/ember/internal/presence"
 )
 
-func SetupRoutes(app *fiber.App, dbInstance *database.Queries) {
+func SetupRoutes(app *fiber.App, dbInstance database.Querier, txManager *events.TxManager, blobStore blob.Store, tracker *presence.Tracker, authenticator *auth.Authenticator, checker *health.Checker) {
 	app.Get("/healthc", handlers.HealthCheck)
 
+	// Create a healthHandler for the liveness and readiness probes
+	healthHandler := handlers.NewHealthHandler(checker)
+	app.Get("/livez", healthHandler.HandlerLivez)
+	app.Get("/readyz", healthHandler.HandlerReadyz)
+
+	// The API description and a browsable UI over it
+	app.Get("/openapi.json", openapi.Handler)
+	app.Use("/docs", openapi.DocsHandler())
+
 	// Create URI group for app
 	v1 := app.Group("/v1/auth")
 
 	// Create a userHandler
-	userHandler := handlers.NewUserHandler(dbInstance)
+	userHandler := handlers.NewUserHandler(dbInstance, txManager, authenticator)
 
 	// All non-protected endpoints
 	v1.Post("/register", userHandler.HandlerCreateUser)
@@ -24,12 +39,67 @@ func SetupRoutes(app *fiber.App, dbInstance *database.Queries) {
 	v1.Post("/refresh", userHandler.HandlerRefreshToken)
 
 	// Group for all auth protected endpoints
-	protected := app.Group("/v1", middleware.JWTAuthMiddleware)
+	protected := app.Group("/v1", middleware.JWTAuthMiddleware(authenticator, dbInstance), middleware.PresenceMiddleware(tracker))
 	protected.Get("/test", userHandler.HandlerAuthTest)
-	protected.Get("/users", userHandler.HandlerGetUser)
+	protected.Get("/users/:username", userHandler.HandlerGetUser)
 
 	// Create a messageHandler
-	messageHandler := handlers.NewMessageHandler(dbInstance)
+	messageHandler := handlers.NewMessageHandler(dbInstance, txManager)
 	protected.Post("/messages", messageHandler.HandlerCreateMessage)
 	protected.Get("/messages", messageHandler.HandlerGetMessages)
+	protected.Get("/messages/search", messageHandler.HandlerSearchMessages)
+	protected.Get("/messages/scheduled", messageHandler.HandlerGetScheduledMessages)
+	protected.Patch("/messages/scheduled/:id", messageHandler.HandlerUpdateScheduledMessage)
+	protected.Delete("/messages/scheduled/:id", messageHandler.HandlerCancelScheduledMessage)
+
+	// Create a conversationHandler
+	conversationHandler := handlers.NewConversationHandler(dbInstance, txManager)
+	protected.Get("/conversations", conversationHandler.HandlerGetConversations)
+	protected.Post("/conversations/:username/read", conversationHandler.HandlerMarkConversationRead)
+
+	// Create a contactHandler
+	contactHandler := handlers.NewContactHandler(dbInstance, txManager)
+	protected.Get("/contacts", contactHandler.HandlerGetContacts)
+	protected.Delete("/contacts/:username", contactHandler.HandlerDeleteContact)
+	protected.Get("/contacts/requests", contactHandler.HandlerGetContactRequests)
+	protected.Post("/contacts/requests", contactHandler.HandlerCreateContactRequest)
+	protected.Post("/contacts/requests/:username/accept", contactHandler.HandlerAcceptContactRequest)
+	protected.Post("/contacts/requests/:username/decline", contactHandler.HandlerDeclineContactRequest)
+	protected.Get("/blocks", contactHandler.HandlerGetBlockedUsers)
+	protected.Post("/blocks", contactHandler.HandlerBlockUser)
+	protected.Delete("/blocks/:username", contactHandler.HandlerUnblockUser)
+	protected.Put("/settings/privacy", contactHandler.HandlerUpdatePrivacySettings)
+
+	// Create a profileHandler
+	profileHandler := handlers.NewProfileHandler(dbInstance, blobStore)
+	protected.Get("/me", profileHandler.HandlerGetProfile)
+	protected.Patch("/me", profileHandler.HandlerUpdateProfile)
+	protected.Put("/me/avatar", profileHandler.HandlerUploadAvatar)
+	protected.Delete("/me/avatar", profileHandler.HandlerDeleteAvatar)
+	protected.Get("/users/:username/avatar", profileHandler.HandlerGetAvatar)
+
+	// Create a presenceHandler
+	presenceHandler := handlers.NewPresenceHandler(dbInstance, tracker)
+	protected.Get("/contacts/presence", presenceHandler.HandlerGetContactsPresence)
+	protected.Post("/conversations/:username/typing", presenceHandler.HandlerSetTyping)
+	protected.Get("/conversations/:username/typing", presenceHandler.HandlerGetTyping)
+
+	// Create a webhookHandler for the user's own webhooks
+	webhookHandler := handlers.NewWebhookHandler(dbInstance, false)
+	protected.Get("/webhooks", webhookHandler.HandlerGetWebhooks)
+	protected.Post("/webhooks", webhookHandler.HandlerCreateWebhook)
+	protected.Patch("/webhooks/:id", webhookHandler.HandlerUpdateWebhook)
+	protected.Delete("/webhooks/:id", webhookHandler.HandlerDeleteWebhook)
+	protected.Get("/webhooks/:id/deliveries", webhookHandler.HandlerGetWebhookDeliveries)
+
+	// Group for admin only endpoints
+	admin := protected.Group("/admin", middleware.AdminMiddleware(dbInstance))
+
+	// Create a webhookHandler for global webhooks
+	adminWebhookHandler := handlers.NewWebhookHandler(dbInstance, true)
+	admin.Get("/webhooks", adminWebhookHandler.HandlerGetWebhooks)
+	admin.Post("/webhooks", adminWebhookHandler.HandlerCreateWebhook)
+	admin.Patch("/webhooks/:id", adminWebhookHandler.HandlerUpdateWebhook)
+	admin.Delete("/webhooks/:id", adminWebhookHandler.HandlerDeleteWebhook)
+	admin.Get("/webhooks/:id/deliveries", adminWebhookHandler.HandlerGetWebhookDeliveries)
 }
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PlatosRepublic7/ember/internal/openapi"
+)
+
+func newApp() *fiber.App {
+	app := fiber.New()
+	SetupRoutes(app, nil, nil, nil, nil, nil, nil)
+	return app
+}
+
+// Every route the server registers must be described by the OpenAPI document, and the document must
+// not describe routes that do not exist
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	doc := openapi.Build()
+
+	registered := make(map[string]bool)
+	for _, route := range newApp().GetRoutes(true) {
+		// Fiber adds HEAD for every GET
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		path := route.Path
+		for _, param := range route.Params {
+			path = strings.Replace(path, ":"+param, "{"+param+"}", 1)
+		}
+
+		key := route.Method + " " + path
+		registered[key] = true
+		if _, ok := doc.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s is registered but missing from the OpenAPI document", key)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			if !registered[key] {
+				t.Errorf("%s is in the OpenAPI document but not registered", key)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsServed(t *testing.T) {
+	resp, err := newApp().Test(httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("expected openapi 3.1.0, got %q", doc.OpenAPI)
+	}
+	for _, name := range []string{"Message", "Problem", "RegisterRequest", "Webhook"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("expected a %s schema", name)
+		}
+	}
+}
+
+func TestDocsUIIsServed(t *testing.T) {
+	app := newApp()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "/docs/" {
+		t.Fatalf("expected a redirect to /docs/, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	for path, want := range map[string]string{
+		"/docs/":                       "swagger-ui",
+		"/docs/swagger-initializer.js": `url: "/openapi.json"`,
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("%s: expected 200 containing %q, got %d", path, want, resp.StatusCode)
+		}
+	}
+}
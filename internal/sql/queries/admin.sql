@@ -0,0 +1,18 @@
+-- Row counts for ember admin stats. Expired messages are the ones the reaper has not got to yet
+-- name: GetStorageStats :one
+SELECT
+    (SELECT COUNT(*) FROM users) AS users,
+    (SELECT COUNT(*) FROM users WHERE users.suspended_at IS NOT NULL) AS suspended_users,
+    (SELECT COUNT(*) FROM users WHERE users.is_admin = true) AS admins,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = false AND messages.delivered_at IS NOT NULL) AS delivered_messages,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = false AND messages.delivered_at IS NULL) AS scheduled_messages,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = false AND messages.expires_at <= sqlc.arg(now)) AS expired_messages,
+    (SELECT COUNT(*) FROM messages WHERE messages.deleted = true) AS deleted_messages,
+    (SELECT COUNT(*) FROM refresh_tokens WHERE refresh_tokens.is_valid = true) AS valid_refresh_tokens,
+    (SELECT COUNT(*) FROM contacts) AS contacts,
+    (SELECT COUNT(*) FROM blocks) AS blocks,
+    (SELECT COUNT(*) FROM webhooks) AS webhooks,
+    (SELECT COUNT(*) FROM outbox WHERE outbox.processed_at IS NULL) AS pending_events;
+
+-- name: GetDatabaseSize :one
+SELECT pg_database_size(current_database())::BIGINT AS size;
@@ -0,0 +1,75 @@
+-- name: CreateContactRequest :one
+INSERT INTO contacts (requester_id, addressee_id, status, created_at, updated_at)
+VALUES ($1, $2, 'pending', $3, $3)
+RETURNING *;
+
+-- name: GetContactBetween :one
+SELECT * FROM contacts
+WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1);
+
+-- name: AcceptContactRequest :execrows
+UPDATE contacts SET status = 'accepted', updated_at = $1
+WHERE requester_id = $2 AND addressee_id = $3 AND status = 'pending';
+
+-- name: DeclineContactRequest :execrows
+DELETE FROM contacts
+WHERE requester_id = $1 AND addressee_id = $2 AND status = 'pending';
+
+-- name: DeleteContact :execrows
+DELETE FROM contacts
+WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1);
+
+-- name: AreContacts :one
+SELECT EXISTS (
+    SELECT 1 FROM contacts
+    WHERE ((requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1))
+    AND status = 'accepted'
+);
+
+-- name: GetContacts :many
+SELECT users.id, users.username, contacts.status, contacts.created_at, contacts.updated_at
+FROM contacts
+JOIN users ON users.id = (CASE WHEN contacts.requester_id = $1 THEN contacts.addressee_id ELSE contacts.requester_id END)
+WHERE (contacts.requester_id = $1 OR contacts.addressee_id = $1) AND contacts.status = 'accepted'
+ORDER BY users.username;
+
+-- name: GetIncomingContactRequests :many
+SELECT users.id, users.username, contacts.status, contacts.created_at, contacts.updated_at
+FROM contacts
+JOIN users ON users.id = contacts.requester_id
+WHERE contacts.addressee_id = $1 AND contacts.status = 'pending'
+ORDER BY contacts.created_at DESC;
+
+-- name: GetOutgoingContactRequests :many
+SELECT users.id, users.username, contacts.status, contacts.created_at, contacts.updated_at
+FROM contacts
+JOIN users ON users.id = contacts.addressee_id
+WHERE contacts.requester_id = $1 AND contacts.status = 'pending'
+ORDER BY contacts.created_at DESC;
+
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: IsBlocked :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2
+);
+
+-- name: GetBlockedUsers :many
+SELECT users.id, users.username, blocks.created_at
+FROM blocks
+JOIN users ON users.id = blocks.blocked_id
+WHERE blocks.blocker_id = $1
+ORDER BY blocks.created_at DESC;
+
+-- name: GetContactsPresenceInfo :many
+SELECT users.id, users.username, users.hide_last_seen
+FROM contacts
+JOIN users ON users.id = (CASE WHEN contacts.requester_id = $1 THEN contacts.addressee_id ELSE contacts.requester_id END)
+WHERE (contacts.requester_id = $1 OR contacts.addressee_id = $1) AND contacts.status = 'accepted'
+ORDER BY users.username;
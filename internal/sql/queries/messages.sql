@@ -1,29 +1,121 @@
 -- name: CreateMessage :one
-INSERT INTO messages (id, sender_id, recipient_id, content, created_at, ttl_seconds, expires_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
-RETURNING *;
+INSERT INTO messages (id, sender_id, recipient_id, content, created_at, ttl_seconds, expires_at, deliver_at, delivered_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at;
 
 -- name: GetSentMessagesFromThisUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE sender_id = $1 AND deleted = false ORDER BY created_at DESC;
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE sender_id = $1 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC;
 
 -- name: GetSentMessagesToNamedUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE sender_id = $1 AND recipient_id = $2 AND deleted = false ORDER BY created_at DESC;
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE sender_id = $1 AND recipient_id = $2 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC;
 
 -- name: GetReceivedMessagesFromNamedUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE recipient_id = $1 AND sender_id = $2 AND deleted = false ORDER BY created_at DESC;
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE recipient_id = $1 AND sender_id = $2 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC;
 
 -- name: GetReceivedMessagesToThisUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE recipient_id = $1 AND deleted = false ORDER BY created_at DESC;
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE recipient_id = $1 AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC;
 
 -- name: GetUserMessageHistory :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE sender_id = $1 OR recipient_id = $1 AND deleted = false ORDER BY created_at DESC;
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE (sender_id = $1 OR recipient_id = $1) AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC;
 
 -- name: GetMessageHistoryWithNamedUser :many
-SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted FROM
-messages WHERE (sender_id = $1 AND recipient_id = $2) OR (sender_id = $2 AND recipient_id = $1) 
-AND deleted = false ORDER BY created_at DESC;
\ No newline at end of file
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE ((sender_id = $1 AND recipient_id = $2) OR (sender_id = $2 AND recipient_id = $1))
+AND deleted = false AND delivered_at IS NOT NULL ORDER BY COALESCE(delivered_at, created_at) DESC;
+
+-- name: GetScheduledMessagesFromThisUser :many
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE sender_id = $1 AND deleted = false AND delivered_at IS NULL ORDER BY deliver_at ASC;
+
+-- name: GetScheduledMessage :one
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE id = $1 AND sender_id = $2 AND deleted = false AND delivered_at IS NULL;
+
+-- name: UpdateScheduledMessage :one
+UPDATE messages SET
+content = $1, ttl_seconds = $2, deliver_at = $3
+WHERE id = $4 AND sender_id = $5 AND deleted = false AND delivered_at IS NULL
+RETURNING id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at;
+
+-- name: CancelScheduledMessage :execrows
+UPDATE messages SET deleted = true
+WHERE id = $1 AND sender_id = $2 AND deleted = false AND delivered_at IS NULL;
+
+-- name: GetDueScheduledMessages :many
+SELECT id, sender_id, recipient_id, content, created_at, read_at, ttl_seconds, expires_at, deleted, deliver_at, delivered_at FROM
+messages WHERE delivered_at IS NULL AND deleted = false AND deliver_at <= $1
+ORDER BY deliver_at ASC LIMIT $2 FOR UPDATE SKIP LOCKED;
+
+-- name: MarkMessageDelivered :exec
+UPDATE messages SET
+delivered_at = $1, expires_at = $2
+WHERE id = $3 AND delivered_at IS NULL;
+
+-- The snippet is HTML, so the content is escaped (as html.EscapeString does) before the matches are marked
+-- name: SearchMessages :many
+SELECT sqlc.embed(messages),
+ts_headline('english',
+    replace(replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+    websearch_to_tsquery('english', sqlc.arg(query)),
+    'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')::text AS snippet
+FROM messages
+WHERE (sender_id = sqlc.arg(user_id) OR recipient_id = sqlc.arg(user_id))
+AND to_tsvector('english', content) @@ websearch_to_tsquery('english', sqlc.arg(query))
+AND deleted = false AND delivered_at IS NOT NULL
+AND (expires_at IS NULL OR expires_at > sqlc.arg(now))
+AND (sqlc.narg(counterpart_id)::uuid IS NULL OR sender_id = sqlc.narg(counterpart_id) OR recipient_id = sqlc.narg(counterpart_id))
+AND (sqlc.narg(sent_after)::timestamp IS NULL OR COALESCE(delivered_at, created_at) >= sqlc.narg(sent_after))
+AND (sqlc.narg(sent_before)::timestamp IS NULL OR COALESCE(delivered_at, created_at) < sqlc.narg(sent_before))
+ORDER BY COALESCE(delivered_at, created_at) DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: GetConversationSummaries :many
+WITH visible AS (
+    SELECT id, sender_id, recipient_id, content, read_at, expires_at,
+    COALESCE(delivered_at, created_at) AS sent_at,
+    (CASE WHEN sender_id = sqlc.arg(user_id) THEN recipient_id ELSE sender_id END)::uuid AS counterpart_id
+    FROM messages
+    WHERE (sender_id = sqlc.arg(user_id) OR recipient_id = sqlc.arg(user_id))
+    AND deleted = false AND delivered_at IS NOT NULL
+    AND (expires_at IS NULL OR expires_at > sqlc.arg(now))
+), latest AS (
+    SELECT DISTINCT ON (counterpart_id) counterpart_id, id, sender_id, content, sent_at
+    FROM visible
+    ORDER BY counterpart_id, sent_at DESC
+), unread AS (
+    SELECT counterpart_id, COUNT(*) AS unread_count
+    FROM visible
+    WHERE recipient_id = sqlc.arg(user_id) AND read_at IS NULL
+    GROUP BY counterpart_id
+)
+SELECT users.username AS counterpart_username, latest.counterpart_id,
+latest.id AS last_message_id, latest.sender_id AS last_message_sender_id,
+latest.content AS last_message_content, latest.sent_at AS last_activity_at,
+COALESCE(unread.unread_count, 0)::bigint AS unread_count
+FROM latest
+JOIN users ON users.id = latest.counterpart_id
+LEFT JOIN unread ON unread.counterpart_id = latest.counterpart_id
+ORDER BY latest.sent_at DESC;
+
+-- name: MarkConversationRead :many
+UPDATE messages SET read_at = sqlc.arg(read_at)
+WHERE recipient_id = sqlc.arg(recipient_id) AND sender_id = sqlc.arg(sender_id)
+AND read_at IS NULL AND deleted = false AND delivered_at IS NOT NULL
+AND COALESCE(delivered_at, created_at) <= sqlc.arg(up_to)::timestamp
+RETURNING id;
+
+-- name: ExpireMessages :many
+UPDATE messages SET deleted = true, content = ''
+WHERE id IN (
+    SELECT expiring.id FROM messages AS expiring
+    WHERE expiring.deleted = false AND expiring.expires_at <= sqlc.arg(now)
+    ORDER BY expiring.expires_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
@@ -0,0 +1,28 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox (event_type, payload, user_ids, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetUnprocessedOutboxEvents :many
+SELECT * FROM outbox
+WHERE processed_at IS NULL
+ORDER BY id ASC
+LIMIT $1 FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventProcessed :exec
+UPDATE outbox SET processed_at = $1 WHERE id = $2;
+
+-- name: GetOutboxEvent :one
+SELECT * FROM outbox WHERE id = $1;
+
+-- Events are kept until every delivery made for them has been pruned, so pending retries still have a payload
+-- name: PruneOutboxEvents :execrows
+DELETE FROM outbox
+WHERE id IN (
+    SELECT pruned.id FROM outbox AS pruned
+    WHERE pruned.processed_at < sqlc.arg(before)::timestamp
+    AND NOT EXISTS (SELECT 1 FROM webhook_deliveries WHERE webhook_deliveries.event_id = pruned.id)
+    ORDER BY pruned.id ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
@@ -0,0 +1,15 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys (id, purpose, secret, created_at, activates_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- Newest first, which is the order the authenticator picks a key to sign with
+-- name: GetSigningKeys :many
+SELECT * FROM signing_keys ORDER BY activates_at DESC, created_at DESC;
+
+-- name: RetireSigningKeys :execrows
+UPDATE signing_keys SET retires_at = $1
+WHERE purpose = $2 AND retires_at IS NULL;
+
+-- name: UpdateSigningKeySecret :exec
+UPDATE signing_keys SET secret = $1 WHERE id = $2;
@@ -7,7 +7,7 @@ RETURNING *;
 SELECT * FROM users WHERE username = $1;
 
 -- name: GetUserLoginInfo :one
-SELECT id, username, email, password FROM users WHERE email = $1; 
+SELECT id, username, email, password, suspended_at FROM users WHERE email = $1;
 
 -- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens(refresh_token, is_valid, created_at, updated_at, user_id)
@@ -20,7 +20,84 @@ SELECT * FROM refresh_tokens WHERE refresh_token = $1;
 -- name: GetAllUserRefreshTokens :many
 SELECT * FROM refresh_tokens WHERE user_id = $1;
 
+-- Sessions are refresh tokens that are still valid and younger than the refresh token lifetime
+-- name: CountActiveRefreshTokens :one
+SELECT COUNT(*) FROM refresh_tokens
+WHERE is_valid = true AND created_at > sqlc.arg(created_after);
+
 -- name: UpdateRefreshToken :exec
 UPDATE refresh_tokens SET 
 is_valid = $1, updated_at = $2 
-WHERE refresh_token = $3;
\ No newline at end of file
+WHERE refresh_token = $3;
+
+-- name: UpdateUserPrivacySettings :one
+UPDATE users SET
+contacts_only = COALESCE(sqlc.narg(contacts_only), contacts_only),
+hide_last_seen = COALESCE(sqlc.narg(hide_last_seen), hide_last_seen),
+updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: UpdateUserProfile :one
+UPDATE users SET
+display_name = COALESCE(sqlc.narg(display_name), display_name),
+bio = COALESCE(sqlc.narg(bio), bio),
+status_text = COALESCE(sqlc.narg(status_text), status_text),
+timezone = COALESCE(sqlc.narg(timezone), timezone),
+updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateUserAvatar :one
+UPDATE users SET
+avatar_key = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: IsUserAdmin :one
+SELECT is_admin FROM users WHERE id = $1;
+
+-- name: IsUserSuspended :one
+SELECT (suspended_at IS NOT NULL)::boolean AS suspended FROM users WHERE id = $1;
+
+-- name: SetUserAdmin :one
+UPDATE users SET
+is_admin = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- A NULL suspended_at lifts the suspension
+-- name: UpdateUserSuspension :one
+UPDATE users SET
+suspended_at = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users SET
+password = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- Messages, contacts, blocks, refresh tokens and webhooks go with the user, see the ON DELETE CASCADE
+-- foreign keys
+-- name: DeleteUser :execrows
+DELETE FROM users WHERE id = $1;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens SET
+is_valid = false, updated_at = $1
+WHERE user_id = $2 AND is_valid = true;
+
+-- name: RevokeRefreshTokenByID :execrows
+UPDATE refresh_tokens SET
+is_valid = false, updated_at = $1
+WHERE id = $2 AND is_valid = true;
+
+-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens SET
+is_valid = false, updated_at = $1
+WHERE is_valid = true;
@@ -0,0 +1,88 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, owner_id, url, secret, events, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+RETURNING *;
+
+-- name: GetWebhooksByOwner :many
+SELECT * FROM webhooks
+WHERE owner_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetGlobalWebhooks :many
+SELECT * FROM webhooks
+WHERE owner_id IS NULL
+ORDER BY created_at DESC;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks SET
+url = sqlc.arg(url),
+events = sqlc.arg(events),
+enabled = sqlc.arg(enabled),
+consecutive_failures = CASE WHEN sqlc.arg(enabled)::boolean THEN 0 ELSE consecutive_failures END,
+disabled_at = CASE WHEN sqlc.arg(enabled)::boolean THEN NULL ELSE disabled_at END,
+updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1;
+
+-- name: GetWebhooksForEvent :many
+SELECT * FROM webhooks
+WHERE enabled = true
+AND sqlc.arg(event_type)::text = ANY(events)
+AND (owner_id IS NULL OR owner_id = ANY(sqlc.arg(user_ids)::uuid[]));
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, status, next_attempt_at, created_at, updated_at)
+VALUES ($1, $2, $3, 'pending', $4, $4, $4)
+ON CONFLICT (webhook_id, event_id) DO NOTHING;
+
+-- Claiming pushes next_attempt_at out by a lease, so a crashed worker's claims are retried by others
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT webhook_deliveries.id FROM webhook_deliveries
+    WHERE webhook_deliveries.status = 'pending' AND webhook_deliveries.next_attempt_at <= sqlc.arg(now)
+    ORDER BY webhook_deliveries.next_attempt_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries SET
+status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, updated_at = $6
+WHERE id = $7;
+
+-- name: RecordWebhookSuccess :exec
+UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1;
+
+-- name: RecordWebhookFailure :one
+UPDATE webhooks SET
+consecutive_failures = consecutive_failures + 1,
+enabled = CASE WHEN consecutive_failures + 1 >= sqlc.arg(disable_after)::int THEN false ELSE enabled END,
+disabled_at = CASE WHEN consecutive_failures + 1 >= sqlc.arg(disable_after)::int AND enabled THEN sqlc.arg(now) ELSE disabled_at END,
+updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- Pending deliveries are never pruned, however old, so a retry in progress is not lost
+-- name: PruneWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE id IN (
+    SELECT pruned.id FROM webhook_deliveries AS pruned
+    WHERE pruned.status <> 'pending' AND pruned.updated_at < sqlc.arg(before)
+    ORDER BY pruned.id ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE messages
+ADD deliver_at TIMESTAMP,
+ADD delivered_at TIMESTAMP;
+
+-- Every message that exists before scheduling was introduced has already been delivered
+UPDATE messages SET delivered_at = created_at;
+
+CREATE INDEX messages_pending_delivery_idx ON messages (deliver_at)
+WHERE delivered_at IS NULL AND deleted = false;
+
+-- +goose Down
+DROP INDEX messages_pending_delivery_idx;
+ALTER TABLE messages DROP COLUMN delivered_at;
+ALTER TABLE messages DROP COLUMN deliver_at;
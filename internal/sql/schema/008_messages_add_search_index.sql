@@ -0,0 +1,6 @@
+-- +goose Up
+-- SearchMessages matches on this exact expression, which is what lets it use the index
+CREATE INDEX messages_search_idx ON messages USING GIN (to_tsvector('english', content));
+
+-- +goose Down
+DROP INDEX messages_search_idx;
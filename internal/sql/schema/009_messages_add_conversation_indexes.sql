@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE INDEX messages_sender_created_idx ON messages (sender_id, created_at DESC);
+CREATE INDEX messages_recipient_created_idx ON messages (recipient_id, created_at DESC);
+CREATE INDEX messages_unread_idx ON messages (recipient_id, sender_id) WHERE read_at IS NULL;
+
+-- +goose Down
+DROP INDEX messages_unread_idx;
+DROP INDEX messages_recipient_created_idx;
+DROP INDEX messages_sender_created_idx;
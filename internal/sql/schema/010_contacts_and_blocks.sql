@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE contacts (
+    requester_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    addressee_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status          VARCHAR(16) NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    PRIMARY KEY (requester_id, addressee_id),
+    CHECK (requester_id <> addressee_id)
+);
+
+CREATE INDEX contacts_addressee_idx ON contacts (addressee_id);
+
+CREATE TABLE blocks (
+    blocker_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at      TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+ALTER TABLE users
+ADD contacts_only BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN contacts_only;
+DROP TABLE blocks;
+DROP TABLE contacts;
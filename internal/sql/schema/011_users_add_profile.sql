@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE users
+ADD display_name VARCHAR(100) NOT NULL DEFAULT '',
+ADD bio VARCHAR(500) NOT NULL DEFAULT '',
+ADD avatar_key VARCHAR(255) NOT NULL DEFAULT '',
+ADD status_text VARCHAR(140) NOT NULL DEFAULT '',
+ADD timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE users DROP COLUMN status_text;
+ALTER TABLE users DROP COLUMN avatar_key;
+ALTER TABLE users DROP COLUMN bio;
+ALTER TABLE users DROP COLUMN display_name;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE users
+ADD hide_last_seen BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN hide_last_seen;
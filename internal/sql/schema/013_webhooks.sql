@@ -0,0 +1,55 @@
+-- +goose Up
+ALTER TABLE users
+ADD is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE webhooks (
+    id                      UUID PRIMARY KEY,
+    owner_id                UUID REFERENCES users(id) ON DELETE CASCADE,
+    url                     TEXT NOT NULL,
+    secret                  VARCHAR(128) NOT NULL,
+    events                  TEXT[] NOT NULL,
+    enabled                 BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures    INT NOT NULL DEFAULT 0,
+    disabled_at             TIMESTAMP,
+    created_at              TIMESTAMP NOT NULL,
+    updated_at              TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhooks_owner_idx ON webhooks (owner_id);
+
+-- Events are written here in the same transaction as the change that caused them,
+-- and fanned out to the matching webhooks by the delivery worker
+CREATE TABLE webhook_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    event_type      VARCHAR(64) NOT NULL,
+    payload         JSONB NOT NULL,
+    user_ids        UUID[] NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    processed_at    TIMESTAMP
+);
+
+CREATE INDEX webhook_outbox_unprocessed_idx ON webhook_outbox (id) WHERE processed_at IS NULL;
+
+CREATE TABLE webhook_deliveries (
+    id                  BIGSERIAL PRIMARY KEY,
+    webhook_id          UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id            BIGINT NOT NULL REFERENCES webhook_outbox(id) ON DELETE CASCADE,
+    event_type          VARCHAR(64) NOT NULL,
+    status              VARCHAR(16) NOT NULL,
+    attempts            INT NOT NULL DEFAULT 0,
+    next_attempt_at     TIMESTAMP NOT NULL,
+    last_status_code    INT,
+    last_error          TEXT,
+    created_at          TIMESTAMP NOT NULL,
+    updated_at          TIMESTAMP NOT NULL,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_outbox;
+DROP TABLE webhooks;
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- The outbox now carries every domain event, webhooks are just one of its consumers
+ALTER TABLE webhook_outbox RENAME TO outbox;
+ALTER INDEX webhook_outbox_unprocessed_idx RENAME TO outbox_unprocessed_idx;
+
+-- +goose Down
+ALTER INDEX outbox_unprocessed_idx RENAME TO webhook_outbox_unprocessed_idx;
+ALTER TABLE outbox RENAME TO webhook_outbox;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Suspended users cannot log in or refresh their access tokens, see ember admin suspend
+ALTER TABLE users
+ADD suspended_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN suspended_at;
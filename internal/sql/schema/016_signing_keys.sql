@@ -0,0 +1,15 @@
+-- +goose Up
+-- Keys that access and refresh tokens are signed with, rotated by ember admin rotate-keys. Tokens name
+-- their key in the kid header. A key with an empty secret stands for the secret in the configuration,
+-- which is what tokens without a kid were signed with, so it can be retired like any other
+CREATE TABLE signing_keys (
+    id              VARCHAR(64) PRIMARY KEY,
+    purpose         VARCHAR(16) NOT NULL CHECK (purpose IN ('access', 'refresh')),
+    secret          VARCHAR(128) NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    activates_at    TIMESTAMP NOT NULL,
+    retires_at      TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE signing_keys;
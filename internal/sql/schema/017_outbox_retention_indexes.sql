@@ -0,0 +1,10 @@
+-- +goose Up
+-- The reaper prunes processed events and finished deliveries once they are older than its retention
+CREATE INDEX outbox_processed_idx ON outbox (processed_at) WHERE processed_at IS NOT NULL;
+CREATE INDEX webhook_deliveries_event_idx ON webhook_deliveries (event_id);
+CREATE INDEX webhook_deliveries_finished_idx ON webhook_deliveries (updated_at) WHERE status <> 'pending';
+
+-- +goose Down
+DROP INDEX webhook_deliveries_finished_idx;
+DROP INDEX webhook_deliveries_event_idx;
+DROP INDEX outbox_processed_idx;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Secrets are now encrypted with KEY_ENCRYPTION_KEY, which makes them longer than a plain one. Rows written
+-- before stay readable and are encrypted in place by the next ember admin rotate-keys
+ALTER TABLE signing_keys ALTER COLUMN secret TYPE TEXT;
+
+-- +goose Down
+ALTER TABLE signing_keys ALTER COLUMN secret TYPE VARCHAR(128);
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Two users who send each other a request at the same moment could both pass the handler's check and
+-- end up with a row each way. Pairs already stored twice keep the accepted row, or else the older one
+DELETE FROM contacts
+USING contacts AS other
+WHERE contacts.requester_id = other.addressee_id AND contacts.addressee_id = other.requester_id
+AND (
+    (other.status = 'accepted' AND contacts.status <> 'accepted')
+    OR (other.status = contacts.status AND (other.created_at, other.requester_id) < (contacts.created_at, contacts.requester_id))
+);
+
+CREATE UNIQUE INDEX contacts_pair_idx ON contacts (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
+
+-- +goose Down
+DROP INDEX contacts_pair_idx;
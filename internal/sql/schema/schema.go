@@ -0,0 +1,7 @@
+// Package schema embeds the goose migrations so the binary can apply them itself
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentDB wraps db so every query gets a span named after its sqlc method, as a child of the
+// span in the query's context. It is a database.Instrument, see database.NewStore
+func InstrumentDB(db database.DBTX) database.DBTX {
+	return &instrumentedDB{next: db}
+}
+
+type instrumentedDB struct {
+	next database.DBTX
+}
+
+func (db *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	result, err := db.next.ExecContext(ctx, query, args...)
+	endQuery(span, err)
+	return result, err
+}
+
+func (db *instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.next.PrepareContext(ctx, query)
+}
+
+func (db *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	rows, err := db.next.QueryContext(ctx, query, args...)
+	endQuery(span, err)
+	return rows, err
+}
+
+// The span covers running the query, the row is scanned after it has ended
+func (db *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	return db.next.QueryRowContext(ctx, query, args...)
+}
+
+// Only the SQL is recorded, never the arguments, which hold message contents and password hashes
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := database.QueryName(query)
+	return Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func endQuery(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
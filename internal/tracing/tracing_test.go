@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+)
+
+// Record every span ended during the test
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	return recorder
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	recorder := record(t)
+
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/v1/users/:username", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "down")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/alice", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+
+	if span.Name() != "GET /v1/users/:username" {
+		t.Errorf("got name %q", span.Name())
+	}
+	if got := span.Parent().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("got trace %s, want the caller's", got)
+	}
+	if !hasAttr(span, semconv.HTTPResponseStatusCode(fiber.StatusServiceUnavailable)) {
+		t.Errorf("span does not record the status: %v", span.Attributes())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("got status %v, want an error", span.Status())
+	}
+}
+
+func TestInstrumentDBNamesSpanAfterQuery(t *testing.T) {
+	recorder := record(t)
+
+	db := InstrumentDB(stubDB{})
+	if _, err := db.ExecContext(context.Background(), "-- name: DeleteContact :exec\nDELETE FROM contacts"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "DeleteContact" {
+		t.Fatalf("got spans %v, want one named DeleteContact", spans)
+	}
+}
+
+func TestTransportPropagatesTrace(t *testing.T) {
+	recorder := record(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL + "/hook?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if want := "00-" + spans[0].SpanContext().TraceID().String() + "-" + spans[0].SpanContext().SpanID().String() + "-01"; traceparent != want {
+		t.Errorf("got traceparent %q, want %q", traceparent, want)
+	}
+	if !hasAttr(spans[0], semconv.URLFull(server.URL+"/hook")) {
+		t.Errorf("span URL should leave out the query: %v", spans[0].Attributes())
+	}
+}
+
+func hasAttr(span sdktrace.ReadOnlySpan, want attribute.KeyValue) bool {
+	for _, attr := range span.Attributes() {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
+
+type stubDB struct{}
+
+func (stubDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, nil
+}
+
+func (stubDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, nil
+}
+
+func (stubDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+
+func (stubDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
@@ -0,0 +1,489 @@
+// Package tui is a full-screen terminal client for Ember: a conversation list with unread counts, the
+// open conversation with countdowns on expiring messages, and a composer. It polls the API for updates,
+// since there is no streaming endpoint
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/client"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Backend is the part of the API the TUI uses. *client.Client implements it, tests use a scripted fake
+type Backend interface {
+	GetConversations(ctx context.Context) ([]client.Conversation, error)
+	GetMessages(ctx context.Context, params client.GetMessagesParams) ([]client.Message, error)
+	SendMessage(ctx context.Context, req client.SendMessageRequest) (*client.Message, error)
+	MarkConversationRead(ctx context.Context, username string, upTo time.Time) (int, error)
+}
+
+type Options struct {
+	// Username of the logged in user, shown as the sender of their own messages
+	Me string
+	// How often conversations and the open conversation are fetched again, 2s by default
+	PollInterval time.Duration
+	// Timeout for a single API call, 10s by default
+	RequestTimeout time.Duration
+	// Source of the current time for countdowns, time.Now by default
+	Now func() time.Time
+}
+
+const listWidth = 28
+
+type focus int
+
+const (
+	focusList focus = iota
+	focusComposer
+)
+
+type Model struct {
+	backend Backend
+	opts    Options
+
+	width  int
+	height int
+	focus  focus
+
+	conversations []client.Conversation
+	selected      int
+	// Username of the open conversation, which may not be in the list yet when it was opened with /to
+	open     string
+	messages []client.Message
+
+	pane     viewport.Model
+	composer textinput.Model
+	// Applied to every message sent until changed with /ttl
+	ttl    time.Duration
+	status string
+}
+
+// Results of background calls, handed back to Update
+type (
+	conversationsMsg struct {
+		conversations []client.Conversation
+		err           error
+	}
+	messagesMsg struct {
+		username string
+		messages []client.Message
+		err      error
+	}
+	sentMsg struct {
+		message *client.Message
+		err     error
+	}
+	markedReadMsg struct {
+		err error
+	}
+	clockMsg time.Time
+	pollMsg  struct{}
+)
+
+func New(backend Backend, opts Options) *Model {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = 10 * time.Second
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	composer := textinput.New()
+	composer.Placeholder = "Type a message, /help for commands"
+	composer.Prompt = "> "
+
+	return &Model{
+		backend:  backend,
+		opts:     opts,
+		pane:     viewport.New(0, 0),
+		composer: composer,
+		status:   "↑/↓ choose a conversation, enter to write, tab to switch, ctrl+c to quit",
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return tea.Batch(m.fetchConversations(), m.tickClock(), m.schedulePoll())
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+
+	case conversationsMsg:
+		if msg.err != nil {
+			m.status = "Cannot load conversations: " + msg.err.Error()
+			return m, nil
+		}
+		m.conversations = msg.conversations
+		m.keepSelection()
+		if m.open == "" && len(m.conversations) > 0 {
+			return m, m.openConversation(m.conversations[m.selected].CounterpartUsername)
+		}
+		return m, nil
+
+	case messagesMsg:
+		if msg.username != m.open {
+			// An answer for a conversation that is no longer open
+			return m, nil
+		}
+		if msg.err != nil {
+			m.status = "Cannot load messages: " + msg.err.Error()
+			return m, nil
+		}
+		atBottom := m.pane.AtBottom() || len(m.messages) == 0
+		changed := len(msg.messages) != len(m.messages)
+		m.messages = msg.messages
+		slices.SortFunc(m.messages, func(a client.Message, b client.Message) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+		m.render()
+		if atBottom || changed {
+			m.pane.GotoBottom()
+		}
+		if m.unread() {
+			return m, m.markRead(m.open)
+		}
+		return m, nil
+
+	case sentMsg:
+		if msg.err != nil {
+			m.status = "Not sent: " + msg.err.Error()
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Sent to %s", m.open)
+		return m, tea.Batch(m.fetchMessages(m.open), m.fetchConversations())
+
+	case markedReadMsg:
+		if msg.err != nil {
+			m.status = "Cannot mark as read: " + msg.err.Error()
+			return m, nil
+		}
+		return m, m.fetchConversations()
+
+	case clockMsg:
+		// Countdowns move every second, and messages drop out once they expire
+		m.render()
+		return m, m.tickClock()
+
+	case pollMsg:
+		cmds := []tea.Cmd{m.fetchConversations(), m.schedulePoll()}
+		if m.open != "" {
+			cmds = append(cmds, m.fetchMessages(m.open))
+		}
+		return m, tea.Batch(cmds...)
+	}
+
+	return m, nil
+}
+
+func (m *Model) handleKey(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "tab":
+		m.setFocus(1 - m.focus)
+		return m, nil
+	case "pgup", "pgdown":
+		var cmd tea.Cmd
+		m.pane, cmd = m.pane.Update(key)
+		return m, cmd
+	}
+
+	if m.focus == focusComposer {
+		switch key.String() {
+		case "esc":
+			m.setFocus(focusList)
+			return m, nil
+		case "enter":
+			input := strings.TrimSpace(m.composer.Value())
+			m.composer.SetValue("")
+			if strings.HasPrefix(input, "/") {
+				return m, m.command(input)
+			}
+			return m, m.send(input)
+		}
+
+		var cmd tea.Cmd
+		m.composer, cmd = m.composer.Update(key)
+		return m, cmd
+	}
+
+	switch key.String() {
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		return m, m.selectConversation(m.selected - 1)
+	case "down", "j":
+		return m, m.selectConversation(m.selected + 1)
+	case "enter":
+		m.setFocus(focusComposer)
+	}
+	return m, nil
+}
+
+// Run a composer command
+func (m *Model) command(input string) tea.Cmd {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/ttl":
+		switch arg {
+		case "", "off", "0":
+			m.ttl = 0
+			m.status = "Messages no longer expire"
+		default:
+			ttl, err := time.ParseDuration(arg)
+			if err != nil || ttl < time.Second {
+				m.status = "Usage: /ttl <duration>, e.g. /ttl 10m, or /ttl off"
+				return nil
+			}
+			m.ttl = ttl.Truncate(time.Second)
+			m.status = fmt.Sprintf("Messages expire %s after they are read", m.ttl)
+		}
+	case "/to":
+		if arg == "" {
+			m.status = "Usage: /to <username>"
+			return nil
+		}
+		m.status = "Writing to " + arg
+		return m.openConversation(arg)
+	case "/quit":
+		return tea.Quit
+	case "/help":
+		m.status = "/to <user> opens a conversation, /ttl <duration>|off sets expiry, /quit exits"
+	default:
+		m.status = fmt.Sprintf("Unknown command %s, try /help", name)
+	}
+	return nil
+}
+
+func (m *Model) send(content string) tea.Cmd {
+	if content == "" {
+		return nil
+	}
+	if m.open == "" {
+		m.status = "Choose a conversation first, or start one with /to <user>"
+		return nil
+	}
+
+	req := client.SendMessageRequest{Username: m.open, Content: content}
+	if m.ttl > 0 {
+		seconds := int32(m.ttl.Seconds())
+		req.TtlSeconds = &seconds
+	}
+
+	m.status = "Sending…"
+	return m.call(func(ctx context.Context) tea.Msg {
+		message, err := m.backend.SendMessage(ctx, req)
+		return sentMsg{message: message, err: err}
+	})
+}
+
+func (m *Model) selectConversation(index int) tea.Cmd {
+	if index < 0 || index >= len(m.conversations) || index == m.selected && m.open != "" {
+		return nil
+	}
+	m.selected = index
+	return m.openConversation(m.conversations[index].CounterpartUsername)
+}
+
+func (m *Model) openConversation(username string) tea.Cmd {
+	m.open = username
+	m.messages = nil
+	m.render()
+	if i := m.indexOf(username); i >= 0 {
+		m.selected = i
+	}
+	return m.fetchMessages(username)
+}
+
+// Keep the open conversation selected after the list is reordered by new activity
+func (m *Model) keepSelection() {
+	if i := m.indexOf(m.open); i >= 0 {
+		m.selected = i
+	}
+	m.selected = min(m.selected, max(len(m.conversations)-1, 0))
+}
+
+func (m *Model) indexOf(username string) int {
+	return slices.IndexFunc(m.conversations, func(conversation client.Conversation) bool {
+		return conversation.CounterpartUsername == username
+	})
+}
+
+// Whether the open conversation has messages the user has now seen but that are not marked as read
+func (m *Model) unread() bool {
+	i := m.indexOf(m.open)
+	return i >= 0 && m.conversations[i].UnreadCount > 0
+}
+
+func (m *Model) setFocus(f focus) {
+	m.focus = f
+	if f == focusComposer {
+		m.composer.Focus()
+	} else {
+		m.composer.Blur()
+	}
+}
+
+// Run fn in the background with the request timeout, the result comes back as a message
+func (m *Model) call(fn func(ctx context.Context) tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), m.opts.RequestTimeout)
+		defer cancel()
+		return fn(ctx)
+	}
+}
+
+func (m *Model) fetchConversations() tea.Cmd {
+	return m.call(func(ctx context.Context) tea.Msg {
+		conversations, err := m.backend.GetConversations(ctx)
+		return conversationsMsg{conversations: conversations, err: err}
+	})
+}
+
+func (m *Model) fetchMessages(username string) tea.Cmd {
+	return m.call(func(ctx context.Context) tea.Msg {
+		messages, err := m.backend.GetMessages(ctx, client.GetMessagesParams{Username: username})
+		return messagesMsg{username: username, messages: messages, err: err}
+	})
+}
+
+func (m *Model) markRead(username string) tea.Cmd {
+	return m.call(func(ctx context.Context) tea.Msg {
+		_, err := m.backend.MarkConversationRead(ctx, username, time.Time{})
+		return markedReadMsg{err: err}
+	})
+}
+
+func (m *Model) tickClock() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return clockMsg(t) })
+}
+
+func (m *Model) schedulePoll() tea.Cmd {
+	return tea.Tick(m.opts.PollInterval, func(time.Time) tea.Msg { return pollMsg{} })
+}
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Bold(true).Reverse(true)
+	badgeStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("9"))
+	dimStyle      = lipgloss.NewStyle().Faint(true)
+	ttlStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	listStyle     = lipgloss.NewStyle().Width(listWidth).BorderStyle(lipgloss.NormalBorder()).BorderRight(true)
+)
+
+// Size the panes to the terminal: a title line, the list and conversation side by side, then the
+// composer and the status line
+func (m *Model) layout() {
+	m.pane.Width = max(m.width-listWidth-2, 10)
+	m.pane.Height = max(m.height-3, 1)
+	m.composer.Width = max(m.width-20, 10)
+	m.render()
+}
+
+// Render the open conversation into the message pane
+func (m *Model) render() {
+	now := m.opts.Now()
+
+	var lines []string
+	for _, message := range m.messages {
+		remaining := message.ExpiresAt.Time.Sub(now)
+		if message.ExpiresAt.Valid && remaining <= 0 {
+			continue
+		}
+
+		sender := m.opts.Me
+		if sender == "" {
+			sender = "you"
+		}
+		if i := m.indexOf(m.open); i >= 0 && message.SenderID == m.conversations[i].CounterpartID {
+			sender = m.open
+		}
+
+		line := fmt.Sprintf("%s %s: %s", dimStyle.Render(message.CreatedAt.Local().Format("15:04")), titleStyle.Render(sender), message.Content)
+		switch {
+		case message.ExpiresAt.Valid:
+			line += " " + ttlStyle.Render("⏳ "+countdown(remaining))
+		case message.TtlSeconds.Valid && !message.ReadAt.Valid:
+			line += " " + ttlStyle.Render(fmt.Sprintf("⏳ %s after read", time.Duration(message.TtlSeconds.Int32)*time.Second))
+		}
+		lines = append(lines, lipgloss.NewStyle().Width(m.pane.Width).Render(line))
+	}
+
+	if len(lines) == 0 && m.open != "" {
+		lines = append(lines, dimStyle.Render("No messages with "+m.open+" yet"))
+	}
+	m.pane.SetContent(strings.Join(lines, "\n"))
+}
+
+// Remaining time to the second, e.g. 4m05s
+func countdown(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+func (m *Model) View() string {
+	if m.width == 0 {
+		return "Loading…"
+	}
+
+	var list []string
+	for i, conversation := range m.conversations {
+		name := conversation.CounterpartUsername
+		badge := ""
+		if conversation.UnreadCount > 0 {
+			badge = " " + badgeStyle.Render(fmt.Sprintf("(%d)", conversation.UnreadCount))
+		}
+		if i == m.selected {
+			name = selectedStyle.Render(name)
+		}
+		list = append(list, name+badge)
+	}
+	if len(m.conversations) == 0 {
+		list = append(list, dimStyle.Render("No conversations"))
+	}
+
+	header := titleStyle.Render("Ember")
+	if m.opts.Me != "" {
+		header += dimStyle.Render(" — " + m.opts.Me)
+	}
+	if m.open != "" {
+		header += "  " + titleStyle.Render("@"+m.open)
+	}
+
+	composer := m.composer.View()
+	if m.ttl > 0 {
+		composer = ttlStyle.Render(fmt.Sprintf("[ttl %s] ", m.ttl)) + composer
+	}
+
+	body := lipgloss.JoinHorizontal(lipgloss.Top,
+		listStyle.Height(m.pane.Height).Render(strings.Join(list, "\n")),
+		" "+m.pane.View(),
+	)
+	return strings.Join([]string{header, body, composer, dimStyle.Render(m.status)}, "\n")
+}
@@ -0,0 +1,320 @@
+package tui
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/client"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+// A scripted backend: tests set up conversations and messages, and check what the TUI sent
+type fakeBackend struct {
+	mu            sync.Mutex
+	me            uuid.UUID
+	conversations []client.Conversation
+	messages      map[string][]client.Message
+	sent          []client.SendMessageRequest
+	marked        []string
+	err           error
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{me: uuid.New(), messages: make(map[string][]client.Message)}
+}
+
+// Add a conversation with a message from username, received at createdAt
+func (f *fakeBackend) receive(username string, content string, createdAt time.Time) client.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.indexOf(username)
+	if i < 0 {
+		f.conversations = append(f.conversations, client.Conversation{CounterpartID: uuid.New(), CounterpartUsername: username})
+		i = len(f.conversations) - 1
+	}
+	f.conversations[i].UnreadCount++
+	f.conversations[i].LastActivityAt = createdAt
+
+	message := client.Message{ID: uuid.New(), SenderID: f.conversations[i].CounterpartID, RecipientID: f.me, Content: content, CreatedAt: createdAt}
+	f.messages[username] = append(f.messages[username], message)
+	return message
+}
+
+func (f *fakeBackend) indexOf(username string) int {
+	for i := range f.conversations {
+		if f.conversations[i].CounterpartUsername == username {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeBackend) GetConversations(ctx context.Context) ([]client.Conversation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]client.Conversation(nil), f.conversations...), f.err
+}
+
+func (f *fakeBackend) GetMessages(ctx context.Context, params client.GetMessagesParams) ([]client.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]client.Message(nil), f.messages[params.Username]...), f.err
+}
+
+func (f *fakeBackend) SendMessage(ctx context.Context, req client.SendMessageRequest) (*client.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.sent = append(f.sent, req)
+	message := client.Message{ID: uuid.New(), SenderID: f.me, Content: req.Content, CreatedAt: time.Now()}
+	if req.TtlSeconds != nil {
+		message.TtlSeconds = sql.NullInt32{Int32: *req.TtlSeconds, Valid: true}
+	}
+	f.messages[req.Username] = append(f.messages[req.Username], message)
+	return &message, nil
+}
+
+func (f *fakeBackend) MarkConversationRead(ctx context.Context, username string, upTo time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.marked = append(f.marked, username)
+	if i := f.indexOf(username); i >= 0 {
+		marked := int(f.conversations[i].UnreadCount)
+		f.conversations[i].UnreadCount = 0
+		return marked, nil
+	}
+	return 0, nil
+}
+
+// Drives a model the way a tea.Program would, running commands until nothing is left to do. Timers
+// never fire on their own, tests send clockMsg and pollMsg to move time along
+type driver struct {
+	t     *testing.T
+	model *Model
+	quit  bool
+}
+
+func start(t *testing.T, backend Backend, opts Options) *driver {
+	d := &driver{t: t, model: New(backend, opts)}
+	d.run(d.model.Init())
+	d.send(tea.WindowSizeMsg{Width: 100, Height: 20})
+	return d
+}
+
+func (d *driver) send(msg tea.Msg) {
+	_, cmd := d.model.Update(msg)
+	d.run(cmd)
+}
+
+func (d *driver) run(cmd tea.Cmd) {
+	for _, msg := range d.exec(cmd) {
+		d.send(msg)
+	}
+}
+
+// Run cmd and every command it batches, dropping those still waiting after a moment, i.e. timers
+func (d *driver) exec(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+
+	var msg tea.Msg
+	select {
+	case msg = <-done:
+	case <-time.After(20 * time.Millisecond):
+		return nil
+	}
+
+	switch msg := msg.(type) {
+	case nil:
+		return nil
+	case tea.QuitMsg:
+		d.quit = true
+		return nil
+	case tea.BatchMsg:
+		var msgs []tea.Msg
+		results := make([][]tea.Msg, len(msg))
+		var wg sync.WaitGroup
+		for i, batched := range msg {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = d.exec(batched)
+			}()
+		}
+		wg.Wait()
+		for _, result := range results {
+			msgs = append(msgs, result...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+func (d *driver) typeText(text string) {
+	d.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+}
+
+func (d *driver) press(key tea.KeyType) {
+	d.send(tea.KeyMsg{Type: key})
+}
+
+func (d *driver) expectView(want ...string) {
+	d.t.Helper()
+	view := d.model.View()
+	for _, w := range want {
+		if !strings.Contains(view, w) {
+			d.t.Errorf("expected %q in the view:\n%s", w, view)
+		}
+	}
+}
+
+func (d *driver) rejectView(unwanted ...string) {
+	d.t.Helper()
+	view := d.model.View()
+	for _, u := range unwanted {
+		if strings.Contains(view, u) {
+			d.t.Errorf("did not expect %q in the view:\n%s", u, view)
+		}
+	}
+}
+
+func TestUnreadBadgesClearOnceOpened(t *testing.T) {
+	backend := newFakeBackend()
+	backend.receive("bob", "hi alice", time.Now())
+	backend.receive("bob", "you there?", time.Now())
+	backend.receive("carol", "lunch?", time.Now())
+
+	d := start(t, backend, Options{Me: "alice"})
+
+	// bob's conversation opens first and is marked read, carol's stays unread
+	d.expectView("bob", "carol (1)", "bob: hi alice", "bob: you there?")
+	d.rejectView("bob (2)")
+	if len(backend.marked) != 1 || backend.marked[0] != "bob" {
+		t.Errorf("expected bob's conversation to be marked read, got %v", backend.marked)
+	}
+
+	d.press(tea.KeyDown)
+	d.expectView("@carol", "carol: lunch?")
+	d.rejectView("carol (1)", "bob: hi alice")
+}
+
+func TestCountdownsOnExpiringMessages(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	backend := newFakeBackend()
+	message := backend.receive("bob", "self destructs", now)
+	backend.messages["bob"][0].ExpiresAt = sql.NullTime{Time: now.Add(4*time.Minute + 5*time.Second), Valid: true}
+	backend.receive("bob", "stays around", now.Add(time.Second))
+
+	clock := now
+	d := start(t, backend, Options{Me: "alice", Now: func() time.Time { return clock }})
+	d.expectView("self destructs ⏳ 4m05s")
+
+	clock = clock.Add(5 * time.Second)
+	d.send(clockMsg(clock))
+	d.expectView("self destructs ⏳ 4m00s")
+
+	clock = message.CreatedAt.Add(5 * time.Minute)
+	d.send(clockMsg(clock))
+	d.rejectView("self destructs")
+	d.expectView("stays around")
+}
+
+func TestComposerSendsWithTTL(t *testing.T) {
+	backend := newFakeBackend()
+	backend.receive("bob", "hi", time.Now())
+
+	d := start(t, backend, Options{Me: "alice"})
+	d.press(tea.KeyEnter)
+
+	d.typeText("/ttl 10m")
+	d.press(tea.KeyEnter)
+	d.expectView("[ttl 10m0s]", "expire 10m0s after they are read")
+
+	d.typeText("burn after reading")
+	d.press(tea.KeyEnter)
+
+	if len(backend.sent) != 1 {
+		t.Fatalf("expected one message to be sent, got %v", backend.sent)
+	}
+	sent := backend.sent[0]
+	if sent.Username != "bob" || sent.Content != "burn after reading" || sent.TtlSeconds == nil || *sent.TtlSeconds != 600 {
+		t.Errorf("unexpected message %+v", sent)
+	}
+	d.expectView("alice: burn after reading ⏳ 10m0s after read")
+
+	d.typeText("/ttl off")
+	d.press(tea.KeyEnter)
+	d.typeText("plain")
+	d.press(tea.KeyEnter)
+	if sent := backend.sent[1]; sent.TtlSeconds != nil {
+		t.Errorf("expected no TTL after /ttl off, got %d", *sent.TtlSeconds)
+	}
+	d.rejectView("[ttl")
+}
+
+func TestPollingShowsNewMessages(t *testing.T) {
+	backend := newFakeBackend()
+	backend.receive("bob", "first", time.Now())
+
+	d := start(t, backend, Options{Me: "alice"})
+	d.rejectView("second", "dave")
+
+	backend.receive("bob", "second", time.Now())
+	backend.receive("dave", "new here", time.Now())
+	d.send(pollMsg{})
+
+	d.expectView("bob: second", "dave (1)")
+}
+
+func TestStartConversationWithTo(t *testing.T) {
+	backend := newFakeBackend()
+	d := start(t, backend, Options{Me: "alice"})
+	d.expectView("No conversations")
+
+	d.press(tea.KeyTab)
+	d.typeText("/to erin")
+	d.press(tea.KeyEnter)
+	d.expectView("@erin", "No messages with erin yet")
+
+	d.typeText("hello erin")
+	d.press(tea.KeyEnter)
+	if len(backend.sent) != 1 || backend.sent[0].Username != "erin" {
+		t.Errorf("expected a message to erin, got %v", backend.sent)
+	}
+
+	d.typeText("/quit")
+	d.press(tea.KeyEnter)
+	if !d.quit {
+		t.Errorf("expected /quit to quit")
+	}
+}
+
+func TestBackendErrorsShowInStatus(t *testing.T) {
+	backend := newFakeBackend()
+	backend.receive("bob", "hi", time.Now())
+	d := start(t, backend, Options{Me: "alice"})
+
+	backend.err = errors.New("connection refused")
+	d.press(tea.KeyEnter)
+	d.typeText("hello")
+	d.press(tea.KeyEnter)
+	d.expectView("Not sent: connection refused")
+
+	d.send(pollMsg{})
+	d.expectView("Cannot load")
+}
@@ -0,0 +1,256 @@
+// Package validate checks request structs against the rules in their validate tags, e.g.
+//
+//	type createMessageRequest struct {
+//		Username   string `json:"username" validate:"required"`
+//		Content    string `json:"content" validate:"content"`
+//		TtlSeconds *int32 `json:"ttl_seconds" validate:"ttl"`
+//	}
+//
+// Rules are separated by commas and checked in order, the first one a field fails is reported. A nil
+// pointer is an absent optional field and only fails required, otherwise the rules apply to the value
+// it points to
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+)
+
+// Limits shared by the reusable rules
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+	// bcrypt ignores everything past 72 bytes, so longer passwords would be silently truncated
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+	MaxContentLength  = 4000
+	MinTTLSeconds     = 1
+	MaxTTLSeconds     = 30 * 24 * 60 * 60
+)
+
+// Usernames appear in URLs, so they are limited to characters that never need escaping
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// A rule returns a message describing why value fails it, or "" if it passes. param is the text after
+// "=" in the tag, if any
+type rule func(value reflect.Value, param string) string
+
+var rules = map[string]rule{
+	"required": required,
+	"min":      minimum,
+	"max":      maximum,
+	"username": username,
+	"email":    email,
+	"password": password,
+	"content":  content,
+	"ttl":      ttl,
+	"url":      httpURL,
+	"timezone": timezone,
+	"future":   future,
+}
+
+// Struct checks every tagged field of the struct v points to, returning an *apierr.Error listing each
+// failing field, or nil if they all pass
+func Struct(v any) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	var fields []apierr.FieldError
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		if message := check(value.Field(i), tag); message != "" {
+			fields = append(fields, apierr.FieldError{Field: fieldName(field), Message: message})
+		}
+	}
+
+	if len(fields) > 0 {
+		return apierr.Invalid(fields...)
+	}
+	return nil
+}
+
+// Run the rules in tag against value, returning the message of the first one it fails
+func check(value reflect.Value, tag string) string {
+	for _, spec := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(spec, "=")
+		fn, ok := rules[name]
+		if !ok {
+			panic(fmt.Sprintf("validate: unknown rule %q", name))
+		}
+
+		if name == "required" {
+			if message := fn(value, param); message != "" {
+				return message
+			}
+			continue
+		}
+
+		// Optional fields that were left out pass every other rule
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return ""
+			}
+			value = value.Elem()
+		}
+
+		if message := fn(value, param); message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+// Name a field as the client knows it: by its json or query tag, falling back to the Go name
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "query"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func required(value reflect.Value, _ string) string {
+	switch value.Kind() {
+	case reflect.String:
+		if strings.TrimSpace(value.String()) == "" {
+			return "is required"
+		}
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if value.IsNil() || (value.Kind() != reflect.Pointer && value.Len() == 0) {
+			return "is required"
+		}
+	default:
+		if value.IsZero() {
+			return "is required"
+		}
+	}
+	return ""
+}
+
+// Strings are measured in characters, slices in items and numbers by their value
+func size(value reflect.Value) (int64, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return int64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice:
+		return int64(value.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), ""
+	}
+	panic(fmt.Sprintf("validate: cannot measure a %s", value.Kind()))
+}
+
+func limit(param string) int64 {
+	n, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: bad limit %q", param))
+	}
+	return n
+}
+
+func minimum(value reflect.Value, param string) string {
+	n, unit := size(value)
+	if min := limit(param); n < min {
+		if unit == "" {
+			return fmt.Sprintf("must be at least %d", min)
+		}
+		return fmt.Sprintf("must have at least %d%s", min, unit)
+	}
+	return ""
+}
+
+func maximum(value reflect.Value, param string) string {
+	n, unit := size(value)
+	if max := limit(param); n > max {
+		if unit == "" {
+			return fmt.Sprintf("must be at most %d", max)
+		}
+		return fmt.Sprintf("cannot be longer than %d%s", max, unit)
+	}
+	return ""
+}
+
+func username(value reflect.Value, _ string) string {
+	name := value.String()
+	if n := utf8.RuneCountInString(name); n < MinUsernameLength || n > MaxUsernameLength {
+		return fmt.Sprintf("must be between %d and %d characters", MinUsernameLength, MaxUsernameLength)
+	}
+	if !usernamePattern.MatchString(name) {
+		return "may only contain letters, digits, '_', '.' and '-', and must start with a letter or digit"
+	}
+	return ""
+}
+
+// Only a bare address is accepted, not "Alice <alice@example.com>"
+func email(value reflect.Value, _ string) string {
+	address, err := mail.ParseAddress(value.String())
+	if err != nil || address.Address != value.String() {
+		return "must be an email address"
+	}
+	return ""
+}
+
+func password(value reflect.Value, _ string) string {
+	if n := len(value.String()); n < MinPasswordLength || n > MaxPasswordLength {
+		return fmt.Sprintf("must be between %d and %d bytes", MinPasswordLength, MaxPasswordLength)
+	}
+	return ""
+}
+
+func content(value reflect.Value, _ string) string {
+	if strings.TrimSpace(value.String()) == "" {
+		return "cannot be empty"
+	}
+	if utf8.RuneCountInString(value.String()) > MaxContentLength {
+		return fmt.Sprintf("cannot be longer than %d characters", MaxContentLength)
+	}
+	return ""
+}
+
+func ttl(value reflect.Value, _ string) string {
+	if seconds := value.Int(); seconds < MinTTLSeconds || seconds > MaxTTLSeconds {
+		return fmt.Sprintf("must be between %d and %d seconds", MinTTLSeconds, MaxTTLSeconds)
+	}
+	return ""
+}
+
+func httpURL(value reflect.Value, _ string) string {
+	parsed, err := url.Parse(value.String())
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return "must be an absolute http(s) URL"
+	}
+	return ""
+}
+
+// time.LoadLocation also accepts "" and "Local", which name the server's zone rather than the user's
+func timezone(value reflect.Value, _ string) string {
+	name := value.String()
+	if _, err := time.LoadLocation(name); err != nil || name == "" || name == "Local" {
+		return "must be an IANA time zone name, e.g. 'Europe/Berlin'"
+	}
+	return ""
+}
+
+func future(value reflect.Value, _ string) string {
+	if t, ok := value.Interface().(time.Time); !ok || !t.After(time.Now()) {
+		return "must be in the future"
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+)
+
+type request struct {
+	Username  string     `json:"username" validate:"required,username"`
+	Email     string     `json:"email,omitempty" validate:"email"`
+	Bio       *string    `json:"bio" validate:"max=5"`
+	TTL       *int32     `json:"ttl_seconds" validate:"ttl"`
+	DeliverAt *time.Time `json:"deliver_at" validate:"future"`
+	Limit     int32      `query:"limit" validate:"min=1,max=100"`
+	Events    []string   `json:"events" validate:"required"`
+	Untagged  string
+}
+
+func valid() request {
+	return request{Username: "alice", Email: "alice@example.com", Limit: 10, Events: []string{"message.created"}}
+}
+
+// The fields reported by Struct, in order
+func failing(t *testing.T, req request) []string {
+	t.Helper()
+
+	err := Struct(&req)
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierr.ValidationFailed {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+
+	var fields []string
+	for _, field := range apiErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	return fields
+}
+
+func TestStruct(t *testing.T) {
+	long := "too long"
+	negative := int32(-1)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		modify func(*request)
+		want   []string
+	}{
+		{"valid", func(r *request) {}, nil},
+		{"absent optional fields", func(r *request) { r.Bio, r.TTL, r.DeliverAt = nil, nil, nil }, nil},
+		{"blank required", func(r *request) { r.Username = "  " }, []string{"username"}},
+		{"username characters", func(r *request) { r.Username = "al ice" }, []string{"username"}},
+		{"username length", func(r *request) { r.Username = strings.Repeat("a", MaxUsernameLength+1) }, []string{"username"}},
+		{"email with display name", func(r *request) { r.Email = "Alice <alice@example.com>" }, []string{"email"}},
+		{"pointer max", func(r *request) { r.Bio = &long }, []string{"bio"}},
+		{"ttl", func(r *request) { r.TTL = &negative }, []string{"ttl_seconds"}},
+		{"future", func(r *request) { r.DeliverAt = &past }, []string{"deliver_at"}},
+		{"query name", func(r *request) { r.Limit = 0 }, []string{"limit"}},
+		{"empty slice", func(r *request) { r.Events = nil }, []string{"events"}},
+		{"every field", func(r *request) { *r = request{Email: "nope"} }, []string{"username", "email", "limit", "events"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+
+			got := failing(t, req)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownRulePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for an unknown rule")
+		}
+	}()
+
+	Struct(&struct {
+		Name string `validate:"nonsense"`
+	}{})
+}
@@ -0,0 +1,113 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestAllowedIP(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"100.63.255.255", true},
+		{"100.128.0.1", true},
+		{"198.20.0.1", true},
+		{"2606:4700::1111", true},
+		{"::ffff:93.184.216.34", true},
+
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"10.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.100.100.200", false},
+		{"127.0.0.1", false},
+		{"127.255.0.9", false},
+		{"169.254.169.254", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.0.0.170", false},
+		{"192.168.1.1", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"224.0.0.1", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+
+		{"::", false},
+		{"::1", false},
+		{"::127.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:100.100.100.200", false},
+		{"64:ff9b::a00:1", false},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b:1::1", false},
+		{"2001:0:4136:e378::1", false},
+		{"2002:a00:1::1", false},
+		{"2002:7f00:1::1", false},
+		{"fc00::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"fe80::1%eth0", false},
+		{"ff02::1", false},
+	}
+
+	for _, tt := range tests {
+		if got := allowedIP(netip.MustParseAddr(tt.address)); got != tt.want {
+			t.Errorf("allowedIP(%s) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for rawURL, want := range map[string]error{
+		"https://example.com/hook":            nil,
+		"https://93.184.216.34/hook":          nil,
+		"http://localhost:8080/hook":          ErrForbiddenAddress,
+		"http://api.localhost/hook":           ErrForbiddenAddress,
+		"http://127.0.0.1/hook":               ErrForbiddenAddress,
+		"http://[::1]:9000/hook":              ErrForbiddenAddress,
+		"http://169.254.169.254/latest/meta":  ErrForbiddenAddress,
+		"http://10.0.0.5/hook":                ErrForbiddenAddress,
+		"http://[::ffff:192.168.0.1]:80/hook": ErrForbiddenAddress,
+	} {
+		if err := CheckURL(rawURL); !errors.Is(err, want) {
+			t.Errorf("CheckURL(%s) = %v, want %v", rawURL, err, want)
+		}
+	}
+}
+
+func TestClientRefusesLocalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	// The test server listens on loopback, exactly what the client must not reach
+	_, err := NewClient().Post(server.URL, "application/json", nil)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("got error %v, want %v", err, ErrForbiddenAddress)
+	}
+	if called {
+		t.Error("request reached the loopback server")
+	}
+
+	if publicError(err) != ErrForbiddenAddress.Error() {
+		t.Errorf("got public error %q", publicError(err))
+	}
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	client := NewClient()
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+	if err := client.CheckRedirect(req, []*http.Request{req}); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("CheckRedirect returned %v, want http.ErrUseLastResponse", err)
+	}
+}
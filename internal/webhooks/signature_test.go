@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signature := Sign("whsec_test", 1700000000, body)
+
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("got signature %q, want a sha256= prefix", signature)
+	}
+	if !Verify("whsec_test", 1700000000, body, signature) {
+		t.Error("signature does not verify")
+	}
+
+	for name, ok := range map[string]bool{
+		"other secret":    Verify("whsec_other", 1700000000, body, signature),
+		"other timestamp": Verify("whsec_test", 1700000001, body, signature),
+		"other body":      Verify("whsec_test", 1700000000, []byte(`{"id":2}`), signature),
+	} {
+		if ok {
+			t.Errorf("%s: signature verifies", name)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	first, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(first, "whsec_") || len(first) != len("whsec_")+64 || first == second {
+		t.Errorf("got secrets %q and %q", first, second)
+	}
+}
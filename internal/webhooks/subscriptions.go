@@ -0,0 +1,9 @@
+package webhooks
+
+import "github.com/PlatosRepublic7/ember/internal/events"
+
+// Events that a regular user's webhook can subscribe to. They only ever receive events about
+// messages they sent or received, everything else is reserved for admin (global) webhooks
+var UserEvents = []string{events.TypeMessageCreated, events.TypeMessageRead, events.TypeMessageExpired}
+
+var AllEvents = []string{events.TypeMessageCreated, events.TypeMessageRead, events.TypeMessageExpired, events.TypeUserRegistered}
@@ -0,0 +1,291 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/lifecycle"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+	DeliverySkipped   = "skipped"
+)
+
+// Envelope is the JSON body POSTed to a webhook. ID identifies the event, so receivers can use it to
+// drop duplicates caused by retries
+type Envelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Worker moves events from the outbox to the webhooks subscribed to them and delivers them with
+// retries. Like the dispatcher, its state lives in the database so it survives restarts and can run
+// on several instances at once
+type Worker struct {
+	Store     database.Store
+	Client    *http.Client
+	Interval  time.Duration
+	BatchSize int32
+	// A delivery is given up after MaxAttempts, and a webhook is disabled after DisableAfter
+	// failed attempts in a row (across all of its deliveries)
+	MaxAttempts  int32
+	DisableAfter int32
+	// Retries back off exponentially from BaseBackoff up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// How long a claimed delivery is hidden from other workers while it is being attempted
+	Lease time.Duration
+	// Beats after every pass in which both steps succeeded
+	Heartbeat lifecycle.Heartbeat
+}
+
+func NewWorker(store database.Store, interval time.Duration) *Worker {
+	return &Worker{
+		Store:        store,
+		Client:       NewClient(),
+		Interval:     interval,
+		BatchSize:    50,
+		MaxAttempts:  8,
+		DisableAfter: 20,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		Lease:        time.Minute,
+	}
+}
+
+// Run fans out and delivers events every Interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, fanOutErr := w.FanOut(ctx)
+		if fanOutErr != nil {
+			slog.ErrorContext(ctx, "webhook fan-out failed", "error", fanOutErr)
+		}
+
+		_, deliverErr := w.DeliverDue(ctx)
+		if deliverErr != nil {
+			slog.ErrorContext(ctx, "webhook delivery pass failed", "error", deliverErr)
+		}
+
+		if fanOutErr == nil && deliverErr == nil {
+			w.Heartbeat.Beat()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FanOut turns one batch of outbox events into a pending delivery per subscribed webhook
+func (w *Worker) FanOut(ctx context.Context) (int, error) {
+	var processed int
+	err := w.Store.ExecTx(ctx, func(qtx database.Querier) error {
+		now := time.Now().UTC()
+
+		outboxEvents, err := qtx.GetUnprocessedOutboxEvents(ctx, w.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range outboxEvents {
+			webhooksParams := database.GetWebhooksForEventParams{
+				EventType: event.EventType,
+				UserIds:   event.UserIds,
+			}
+			subscribed, err := qtx.GetWebhooksForEvent(ctx, webhooksParams)
+			if err != nil {
+				return err
+			}
+
+			for _, webhook := range subscribed {
+				deliveryParams := database.CreateWebhookDeliveryParams{
+					WebhookID:     webhook.ID,
+					EventID:       event.ID,
+					EventType:     event.EventType,
+					NextAttemptAt: now,
+				}
+				if err := qtx.CreateWebhookDelivery(ctx, deliveryParams); err != nil {
+					return err
+				}
+			}
+
+			processedParams := database.MarkOutboxEventProcessedParams{
+				ProcessedAt: sql.NullTime{
+					Time:  now,
+					Valid: true,
+				},
+				ID: event.ID,
+			}
+			if err := qtx.MarkOutboxEventProcessed(ctx, processedParams); err != nil {
+				return err
+			}
+		}
+
+		processed = len(outboxEvents)
+		return nil
+	})
+
+	return processed, err
+}
+
+// DeliverDue attempts one batch of deliveries that are due and returns how many were attempted
+func (w *Worker) DeliverDue(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	claimParams := database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: now.Add(w.Lease),
+		Now:        now,
+		BatchSize:  w.BatchSize,
+	}
+	deliveries, err := w.Store.ClaimDueWebhookDeliveries(ctx, claimParams)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if err := w.attempt(ctx, delivery); err != nil {
+			slog.ErrorContext(ctx, "webhook delivery failed", "delivery_id", delivery.ID, "error", err)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// Attempt a single delivery and record the outcome
+func (w *Worker) attempt(ctx context.Context, delivery database.WebhookDelivery) error {
+	webhook, err := w.Store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	attempts := delivery.Attempts + 1
+	updateParams := database.UpdateWebhookDeliveryParams{
+		Attempts:      attempts,
+		NextAttemptAt: time.Now().UTC(),
+		ID:            delivery.ID,
+	}
+
+	// Deliveries queued before the webhook was disabled are dropped rather than retried forever
+	if !webhook.Enabled {
+		updateParams.Attempts = delivery.Attempts
+		updateParams.Status = DeliverySkipped
+		updateParams.LastError = sql.NullString{String: "webhook is disabled", Valid: true}
+		updateParams.UpdatedAt = time.Now().UTC()
+		return w.Store.UpdateWebhookDelivery(ctx, updateParams)
+	}
+
+	event, err := w.Store.GetOutboxEvent(ctx, delivery.EventID)
+	if err != nil {
+		return err
+	}
+
+	statusCode, sendErr := w.send(ctx, webhook, delivery, event)
+	updateParams.UpdatedAt = time.Now().UTC()
+	if statusCode != 0 {
+		updateParams.LastStatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+
+	if sendErr == nil {
+		updateParams.Status = DeliverySucceeded
+		if err := w.Store.UpdateWebhookDelivery(ctx, updateParams); err != nil {
+			return err
+		}
+		return w.Store.RecordWebhookSuccess(ctx, webhook.ID)
+	}
+
+	slog.InfoContext(ctx, "webhook delivery attempt failed", "delivery_id", delivery.ID, "webhook_id", webhook.ID, "attempts", attempts, "error", sendErr)
+	updateParams.LastError = sql.NullString{String: publicError(sendErr), Valid: true}
+	if attempts >= w.MaxAttempts {
+		updateParams.Status = DeliveryFailed
+	} else {
+		updateParams.Status = DeliveryPending
+		updateParams.NextAttemptAt = updateParams.UpdatedAt.Add(w.backoff(attempts))
+	}
+
+	if err := w.Store.UpdateWebhookDelivery(ctx, updateParams); err != nil {
+		return err
+	}
+
+	failureParams := database.RecordWebhookFailureParams{
+		DisableAfter: w.DisableAfter,
+		Now:          updateParams.UpdatedAt,
+		ID:           webhook.ID,
+	}
+	updatedWebhook, err := w.Store.RecordWebhookFailure(ctx, failureParams)
+	if err != nil {
+		return err
+	}
+
+	if !updatedWebhook.Enabled {
+		slog.WarnContext(ctx, "disabled webhook after repeated failures", "webhook_id", webhook.ID, "consecutive_failures", updatedWebhook.ConsecutiveFailures)
+	}
+
+	return nil
+}
+
+// POST the signed envelope to the webhook, any 2xx response counts as delivered
+func (w *Worker) send(ctx context.Context, webhook database.Webhook, delivery database.WebhookDelivery, event database.Outbox) (int, error) {
+	body, err := json.Marshal(Envelope{
+		ID:        event.ID,
+		Type:      event.EventType,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Ember-Webhooks/1.0")
+	req.Header.Set("X-Ember-Event", event.EventType)
+	req.Header.Set("X-Ember-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Ember-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Ember-Signature", Sign(webhook.Secret, timestamp, body))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &statusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Exponential backoff with up to 10% jitter, so a recovering endpoint is not hit by every retry at once
+func (w *Worker) backoff(attempts int32) time.Duration {
+	delay := w.MaxBackoff
+	if shift := attempts - 1; shift < 32 {
+		if d := w.BaseBackoff << shift; d > 0 && d < w.MaxBackoff {
+			delay = d
+		}
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/10+1))
+}
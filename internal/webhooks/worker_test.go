@@ -0,0 +1,233 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/database/memstore"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/google/uuid"
+)
+
+var ctx = context.Background()
+
+// A worker delivering to a test server that answers with status. The server listens on loopback,
+// so the worker uses the server's own client instead of the guarded one from NewClient
+func newTestWorker(t *testing.T, status int) (*Worker, *memstore.Store, *database.Webhook) {
+	t.Helper()
+
+	store := memstore.New()
+	webhook := &database.Webhook{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding delivery: %v", err)
+		}
+		payload, _ := json.Marshal(body)
+
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-Ember-Timestamp"), 10, 64)
+		if !Verify(webhook.Secret, timestamp, payload, r.Header.Get("X-Ember-Signature")) {
+			t.Error("delivery signature does not verify")
+		}
+		if r.Header.Get("X-Ember-Event") != events.TypeMessageCreated {
+			t.Errorf("got X-Ember-Event %q", r.Header.Get("X-Ember-Event"))
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	created, err := store.CreateWebhook(ctx, database.CreateWebhookParams{
+		ID:        uuid.New(),
+		Url:       server.URL,
+		Secret:    "whsec_test",
+		Events:    []string{events.TypeMessageCreated},
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	*webhook = created
+
+	worker := NewWorker(store, time.Second)
+	worker.Client = server.Client()
+	return worker, store, webhook
+}
+
+// Queue an event and fan it out, returning the pending delivery it produced
+func queueDelivery(t *testing.T, worker *Worker, store *memstore.Store) database.WebhookDelivery {
+	t.Helper()
+
+	_, err := store.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType: events.TypeMessageCreated,
+		Payload:   json.RawMessage(`{"hello":"world"}`),
+		UserIds:   []uuid.UUID{},
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := worker.FanOut(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := store.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().UTC().Add(time.Minute),
+		Now:        time.Now().UTC(),
+		BatchSize:  10,
+	})
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("claiming the delivery: got %+v, %v", deliveries, err)
+	}
+	return deliveries[0]
+}
+
+func getDelivery(t *testing.T, store *memstore.Store, webhook *database.Webhook, id int64) database.WebhookDelivery {
+	t.Helper()
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.ID == id {
+			return delivery
+		}
+	}
+	t.Fatalf("delivery %d not found", id)
+	return database.WebhookDelivery{}
+}
+
+func TestAttemptSucceeds(t *testing.T) {
+	worker, store, webhook := newTestWorker(t, http.StatusNoContent)
+	delivery := queueDelivery(t, worker, store)
+
+	if err := worker.attempt(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery = getDelivery(t, store, webhook, delivery.ID)
+	if delivery.Status != DeliverySucceeded || delivery.Attempts != 1 || delivery.LastStatusCode.Int32 != http.StatusNoContent {
+		t.Errorf("got delivery %+v", delivery)
+	}
+}
+
+func TestAttemptRetriesThenGivesUp(t *testing.T) {
+	worker, store, webhook := newTestWorker(t, http.StatusInternalServerError)
+	worker.MaxAttempts = 2
+	delivery := queueDelivery(t, worker, store)
+
+	before := time.Now().UTC()
+	if err := worker.attempt(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery = getDelivery(t, store, webhook, delivery.ID)
+	if delivery.Status != DeliveryPending || delivery.Attempts != 1 || delivery.LastError.String != "unexpected response status 500" {
+		t.Errorf("after the first failure: got %+v", delivery)
+	}
+	if delivery.NextAttemptAt.Before(before.Add(worker.BaseBackoff)) {
+		t.Errorf("retry scheduled at %v, want at least %v from now", delivery.NextAttemptAt, worker.BaseBackoff)
+	}
+
+	if err := worker.attempt(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery = getDelivery(t, store, webhook, delivery.ID); delivery.Status != DeliveryFailed || delivery.Attempts != 2 {
+		t.Errorf("after MaxAttempts: got %+v", delivery)
+	}
+}
+
+func TestAttemptDisablesFailingWebhook(t *testing.T) {
+	worker, store, webhook := newTestWorker(t, http.StatusBadGateway)
+	worker.DisableAfter = 2
+
+	for range 2 {
+		if err := worker.attempt(ctx, queueDelivery(t, worker, store)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	disabled, err := store.GetWebhook(ctx, webhook.ID)
+	if err != nil || disabled.Enabled || !disabled.DisabledAt.Valid || disabled.ConsecutiveFailures != 2 {
+		t.Fatalf("got webhook %+v, %v, want it disabled after 2 failures", disabled, err)
+	}
+
+	// A delivery that was already queued is skipped without sending anything
+	pending, err := store.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType: events.TypeMessageCreated,
+		Payload:   json.RawMessage(`{}`),
+		UserIds:   []uuid.UUID{},
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		WebhookID:     webhook.ID,
+		EventID:       pending.ID,
+		EventType:     pending.EventType,
+		NextAttemptAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := worker.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.EventID == pending.ID && delivery.Status != DeliverySkipped {
+			t.Errorf("delivery to a disabled webhook: got %+v", delivery)
+		}
+	}
+}
+
+func TestAttemptHidesTransportErrors(t *testing.T) {
+	worker, store, webhook := newTestWorker(t, http.StatusOK)
+	worker.Client = NewClient()
+	delivery := queueDelivery(t, worker, store)
+
+	if err := worker.attempt(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner learns the address was refused, not which one or how the dial went
+	delivery = getDelivery(t, store, webhook, delivery.ID)
+	if delivery.Status != DeliveryPending || delivery.LastError.String != ErrForbiddenAddress.Error() || delivery.LastStatusCode.Valid {
+		t.Errorf("got delivery %+v", delivery)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	worker := NewWorker(nil, time.Second)
+
+	for _, tc := range []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{9, 30 * time.Second << 8},
+		{11, 6 * time.Hour},
+		{64, 6 * time.Hour},
+	} {
+		got := worker.backoff(tc.attempts)
+		if got < tc.want || got > tc.want+tc.want/10 {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", tc.attempts, got, tc.want, tc.want+tc.want/10)
+		}
+	}
+}
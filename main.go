@@ -1,61 +1,295 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/PlatosRepublic7/ember/internal/admin"
+	"github.com/PlatosRepublic7/ember/internal/apierr"
+	"github.com/PlatosRepublic7/ember/internal/auth"
+	"github.com/PlatosRepublic7/ember/internal/blob"
+	"github.com/PlatosRepublic7/ember/internal/config"
 	"github.com/PlatosRepublic7/ember/internal/database"
+	"github.com/PlatosRepublic7/ember/internal/dispatcher"
+	"github.com/PlatosRepublic7/ember/internal/events"
+	"github.com/PlatosRepublic7/ember/internal/health"
+	"github.com/PlatosRepublic7/ember/internal/lifecycle"
+	"github.com/PlatosRepublic7/ember/internal/logging"
+	"github.com/PlatosRepublic7/ember/internal/metrics"
+	"github.com/PlatosRepublic7/ember/internal/migrate"
+	"github.com/PlatosRepublic7/ember/internal/presence"
+	"github.com/PlatosRepublic7/ember/internal/reaper"
 	"github.com/PlatosRepublic7/ember/internal/routes"
+	"github.com/PlatosRepublic7/ember/internal/tracing"
+	"github.com/PlatosRepublic7/ember/internal/webhooks"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
-	"github.com/joho/godotenv"
 
 	_ "github.com/lib/pq"
 )
 
-type apiConfig struct {
-	DB *database.Queries
-}
+// Exit codes, following sysexits.h where one fits
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 64
+	exitUnavailable = 69
+	exitConfig      = 78
+)
 
 func main() {
-	// Get environment variables and initialize port number
-	godotenv.Load()
+	os.Exit(run())
+}
+
+// Run the server, or the subcommand named on the command line, and return the process exit code
+func run() int {
+	var command string
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	// Settings come from the defaults, an optional config file, .env and the environment, see config.Load.
+	// The subcommands neither listen nor sign tokens, so they do not need the server's settings
+	load := config.Load
+	if command == "migrate" || command == "admin" {
+		load = config.LoadCommand
+	}
+	cfg, err := load()
+	if err != nil {
+		// The logger is configured from these settings, so this goes out through the standard one
+		log.Println(err)
+		return exitConfig
+	}
+
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log))
+
+	// Spans for requests, queries, password hashing and webhook deliveries go to the configured
+	// exporter. Whatever is still buffered is flushed on the way out
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("cannot set up tracing", "error", err)
+		return exitConfig
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("cannot flush traces", "error", err)
+		}
+	}()
 
-	portString := os.Getenv("SERVER_PORT")
-	if portString == "" {
-		log.Fatal("PORT is not found in the environment")
+	// SIGINT or SIGTERM cancels ctx, which starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Connect to database, retrying while it comes up
+	conn, err := sql.Open("postgres", cfg.Database.URL)
+	if err != nil {
+		slog.Error("cannot connect to database", "error", err)
+		return exitConfig
 	}
+	defer conn.Close()
 
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL is not found in the environment")
+	conn.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+
+	connectCtx, cancelConnect := context.WithTimeout(ctx, cfg.Database.ConnectTimeout)
+	err = lifecycle.WaitForDB(connectCtx, conn)
+	cancelConnect()
+	if err != nil {
+		slog.Error("cannot reach database", "error", err)
+		return exitUnavailable
 	}
 
-	// Connect to database
-	conn, err := sql.Open("postgres", dbURL)
+	// `ember migrate ...` manages the schema and exits instead of serving
+	if command == "migrate" {
+		if err := runMigrate(ctx, conn, os.Args[2:]); err != nil {
+			slog.Error("migrate failed", "error", err)
+			if errors.Is(err, errMigrateUsage) {
+				return exitUsage
+			}
+			return exitError
+		}
+		return exitOK
+	}
+
+	// `ember admin ...` runs one operator command against the database and exits
+	if command == "admin" {
+		if err := admin.Run(ctx, conn, cfg, os.Args[2:]); err != nil {
+			slog.Error("admin command failed", "error", err)
+			if errors.Is(err, admin.ErrUsage) {
+				return exitUsage
+			}
+			return exitError
+		}
+		return exitOK
+	}
+
+	autoMigrate := flag.Bool("auto-migrate", cfg.Database.AutoMigrate, "apply pending migrations before serving")
+	flag.Parse()
+
+	// Refuse to serve against a schema this build does not understand
+	migrations, err := migrate.NewProvider(conn)
 	if err != nil {
-		log.Fatal("Cannot connect to database")
+		slog.Error("cannot load migrations", "error", err)
+		return exitError
+	}
+
+	if *autoMigrate {
+		if _, err := migrations.Up(ctx); err != nil {
+			slog.Error("cannot migrate database", "error", err)
+			return exitError
+		}
 	}
 
-	apiCfg := apiConfig{
-		DB: database.New(conn),
+	if err := migrate.Check(ctx, migrations); err != nil {
+		slog.Error("database schema does not match this build", "error", err)
+		return exitConfig
 	}
 
-	log.Println("Config:", apiCfg)
+	blobStore, err := blob.NewFileStore(cfg.Server.BlobDir)
+	if err != nil {
+		slog.Error("cannot open blob store", "error", err)
+		return exitConfig
+	}
 
-	// Create the Fiber application and initialize logger, recovery, and cors
-	app := fiber.New()
-	app.Use(logger.New())
+	// Every query run through the store is timed for /metrics
+	store := database.NewStore(conn, metrics.InstrumentDB, tracing.InstrumentDB)
+
+	// Domain events are written to the outbox with the change that caused them, and handed to in-process
+	// subscribers once that change has committed
+	bus := events.NewBus()
+	txManager := events.NewTxManager(store, bus)
+
+	metrics.SubscribeEvents(bus)
+	metrics.RegisterActiveSessions(store, cfg.Auth.RefreshTokenTTL)
+
+	// Tokens are signed with the keys rotated in by ember admin rotate-keys, or the configured secrets
+	// until there are any. Running instances pick up new keys within auth.KeyReloadInterval
+	authenticator := auth.NewAuthenticator(cfg.Auth)
+	if err := authenticator.LoadKeys(ctx, store); err != nil {
+		slog.Error("cannot load signing keys", "error", err)
+		return exitError
+	}
+
+	// Start delivering scheduled messages, expiring messages and delivering webhooks in the background.
+	// They are stopped in this order, so the workers writing to the outbox are done before the one
+	// reading from it
+	messageDispatcher := dispatcher.NewDispatcher(txManager, 5*time.Second)
+	messageReaper := reaper.NewReaper(txManager, 10*time.Second)
+	webhookWorker := webhooks.NewWorker(store, 5*time.Second)
+	keyLoader := auth.NewKeyLoader(authenticator, store, auth.KeyReloadInterval)
+
+	workers := lifecycle.NewWorkers()
+	workers.Start("message dispatcher", messageDispatcher)
+	workers.Start("message reaper", messageReaper)
+	workers.Start("webhook worker", webhookWorker)
+	workers.Start("signing key loader", keyLoader)
+
+	// Readiness covers the database, the schema version and every background worker. A worker counts as
+	// unhealthy once it has gone three intervals without a successful pass
+	checker := health.NewChecker()
+	checker.Add("database", conn.PingContext)
+	checker.Add("migrations", func(ctx context.Context) error {
+		return migrate.Check(ctx, migrations)
+	})
+	checker.Add("message_dispatcher", health.HeartbeatCheck(&messageDispatcher.Heartbeat, 3*messageDispatcher.Interval))
+	checker.Add("message_reaper", health.HeartbeatCheck(&messageReaper.Heartbeat, 3*messageReaper.Interval))
+	checker.Add("webhook_worker", health.HeartbeatCheck(&webhookWorker.Heartbeat, 3*webhookWorker.Interval))
+	checker.Add("signing_key_loader", health.HeartbeatCheck(&keyLoader.Heartbeat, 3*keyLoader.Interval))
+
+	// Create the Fiber application and initialize tracing, request logging, metrics, recovery, and cors
+	app := fiber.New(fiber.Config{DisableStartupMessage: true, ErrorHandler: apierr.ErrorHandler})
+	app.Use(tracing.Middleware())
+	app.Use(logging.Middleware())
+	app.Use(metrics.Middleware())
 	app.Use(recover.New())
-	app.Use(cors.New())
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: strings.Join(cfg.Server.CORSOrigins, ","),
+	}))
+
+	// Presence is kept in memory, which is only accurate while a single instance is running. Scaling out
+	// needs a presence.Store shared by all instances in place of the MemoryStore
+	tracker := presence.NewTracker(presence.NewMemoryStore())
+
+	routes.SetupRoutes(app, store, txManager, blobStore, tracker, authenticator, checker)
+
+	// /metrics goes on its own port when one is configured, so it can be kept off the public network
+	metricsApp := app
+	if cfg.Server.MetricsPort != "" {
+		metricsApp = fiber.New(fiber.Config{DisableStartupMessage: true, ErrorHandler: apierr.ErrorHandler})
+	}
+	metricsApp.Get("/metrics", metrics.Handler())
+
+	slog.Info("server running", "port", cfg.Server.Port)
 
-	fmt.Println("Server running on port", portString)
-	portString = ":" + portString
+	listenErr := make(chan error, 2)
+	go func() {
+		listenErr <- app.Listen(":" + cfg.Server.Port)
+	}()
+	if metricsApp != app {
+		slog.Info("metrics served", "port", cfg.Server.MetricsPort)
+		go func() {
+			listenErr <- metricsApp.Listen(":" + cfg.Server.MetricsPort)
+		}()
+	}
+
+	code := exitOK
+	select {
+	case err := <-listenErr:
+		// The server stopped on its own, most likely because the port is taken
+		slog.Error("server stopped", "error", err)
+		code = exitError
+	case <-ctx.Done():
+		// Fail readiness first and keep serving for a moment, so load balancers move traffic away
+		// before the listener closes
+		slog.Info("shutting down", "drain_delay", cfg.Server.DrainDelay)
+		checker.Drain()
+
+		// A second signal kills the process instead of waiting for the graceful shutdown
+		stop()
+
+		select {
+		case <-time.After(cfg.Server.DrainDelay):
+		case err := <-listenErr:
+			slog.Error("server stopped", "error", err)
+			code = exitError
+		}
+	}
+
+	// The timeout covers the whole shutdown: draining requests, then stopping the workers
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		slog.Error("cannot drain in-flight requests", "error", err)
+		code = exitError
+	}
+
+	// Metrics stay up until the main server is done, so the shutdown itself can be scraped
+	if metricsApp != app {
+		if err := metricsApp.ShutdownWithContext(shutdownCtx); err != nil {
+			slog.Error("cannot shut down the metrics server", "error", err)
+			code = exitError
+		}
+	}
+
+	if err := workers.Stop(shutdownCtx); err != nil {
+		slog.Error("cannot stop background workers", "error", err)
+		code = exitError
+	}
 
-	routes.SetupRoutes(app, apiCfg.DB)
-	app.Listen(portString)
+	// The deferred conn.Close runs after this, once nothing is using the pool anymore
+	return code
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PlatosRepublic7/ember/internal/migrate"
+	"github.com/pressly/goose/v3"
+)
+
+// Returned for a missing or unknown migrate command
+var errMigrateUsage = errors.New("usage: ember migrate up|down|status|redo")
+
+// Run `ember migrate <command>` against the database at conn
+func runMigrate(ctx context.Context, conn *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return errMigrateUsage
+	}
+
+	provider, err := migrate.NewProvider(conn)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := provider.Up(ctx)
+		printMigrationResults(results...)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	case "down":
+		result, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationResults(result)
+	case "redo":
+		results, err := migrate.Redo(ctx, provider)
+		printMigrationResults(results...)
+		if err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, %w", args[0], errMigrateUsage)
+	}
+
+	return nil
+}
+
+func printMigrationResults(results ...*goose.MigrationResult) {
+	for _, result := range results {
+		if result != nil {
+			fmt.Println(result)
+		}
+	}
+}